@@ -13,7 +13,11 @@ import (
 
 	"github.com/gqvz/mvc/pkg/api"
 	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/gateway"
 	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/notify"
+	"github.com/gqvz/mvc/pkg/printer"
+	"github.com/gqvz/mvc/pkg/services"
 
 	_ "github.com/gqvz/mvc/docs"
 )
@@ -43,7 +47,31 @@ func main() {
 		return
 	}
 
-	router := api.CreateRouter(appConfig)
+	provider, err := gateway.NewProvider(appConfig.Payments)
+	if err != nil {
+		log.Fatal("failed to create payment provider: ", err)
+		return
+	}
+
+	spooler, err := printer.NewSpooler(appConfig.Printers)
+	if err != nil {
+		log.Fatal("failed to configure printers: ", err)
+		return
+	}
+
+	notifier, err := notify.NewNotifier(appConfig.Waitlist)
+	if err != nil {
+		log.Fatal("failed to create waitlist notifier: ", err)
+		return
+	}
+
+	router := api.CreateRouter(appConfig, provider, spooler, notifier)
+
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	go spooler.Run(schedulerCtx)
+	go services.RunEvery(schedulerCtx, time.Minute, services.ApplyPriceSchedules)
+	go services.RunEvery(schedulerCtx, time.Hour, services.PurgeIdempotencyKeys)
+	go services.RunEvery(schedulerCtx, time.Minute, services.ReleaseScheduledItems(spooler))
 
 	server := &http.Server{
 		Addr:    appConfig.ServerAddress,
@@ -62,6 +90,7 @@ func main() {
 	<-quit
 
 	fmt.Println("Shutting down server...")
+	stopScheduler()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS PriceSchedules;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE `PriceSchedules`
+(
+    `id`           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `item_id`      INTEGER       NOT NULL,
+    `price`        DECIMAL(6, 2) NOT NULL,
+    `effective_at` DATETIME      NOT NULL,
+    `applied`      BOOLEAN       NOT NULL DEFAULT FALSE,
+    `created_by`   INTEGER       NOT NULL,
+    FOREIGN KEY (`item_id`) REFERENCES `Items` (`id`),
+    FOREIGN KEY (`created_by`) REFERENCES `Users` (`id`)
+);
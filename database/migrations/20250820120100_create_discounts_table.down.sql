@@ -0,0 +1 @@
+DROP TABLE IF EXISTS Discounts;
\ No newline at end of file
@@ -0,0 +1,16 @@
+CREATE TABLE `Discounts`
+(
+    `id`         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `name`       VARCHAR(64)   NOT NULL,
+    `percent`    DECIMAL(5, 2) NOT NULL,
+    `item_id`    INTEGER,
+    `tag_id`     INTEGER,
+    `days`       TINYINT       NOT NULL DEFAULT 0,
+    `start_time` TIME          NOT NULL DEFAULT '00:00:00',
+    `end_time`   TIME          NOT NULL DEFAULT '00:00:00',
+    `starts_at`  DATETIME,
+    `ends_at`    DATETIME,
+    `active`     BOOLEAN       NOT NULL DEFAULT TRUE,
+    FOREIGN KEY (`item_id`) REFERENCES `Items` (`id`),
+    FOREIGN KEY (`tag_id`) REFERENCES `Tags` (`id`)
+);
@@ -0,0 +1 @@
+ALTER TABLE `OrderItems` DROP COLUMN `unit_price`;
\ No newline at end of file
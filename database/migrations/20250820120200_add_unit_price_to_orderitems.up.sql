@@ -0,0 +1,9 @@
+ALTER TABLE `OrderItems`
+    ADD COLUMN `unit_price` DECIMAL(6, 2);
+
+UPDATE `OrderItems`
+    JOIN `Items` ON `Items`.`id` = `OrderItems`.`item_id`
+SET `OrderItems`.`unit_price` = `Items`.`price`;
+
+ALTER TABLE `OrderItems`
+    MODIFY `unit_price` DECIMAL(6, 2) NOT NULL;
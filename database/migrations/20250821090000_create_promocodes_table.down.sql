@@ -0,0 +1 @@
+DROP TABLE IF EXISTS PromoCodes;
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE `PromoCodes`
+(
+    `id`                INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `code`              VARCHAR(32)                    NOT NULL UNIQUE,
+    `kind`              ENUM ('percentage', 'fixed')   NOT NULL,
+    `value`             DECIMAL(10, 2)                 NOT NULL,
+    `min_spend`         DECIMAL(10, 2)                 NOT NULL DEFAULT 0,
+    `starts_at`         DATETIME,
+    `ends_at`           DATETIME,
+    `max_uses`          INTEGER,
+    `max_uses_per_user` INTEGER,
+    `item_id`           INTEGER,
+    `tag_id`            INTEGER,
+    `active`            BOOLEAN                        NOT NULL DEFAULT TRUE,
+    FOREIGN KEY (`item_id`) REFERENCES `Items` (`id`),
+    FOREIGN KEY (`tag_id`) REFERENCES `Tags` (`id`)
+);
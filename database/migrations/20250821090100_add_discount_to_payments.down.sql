@@ -0,0 +1,11 @@
+ALTER TABLE `Payments`
+    DROP FOREIGN KEY `fk_payments_promo_code`,
+    DROP FOREIGN KEY `fk_payments_discounted_by`,
+    DROP COLUMN `total`,
+    DROP COLUMN `discount`,
+    DROP COLUMN `promo_code_id`,
+    DROP COLUMN `discount_reason`,
+    DROP COLUMN `discounted_by`;
+
+ALTER TABLE `Payments`
+    ADD COLUMN `total` DECIMAL(10, 2) GENERATED ALWAYS AS (order_subtotal + tip) STORED;
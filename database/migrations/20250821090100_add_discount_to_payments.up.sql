@@ -0,0 +1,11 @@
+ALTER TABLE `Payments`
+    DROP COLUMN `total`,
+    ADD COLUMN `discount`        DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `order_subtotal`,
+    ADD COLUMN `promo_code_id`   INTEGER,
+    ADD COLUMN `discount_reason` VARCHAR(255),
+    ADD COLUMN `discounted_by`   INTEGER,
+    ADD CONSTRAINT `fk_payments_promo_code` FOREIGN KEY (`promo_code_id`) REFERENCES `PromoCodes` (`id`),
+    ADD CONSTRAINT `fk_payments_discounted_by` FOREIGN KEY (`discounted_by`) REFERENCES `Users` (`id`);
+
+ALTER TABLE `Payments`
+    ADD COLUMN `total` DECIMAL(10, 2) GENERATED ALWAYS AS (order_subtotal - discount + tip) STORED;
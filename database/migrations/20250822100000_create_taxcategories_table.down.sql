@@ -0,0 +1 @@
+DROP TABLE IF EXISTS TaxCategories;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE `TaxCategories`
+(
+    `id`         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `name`       VARCHAR(32)   NOT NULL UNIQUE,
+    `rate`       DECIMAL(6, 3) NOT NULL,
+    `inclusive`  BOOLEAN       NOT NULL DEFAULT FALSE,
+    `is_default` BOOLEAN       NOT NULL DEFAULT FALSE
+);
@@ -0,0 +1,7 @@
+ALTER TABLE `Items`
+    DROP FOREIGN KEY `fk_items_tax_category`,
+    DROP COLUMN `tax_category_id`;
+
+ALTER TABLE `Tags`
+    DROP FOREIGN KEY `fk_tags_tax_category`,
+    DROP COLUMN `tax_category_id`;
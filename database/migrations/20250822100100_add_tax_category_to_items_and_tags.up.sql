@@ -0,0 +1,7 @@
+ALTER TABLE `Items`
+    ADD COLUMN `tax_category_id` INTEGER,
+    ADD CONSTRAINT `fk_items_tax_category` FOREIGN KEY (`tax_category_id`) REFERENCES `TaxCategories` (`id`);
+
+ALTER TABLE `Tags`
+    ADD COLUMN `tax_category_id` INTEGER,
+    ADD CONSTRAINT `fk_tags_tax_category` FOREIGN KEY (`tax_category_id`) REFERENCES `TaxCategories` (`id`);
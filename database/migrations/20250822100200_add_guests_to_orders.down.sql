@@ -0,0 +1 @@
+ALTER TABLE `Orders` DROP COLUMN `guests`;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE `Orders`
+    ADD COLUMN `guests` INTEGER NOT NULL DEFAULT 1;
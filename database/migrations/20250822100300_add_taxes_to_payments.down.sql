@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS PaymentTaxes;
+
+ALTER TABLE `Payments`
+    DROP COLUMN `total`,
+    DROP COLUMN `service_charge`,
+    DROP COLUMN `tax`,
+    DROP COLUMN `tax_included`;
+
+ALTER TABLE `Payments`
+    ADD COLUMN `total` DECIMAL(10, 2) GENERATED ALWAYS AS (order_subtotal - discount + tip) STORED;
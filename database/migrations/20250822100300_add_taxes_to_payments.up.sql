@@ -0,0 +1,22 @@
+ALTER TABLE `Payments`
+    DROP COLUMN `total`,
+    ADD COLUMN `service_charge` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `discount`,
+    ADD COLUMN `tax`            DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `service_charge`,
+    ADD COLUMN `tax_included`   DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `tax`;
+
+ALTER TABLE `Payments`
+    ADD COLUMN `total` DECIMAL(10, 2) GENERATED ALWAYS AS (order_subtotal - discount + service_charge + tax + tip) STORED;
+
+CREATE TABLE `PaymentTaxes`
+(
+    `id`              INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `payment_id`      INTEGER        NOT NULL,
+    `tax_category_id` INTEGER        NOT NULL,
+    `name`            VARCHAR(32)    NOT NULL,
+    `rate`            DECIMAL(6, 3)  NOT NULL,
+    `inclusive`       BOOLEAN        NOT NULL,
+    `taxable`         DECIMAL(10, 2) NOT NULL,
+    `amount`          DECIMAL(10, 2) NOT NULL,
+    FOREIGN KEY (`payment_id`) REFERENCES `Payments` (`id`),
+    FOREIGN KEY (`tax_category_id`) REFERENCES `TaxCategories` (`id`)
+);
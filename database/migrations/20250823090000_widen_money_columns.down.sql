@@ -0,0 +1,18 @@
+ALTER TABLE `Payments`
+    DROP COLUMN `currency`,
+    MODIFY `tip` DECIMAL(6, 2) NOT NULL;
+
+ALTER TABLE `PromoCodes`
+    MODIFY `value` DECIMAL(10, 2) NOT NULL;
+
+ALTER TABLE `Discounts`
+    MODIFY `percent` DECIMAL(5, 2) NOT NULL;
+
+ALTER TABLE `PriceSchedules`
+    MODIFY `price` DECIMAL(6, 2) NOT NULL;
+
+ALTER TABLE `OrderItems`
+    MODIFY `unit_price` DECIMAL(6, 2) NOT NULL;
+
+ALTER TABLE `Items`
+    MODIFY `price` DECIMAL(6, 2) NOT NULL;
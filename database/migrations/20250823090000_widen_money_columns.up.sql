@@ -0,0 +1,18 @@
+ALTER TABLE `Items`
+    MODIFY `price` DECIMAL(10, 2) NOT NULL;
+
+ALTER TABLE `OrderItems`
+    MODIFY `unit_price` DECIMAL(10, 2) NOT NULL;
+
+ALTER TABLE `PriceSchedules`
+    MODIFY `price` DECIMAL(10, 2) NOT NULL;
+
+ALTER TABLE `Discounts`
+    MODIFY `percent` DECIMAL(6, 3) NOT NULL;
+
+ALTER TABLE `PromoCodes`
+    MODIFY `value` DECIMAL(10, 3) NOT NULL;
+
+ALTER TABLE `Payments`
+    MODIFY `tip` DECIMAL(10, 2) NOT NULL,
+    ADD COLUMN `currency` CHAR(3) NOT NULL DEFAULT 'USD' AFTER `total`;
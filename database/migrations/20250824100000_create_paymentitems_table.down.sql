@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS PaymentItems;
+
+ALTER TABLE `Payments`
+    DROP COLUMN `split_parts`,
+    DROP COLUMN `split_mode`;
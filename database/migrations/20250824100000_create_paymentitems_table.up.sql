@@ -0,0 +1,21 @@
+ALTER TABLE `Payments`
+    ADD COLUMN `split_mode`  ENUM ('full','even','items','amount') NOT NULL DEFAULT 'full' AFTER `currency`,
+    ADD COLUMN `split_parts` INTEGER                               NULL AFTER `split_mode`;
+
+CREATE TABLE `PaymentItems`
+(
+    `id`            INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `payment_id`    INTEGER        NOT NULL,
+    `order_item_id` INTEGER        NOT NULL,
+    `amount`        DECIMAL(10, 2) NOT NULL,
+    FOREIGN KEY (`payment_id`) REFERENCES `Payments` (`id`),
+    FOREIGN KEY (`order_item_id`) REFERENCES `OrderItems` (`id`),
+    INDEX (`order_item_id`)
+);
+
+-- payments made before splitting existed covered the whole order; attribute the items to the latest one
+INSERT INTO `PaymentItems` (payment_id, order_item_id, amount)
+SELECT p.id, oi.id, oi.unit_price * oi.count
+FROM Payments p
+         JOIN OrderItems oi ON oi.order_id = p.order_id
+WHERE p.id = (SELECT MAX(latest.id) FROM Payments latest WHERE latest.order_id = p.order_id);
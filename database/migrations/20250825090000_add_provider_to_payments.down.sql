@@ -0,0 +1,10 @@
+UPDATE `Payments`
+SET `status` = 'processing'
+WHERE `status` = 'declined';
+
+ALTER TABLE `Payments`
+    DROP INDEX `provider`,
+    DROP COLUMN `decline_reason`,
+    DROP COLUMN `provider_ref`,
+    DROP COLUMN `provider`,
+    MODIFY `status` ENUM ('processing','accepted') NOT NULL;
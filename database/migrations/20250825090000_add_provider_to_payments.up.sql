@@ -0,0 +1,6 @@
+ALTER TABLE `Payments`
+    MODIFY `status` ENUM ('processing','accepted','declined') NOT NULL,
+    ADD COLUMN `provider`       VARCHAR(32)  NULL AFTER `status`,
+    ADD COLUMN `provider_ref`   VARCHAR(64)  NULL AFTER `provider`,
+    ADD COLUMN `decline_reason` VARCHAR(255) NULL AFTER `provider_ref`,
+    ADD UNIQUE INDEX (`provider`, `provider_ref`);
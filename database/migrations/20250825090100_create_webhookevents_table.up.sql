@@ -0,0 +1,12 @@
+CREATE TABLE `WebhookEvents`
+(
+    `id`           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `provider`     VARCHAR(32)  NOT NULL,
+    `event_id`     VARCHAR(64)  NOT NULL,
+    `type`         VARCHAR(64)  NOT NULL,
+    `payload`      MEDIUMTEXT   NOT NULL,
+    `received_at`  DATETIME     NOT NULL,
+    `processed_at` DATETIME     NULL,
+    `error`        VARCHAR(255) NULL,
+    UNIQUE (`provider`, `event_id`)
+);
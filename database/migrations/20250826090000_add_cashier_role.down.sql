@@ -0,0 +1,10 @@
+UPDATE `Users`
+SET `role` = `role` & 3;
+
+UPDATE `Requests`
+SET `role` = `role` & 3
+WHERE `role` & 3 <> 0;
+
+DELETE
+FROM `Requests`
+WHERE `role` = 4;
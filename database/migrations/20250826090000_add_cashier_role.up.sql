@@ -0,0 +1,8 @@
+-- admins gain the new cashier flag (4): Admin = Customer | Chef | Cashier
+UPDATE `Users`
+SET `role` = 7
+WHERE `role` = 3;
+
+UPDATE `Requests`
+SET `role` = 7
+WHERE `role` = 3;
@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS Refunds;
+
+UPDATE `Payments`
+SET `status` = 'accepted'
+WHERE `status` IN ('refunded', 'partially_refunded');
+
+UPDATE `Payments`
+SET `status` = 'declined'
+WHERE `status` = 'voided';
+
+ALTER TABLE `Payments`
+    DROP INDEX `created_at`,
+    DROP COLUMN `created_at`,
+    MODIFY `status` ENUM ('processing','accepted','declined') NOT NULL;
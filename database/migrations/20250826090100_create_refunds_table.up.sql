@@ -0,0 +1,25 @@
+ALTER TABLE `Payments`
+    MODIFY `status` ENUM ('processing','accepted','declined','refunded','partially_refunded','voided') NOT NULL,
+    ADD COLUMN `created_at` DATETIME NULL;
+
+UPDATE `Payments`
+    JOIN `Orders` ON `Orders`.`id` = `Payments`.`order_id`
+SET `Payments`.`created_at` = `Orders`.`ordered_at`;
+
+ALTER TABLE `Payments`
+    MODIFY `created_at` DATETIME NOT NULL,
+    ADD INDEX (`created_at`);
+
+CREATE TABLE `Refunds`
+(
+    `id`           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `payment_id`   INTEGER        NOT NULL,
+    `amount`       DECIMAL(10, 2) NOT NULL,
+    `reason`       VARCHAR(255)   NOT NULL,
+    `provider_ref` VARCHAR(64)    NULL,
+    `created_by`   INTEGER        NOT NULL,
+    `created_at`   DATETIME       NOT NULL,
+    FOREIGN KEY (`payment_id`) REFERENCES `Payments` (`id`),
+    FOREIGN KEY (`created_by`) REFERENCES `Users` (`id`),
+    INDEX (`created_at`)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE `StatusTransitions`
+(
+    `id`          INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `entity`      ENUM ('payment','order_item','order') NOT NULL,
+    `entity_id`   INTEGER                               NOT NULL,
+    `from_status` VARCHAR(32)                           NOT NULL,
+    `to_status`   VARCHAR(32)                           NOT NULL,
+    `actor_id`    INTEGER                               NULL,
+    `created_at`  DATETIME                              NOT NULL,
+    FOREIGN KEY (`actor_id`) REFERENCES `Users` (`id`),
+    INDEX (`entity`, `entity_id`)
+);
@@ -0,0 +1,13 @@
+CREATE TABLE `IdempotencyKeys`
+(
+    `id`              INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `user_id`         INTEGER      NOT NULL,
+    `idempotency_key` VARCHAR(255) NOT NULL,
+    `request_hash`    CHAR(64)     NOT NULL,
+    `status_code`     SMALLINT     NULL,
+    `content_type`    VARCHAR(255) NULL,
+    `response_body`   MEDIUMBLOB   NULL,
+    `created_at`      DATETIME     NOT NULL,
+    UNIQUE INDEX (`user_id`, `idempotency_key`),
+    INDEX (`created_at`)
+);
@@ -0,0 +1,7 @@
+CREATE TABLE `Receipts`
+(
+    `number`     INTEGER  NOT NULL PRIMARY KEY,
+    `payment_id` INTEGER  NOT NULL UNIQUE,
+    `issued_at`  DATETIME NOT NULL,
+    FOREIGN KEY (`payment_id`) REFERENCES `Payments` (`id`)
+);
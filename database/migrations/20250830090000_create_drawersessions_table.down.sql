@@ -0,0 +1,10 @@
+ALTER TABLE `Refunds`
+    DROP FOREIGN KEY `fk_refunds_drawer_session`,
+    DROP COLUMN `drawer_session_id`;
+
+ALTER TABLE `Payments`
+    DROP FOREIGN KEY `fk_payments_drawer_session`,
+    DROP COLUMN `method`,
+    DROP COLUMN `drawer_session_id`;
+
+DROP TABLE IF EXISTS DrawerSessions;
@@ -0,0 +1,23 @@
+CREATE TABLE `DrawerSessions`
+(
+    `id`            INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `cashier_id`    INTEGER        NOT NULL,
+    `opened_at`     DATETIME       NOT NULL,
+    `closed_at`     DATETIME       NULL,
+    `opening_float` DECIMAL(10, 2) NOT NULL,
+    `expected_cash` DECIMAL(10, 2) NULL,
+    `counted_cash`  DECIMAL(10, 2) NULL,
+    `variance`      DECIMAL(10, 2) NULL,
+    `notes`         VARCHAR(255)   NULL,
+    FOREIGN KEY (`cashier_id`) REFERENCES `Users` (`id`),
+    INDEX (`cashier_id`, `closed_at`)
+);
+
+ALTER TABLE `Payments`
+    ADD COLUMN `method`            ENUM ('cash','card','other') NOT NULL DEFAULT 'card' AFTER `currency`,
+    ADD COLUMN `drawer_session_id` INTEGER                      NULL AFTER `cashier_id`,
+    ADD CONSTRAINT `fk_payments_drawer_session` FOREIGN KEY (`drawer_session_id`) REFERENCES `DrawerSessions` (`id`);
+
+ALTER TABLE `Refunds`
+    ADD COLUMN `drawer_session_id` INTEGER NULL AFTER `created_by`,
+    ADD CONSTRAINT `fk_refunds_drawer_session` FOREIGN KEY (`drawer_session_id`) REFERENCES `DrawerSessions` (`id`);
@@ -0,0 +1,10 @@
+CREATE TABLE `Shifts`
+(
+    `id`             INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `user_id`        INTEGER  NOT NULL,
+    `clocked_in_at`  DATETIME NOT NULL,
+    `clocked_out_at` DATETIME NULL,
+    FOREIGN KEY (`user_id`) REFERENCES `Users` (`id`),
+    INDEX (`user_id`, `clocked_out_at`),
+    INDEX (`clocked_in_at`)
+);
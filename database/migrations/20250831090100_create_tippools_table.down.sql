@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS TipPoolMembers;
+DROP TABLE IF EXISTS TipPoolRoles;
+DROP TABLE IF EXISTS TipPools;
@@ -0,0 +1,26 @@
+CREATE TABLE `TipPools`
+(
+    `id`         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `name`       VARCHAR(64)                     NOT NULL UNIQUE,
+    `method`     ENUM ('role','hours','points') NOT NULL,
+    `created_at` DATETIME                        NOT NULL
+);
+
+CREATE TABLE `TipPoolRoles`
+(
+    `pool_id` INTEGER       NOT NULL,
+    `role`    TINYINT       NOT NULL,
+    `percent` DECIMAL(6, 3) NOT NULL,
+    PRIMARY KEY (`pool_id`, `role`),
+    FOREIGN KEY (`pool_id`) REFERENCES `TipPools` (`id`) ON DELETE CASCADE
+);
+
+CREATE TABLE `TipPoolMembers`
+(
+    `pool_id` INTEGER NOT NULL,
+    `user_id` INTEGER NOT NULL,
+    `points`  INTEGER NOT NULL,
+    PRIMARY KEY (`pool_id`, `user_id`),
+    FOREIGN KEY (`pool_id`) REFERENCES `TipPools` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`user_id`) REFERENCES `Users` (`id`)
+);
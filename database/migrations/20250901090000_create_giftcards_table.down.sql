@@ -0,0 +1,14 @@
+DROP TABLE IF EXISTS GiftCardTransactions;
+
+ALTER TABLE `Refunds`
+    DROP FOREIGN KEY `fk_refunds_gift_card`,
+    DROP COLUMN `store_credit`,
+    DROP COLUMN `gift_card_id`,
+    DROP COLUMN `gift_card_amount`;
+
+ALTER TABLE `Payments`
+    DROP FOREIGN KEY `fk_payments_gift_card`,
+    DROP COLUMN `gift_card_id`,
+    DROP COLUMN `gift_card_amount`;
+
+DROP TABLE IF EXISTS GiftCards;
@@ -0,0 +1,46 @@
+CREATE TABLE `GiftCards`
+(
+    `id`          INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `code`        CHAR(16)                          NOT NULL UNIQUE,
+    `kind`        ENUM ('gift_card','store_credit') NOT NULL,
+    `balance`     DECIMAL(10, 2)                    NOT NULL,
+    `currency`    CHAR(3)                           NOT NULL,
+    `customer_id` INTEGER                           NULL,
+    `issued_by`   INTEGER                           NULL,
+    `created_at`  DATETIME                          NOT NULL,
+    FOREIGN KEY (`customer_id`) REFERENCES `Users` (`id`),
+    FOREIGN KEY (`issued_by`) REFERENCES `Users` (`id`),
+    CHECK (`balance` >= 0)
+);
+
+ALTER TABLE `Payments`
+    ADD COLUMN `gift_card_id`     INTEGER        NULL AFTER `drawer_session_id`,
+    ADD COLUMN `gift_card_amount` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `gift_card_id`,
+    ADD CONSTRAINT `fk_payments_gift_card` FOREIGN KEY (`gift_card_id`) REFERENCES `GiftCards` (`id`);
+
+ALTER TABLE `Refunds`
+    ADD COLUMN `store_credit`     BOOLEAN        NOT NULL DEFAULT FALSE AFTER `drawer_session_id`,
+    ADD COLUMN `gift_card_id`     INTEGER        NULL AFTER `store_credit`,
+    ADD COLUMN `gift_card_amount` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `gift_card_id`,
+    ADD CONSTRAINT `fk_refunds_gift_card` FOREIGN KEY (`gift_card_id`) REFERENCES `GiftCards` (`id`);
+
+CREATE TABLE `GiftCardTransactions`
+(
+    `id`                INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `gift_card_id`      INTEGER                                      NOT NULL,
+    `kind`              ENUM ('issue','redeem','reversal','refund') NOT NULL,
+    `amount`            DECIMAL(10, 2)                               NOT NULL,
+    `balance_after`     DECIMAL(10, 2)                               NOT NULL,
+    `payment_id`        INTEGER                                      NULL,
+    `refund_id`         INTEGER                                      NULL,
+    `drawer_session_id` INTEGER                                      NULL,
+    `method`            ENUM ('cash','card','other')                 NULL,
+    `created_by`        INTEGER                                      NULL,
+    `created_at`        DATETIME                                     NOT NULL,
+    FOREIGN KEY (`gift_card_id`) REFERENCES `GiftCards` (`id`),
+    FOREIGN KEY (`payment_id`) REFERENCES `Payments` (`id`),
+    FOREIGN KEY (`refund_id`) REFERENCES `Refunds` (`id`),
+    FOREIGN KEY (`drawer_session_id`) REFERENCES `DrawerSessions` (`id`),
+    FOREIGN KEY (`created_by`) REFERENCES `Users` (`id`),
+    INDEX (`gift_card_id`, `id`)
+);
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS LoyaltyTransactions;
+
+ALTER TABLE `Payments`
+    DROP COLUMN `loyalty_points`,
+    DROP COLUMN `loyalty_discount`,
+    DROP COLUMN `loyalty_earned`;
+
+ALTER TABLE `Tags`
+    DROP COLUMN `loyalty_multiplier`;
+
+ALTER TABLE `Users`
+    DROP CHECK `chk_users_loyalty_points`,
+    DROP COLUMN `loyalty_points`;
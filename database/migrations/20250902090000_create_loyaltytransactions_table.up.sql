@@ -0,0 +1,29 @@
+ALTER TABLE `Users`
+    ADD COLUMN `loyalty_points` INTEGER NOT NULL DEFAULT 0,
+    ADD CONSTRAINT `chk_users_loyalty_points` CHECK (`loyalty_points` >= 0);
+
+ALTER TABLE `Tags`
+    ADD COLUMN `loyalty_multiplier` DECIMAL(8, 3) NOT NULL DEFAULT 100;
+
+ALTER TABLE `Payments`
+    ADD COLUMN `loyalty_points`   INTEGER        NOT NULL DEFAULT 0 AFTER `gift_card_amount`,
+    ADD COLUMN `loyalty_discount` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `loyalty_points`,
+    ADD COLUMN `loyalty_earned`   INTEGER        NULL AFTER `loyalty_discount`;
+
+CREATE TABLE `LoyaltyTransactions`
+(
+    `id`            INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `user_id`       INTEGER                                   NOT NULL,
+    `kind`          ENUM ('earn','redeem','reversal','refund') NOT NULL,
+    `points`        INTEGER                                   NOT NULL,
+    `balance_after` INTEGER                                   NOT NULL,
+    `payment_id`    INTEGER                                   NULL,
+    `refund_id`     INTEGER                                   NULL,
+    `created_by`    INTEGER                                   NULL,
+    `created_at`    DATETIME                                  NOT NULL,
+    FOREIGN KEY (`user_id`) REFERENCES `Users` (`id`),
+    FOREIGN KEY (`payment_id`) REFERENCES `Payments` (`id`),
+    FOREIGN KEY (`refund_id`) REFERENCES `Refunds` (`id`),
+    FOREIGN KEY (`created_by`) REFERENCES `Users` (`id`),
+    INDEX (`user_id`, `id`)
+);
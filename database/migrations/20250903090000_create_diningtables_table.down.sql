@@ -0,0 +1,12 @@
+DELETE
+FROM `StatusTransitions`
+WHERE `entity` = 'table';
+
+ALTER TABLE `StatusTransitions`
+    MODIFY `entity` ENUM ('payment','order_item','order') NOT NULL;
+
+ALTER TABLE `Orders`
+    DROP FOREIGN KEY `fk_orders_table`,
+    DROP COLUMN `table_id`;
+
+DROP TABLE IF EXISTS DiningTables;
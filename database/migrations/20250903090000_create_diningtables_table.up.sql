@@ -0,0 +1,36 @@
+CREATE TABLE `DiningTables`
+(
+    `id`         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `number`     INTEGER                                              NOT NULL UNIQUE,
+    `section`    VARCHAR(64)                                          NOT NULL DEFAULT '',
+    `capacity`   INTEGER                                              NOT NULL,
+    `status`     ENUM ('free','occupied','reserved','needs_cleaning') NOT NULL DEFAULT 'free',
+    `qr_token`   CHAR(32)                                             NOT NULL UNIQUE,
+    `created_at` DATETIME                                             NOT NULL,
+    CHECK (`number` > 0),
+    CHECK (`capacity` > 0)
+);
+
+-- every table number orders were taken at becomes a table, occupied while it has an open order
+INSERT INTO `DiningTables` (`number`, `capacity`, `status`, `qr_token`, `created_at`)
+SELECT `table_number`,
+       GREATEST(MAX(`guests`), 1),
+       IF(SUM(`status` = 'open') > 0, 'occupied', 'free'),
+       LOWER(HEX(RANDOM_BYTES(16))),
+       NOW()
+FROM `Orders`
+GROUP BY `table_number`;
+
+ALTER TABLE `Orders`
+    ADD COLUMN `table_id` INTEGER NULL AFTER `customer_id`,
+    ADD CONSTRAINT `fk_orders_table` FOREIGN KEY (`table_id`) REFERENCES `DiningTables` (`id`);
+
+UPDATE `Orders`
+    JOIN `DiningTables` ON `DiningTables`.`number` = `Orders`.`table_number`
+SET `Orders`.`table_id` = `DiningTables`.`id`;
+
+ALTER TABLE `Orders`
+    MODIFY `table_id` INTEGER NOT NULL;
+
+ALTER TABLE `StatusTransitions`
+    MODIFY `entity` ENUM ('payment','order_item','order','table') NOT NULL;
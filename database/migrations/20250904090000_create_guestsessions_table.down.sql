@@ -0,0 +1,8 @@
+ALTER TABLE `Orders`
+    DROP COLUMN `bill_requested_at`;
+
+DROP TABLE IF EXISTS `GuestSessions`;
+
+-- guests that were never converted lose the guest flag (8)
+UPDATE `Users`
+SET `role` = `role` & 7;
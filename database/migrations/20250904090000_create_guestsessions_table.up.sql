@@ -0,0 +1,14 @@
+CREATE TABLE `GuestSessions`
+(
+    `id`           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `user_id`      INTEGER  NOT NULL UNIQUE,
+    `table_id`     INTEGER  NOT NULL,
+    `created_at`   DATETIME NOT NULL,
+    `expires_at`   DATETIME NOT NULL,
+    `converted_at` DATETIME NULL,
+    FOREIGN KEY (`user_id`) REFERENCES `Users` (`id`),
+    FOREIGN KEY (`table_id`) REFERENCES `DiningTables` (`id`) ON DELETE CASCADE
+);
+
+ALTER TABLE `Orders`
+    ADD COLUMN `bill_requested_at` DATETIME NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE `OrderItems`
+    DROP FOREIGN KEY `fk_orderitems_added_by`,
+    DROP COLUMN `added_by`;
+
+ALTER TABLE `Orders`
+    DROP COLUMN `join_code`;
+
+DROP TABLE IF EXISTS `OrderParticipants`;
@@ -0,0 +1,26 @@
+CREATE TABLE `OrderParticipants`
+(
+    `order_id`  INTEGER  NOT NULL,
+    `user_id`   INTEGER  NOT NULL,
+    `joined_at` DATETIME NOT NULL,
+    PRIMARY KEY (`order_id`, `user_id`),
+    INDEX (`user_id`),
+    FOREIGN KEY (`order_id`) REFERENCES `Orders` (`id`),
+    FOREIGN KEY (`user_id`) REFERENCES `Users` (`id`)
+);
+
+-- whoever opened an order takes part in it
+INSERT INTO `OrderParticipants` (`order_id`, `user_id`, `joined_at`)
+SELECT `id`, `customer_id`, `ordered_at`
+FROM `Orders`;
+
+ALTER TABLE `Orders`
+    ADD COLUMN `join_code` CHAR(6) NULL UNIQUE;
+
+ALTER TABLE `OrderItems`
+    ADD COLUMN `added_by` INTEGER NULL,
+    ADD CONSTRAINT `fk_orderitems_added_by` FOREIGN KEY (`added_by`) REFERENCES `Users` (`id`);
+
+UPDATE `OrderItems`
+    JOIN `Orders` ON `Orders`.`id` = `OrderItems`.`order_id`
+SET `OrderItems`.`added_by` = `Orders`.`customer_id`;
@@ -0,0 +1,12 @@
+CREATE TABLE `AuditLog`
+(
+    `id`         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `entity`     VARCHAR(32) NOT NULL,
+    `entity_id`  INTEGER     NOT NULL,
+    `action`     VARCHAR(32) NOT NULL,
+    `actor_id`   INTEGER     NULL,
+    `details`    JSON        NOT NULL,
+    `created_at` DATETIME    NOT NULL,
+    INDEX (`entity`, `entity_id`),
+    FOREIGN KEY (`actor_id`) REFERENCES `Users` (`id`)
+);
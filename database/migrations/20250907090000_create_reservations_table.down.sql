@@ -0,0 +1,12 @@
+DELETE
+FROM `StatusTransitions`
+WHERE `entity` = 'reservation';
+
+ALTER TABLE `StatusTransitions`
+    MODIFY `entity` ENUM ('payment','order_item','order','table') NOT NULL;
+
+DROP TABLE IF EXISTS `ReservationTables`;
+DROP TABLE IF EXISTS `Reservations`;
+
+ALTER TABLE `DiningTables`
+    DROP COLUMN `combinable`;
@@ -0,0 +1,36 @@
+ALTER TABLE `DiningTables`
+    ADD COLUMN `combinable` BOOLEAN NOT NULL DEFAULT FALSE AFTER `capacity`;
+
+CREATE TABLE `Reservations`
+(
+    `id`         INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `party_size` INTEGER                                           NOT NULL,
+    `starts_at`  DATETIME                                          NOT NULL,
+    `ends_at`    DATETIME                                          NOT NULL,
+    `name`       VARCHAR(255)                                      NOT NULL,
+    `phone`      VARCHAR(32)                                       NOT NULL DEFAULT '',
+    `email`      VARCHAR(255)                                      NOT NULL DEFAULT '',
+    `notes`      TEXT                                              NOT NULL,
+    `status`     ENUM ('confirmed','seated','no_show','cancelled') NOT NULL DEFAULT 'confirmed',
+    `order_id`   INTEGER                                           NULL,
+    `created_by` INTEGER                                           NULL,
+    `created_at` DATETIME                                          NOT NULL,
+    INDEX (`starts_at`),
+    FOREIGN KEY (`order_id`) REFERENCES `Orders` (`id`),
+    FOREIGN KEY (`created_by`) REFERENCES `Users` (`id`),
+    CHECK (`party_size` > 0),
+    CHECK (`ends_at` > `starts_at`)
+);
+
+CREATE TABLE `ReservationTables`
+(
+    `reservation_id` INTEGER NOT NULL,
+    `table_id`       INTEGER NOT NULL,
+    PRIMARY KEY (`reservation_id`, `table_id`),
+    INDEX (`table_id`),
+    FOREIGN KEY (`reservation_id`) REFERENCES `Reservations` (`id`) ON DELETE CASCADE,
+    FOREIGN KEY (`table_id`) REFERENCES `DiningTables` (`id`)
+);
+
+ALTER TABLE `StatusTransitions`
+    MODIFY `entity` ENUM ('payment','order_item','order','table','reservation') NOT NULL;
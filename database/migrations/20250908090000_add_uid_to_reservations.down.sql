@@ -0,0 +1,3 @@
+ALTER TABLE `Reservations`
+    DROP INDEX `uq_reservations_uid`,
+    DROP COLUMN `uid`;
@@ -0,0 +1,9 @@
+ALTER TABLE `Reservations`
+    ADD COLUMN `uid` VARCHAR(255) NULL AFTER `id`;
+
+UPDATE `Reservations`
+SET `uid` = UUID();
+
+ALTER TABLE `Reservations`
+    MODIFY `uid` VARCHAR(255) NOT NULL,
+    ADD CONSTRAINT `uq_reservations_uid` UNIQUE (`uid`);
@@ -0,0 +1,8 @@
+DELETE
+FROM `StatusTransitions`
+WHERE `entity` = 'waitlist';
+
+ALTER TABLE `StatusTransitions`
+    MODIFY `entity` ENUM ('payment','order_item','order','table','reservation') NOT NULL;
+
+DROP TABLE IF EXISTS `Waitlist`;
@@ -0,0 +1,24 @@
+CREATE TABLE `Waitlist`
+(
+    `id`           INTEGER PRIMARY KEY AUTO_INCREMENT,
+    `name`         VARCHAR(255)                                     NOT NULL,
+    `party_size`   INTEGER                                          NOT NULL,
+    `phone`        VARCHAR(32)                                      NOT NULL DEFAULT '',
+    `quoted_wait`  INTEGER                                          NOT NULL,
+    `status`       ENUM ('waiting','notified','seated','cancelled') NOT NULL DEFAULT 'waiting',
+    `table_id`     INTEGER                                          NULL,
+    `order_id`     INTEGER                                          NULL,
+    `notified_at`  DATETIME                                         NULL,
+    `seated_at`    DATETIME                                         NULL,
+    `created_by`   INTEGER                                          NULL,
+    `created_at`   DATETIME                                         NOT NULL,
+    INDEX (`status`, `created_at`),
+    FOREIGN KEY (`table_id`) REFERENCES `DiningTables` (`id`) ON DELETE SET NULL,
+    FOREIGN KEY (`order_id`) REFERENCES `Orders` (`id`),
+    FOREIGN KEY (`created_by`) REFERENCES `Users` (`id`),
+    CHECK (`party_size` > 0),
+    CHECK (`quoted_wait` >= 0)
+);
+
+ALTER TABLE `StatusTransitions`
+    MODIFY `entity` ENUM ('payment','order_item','order','table','reservation','waitlist') NOT NULL;
@@ -0,0 +1,22 @@
+ALTER TABLE `Payments`
+    DROP COLUMN `total`,
+    DROP COLUMN `delivery_fee`;
+
+ALTER TABLE `Payments`
+    ADD COLUMN `total` DECIMAL(10, 2) GENERATED ALWAYS AS (order_subtotal - discount + service_charge + tax + tip) STORED;
+
+ALTER TABLE `OrderItems`
+    DROP COLUMN `created_at`;
+
+ALTER TABLE `Orders`
+    DROP CHECK `chk_orders_table`,
+    DROP CHECK `chk_orders_delivery_address`;
+
+-- fails while takeaway or delivery orders exist, as they have no table to go back to
+ALTER TABLE `Orders`
+    DROP COLUMN `type`,
+    DROP COLUMN `pickup_at`,
+    DROP COLUMN `delivery_address`,
+    DROP COLUMN `delivery_fee`,
+    MODIFY `table_id` INTEGER NOT NULL,
+    MODIFY `table_number` INTEGER NOT NULL;
@@ -0,0 +1,30 @@
+ALTER TABLE `Orders`
+    ADD COLUMN `type`             ENUM ('dine_in','takeaway','delivery') NOT NULL DEFAULT 'dine_in' AFTER `status`,
+    ADD COLUMN `pickup_at`        DATETIME                               NULL,
+    ADD COLUMN `delivery_address` VARCHAR(512)                           NULL,
+    ADD COLUMN `delivery_fee`     DECIMAL(10, 2)                         NOT NULL DEFAULT 0,
+    MODIFY `table_id` INTEGER NULL,
+    MODIFY `table_number` INTEGER NULL;
+
+-- only dine-in orders are seated at a table, and only delivery orders have an address
+ALTER TABLE `Orders`
+    ADD CONSTRAINT `chk_orders_table` CHECK ((`type` = 'dine_in') = (`table_id` IS NOT NULL)),
+    ADD CONSTRAINT `chk_orders_delivery_address` CHECK ((`type` = 'delivery') = (`delivery_address` IS NOT NULL));
+
+-- the kitchen queue orders items by when they were ordered, or by their order's pickup time
+ALTER TABLE `OrderItems`
+    ADD COLUMN `created_at` DATETIME NULL;
+
+UPDATE `OrderItems`
+    JOIN `Orders` ON `Orders`.`id` = `OrderItems`.`order_id`
+SET `OrderItems`.`created_at` = `Orders`.`ordered_at`;
+
+ALTER TABLE `OrderItems`
+    MODIFY `created_at` DATETIME NOT NULL;
+
+ALTER TABLE `Payments`
+    DROP COLUMN `total`,
+    ADD COLUMN `delivery_fee` DECIMAL(10, 2) NOT NULL DEFAULT 0 AFTER `service_charge`;
+
+ALTER TABLE `Payments`
+    ADD COLUMN `total` DECIMAL(10, 2) GENERATED ALWAYS AS (order_subtotal - discount + service_charge + delivery_fee + tax + tip) STORED;
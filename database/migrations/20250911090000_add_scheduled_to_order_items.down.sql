@@ -0,0 +1,9 @@
+ALTER TABLE `Orders`
+    DROP INDEX `pickup_at`;
+
+UPDATE `OrderItems`
+SET `status` = 'pending'
+WHERE `status` = 'scheduled';
+
+ALTER TABLE `OrderItems`
+    MODIFY `status` ENUM ('preparing','completed','pending') NOT NULL;
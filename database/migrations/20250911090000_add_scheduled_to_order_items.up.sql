@@ -0,0 +1,7 @@
+-- items of orders scheduled ahead are held out of the kitchen queue until shortly before pickup
+ALTER TABLE `OrderItems`
+    MODIFY `status` ENUM ('preparing','completed','pending','scheduled') NOT NULL;
+
+-- pickup slots count the orders promised for them
+ALTER TABLE `Orders`
+    ADD INDEX (`pickup_at`);
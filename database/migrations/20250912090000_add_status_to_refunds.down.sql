@@ -0,0 +1,5 @@
+DELETE FROM `Refunds`
+WHERE `status` <> 'completed';
+
+ALTER TABLE `Refunds`
+    DROP COLUMN `status`;
@@ -0,0 +1,4 @@
+-- refunds through the payment provider are reserved as pending before the provider is asked, so
+-- concurrent refunds cannot both be issued; they are completed or voided once it answers
+ALTER TABLE `Refunds`
+    ADD COLUMN `status` ENUM ('pending','completed','voided') NOT NULL DEFAULT 'completed' AFTER `amount`;
@@ -0,0 +1,3 @@
+ALTER TABLE `DrawerSessions`
+    DROP INDEX `uq_drawersessions_open_cashier_id`,
+    DROP COLUMN `open_cashier_id`;
@@ -0,0 +1,5 @@
+-- open_cashier_id is only set while a session is open, so the unique index allows one open
+-- session per cashier even when two are opened at once
+ALTER TABLE `DrawerSessions`
+    ADD COLUMN `open_cashier_id` INTEGER GENERATED ALWAYS AS (IF(`closed_at` IS NULL, `cashier_id`, NULL)) STORED,
+    ADD CONSTRAINT `uq_drawersessions_open_cashier_id` UNIQUE (`open_cashier_id`);
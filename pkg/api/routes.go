@@ -2,7 +2,10 @@ package api
 
 import (
 	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/gateway"
 	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/notify"
+	"github.com/gqvz/mvc/pkg/printer"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"net/http"
 	"regexp"
@@ -12,7 +15,7 @@ import (
 	"github.com/gqvz/mvc/pkg/middlewares"
 )
 
-func CreateRouter(appConfig *config.AppConfig) *mux.Router {
+func CreateRouter(appConfig *config.AppConfig, provider gateway.PaymentProvider, spooler *printer.Spooler, notifier notify.Notifier) *mux.Router {
 	router := mux.NewRouter()
 
 	router.PathPrefix("/swagger/").Handler(httpSwagger.Handler(
@@ -28,11 +31,17 @@ func CreateRouter(appConfig *config.AppConfig) *mux.Router {
 	authMiddleware := middlewares.CreateAuthenticationMiddleware(appConfig.JwtSecret)
 	apiRouter.Use(authMiddleware)
 
-	RegisterRoutes(apiRouter)
+	RegisterRoutes(apiRouter, provider, spooler, notifier)
 
 	return router
 }
 
+// idempotent lets an authenticated create endpoint be retried safely with an Idempotency-Key. It goes
+// inside Authorize, so keys are only reserved for requests that are let through.
+func idempotent(handler http.HandlerFunc) http.Handler {
+	return middlewares.CreateIdempotencyMiddleware(config.Config.Idempotency.Window)(handler)
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	var localhostRegex = regexp.MustCompile(`^https?://localhost(:[0-9]+)?$`)
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -40,7 +49,8 @@ func corsMiddleware(next http.Handler) http.Handler {
 		if localhostRegex.MatchString(origin) {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH")
-			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key")
+			w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed")
 		}
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -51,20 +61,59 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func RegisterRoutes(router *mux.Router) {
+func RegisterRoutes(router *mux.Router, provider gateway.PaymentProvider, spooler *printer.Spooler, notifier notify.Notifier) {
 	RegisterUserRoutes(router)
 	RegisterTokenRoutes(router)
 	RegisterTagRoutes(router)
 	RegisterRequestRoutes(router)
 	RegisterItemRoutes(router)
+	RegisterPricingRoutes(router)
+	RegisterTableRoutes(router, notifier)
+	RegisterGuestRoutes(router, spooler)
+	RegisterReservationRoutes(router)
+	RegisterWaitlistRoutes(router, notifier)
 	RegisterOrderRoutes(router)
-	RegisterOrderItemRoutes(router)
-	RegisterPaymentRoutes(router)
+	RegisterOrderItemRoutes(router, spooler)
+	RegisterPaymentRoutes(router, provider, spooler)
+	RegisterWebhookRoutes(router, provider, spooler)
+	RegisterRefundRoutes(router, provider)
+	RegisterReportRoutes(router)
+	RegisterDrawerRoutes(router)
+	RegisterShiftRoutes(router)
+	RegisterTipRoutes(router)
+	RegisterGiftCardRoutes(router)
+	RegisterReceiptRoutes(router)
+	RegisterPromoRoutes(router)
+	RegisterTaxRoutes(router)
+}
+
+func RegisterTaxRoutes(router *mux.Router) {
+	c := controllers.CreateTaxController()
+	createTaxCategoryHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.CreateTaxCategoryHandler))
+	router.Handle("/taxes", createTaxCategoryHandler).Methods("POST", "OPTIONS")
+
+	getTaxCategoriesHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetTaxCategoriesHandler))
+	router.Handle("/taxes", getTaxCategoriesHandler).Methods("GET", "OPTIONS")
+
+	editTaxCategoryHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.EditTaxCategoryHandler))
+	router.Handle("/taxes/{id:[0-9]+}", editTaxCategoryHandler).Methods("PUT", "OPTIONS")
 }
 
-func RegisterPaymentRoutes(router *mux.Router) {
-	c := controllers.CreatePaymentController()
-	createPaymentHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.CreatePaymentHandler))
+func RegisterPromoRoutes(router *mux.Router) {
+	c := controllers.CreatePromoController()
+	createPromoCodeHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.CreatePromoCodeHandler))
+	router.Handle("/promos", createPromoCodeHandler).Methods("POST", "OPTIONS")
+
+	getPromoCodesHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetPromoCodesHandler))
+	router.Handle("/promos", getPromoCodesHandler).Methods("GET", "OPTIONS")
+
+	editPromoCodeHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.EditPromoCodeHandler))
+	router.Handle("/promos/{id:[0-9]+}", editPromoCodeHandler).Methods("PUT", "OPTIONS")
+}
+
+func RegisterPaymentRoutes(router *mux.Router, provider gateway.PaymentProvider, spooler *printer.Spooler) {
+	c := controllers.CreatePaymentController(provider, spooler)
+	createPaymentHandler := middlewares.Authorize(models.Customer)(idempotent(c.CreatePaymentHandler))
 	router.Handle("/payments", createPaymentHandler).Methods("POST", "OPTIONS")
 
 	getPaymentHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetPaymentHandler))
@@ -75,11 +124,115 @@ func RegisterPaymentRoutes(router *mux.Router) {
 
 	editPaymentStatusHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.EditPaymentStatusHandler))
 	router.Handle("/payments/{id:[0-9]+}", editPaymentStatusHandler).Methods("PATCH", "OPTIONS")
+
+	getPaymentTransitionsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetPaymentTransitionsHandler))
+	router.Handle("/payments/{id:[0-9]+}/transitions", getPaymentTransitionsHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterWebhookRoutes(router *mux.Router, provider gateway.PaymentProvider, spooler *printer.Spooler) {
+	c := controllers.CreateWebhookController(provider, spooler)
+	router.HandleFunc("/payments/webhooks/{provider}", c.ReceiveWebhookHandler).Methods("POST", "OPTIONS")
+
+	getWebhookEventsHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetWebhookEventsHandler))
+	router.Handle("/payments/webhooks/events", getWebhookEventsHandler).Methods("GET", "OPTIONS")
+
+	replayWebhookEventHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.ReplayWebhookEventHandler))
+	router.Handle("/payments/webhooks/events/{id:[0-9]+}/replay", replayWebhookEventHandler).Methods("POST", "OPTIONS")
+}
+
+func RegisterRefundRoutes(router *mux.Router, provider gateway.PaymentProvider) {
+	c := controllers.CreateRefundController(provider)
+	createRefundHandler := middlewares.Authorize(models.Cashier)(idempotent(c.CreateRefundHandler))
+	router.Handle("/payments/{id:[0-9]+}/refunds", createRefundHandler).Methods("POST", "OPTIONS")
+
+	getRefundsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetRefundsHandler))
+	router.Handle("/payments/{id:[0-9]+}/refunds", getRefundsHandler).Methods("GET", "OPTIONS")
+
+	voidPaymentHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.VoidPaymentHandler))
+	router.Handle("/payments/{id:[0-9]+}/void", voidPaymentHandler).Methods("POST", "OPTIONS")
+}
+
+func RegisterReportRoutes(router *mux.Router) {
+	c := controllers.CreateReportController()
+	getRevenueReportHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetRevenueReportHandler))
+	router.Handle("/reports/revenue", getRevenueReportHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterDrawerRoutes(router *mux.Router) {
+	c := controllers.CreateDrawerController()
+	openDrawerSessionHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.OpenDrawerSessionHandler))
+	router.Handle("/drawers", openDrawerSessionHandler).Methods("POST", "OPTIONS")
+
+	getDrawerSessionsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetDrawerSessionsHandler))
+	router.Handle("/drawers", getDrawerSessionsHandler).Methods("GET", "OPTIONS")
+
+	getDrawerSessionHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetDrawerSessionHandler))
+	router.Handle("/drawers/{id:[0-9]+}", getDrawerSessionHandler).Methods("GET", "OPTIONS")
+
+	closeDrawerSessionHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.CloseDrawerSessionHandler))
+	router.Handle("/drawers/{id:[0-9]+}/close", closeDrawerSessionHandler).Methods("POST", "OPTIONS")
+
+	getZReportHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetZReportHandler))
+	router.Handle("/drawers/{id:[0-9]+}/report", getZReportHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterShiftRoutes(router *mux.Router) {
+	c := controllers.CreateShiftController()
+	clockInHandler := middlewares.Authorize(models.Any)(http.HandlerFunc(c.ClockInHandler))
+	router.Handle("/shifts/clock-in", clockInHandler).Methods("POST", "OPTIONS")
+
+	clockOutHandler := middlewares.Authorize(models.Any)(http.HandlerFunc(c.ClockOutHandler))
+	router.Handle("/shifts/clock-out", clockOutHandler).Methods("POST", "OPTIONS")
+
+	getShiftsHandler := middlewares.Authorize(models.Any)(http.HandlerFunc(c.GetShiftsHandler))
+	router.Handle("/shifts", getShiftsHandler).Methods("GET", "OPTIONS")
 }
 
-func RegisterOrderItemRoutes(router *mux.Router) {
-	c := controllers.CreateOrderItemController()
-	createOrderItemHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.CreateOrderItem))
+func RegisterTipRoutes(router *mux.Router) {
+	c := controllers.CreateTipController()
+	createTipPoolHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.CreateTipPoolHandler))
+	router.Handle("/tips/pools", createTipPoolHandler).Methods("POST", "OPTIONS")
+
+	getTipPoolsHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetTipPoolsHandler))
+	router.Handle("/tips/pools", getTipPoolsHandler).Methods("GET", "OPTIONS")
+
+	getTipPoolHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetTipPoolHandler))
+	router.Handle("/tips/pools/{id:[0-9]+}", getTipPoolHandler).Methods("GET", "OPTIONS")
+
+	deleteTipPoolHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.DeleteTipPoolHandler))
+	router.Handle("/tips/pools/{id:[0-9]+}", deleteTipPoolHandler).Methods("DELETE", "OPTIONS")
+
+	getTipPayoutsHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetTipPayoutsHandler))
+	router.Handle("/tips/payouts", getTipPayoutsHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterGiftCardRoutes(router *mux.Router) {
+	c := controllers.CreateGiftCardController()
+	issueGiftCardHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.IssueGiftCardHandler))
+	router.Handle("/giftcards", issueGiftCardHandler).Methods("POST", "OPTIONS")
+
+	getGiftCardsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetGiftCardsHandler))
+	router.Handle("/giftcards", getGiftCardsHandler).Methods("GET", "OPTIONS")
+
+	getGiftCardBalanceHandler := middlewares.Authorize(models.Any)(http.HandlerFunc(c.GetGiftCardBalanceHandler))
+	router.Handle("/giftcards/balance", getGiftCardBalanceHandler).Methods("GET", "OPTIONS")
+
+	getGiftCardHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetGiftCardHandler))
+	router.Handle("/giftcards/{id:[0-9]+}", getGiftCardHandler).Methods("GET", "OPTIONS")
+
+	getGiftCardTransactionsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetGiftCardTransactionsHandler))
+	router.Handle("/giftcards/{id:[0-9]+}/transactions", getGiftCardTransactionsHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterReceiptRoutes(router *mux.Router) {
+	c := controllers.CreateReceiptController()
+	getPaymentReceiptHandler := middlewares.Authorize(models.Any)(http.HandlerFunc(c.GetPaymentReceiptHandler))
+	router.Handle("/payments/{id:[0-9]+}/receipt", getPaymentReceiptHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterOrderItemRoutes(router *mux.Router, spooler *printer.Spooler) {
+	c := controllers.CreateOrderItemController(spooler)
+	createOrderItemHandler := middlewares.Authorize(models.Customer)(idempotent(c.CreateOrderItem))
 	router.Handle("/orders/{id:[0-9]+}/items", createOrderItemHandler).Methods("POST", "OPTIONS")
 
 	editOrderItemStatusHandler := middlewares.Authorize(models.Chef)(http.HandlerFunc(c.EditOrderItemStatus))
@@ -90,11 +243,119 @@ func RegisterOrderItemRoutes(router *mux.Router) {
 
 	getOrderItemsByStatusHandler := middlewares.Authorize(models.Chef)(http.HandlerFunc(c.GetOrderItemsByStatus))
 	router.Handle("/orders/items", getOrderItemsByStatusHandler).Methods("GET", "OPTIONS")
+
+	getOrderItemTransitionsHandler := middlewares.Authorize(models.Chef)(http.HandlerFunc(c.GetOrderItemTransitions))
+	router.Handle("/orders/items/{id:[0-9]+}/transitions", getOrderItemTransitionsHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterTableRoutes(router *mux.Router, notifier notify.Notifier) {
+	c := controllers.CreateTableController(notifier)
+	createTableHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.CreateTableHandler))
+	router.Handle("/tables", createTableHandler).Methods("POST", "OPTIONS")
+
+	getTablesHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetTablesHandler))
+	router.Handle("/tables", getTablesHandler).Methods("GET", "OPTIONS")
+
+	getFloorPlanHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetFloorPlanHandler))
+	router.Handle("/tables/floor-plan", getFloorPlanHandler).Methods("GET", "OPTIONS")
+
+	getTableHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetTableHandler))
+	router.Handle("/tables/{id:[0-9]+}", getTableHandler).Methods("GET", "OPTIONS")
+
+	editTableHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.EditTableHandler))
+	router.Handle("/tables/{id:[0-9]+}", editTableHandler).Methods("PUT", "OPTIONS")
+
+	deleteTableHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.DeleteTableHandler))
+	router.Handle("/tables/{id:[0-9]+}", deleteTableHandler).Methods("DELETE", "OPTIONS")
+
+	editTableStatusHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.EditTableStatusHandler))
+	router.Handle("/tables/{id:[0-9]+}/status", editTableStatusHandler).Methods("PATCH", "OPTIONS")
+
+	getTableTransitionsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetTableTransitionsHandler))
+	router.Handle("/tables/{id:[0-9]+}/transitions", getTableTransitionsHandler).Methods("GET", "OPTIONS")
+
+	getTableQRCodeHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetTableQRCodeHandler))
+	router.Handle("/tables/{id:[0-9]+}/qr.png", getTableQRCodeHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterReservationRoutes(router *mux.Router) {
+	c := controllers.CreateReservationController()
+	createReservationHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.CreateReservationHandler))
+	router.Handle("/reservations", createReservationHandler).Methods("POST", "OPTIONS")
+
+	getReservationsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetReservationsHandler))
+	router.Handle("/reservations", getReservationsHandler).Methods("GET", "OPTIONS")
+
+	getReservationAvailabilityHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetReservationAvailabilityHandler))
+	router.Handle("/reservations/availability", getReservationAvailabilityHandler).Methods("GET", "OPTIONS")
+
+	// calendar apps cannot sign in, so the feed checks the feed token in its URL itself
+	router.HandleFunc("/reservations/calendar.ics", c.GetReservationCalendarHandler).Methods("GET", "OPTIONS")
+
+	importReservationsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.ImportReservationsHandler))
+	router.Handle("/reservations/import", importReservationsHandler).Methods("POST", "OPTIONS")
+
+	getReservationHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetReservationHandler))
+	router.Handle("/reservations/{id:[0-9]+}", getReservationHandler).Methods("GET", "OPTIONS")
+
+	editReservationHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.EditReservationHandler))
+	router.Handle("/reservations/{id:[0-9]+}", editReservationHandler).Methods("PUT", "OPTIONS")
+
+	editReservationStatusHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.EditReservationStatusHandler))
+	router.Handle("/reservations/{id:[0-9]+}/status", editReservationStatusHandler).Methods("PATCH", "OPTIONS")
+
+	getReservationTransitionsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetReservationTransitionsHandler))
+	router.Handle("/reservations/{id:[0-9]+}/transitions", getReservationTransitionsHandler).Methods("GET", "OPTIONS")
+}
+
+func RegisterWaitlistRoutes(router *mux.Router, notifier notify.Notifier) {
+	c := controllers.CreateWaitlistController(notifier)
+	createWaitlistEntryHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.CreateWaitlistEntryHandler))
+	router.Handle("/waitlist", createWaitlistEntryHandler).Methods("POST", "OPTIONS")
+
+	getWaitlistHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetWaitlistHandler))
+	router.Handle("/waitlist", getWaitlistHandler).Methods("GET", "OPTIONS")
+
+	getWaitEstimateHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetWaitEstimateHandler))
+	router.Handle("/waitlist/estimate", getWaitEstimateHandler).Methods("GET", "OPTIONS")
+
+	notifyWaitlistEntryHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.NotifyWaitlistEntryHandler))
+	router.Handle("/waitlist/{id:[0-9]+}/notify", notifyWaitlistEntryHandler).Methods("POST", "OPTIONS")
+
+	seatWaitlistEntryHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.SeatWaitlistEntryHandler))
+	router.Handle("/waitlist/{id:[0-9]+}/seat", seatWaitlistEntryHandler).Methods("POST", "OPTIONS")
+
+	editWaitlistStatusHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.EditWaitlistStatusHandler))
+	router.Handle("/waitlist/{id:[0-9]+}/status", editWaitlistStatusHandler).Methods("PATCH", "OPTIONS")
+
+	getWaitlistTransitionsHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetWaitlistTransitionsHandler))
+	router.Handle("/waitlist/{id:[0-9]+}/transitions", getWaitlistTransitionsHandler).Methods("GET", "OPTIONS")
+}
+
+// RegisterGuestRoutes registers what walk-in guests can do from a table's QR code. Every guest route
+// is rate limited, starting sessions more strictly since it needs no token.
+func RegisterGuestRoutes(router *mux.Router, spooler *printer.Spooler) {
+	guests := config.Config.Guests
+	sessionRateLimit := middlewares.CreateRateLimitMiddleware(guests.SessionRateLimit, guests.RateWindow)
+	rateLimit := middlewares.CreateRateLimitMiddleware(guests.RateLimit, guests.RateWindow)
+	guest := func(handler http.HandlerFunc) http.Handler {
+		return rateLimit(middlewares.Authorize(models.Guest)(handler))
+	}
+
+	c := controllers.CreateGuestController(spooler)
+	router.Handle("/guest/sessions", sessionRateLimit(http.HandlerFunc(c.StartGuestSessionHandler))).Methods("POST", "OPTIONS")
+	router.Handle("/guest/session", guest(c.GetGuestSessionHandler)).Methods("GET", "OPTIONS")
+	router.Handle("/guest/session/convert", guest(c.ConvertGuestSessionHandler)).Methods("POST", "OPTIONS")
+	router.Handle("/guest/order/items", rateLimit(middlewares.Authorize(models.Guest)(idempotent(c.CreateGuestOrderItemHandler)))).Methods("POST", "OPTIONS")
+	router.Handle("/guest/order/bill", guest(c.RequestGuestBillHandler)).Methods("POST", "OPTIONS")
+
+	ic := controllers.CreateItemController()
+	router.Handle("/guest/items", guest(ic.GetItemsHandler)).Methods("GET", "OPTIONS")
 }
 
 func RegisterOrderRoutes(router *mux.Router) {
 	c := controllers.CreateOrderController()
-	createOrderHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.CreateOrder))
+	createOrderHandler := middlewares.Authorize(models.Customer)(idempotent(c.CreateOrder))
 	router.Handle("/orders", createOrderHandler).Methods("POST", "OPTIONS")
 
 	closeOrderHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.CloseOrder))
@@ -103,8 +364,37 @@ func RegisterOrderRoutes(router *mux.Router) {
 	getOrderHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetOrder))
 	router.Handle("/orders/{id:[0-9]+}", getOrderHandler).Methods("GET", "OPTIONS")
 
+	getOrderBalanceHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetOrderBalance))
+	router.Handle("/orders/{id:[0-9]+}/balance", getOrderBalanceHandler).Methods("GET", "OPTIONS")
+
+	getOrderTransitionsHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetOrderTransitions))
+	router.Handle("/orders/{id:[0-9]+}/transitions", getOrderTransitionsHandler).Methods("GET", "OPTIONS")
+
 	getOrdersHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetOrders))
 	router.Handle("/orders", getOrdersHandler).Methods("GET", "OPTIONS")
+
+	getPickupSlotsHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetPickupSlots))
+	router.Handle("/orders/slots", getPickupSlotsHandler).Methods("GET", "OPTIONS")
+
+	// join codes are short, so guessing them is rate limited like the guest routes
+	joinRateLimit := middlewares.CreateRateLimitMiddleware(config.Config.Guests.RateLimit, config.Config.Guests.RateWindow)
+	joinOrderHandler := joinRateLimit(middlewares.Authorize(models.Customer)(http.HandlerFunc(c.JoinOrder)))
+	router.Handle("/orders/join", joinOrderHandler).Methods("POST", "OPTIONS")
+
+	getOrderJoinCodeHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetOrderJoinCode))
+	router.Handle("/orders/{id:[0-9]+}/invite", getOrderJoinCodeHandler).Methods("POST", "OPTIONS")
+
+	getOrderParticipantsHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetOrderParticipants))
+	router.Handle("/orders/{id:[0-9]+}/participants", getOrderParticipantsHandler).Methods("GET", "OPTIONS")
+
+	transferOrderHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.TransferOrder))
+	router.Handle("/orders/{id:[0-9]+}/transfer", transferOrderHandler).Methods("POST", "OPTIONS")
+
+	mergeOrdersHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.MergeOrders))
+	router.Handle("/orders/{id:[0-9]+}/merge", mergeOrdersHandler).Methods("POST", "OPTIONS")
+
+	getOrderAuditLogHandler := middlewares.Authorize(models.Cashier)(http.HandlerFunc(c.GetOrderAuditLog))
+	router.Handle("/orders/{id:[0-9]+}/audit", getOrderAuditLogHandler).Methods("GET", "OPTIONS")
 }
 
 func RegisterItemRoutes(router *mux.Router) {
@@ -122,6 +412,24 @@ func RegisterItemRoutes(router *mux.Router) {
 	router.Handle("/items/{id:[0-9]+}", editItemHandler).Methods("PUT", "OPTIONS")
 }
 
+func RegisterPricingRoutes(router *mux.Router) {
+	c := controllers.CreatePricingController()
+	createPriceScheduleHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.CreatePriceScheduleHandler))
+	router.Handle("/items/{id:[0-9]+}/prices", createPriceScheduleHandler).Methods("POST", "OPTIONS")
+
+	getPriceSchedulesHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.GetPriceSchedulesHandler))
+	router.Handle("/items/{id:[0-9]+}/prices", getPriceSchedulesHandler).Methods("GET", "OPTIONS")
+
+	createDiscountHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.CreateDiscountHandler))
+	router.Handle("/discounts", createDiscountHandler).Methods("POST", "OPTIONS")
+
+	getDiscountsHandler := middlewares.Authorize(models.Customer)(http.HandlerFunc(c.GetDiscountsHandler))
+	router.Handle("/discounts", getDiscountsHandler).Methods("GET", "OPTIONS")
+
+	editDiscountHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(c.EditDiscountHandler))
+	router.Handle("/discounts/{id:[0-9]+}", editDiscountHandler).Methods("PUT", "OPTIONS")
+}
+
 func RegisterRequestRoutes(router *mux.Router) {
 	c := controllers.CreateRequestController()
 	router.HandleFunc("/requests", c.CreateRequestHandler).Methods("POST", "OPTIONS")
@@ -169,4 +477,8 @@ func RegisterUserRoutes(router *mux.Router) {
 
 	getUsersHandler := middlewares.Authorize(models.Admin)(http.HandlerFunc(uc.GetUsersHandler))
 	router.Handle("/users", getUsersHandler).Methods("GET", "OPTIONS")
+
+	lc := controllers.CreateLoyaltyController()
+	getLoyaltyHandler := middlewares.Authorize(models.Any)(http.HandlerFunc(lc.GetLoyaltyHandler))
+	router.Handle("/users/{id}/loyalty", getLoyaltyHandler).Methods("GET", "OPTIONS")
 }
@@ -2,9 +2,13 @@ package config
 
 import (
 	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
 	"github.com/joho/godotenv"
 	"go-simpler.org/env"
+	"net/url"
+	"sort"
 	"strings"
+	"time"
 )
 
 var Config AppConfig
@@ -13,6 +17,17 @@ type AppConfig struct {
 	JwtSecret     string `env:"JWT_SECRET"`
 	ServerAddress string `env:"SERVER_ADDRESS"`
 	DB            DBConfig
+	Billing       BillingConfig
+	Payments      PaymentsConfig
+	Idempotency   IdempotencyConfig
+	Restaurant    RestaurantConfig
+	Printers      PrintersConfig
+	Loyalty       LoyaltyConfig
+	Tables        TablesConfig
+	Guests        GuestsConfig
+	Reservations  ReservationsConfig
+	Waitlist      WaitlistConfig
+	Kitchen       KitchenConfig
 }
 
 type DBConfig struct {
@@ -30,6 +45,170 @@ type DefaultUserConfig struct {
 	Email    string `env:"DEFAULT_USER_EMAIL"`
 }
 
+type BillingConfig struct {
+	Currency               string        `env:"CURRENCY" default:"USD"`
+	ServiceChargePercent   money.Percent `env:"SERVICE_CHARGE_PERCENT" default:"0"`
+	ServiceChargeMinGuests int           `env:"SERVICE_CHARGE_MIN_GUESTS" default:"6"`
+	// DeliveryFee is added to delivery orders when they are created.
+	DeliveryFee money.Money `env:"DELIVERY_FEE" default:"0"`
+}
+
+type PaymentsConfig struct {
+	Provider  string        `env:"PAYMENT_PROVIDER" default:"mock"`
+	MockDelay time.Duration `env:"MOCK_PROVIDER_DELAY" default:"2s"`
+	// WebhookSecret signs inbound provider webhooks; webhooks are rejected while it is empty.
+	WebhookSecret    string        `env:"PAYMENT_WEBHOOK_SECRET"`
+	WebhookTolerance time.Duration `env:"PAYMENT_WEBHOOK_TOLERANCE" default:"5m"`
+}
+
+// RestaurantConfig is printed in the header and footer of receipts.
+type RestaurantConfig struct {
+	Name          string `env:"RESTAURANT_NAME" default:"MVC Restaurant"`
+	Address       string `env:"RESTAURANT_ADDRESS"`
+	Phone         string `env:"RESTAURANT_PHONE"`
+	TaxID         string `env:"RESTAURANT_TAX_ID"`
+	ReceiptFooter string `env:"RECEIPT_FOOTER" default:"Thank you for dining with us!"`
+}
+
+// TablesConfig sets where the QR codes on the tables lead. A code opens GuestOrderingURL with the
+// table's token in the table query parameter.
+type TablesConfig struct {
+	GuestOrderingURL string `env:"GUEST_ORDERING_URL" default:"http://localhost:5173/order"`
+}
+
+// GuestsConfig limits the sessions walk-in guests start by scanning a table's QR code. Each client
+// may start SessionRateLimit sessions and make RateLimit guest requests per RateWindow.
+type GuestsConfig struct {
+	SessionLifetime  time.Duration `env:"GUEST_SESSION_LIFETIME" default:"3h"`
+	RateLimit        int           `env:"GUEST_RATE_LIMIT" default:"60"`
+	SessionRateLimit int           `env:"GUEST_SESSION_RATE_LIMIT" default:"5"`
+	RateWindow       time.Duration `env:"GUEST_RATE_WINDOW" default:"1m"`
+}
+
+// ReservationsConfig sets how bookings are fitted around each other. A table is held for Duration
+// unless the booking says otherwise, plus TurnTime to clear and reset it before the next party.
+// Parties too big for one table may be seated at up to MaxCombinedTables combinable tables of one
+// section. Availability is offered every SlotInterval from FirstSeating to LastSeating ("15:04").
+// Calendar apps subscribe to the reservations with FeedToken in the feed URL; the feed is off while
+// it is empty.
+type ReservationsConfig struct {
+	Duration          time.Duration `env:"RESERVATION_DURATION" default:"90m"`
+	TurnTime          time.Duration `env:"RESERVATION_TURN_TIME" default:"15m"`
+	MaxCombinedTables int           `env:"RESERVATION_MAX_COMBINED_TABLES" default:"3"`
+	SlotInterval      time.Duration `env:"RESERVATION_SLOT_INTERVAL" default:"15m"`
+	FirstSeating      string        `env:"RESERVATION_FIRST_SEATING" default:"11:00"`
+	LastSeating       string        `env:"RESERVATION_LAST_SEATING" default:"21:30"`
+	FeedToken         string        `env:"RESERVATION_FEED_TOKEN"`
+}
+
+// WaitlistConfig sets how walk-ins waiting for a table are told it is ready, and how far back closed
+// orders are averaged to estimate how long a table is taken.
+type WaitlistConfig struct {
+	Notifier   string        `env:"WAITLIST_NOTIFIER" default:"log"`
+	TurnWindow time.Duration `env:"WAITLIST_TURN_WINDOW" default:"720h"`
+}
+
+// KitchenConfig sets how long before a takeaway or delivery order's promised pickup time the kitchen
+// starts on it, which places it in the kitchen queue among the orders to cook right away. Items of
+// orders scheduled further ahead are held out of the queue until ReleaseLeadTime before the pickup
+// time. Pickup times are offered every SlotInterval from FirstPickup to LastPickup ("15:04"), each
+// taking at most SlotCapacity orders, or any number while it is 0.
+type KitchenConfig struct {
+	PrepTime        time.Duration `env:"KITCHEN_PREP_TIME" default:"20m"`
+	ReleaseLeadTime time.Duration `env:"KITCHEN_RELEASE_LEAD_TIME" default:"45m"`
+	SlotInterval    time.Duration `env:"KITCHEN_SLOT_INTERVAL" default:"15m"`
+	SlotCapacity    int           `env:"KITCHEN_SLOT_CAPACITY" default:"0"`
+	FirstPickup     string        `env:"KITCHEN_FIRST_PICKUP" default:"11:00"`
+	LastPickup      string        `env:"KITCHEN_LAST_PICKUP" default:"21:30"`
+}
+
+// PrintersConfig points at ESC/POS printers as "host[:port]", "tcp://host:port" or "file:///path".
+// A printer left empty is not used.
+type PrintersConfig struct {
+	Kitchen       string        `env:"KITCHEN_PRINTER"`
+	Receipt       string        `env:"RECEIPT_PRINTER"`
+	Width         int           `env:"PRINTER_WIDTH" default:"42"`
+	Timeout       time.Duration `env:"PRINTER_TIMEOUT" default:"5s"`
+	RetryAttempts int           `env:"PRINTER_RETRY_ATTEMPTS" default:"5"`
+	RetryBackoff  time.Duration `env:"PRINTER_RETRY_BACKOFF" default:"2s"`
+}
+
+// LoyaltyConfig sets how registered customers earn and spend points. EarnRate is the number of
+// points per whole unit of currency spent and PointValue what a point is worth when redeemed.
+// Tiers multiply the earn rate once a customer's spend over the trailing TierWindow reaches them.
+type LoyaltyConfig struct {
+	EarnRate   int           `env:"LOYALTY_EARN_RATE" default:"1"`
+	PointValue money.Money   `env:"LOYALTY_POINT_VALUE" default:"0.01"`
+	TierWindow time.Duration `env:"LOYALTY_TIER_WINDOW" default:"8760h"`
+	Tiers      LoyaltyTiers  `env:"LOYALTY_TIERS" default:"silver:500:125,gold:2000:150"`
+}
+
+type LoyaltyTier struct {
+	Name       string        `json:"name"`
+	MinSpend   money.Money   `json:"min_spend"`
+	Multiplier money.Percent `json:"multiplier"`
+} // @name LoyaltyTier
+
+// BaseLoyaltyTier is the tier of customers who have not reached any configured tier.
+var BaseLoyaltyTier = LoyaltyTier{Name: "member", Multiplier: money.OneHundredPercent}
+
+// LoyaltyTiers is read from a comma separated list of "name:min spend:multiplier percent", such as
+// "silver:500:125,gold:2000:150", and kept sorted by minimum spend.
+type LoyaltyTiers []LoyaltyTier
+
+func (t *LoyaltyTiers) UnmarshalText(text []byte) error {
+	var tiers LoyaltyTiers
+	for _, part := range strings.Split(string(text), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		fields := strings.Split(part, ":")
+		if len(fields) != 3 || strings.TrimSpace(fields[0]) == "" {
+			return fmt.Errorf("invalid loyalty tier '%s', expected name:min spend:multiplier", part)
+		}
+		minSpend, err := money.ParseMoney(fields[1])
+		if err != nil || minSpend <= 0 {
+			return fmt.Errorf("invalid minimum spend in loyalty tier '%s'", part)
+		}
+		multiplier, err := money.ParsePercent(fields[2])
+		if err != nil || multiplier <= 0 {
+			return fmt.Errorf("invalid multiplier in loyalty tier '%s'", part)
+		}
+
+		tiers = append(tiers, LoyaltyTier{Name: strings.TrimSpace(fields[0]), MinSpend: minSpend, Multiplier: multiplier})
+	}
+
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinSpend < tiers[j].MinSpend })
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].MinSpend == tiers[i-1].MinSpend {
+			return fmt.Errorf("loyalty tiers '%s' and '%s' have the same minimum spend", tiers[i-1].Name, tiers[i].Name)
+		}
+	}
+
+	*t = tiers
+	return nil
+}
+
+// For returns the tier a customer with the given trailing spend is in and the next tier up, which
+// is nil at the top tier.
+func (t LoyaltyTiers) For(spend money.Money) (LoyaltyTier, *LoyaltyTier) {
+	current := BaseLoyaltyTier
+	for i, tier := range t {
+		if spend < tier.MinSpend {
+			return current, &t[i]
+		}
+		current = tier
+	}
+	return current, nil
+}
+
+type IdempotencyConfig struct {
+	// Window is how long the response to an Idempotency-Key is kept for replay.
+	Window time.Duration `env:"IDEMPOTENCY_WINDOW" default:"24h"`
+}
+
 func LoadConfig() (*AppConfig, error) {
 	err := godotenv.Load()
 	if err != nil {
@@ -43,5 +222,98 @@ func LoadConfig() (*AppConfig, error) {
 		return nil, fmt.Errorf("error building config object: %v", err)
 	}
 
+	Config.Billing.Currency = strings.ToUpper(Config.Billing.Currency)
+	if len(Config.Billing.Currency) != 3 {
+		return nil, fmt.Errorf("CURRENCY must be a three letter ISO 4217 code, got '%s'", Config.Billing.Currency)
+	}
+
+	if Config.Billing.DeliveryFee < 0 {
+		return nil, fmt.Errorf("DELIVERY_FEE cannot be negative, got '%s'", Config.Billing.DeliveryFee)
+	}
+
+	if Config.Idempotency.Window <= 0 {
+		return nil, fmt.Errorf("IDEMPOTENCY_WINDOW must be positive, got '%s'", Config.Idempotency.Window)
+	}
+
+	if Config.Loyalty.EarnRate < 0 {
+		return nil, fmt.Errorf("LOYALTY_EARN_RATE cannot be negative, got %d", Config.Loyalty.EarnRate)
+	}
+
+	if Config.Loyalty.PointValue <= 0 {
+		return nil, fmt.Errorf("LOYALTY_POINT_VALUE must be positive, got '%s'", Config.Loyalty.PointValue)
+	}
+
+	if Config.Loyalty.TierWindow <= 0 {
+		return nil, fmt.Errorf("LOYALTY_TIER_WINDOW must be positive, got '%s'", Config.Loyalty.TierWindow)
+	}
+
+	if guestUrl, err := url.Parse(Config.Tables.GuestOrderingURL); err != nil || !guestUrl.IsAbs() {
+		return nil, fmt.Errorf("GUEST_ORDERING_URL must be an absolute URL, got '%s'", Config.Tables.GuestOrderingURL)
+	}
+
+	if Config.Guests.SessionLifetime <= 0 {
+		return nil, fmt.Errorf("GUEST_SESSION_LIFETIME must be positive, got '%s'", Config.Guests.SessionLifetime)
+	}
+
+	if Config.Guests.RateLimit <= 0 || Config.Guests.SessionRateLimit <= 0 || Config.Guests.RateWindow <= 0 {
+		return nil, fmt.Errorf("GUEST_RATE_LIMIT, GUEST_SESSION_RATE_LIMIT and GUEST_RATE_WINDOW must be positive")
+	}
+
+	if Config.Reservations.Duration <= 0 || Config.Reservations.SlotInterval <= 0 {
+		return nil, fmt.Errorf("RESERVATION_DURATION and RESERVATION_SLOT_INTERVAL must be positive")
+	}
+
+	if Config.Reservations.TurnTime < 0 {
+		return nil, fmt.Errorf("RESERVATION_TURN_TIME cannot be negative, got '%s'", Config.Reservations.TurnTime)
+	}
+
+	if Config.Reservations.MaxCombinedTables < 1 {
+		return nil, fmt.Errorf("RESERVATION_MAX_COMBINED_TABLES must be at least 1, got %d", Config.Reservations.MaxCombinedTables)
+	}
+
+	firstSeating, err := time.Parse("15:04", Config.Reservations.FirstSeating)
+	if err != nil {
+		return nil, fmt.Errorf("RESERVATION_FIRST_SEATING must be a time like 11:00, got '%s'", Config.Reservations.FirstSeating)
+	}
+	lastSeating, err := time.Parse("15:04", Config.Reservations.LastSeating)
+	if err != nil {
+		return nil, fmt.Errorf("RESERVATION_LAST_SEATING must be a time like 21:30, got '%s'", Config.Reservations.LastSeating)
+	}
+	if lastSeating.Before(firstSeating) {
+		return nil, fmt.Errorf("RESERVATION_LAST_SEATING must not be before RESERVATION_FIRST_SEATING")
+	}
+
+	if Config.Waitlist.TurnWindow <= 0 {
+		return nil, fmt.Errorf("WAITLIST_TURN_WINDOW must be positive, got '%s'", Config.Waitlist.TurnWindow)
+	}
+
+	if Config.Kitchen.PrepTime < 0 {
+		return nil, fmt.Errorf("KITCHEN_PREP_TIME cannot be negative, got '%s'", Config.Kitchen.PrepTime)
+	}
+
+	if Config.Kitchen.ReleaseLeadTime < Config.Kitchen.PrepTime {
+		return nil, fmt.Errorf("KITCHEN_RELEASE_LEAD_TIME cannot be shorter than KITCHEN_PREP_TIME, got '%s'", Config.Kitchen.ReleaseLeadTime)
+	}
+
+	if Config.Kitchen.SlotInterval <= 0 {
+		return nil, fmt.Errorf("KITCHEN_SLOT_INTERVAL must be positive, got '%s'", Config.Kitchen.SlotInterval)
+	}
+
+	if Config.Kitchen.SlotCapacity < 0 {
+		return nil, fmt.Errorf("KITCHEN_SLOT_CAPACITY cannot be negative, got %d", Config.Kitchen.SlotCapacity)
+	}
+
+	firstPickup, err := time.Parse("15:04", Config.Kitchen.FirstPickup)
+	if err != nil {
+		return nil, fmt.Errorf("KITCHEN_FIRST_PICKUP must be a time like 11:00, got '%s'", Config.Kitchen.FirstPickup)
+	}
+	lastPickup, err := time.Parse("15:04", Config.Kitchen.LastPickup)
+	if err != nil {
+		return nil, fmt.Errorf("KITCHEN_LAST_PICKUP must be a time like 21:30, got '%s'", Config.Kitchen.LastPickup)
+	}
+	if lastPickup.Before(firstPickup) {
+		return nil, fmt.Errorf("KITCHEN_LAST_PICKUP must not be before KITCHEN_FIRST_PICKUP")
+	}
+
 	return &Config, nil
 }
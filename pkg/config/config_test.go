@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"testing"
+
+	"github.com/gqvz/mvc/pkg/money"
 )
 
 func TestLoadConfig_Success(t *testing.T) {
@@ -62,3 +64,43 @@ func TestLoadConfig_Success(t *testing.T) {
 		t.Errorf("Expected DefaultUser.Email to be 'admin@admin.com', got '%s'", cfg.DB.DefaultUser.Email)
 	}
 }
+
+func TestLoyaltyTiers(t *testing.T) {
+	var tiers LoyaltyTiers
+	if err := tiers.UnmarshalText([]byte("gold:2000:150, silver:500:125")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(tiers) != 2 || tiers[0].Name != "silver" || tiers[1].Name != "gold" {
+		t.Fatalf("Expected tiers sorted by minimum spend, got %+v", tiers)
+	}
+	if tiers[0].MinSpend != money.Money(50000) || tiers[0].Multiplier != money.Percent(125_000) {
+		t.Errorf("Expected silver at 500.00 with 125%%, got %+v", tiers[0])
+	}
+
+	cases := []struct {
+		spend   money.Money
+		current string
+		next    string
+	}{
+		{0, "member", "silver"},
+		{49999, "member", "silver"},
+		{50000, "silver", "gold"},
+		{250000, "gold", ""},
+	}
+	for _, c := range cases {
+		current, next := tiers.For(c.spend)
+		nextName := ""
+		if next != nil {
+			nextName = next.Name
+		}
+		if current.Name != c.current || nextName != c.next {
+			t.Errorf("For(%s) = %s, %s; want %s, %s", c.spend, current.Name, nextName, c.current, c.next)
+		}
+	}
+
+	for _, invalid := range []string{"silver", "silver:0:125", "silver:500:0", ":500:125", "a:500:110,b:500:120"} {
+		if err := tiers.UnmarshalText([]byte(invalid)); err == nil {
+			t.Errorf("Expected '%s' to be rejected", invalid)
+		}
+	}
+}
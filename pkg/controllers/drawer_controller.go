@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DrawerController struct{}
+
+func CreateDrawerController() *DrawerController {
+	return &DrawerController{}
+}
+
+type OpenDrawerSessionRequest struct {
+	OpeningFloat money.Money `json:"opening_float" example:"150.00"`
+} // @name OpenDrawerSessionRequest
+
+type CloseDrawerSessionRequest struct {
+	CountedCash money.Money `json:"counted_cash" example:"412.35"`
+	Notes       string      `json:"notes" example:"Two coins short"`
+} // @name CloseDrawerSessionRequest
+
+type GetDrawerSessionResponse = models.DrawerSession // @name GetDrawerSessionResponse
+
+type GetZReportResponse = models.ZReport // @name GetZReportResponse
+
+// @Summary Open a drawer session
+// @ID openDrawerSession
+// @Description Start a cash drawer session with the float already in the drawer. Cash payments and refunds taken by the
+// @Description cashier are recorded against it until it is closed. A cashier can only have one open session.
+// @Tags drawers
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param request body OpenDrawerSessionRequest true "Opening float"
+// @Success 201 {object} GetDrawerSessionResponse "Drawer session opened"
+// @Failure 400 {object} string "Bad request, invalid float"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 409 {object} string "You already have an open drawer session"
+// @Failure 500 {object} string "Internal server error"
+// @Router /drawers [post]
+func (c *DrawerController) OpenDrawerSessionHandler(w http.ResponseWriter, r *http.Request) {
+	var req OpenDrawerSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.OpeningFloat < 0 {
+		http.Error(w, "Opening float cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := models.OpenDrawerSession(userId, req.OpeningFloat, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "already has an open") {
+			http.Error(w, "You already have an open drawer session", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to open drawer session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(session); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get drawer sessions
+// @ID getDrawerSessions
+// @Description Get drawer sessions, newest first. Cashiers see their own sessions; admins see everyone's.
+// @Tags drawers
+// @Produce json
+// @Security jwt
+// @Param cashier_id query int false "Cashier ID (admins only)"
+// @Param open query bool false "Only open sessions"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} GetDrawerSessionResponse "List of drawer sessions"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 500 {object} string "Internal server error"
+// @Router /drawers [get]
+func (c *DrawerController) GetDrawerSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+	cashierIdStr := r.URL.Query().Get("cashier_id")
+
+	var cashierId int64
+	if cashierIdStr != "" {
+		var err error
+		cashierId, err = strconv.ParseInt(cashierIdStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid cashier ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := 10
+	if limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 20 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	role := models.Role(r.Context().Value("role").(byte))
+	if !role.HasFlag(models.Admin) {
+		cashierId = userId
+	}
+
+	sessions, err := models.GetDrawerSessions(cashierId, r.URL.Query().Get("open") == "true", limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve drawer sessions", http.StatusInternalServerError)
+		return
+	}
+
+	if len(sessions) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sessions); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get a drawer session
+// @ID getDrawerSession
+// @Description Get a drawer session. Closed sessions show the expected and counted cash and their variance.
+// @Tags drawers
+// @Produce json
+// @Security jwt
+// @Param id path int true "Drawer session ID"
+// @Success 200 {object} GetDrawerSessionResponse "Drawer session"
+// @Failure 400 {object} string "Bad request, invalid drawer session ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 404 {object} string "Drawer session not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /drawers/{id} [get]
+func (c *DrawerController) GetDrawerSessionHandler(w http.ResponseWriter, r *http.Request) {
+	session, status, errMessage := drawerSessionFromRequest(r)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(session); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Close a drawer session
+// @ID closeDrawerSession
+// @Description End a drawer session with the cash counted in the drawer. The expected cash is the opening float plus
+// @Description cash payments minus cash refunds of the session; the variance is counted minus expected, so a negative
+// @Description variance means cash is missing. Admins can close any cashier's session.
+// @Tags drawers
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Drawer session ID"
+// @Param request body CloseDrawerSessionRequest true "Counted cash"
+// @Success 200 {object} GetDrawerSessionResponse "Drawer session closed"
+// @Failure 400 {object} string "Bad request, invalid counted cash"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 404 {object} string "Drawer session not found"
+// @Failure 409 {object} string "Drawer session is already closed"
+// @Failure 500 {object} string "Internal server error"
+// @Router /drawers/{id}/close [post]
+func (c *DrawerController) CloseDrawerSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid drawer session ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CloseDrawerSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.CountedCash < 0 {
+		http.Error(w, "Counted cash cannot be negative", http.StatusBadRequest)
+		return
+	}
+	req.Notes = strings.TrimSpace(req.Notes)
+	if len(req.Notes) > 255 {
+		http.Error(w, "Notes are too long", http.StatusBadRequest)
+		return
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	role := models.Role(r.Context().Value("role").(byte))
+	if role.HasFlag(models.Admin) {
+		userId = 0
+	}
+
+	session, err := models.CloseDrawerSession(id, userId, req.CountedCash, req.Notes, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Drawer session not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "already closed") {
+			http.Error(w, "Drawer session is already closed", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to close drawer session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(session); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get drawer session Z-report
+// @ID getDrawerZReport
+// @Description Get the end-of-shift totals of a drawer session broken down by payment method. Refunds are counted on
+// @Description the session that paid them out. For an open session the report shows the takings so far.
+// @Tags drawers
+// @Produce json
+// @Security jwt
+// @Param id path int true "Drawer session ID"
+// @Success 200 {object} GetZReportResponse "Z-report"
+// @Failure 400 {object} string "Bad request, invalid drawer session ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 404 {object} string "Drawer session not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /drawers/{id}/report [get]
+func (c *DrawerController) GetZReportHandler(w http.ResponseWriter, r *http.Request) {
+	session, status, errMessage := drawerSessionFromRequest(r)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	report, err := models.GetZReport(session.ID)
+	if err != nil {
+		http.Error(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+	report.Currency = config.Config.Billing.Currency
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// drawerSessionFromRequest loads the session in the path. Cashiers only see their own sessions.
+func drawerSessionFromRequest(r *http.Request) (*models.DrawerSession, int, string) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, http.StatusBadRequest, "Invalid drawer session ID"
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		return nil, http.StatusUnauthorized, "Unauthorized"
+	}
+
+	session, err := models.GetDrawerSessionById(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, http.StatusNotFound, "Drawer session not found"
+		}
+		return nil, http.StatusInternalServerError, "Failed to retrieve drawer session"
+	}
+
+	role := models.Role(r.Context().Value("role").(byte))
+	if !role.HasFlag(models.Admin) && session.CashierID != userId {
+		return nil, http.StatusNotFound, "Drawer session not found"
+	}
+	return session, http.StatusOK, ""
+}
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type GiftCardController struct{}
+
+func CreateGiftCardController() *GiftCardController {
+	return &GiftCardController{}
+}
+
+type IssueGiftCardRequest struct {
+	Amount     money.Money          `json:"amount" example:"50.00"`
+	Method     models.PaymentMethod `json:"method" example:"cash"`
+	CustomerID int64                `json:"customer_id"`
+} // @name IssueGiftCardRequest
+
+type GetGiftCardResponse = models.GiftCard // @name GetGiftCardResponse
+
+type GetGiftCardTransactionResponse = models.GiftCardTransaction // @name GetGiftCardTransactionResponse
+
+type GetGiftCardBalanceResponse struct {
+	Kind     models.GiftCardKind `json:"kind"`
+	Balance  money.Money         `json:"balance"`
+	Currency string              `json:"currency"`
+} // @name GetGiftCardBalanceResponse
+
+// @Summary Issue a gift card
+// @ID issueGiftCard
+// @Description Sell a gift card loaded with amount, paid for at the till with method. The new card's code is only
+// @Description returned here. Cards sold for cash need an open drawer session and count towards its expected cash.
+// @Tags giftcards
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param request body IssueGiftCardRequest true "Gift card"
+// @Success 201 {object} GetGiftCardResponse "Gift card issued"
+// @Failure 400 {object} string "Bad request, invalid amount or method"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 404 {object} string "Customer not found"
+// @Failure 409 {object} string "No open drawer session"
+// @Failure 500 {object} string "Internal server error"
+// @Router /giftcards [post]
+func (c *GiftCardController) IssueGiftCardHandler(w http.ResponseWriter, r *http.Request) {
+	var req IssueGiftCardRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.Method == "" {
+		req.Method = models.CardPayment
+	}
+	if req.Method != models.CardPayment && req.Method != models.CashPayment && req.Method != models.OtherPayment {
+		http.Error(w, "Method must be one of 'card', 'cash' or 'other'", http.StatusBadRequest)
+		return
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	issue := &models.GiftCardTransaction{
+		Amount:    req.Amount,
+		Method:    req.Method,
+		CreatedBy: userId,
+	}
+
+	session, err := models.GetOpenDrawerSession(userId)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		http.Error(w, "Failed to retrieve drawer session", http.StatusInternalServerError)
+		return
+	}
+	if session != nil {
+		issue.DrawerSessionID = session.ID
+	} else if req.Method == models.CashPayment {
+		http.Error(w, "Open a drawer session before taking cash", http.StatusConflict)
+		return
+	}
+
+	card, err := models.IssueGiftCard(&models.GiftCard{
+		Kind:       models.StandardGiftCard,
+		Currency:   config.Config.Billing.Currency,
+		CustomerID: req.CustomerID,
+		IssuedBy:   userId,
+	}, issue)
+	if err != nil {
+		if strings.Contains(err.Error(), "customer not found") {
+			http.Error(w, "Customer not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to issue gift card", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(card); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get gift card balance
+// @ID getGiftCardBalance
+// @Description Look up the balance of a gift card or store credit by its code. Spaces and dashes in the code are ignored.
+// @Tags giftcards
+// @Produce json
+// @Security jwt
+// @Param code query string true "Gift card code"
+// @Success 200 {object} GetGiftCardBalanceResponse "Gift card balance"
+// @Failure 400 {object} string "Bad request, missing code"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 404 {object} string "Gift card not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /giftcards/balance [get]
+func (c *GiftCardController) GetGiftCardBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	code := models.NormalizeGiftCardCode(r.URL.Query().Get("code"))
+	if code == "" {
+		http.Error(w, "Code is required", http.StatusBadRequest)
+		return
+	}
+
+	card, err := models.GetGiftCardByCode(code)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Gift card not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve gift card", http.StatusInternalServerError)
+		return
+	}
+
+	response := GetGiftCardBalanceResponse{
+		Kind:     card.Kind,
+		Balance:  card.Balance,
+		Currency: card.Currency,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get gift cards
+// @ID getGiftCards
+// @Description Get gift cards and store credit, newest first
+// @Tags giftcards
+// @Produce json
+// @Security jwt
+// @Param customer_id query int false "Customer ID"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} GetGiftCardResponse "List of gift cards"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 500 {object} string "Internal server error"
+// @Router /giftcards [get]
+func (c *GiftCardController) GetGiftCardsHandler(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+	customerIdStr := r.URL.Query().Get("customer_id")
+
+	var customerId int64
+	if customerIdStr != "" {
+		var err error
+		customerId, err = strconv.ParseInt(customerIdStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid customer ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := 10
+	if limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 20 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	cards, err := models.GetGiftCards(customerId, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve gift cards", http.StatusInternalServerError)
+		return
+	}
+
+	if len(cards) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(cards); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get a gift card
+// @ID getGiftCard
+// @Description Get a gift card or store credit by ID
+// @Tags giftcards
+// @Produce json
+// @Security jwt
+// @Param id path int true "Gift card ID"
+// @Success 200 {object} GetGiftCardResponse "Gift card"
+// @Failure 400 {object} string "Bad request, invalid gift card ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 404 {object} string "Gift card not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /giftcards/{id} [get]
+func (c *GiftCardController) GetGiftCardHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid gift card ID", http.StatusBadRequest)
+		return
+	}
+
+	card, err := models.GetGiftCardById(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Gift card not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve gift card", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(card); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get gift card transactions
+// @ID getGiftCardTransactions
+// @Description Get the ledger of a gift card: every credit and debit with the balance after it, oldest first
+// @Tags giftcards
+// @Produce json
+// @Security jwt
+// @Param id path int true "Gift card ID"
+// @Success 200 {array} GetGiftCardTransactionResponse "Gift card ledger"
+// @Failure 400 {object} string "Bad request, invalid gift card ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not a cashier"
+// @Failure 404 {object} string "Gift card not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /giftcards/{id}/transactions [get]
+func (c *GiftCardController) GetGiftCardTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid gift card ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := models.GetGiftCardById(id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Gift card not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve gift card", http.StatusInternalServerError)
+		return
+	}
+
+	transactions, err := models.GetGiftCardTransactions(id)
+	if err != nil {
+		http.Error(w, "Failed to retrieve gift card transactions", http.StatusInternalServerError)
+		return
+	}
+
+	if len(transactions) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(transactions); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
@@ -0,0 +1,407 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/printer"
+	"golang.org/x/crypto/bcrypt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxGuestQuantity caps how many of an item a guest orders at once; larger orders go through staff.
+const maxGuestQuantity = 20
+
+type GuestController struct {
+	spooler *printer.Spooler
+}
+
+func CreateGuestController(spooler *printer.Spooler) *GuestController {
+	return &GuestController{spooler: spooler}
+}
+
+type StartGuestSessionRequest struct {
+	TableToken string `json:"table_token" example:"0123456789abcdef0123456789abcdef"`
+} // @name StartGuestSessionRequest
+
+type StartGuestSessionResponse struct {
+	Token       string    `json:"token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	TableNumber int       `json:"table_number"`
+} // @name StartGuestSessionResponse
+
+// @Summary Start a guest session
+// @ID startGuestSession
+// @Description Start an anonymous session at the table whose QR code was scanned. The token works on the guest
+// @Description endpoints only, for the table it was issued for, until the session expires.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Param request body StartGuestSessionRequest true "Token from the table's QR code"
+// @Success 201 {object} StartGuestSessionResponse "Guest session"
+// @Failure 400 {object} string "Bad request, missing table token"
+// @Failure 404 {object} string "Table not found"
+// @Failure 429 {object} string "Too many sessions started from this address"
+// @Failure 500 {object} string "Internal server error"
+// @Router /guest/sessions [post]
+func (c *GuestController) StartGuestSessionHandler(w http.ResponseWriter, r *http.Request) {
+	var req StartGuestSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.TableToken = strings.TrimSpace(req.TableToken)
+	if req.TableToken == "" {
+		http.Error(w, "Table token is required", http.StatusBadRequest)
+		return
+	}
+
+	table, err := models.GetTableByToken(req.TableToken)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve table", http.StatusInternalServerError)
+		return
+	}
+
+	session, err := models.StartGuestSession(table.ID, time.Now(), config.Config.Guests.SessionLifetime)
+	if err != nil {
+		http.Error(w, "Failed to start guest session", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := signToken(session.UserID, models.Guest, session.ExpiresAt)
+	if err != nil {
+		http.Error(w, "Error signing token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	response := StartGuestSessionResponse{
+		Token:       token,
+		ExpiresAt:   session.ExpiresAt,
+		TableNumber: table.Number,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetGuestSessionResponse struct {
+	Session     models.GuestSession `json:"session"`
+	TableNumber int                 `json:"table_number"`
+	Order       *models.Order       `json:"order,omitempty"`
+	Items       []models.OrderItem  `json:"items"`
+} // @name GetGuestSessionResponse
+
+// @Summary Get guest session
+// @ID getGuestSession
+// @Description Get the guest's session with the open order at their table and what has been ordered on it
+// @Tags guests
+// @Produce json
+// @Security jwt
+// @Success 200 {object} GetGuestSessionResponse "Guest session"
+// @Failure 401 {object} string "Unauthorized, the guest session has ended"
+// @Failure 403 {object} string "Forbidden, not a guest"
+// @Failure 429 {object} string "Too many requests"
+// @Failure 500 {object} string "Internal server error"
+// @Router /guest/session [get]
+func (c *GuestController) GetGuestSessionHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := guestSession(w, r)
+	if !ok {
+		return
+	}
+
+	table, err := models.GetTableById(session.TableID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve table", http.StatusInternalServerError)
+		return
+	}
+
+	response := GetGuestSessionResponse{
+		Session:     *session,
+		TableNumber: table.Number,
+		Items:       []models.OrderItem{},
+	}
+	order, err := models.GetOpenTableOrder(session.TableID)
+	if err != nil && !strings.Contains(err.Error(), "not found") {
+		http.Error(w, "Failed to retrieve order", http.StatusInternalServerError)
+		return
+	}
+	if order != nil {
+		orderItems, err := models.GetItemsByOrderId(order.ID, 0)
+		if err != nil {
+			http.Error(w, "Failed to retrieve order items", http.StatusInternalServerError)
+			return
+		}
+		response.Order = order
+		if len(*orderItems) > 0 {
+			response.Items = *orderItems
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Order as a guest
+// @ID createGuestOrderItem
+// @Description Add an item to the open order at the guest's table, opening one if the table has none. The guest
+// @Description joins the order and the item is attributed to them.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param request body CreateOrderItemRequest true "Create Order Item Request"
+// @Param Idempotency-Key header string false "Replays the first response when the same request is retried with this key"
+// @Success 201 {object} CreateOrderItemResponse
+// @Failure 400 {object} string "Bad request, invalid item or quantity"
+// @Failure 401 {object} string "Unauthorized, the guest session has ended"
+// @Failure 403 {object} string "Forbidden, not a guest"
+// @Failure 404 {object} string "Item not found"
+// @Failure 409 {object} string "Conflict, the table needs cleaning or the order was just closed"
+// @Failure 422 {object} string "Idempotency-Key was already used for a different request"
+// @Failure 429 {object} string "Too many requests"
+// @Failure 500 {object} string "Internal server error"
+// @Router /guest/order/items [post]
+func (c *GuestController) CreateGuestOrderItemHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := guestSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateOrderItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ItemID <= 0 || req.Quantity <= 0 || req.Quantity > maxGuestQuantity {
+		http.Error(w, "Item ID is required and quantity must be between 1 and 20", http.StatusBadRequest)
+		return
+	}
+
+	item, err := models.GetItemById(req.ItemID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Item not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve item", http.StatusInternalServerError)
+		}
+		return
+	}
+	if !item.Available {
+		http.Error(w, "Item is not available", http.StatusBadRequest)
+		return
+	}
+
+	prices, err := models.GetItemPrices([]models.Item{*item}, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to resolve item price", http.StatusInternalServerError)
+		return
+	}
+
+	order, err := guestTableOrder(session)
+	if err != nil {
+		if strings.Contains(err.Error(), "needs cleaning") {
+			http.Error(w, "The table is being cleaned, please ask a member of staff", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "order is closed") {
+			http.Error(w, "The order at the table was just closed, please try again", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to open an order at the table", http.StatusInternalServerError)
+		return
+	}
+
+	orderItem, err := models.CreateOrderItem(order.ID, session.UserID, req.ItemID, req.Quantity, prices[item.ID].EffectivePrice, req.CustomInstructions)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "The order at the table was just closed, please try again", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create order item", http.StatusInternalServerError)
+		return
+	}
+
+	printKitchenTicket(c.spooler, orderItem, item)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(CreateOrderItemResponse{OrderItemID: orderItem.ID}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Request the bill as a guest
+// @ID requestGuestBill
+// @Description Ask for the bill of the open order at the guest's table. The floor plan shows the request to
+// @Description staff; the response is what is left to pay.
+// @Tags guests
+// @Produce json
+// @Security jwt
+// @Success 200 {object} GetOrderBalanceResponse "Order balance"
+// @Failure 401 {object} string "Unauthorized, the guest session has ended"
+// @Failure 403 {object} string "Forbidden, not a guest"
+// @Failure 404 {object} string "No open order at the table"
+// @Failure 429 {object} string "Too many requests"
+// @Failure 500 {object} string "Internal server error"
+// @Router /guest/order/bill [post]
+func (c *GuestController) RequestGuestBillHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := guestSession(w, r)
+	if !ok {
+		return
+	}
+
+	order, err := models.GetOpenTableOrder(session.TableID)
+	if err == nil {
+		err = models.RequestBill(order.ID, time.Now())
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "There is no open order at the table", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to request the bill", http.StatusInternalServerError)
+		return
+	}
+
+	balance, err := orderBalance(order)
+	if err != nil {
+		http.Error(w, "Failed to retrieve order balance", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(balance); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Convert a guest into a customer
+// @ID convertGuestSession
+// @Description Register the guest as a customer. Orders placed during the session stay with the new account and
+// @Description the guest token stops working; use the returned token from now on.
+// @Tags guests
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param user body CreateUserRequest true "User information"
+// @Success 200 {object} CreateTokenResponse "Customer token"
+// @Failure 400 {object} string "Bad request, missing or invalid parameters"
+// @Failure 401 {object} string "Unauthorized, the guest session has ended"
+// @Failure 403 {object} string "Forbidden, not a guest"
+// @Failure 409 {object} string "Conflict, user with the same email or username already exists"
+// @Failure 429 {object} string "Too many requests"
+// @Failure 500 {object} string "Internal server error"
+// @Router /guest/session/convert [post]
+func (c *GuestController) ConvertGuestSessionHandler(w http.ResponseWriter, r *http.Request) {
+	session, ok := guestSession(w, r)
+	if !ok {
+		return
+	}
+
+	var req CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.Password == "" || req.Email == "" {
+		http.Error(w, "Missing required fields: name, password, or email", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByEmailOrUsername(req.Email, req.Name)
+	if err != nil {
+		http.Error(w, "Error checking for existing user", http.StatusInternalServerError)
+		return
+	}
+	if user != nil {
+		http.Error(w, "User with the same email or username already exists", http.StatusConflict)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Error hashing password", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.ConvertGuestSession(session.UserID, req.Name, req.Email, string(hashedPassword), time.Now()); err != nil {
+		if strings.Contains(err.Error(), "has ended") || strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Guest session has ended", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Failed to convert guest session", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := signToken(session.UserID, models.Customer, time.Now().Add(2*time.Hour))
+	if err != nil {
+		http.Error(w, "Error signing token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(CreateTokenResponse{Token: token}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// guestSession loads the live session of the guest making the request. Guest tokens outlive
+// conversion in the token cache, so every guest request checks the session is still going.
+func guestSession(w http.ResponseWriter, r *http.Request) (*models.GuestSession, bool) {
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	session, err := models.GetGuestSession(userId, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "has ended") || strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Guest session has ended", http.StatusUnauthorized)
+			return nil, false
+		}
+		http.Error(w, "Failed to retrieve guest session", http.StatusInternalServerError)
+		return nil, false
+	}
+	return session, true
+}
+
+// guestTableOrder returns the open order at the guest's table with the guest as a participant,
+// opening one for the guest when the table has none. Two guests opening an order at once end up on
+// the same one.
+func guestTableOrder(session *models.GuestSession) (*models.Order, error) {
+	order, err := models.GetOpenTableOrder(session.TableID)
+	if err != nil && strings.Contains(err.Error(), "not found") {
+		order, err = models.CreateOrder(session.UserID, session.TableID, 1)
+		if err != nil && strings.Contains(err.Error(), "already exists") {
+			order, err = models.GetOpenTableOrder(session.TableID)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := models.JoinOrder(order.ID, session.UserID, time.Now()); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"github.com/gorilla/mux"
 	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
 	"github.com/gqvz/mvc/pkg/services"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type ItemController struct {
@@ -19,12 +21,13 @@ func CreateItemController() *ItemController {
 }
 
 type CreateItemRequest struct {
-	Name        string   `json:"name" example:"real"`
-	Price       float64  `json:"price" example:"69.69"`
-	Description string   `json:"description" example:"real"`
-	ImageURL    string   `json:"image_url" example:"https://http.cat/404"`
-	Tags        []string `json:"tags" example:"real,tag"`
-	Available   bool     `json:"available" example:"true"`
+	Name          string      `json:"name" example:"real"`
+	Price         money.Money `json:"price" example:"69.69"`
+	Description   string      `json:"description" example:"real"`
+	ImageURL      string      `json:"image_url" example:"https://http.cat/404"`
+	Tags          []string    `json:"tags" example:"real,tag"`
+	Available     bool        `json:"available" example:"true"`
+	TaxCategoryID int64       `json:"tax_category_id" example:"0"`
 } // @name CreateItemRequest
 
 type CreateItemResponse struct {
@@ -79,7 +82,7 @@ func (c *ItemController) CreateItemHandler(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	item, err := models.CreateItem(r.Context(), req.Name, req.Description, req.Price, tags, req.ImageURL, req.Available)
+	item, err := models.CreateItem(r.Context(), req.Name, req.Description, req.Price, tags, req.ImageURL, req.Available, req.TaxCategoryID)
 	if err != nil {
 		if strings.Contains(err.Error(), "exists") {
 			http.Error(w, "Item with this name already exists", http.StatusConflict)
@@ -100,13 +103,15 @@ func (c *ItemController) CreateItemHandler(w http.ResponseWriter, r *http.Reques
 }
 
 type GetItemResponse struct {
-	ID          int64        `json:"id"`
-	Name        string       `json:"name"`
-	Description string       `json:"description"`
-	Price       float64      `json:"price"`
-	Tags        []models.Tag `json:"tags"`
-	ImageURL    string       `json:"image_url"`
-	Available   bool         `json:"available"`
+	ID             int64        `json:"id"`
+	Name           string       `json:"name"`
+	Description    string       `json:"description"`
+	Price          money.Money  `json:"price"`
+	EffectivePrice money.Money  `json:"effective_price"`
+	Tags           []models.Tag `json:"tags"`
+	ImageURL       string       `json:"image_url"`
+	Available      bool         `json:"available"`
+	TaxCategoryID  int64        `json:"tax_category_id"`
 } // @name GetItemResponse
 
 // @Summary Get item by ID
@@ -149,14 +154,23 @@ func (c *ItemController) GetItemHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	prices, err := models.GetItemPrices([]models.Item{*item}, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to get item price", http.StatusInternalServerError)
+		log.Printf("Error resolving item price: %v", err)
+		return
+	}
+
 	response := GetItemResponse{
-		ID:          item.ID,
-		Name:        item.Name,
-		Description: item.Description,
-		Price:       item.Price,
-		Tags:        item.Tags,
-		ImageURL:    item.ImageURL,
-		Available:   item.Available,
+		ID:             item.ID,
+		Name:           item.Name,
+		Description:    item.Description,
+		Price:          prices[item.ID].ListPrice,
+		EffectivePrice: prices[item.ID].EffectivePrice,
+		Tags:           item.Tags,
+		ImageURL:       item.ImageURL,
+		Available:      item.Available,
+		TaxCategoryID:  item.TaxCategoryID,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -260,16 +274,25 @@ func (c *ItemController) GetItemsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	prices, err := models.GetItemPrices(items, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to get item prices", http.StatusInternalServerError)
+		log.Printf("Error resolving item prices: %v", err)
+		return
+	}
+
 	responseItems := make([]GetItemResponse, len(items))
 	for i, item := range items {
 		responseItems[i] = GetItemResponse{
-			ID:          item.ID,
-			Name:        item.Name,
-			Description: item.Description,
-			Price:       item.Price,
-			Tags:        item.Tags,
-			ImageURL:    item.ImageURL,
-			Available:   item.Available,
+			ID:             item.ID,
+			Name:           item.Name,
+			Description:    item.Description,
+			Price:          prices[item.ID].ListPrice,
+			EffectivePrice: prices[item.ID].EffectivePrice,
+			Tags:           item.Tags,
+			ImageURL:       item.ImageURL,
+			Available:      item.Available,
+			TaxCategoryID:  item.TaxCategoryID,
 		}
 	}
 
@@ -351,7 +374,7 @@ func (c *ItemController) EditItemHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	item, err := models.EditItem(r.Context(), id, req.Name, req.Description, req.Price, tags, req.ImageURL, req.Available)
+	item, err := models.EditItem(r.Context(), id, req.Name, req.Description, req.Price, tags, req.ImageURL, req.Available, req.TaxCategoryID)
 	if err != nil {
 		http.Error(w, "Failed to edit item", http.StatusInternalServerError)
 		log.Printf("Error editing item: %v", err)
@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type LoyaltyController struct{}
+
+func CreateLoyaltyController() *LoyaltyController {
+	return &LoyaltyController{}
+}
+
+type GetLoyaltyResponse struct {
+	UserID          int64                       `json:"user_id"`
+	Points          int64                       `json:"points"`
+	Value           money.Money                 `json:"value"`
+	EarnRate        int                         `json:"earn_rate"`
+	Tier            config.LoyaltyTier          `json:"tier"`
+	TrailingSpend   money.Money                 `json:"trailing_spend"`
+	NextTier        *config.LoyaltyTier         `json:"next_tier,omitempty"`
+	SpendToNextTier money.Money                 `json:"spend_to_next_tier"`
+	Transactions    []models.LoyaltyTransaction `json:"transactions"`
+} // @name GetLoyaltyResponse
+
+// @Summary Get loyalty account
+// @ID getLoyalty
+// @Description Get a customer's loyalty points, what they are worth, the tier their spend over the trailing tier
+// @Description window puts them in and their points ledger, newest first. Customers can only see their own account.
+// @Tags users
+// @Produce json
+// @Security jwt
+// @Param id path int true "User ID"
+// @Param limit query int false "Number of ledger entries"
+// @Param offset query int false "Offset into the ledger"
+// @Success 200 {object} GetLoyaltyResponse "Loyalty account"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view this account"
+// @Failure 404 {object} string "User not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /users/{id}/loyalty [get]
+func (c *LoyaltyController) GetLoyaltyHandler(w http.ResponseWriter, r *http.Request) {
+	userId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	currentUserId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	role := models.Role(r.Context().Value("role").(byte))
+	if userId != currentUserId && !role.HasFlag(models.Admin) {
+		http.Error(w, "You are not allowed to view this loyalty account", http.StatusForbidden)
+		return
+	}
+
+	limit := 10
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 20 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	points, err := models.GetLoyaltyPoints(userId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve loyalty points", http.StatusInternalServerError)
+		return
+	}
+
+	program := config.Config.Loyalty
+	spend, err := models.GetTrailingSpend(userId, time.Now().Add(-program.TierWindow))
+	if err != nil {
+		http.Error(w, "Failed to retrieve trailing spend", http.StatusInternalServerError)
+		return
+	}
+
+	transactions, err := models.GetLoyaltyTransactions(userId, limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve loyalty transactions", http.StatusInternalServerError)
+		return
+	}
+	if transactions == nil {
+		transactions = []models.LoyaltyTransaction{}
+	}
+
+	tier, next := program.Tiers.For(spend)
+	response := GetLoyaltyResponse{
+		UserID:        userId,
+		Points:        points,
+		Value:         program.PointValue * money.Money(points),
+		EarnRate:      program.EarnRate,
+		Tier:          tier,
+		TrailingSpend: spend,
+		NextTier:      next,
+		Transactions:  transactions,
+	}
+	if next != nil {
+		response.SpendToNextTier = next.MinSpend - spend
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
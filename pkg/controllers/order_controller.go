@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"github.com/gorilla/mux"
 	"github.com/gqvz/mvc/pkg/models"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -18,7 +19,14 @@ func CreateOrderController() *OrderController {
 }
 
 type CreateOrderRequest struct {
-	TableNumber int `json:"table_number"`
+	// Order type, dine_in when left out
+	Type        models.OrderType `json:"type" example:"dine_in"`
+	TableID     int64            `json:"table_id" example:"1"`
+	TableNumber int              `json:"table_number" example:"0"`
+	Guests      int              `json:"guests" example:"2"`
+	// When a takeaway or delivery order was promised to be ready, as soon as possible when left out
+	PickupAt        *time.Time `json:"pickup_at" example:"2025-09-12T18:30:00Z"`
+	DeliveryAddress string     `json:"delivery_address" example:"1 High Street, Springfield"`
 } // @name CreateOrderRequest
 
 type CreateOrderResponse struct {
@@ -27,17 +35,26 @@ type CreateOrderResponse struct {
 
 // @Summary Create a new order
 // @ID createOrder
-// @Description Create a new order
+// @Description Create a new order. Dine-in orders are seated at a table, given by table_id or table_number, which
+// @Description becomes occupied until the order is closed, after which it needs cleaning before it seats the next order.
+// @Description Takeaway and delivery orders have no table; they may be promised for a pickup_at time, which the kitchen
+// @Description queue works towards, and delivery orders need a delivery_address and carry the configured delivery fee.
+// @Description A pickup_at time has to fall within pickup hours, in a slot that is not full and that the kitchen can
+// @Description still prepare the order for (see /orders/slots). Items
+// @Description of orders scheduled further ahead are held out of the kitchen queue until shortly before pickup.
 // @Tags orders
 // @Accept json
 // @Produce json
 // @Security jwt
 // @Param request body CreateOrderRequest true "Create Order Request"
+// @Param Idempotency-Key header string false "Replays the first response when the same request is retried with this key"
 // @Success 200 {object} CreateOrderResponse
 // @Failure 400 {object} string "Bad Request"
 // @Failure 401 {object} string "Unauthorized"
 // @Failure 403 {object} string "Forbidden"
-// @Failure 409 {object} string "Conflict"
+// @Failure 404 {object} string "Table not found"
+// @Failure 409 {object} string "Conflict, the table has an open order or needs cleaning, or the pickup slot is full"
+// @Failure 422 {object} string "Idempotency-Key was already used for a different request"
 // @Failure 500 {object} string "Internal Server Error"
 // @Router /orders [post]
 func (c *OrderController) CreateOrder(w http.ResponseWriter, r *http.Request) {
@@ -47,15 +64,94 @@ func (c *OrderController) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.TableNumber <= 0 || req.TableNumber > 100 {
-		http.Error(w, "Table number must be greater than 0 and less than 100", http.StatusBadRequest)
+	if req.Guests < 0 {
+		http.Error(w, "Guests cannot be negative", http.StatusBadRequest)
 		return
 	}
+	if req.Guests == 0 {
+		req.Guests = 1
+	}
 
 	userId := r.Context().Value("userid").(int64)
-	order, err := models.CreateOrder(userId, req.TableNumber)
+	switch req.Type {
+	case "", models.DineIn:
+	case models.Takeaway, models.Delivery:
+		c.createTakeawayOrder(w, &req, userId)
+		return
+	default:
+		http.Error(w, "Type must be dine_in, takeaway or delivery", http.StatusBadRequest)
+		return
+	}
+
+	if req.TableID < 0 || req.TableNumber < 0 || (req.TableID == 0) == (req.TableNumber == 0) {
+		http.Error(w, "Either a table ID or a table number is required", http.StatusBadRequest)
+		return
+	}
+	if req.PickupAt != nil || strings.TrimSpace(req.DeliveryAddress) != "" {
+		http.Error(w, "Pickup times and delivery addresses are only for takeaway and delivery orders", http.StatusBadRequest)
+		return
+	}
+
+	if req.TableID == 0 {
+		table, err := models.GetTableByNumber(req.TableNumber)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "Table not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to retrieve table", http.StatusInternalServerError)
+			return
+		}
+		req.TableID = table.ID
+	}
+
+	order, err := models.CreateOrder(userId, req.TableID, req.Guests)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Table not found", http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "needs cleaning") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to create order", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := CreateOrderResponse{
+		OrderID: order.ID,
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func (c *OrderController) createTakeawayOrder(w http.ResponseWriter, req *CreateOrderRequest, userId int64) {
+	req.DeliveryAddress = strings.TrimSpace(req.DeliveryAddress)
+	if req.TableID != 0 || req.TableNumber != 0 {
+		http.Error(w, "Takeaway and delivery orders are not seated at a table", http.StatusBadRequest)
+		return
+	}
+	if req.PickupAt != nil && req.PickupAt.Before(time.Now()) {
+		http.Error(w, "Pickup time cannot be in the past", http.StatusBadRequest)
+		return
+	}
+	if req.Type == models.Delivery && (req.DeliveryAddress == "" || len(req.DeliveryAddress) > 512) {
+		http.Error(w, "Delivery address is required and cannot be longer than 512 characters", http.StatusBadRequest)
+		return
+	}
+	if req.Type == models.Takeaway && req.DeliveryAddress != "" {
+		http.Error(w, "Only delivery orders have a delivery address", http.StatusBadRequest)
+		return
+	}
+
+	order, err := models.CreateTakeawayOrder(userId, req.Type, req.Guests, req.PickupAt, req.DeliveryAddress)
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
+		if strings.Contains(err.Error(), "outside pickup hours") || strings.Contains(err.Error(), "too soon") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else if strings.Contains(err.Error(), "is full") {
 			http.Error(w, err.Error(), http.StatusConflict)
 		} else {
 			http.Error(w, "Failed to create order", http.StatusInternalServerError)
@@ -76,7 +172,8 @@ func (c *OrderController) CreateOrder(w http.ResponseWriter, r *http.Request) {
 
 // @Summary Close an order
 // @ID closeOrderById
-// @Description Close an order by ID
+// @Description Close an order by ID. Only possible once the accepted payments cover every order item. Participants
+// @Description who joined the order cannot close it.
 // @Tags orders
 // @Security jwt
 // @Param id path int true "Order ID"
@@ -85,6 +182,7 @@ func (c *OrderController) CreateOrder(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {object} string "Unauthorized"
 // @Failure 403 {object} string "Forbidden"
 // @Failure 404 {object} string "Not Found"
+// @Failure 409 {object} string "Conflict, the order is already closed or accepted payments do not cover it yet"
 // @Failure 500 {object} string "Internal Server Error"
 // @Router /orders/{id}/close [post]
 func (c *OrderController) CloseOrder(w http.ResponseWriter, r *http.Request) {
@@ -101,15 +199,42 @@ func (c *OrderController) CloseOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userId := r.Context().Value("userid").(int64)
+	actorId := r.Context().Value("userid").(int64)
+	userId := actorId
 	role := models.Role(r.Context().Value("role").(byte))
 	if role.HasFlag(models.Admin) {
 		userId = 0
 	}
-	err = models.EditOrderStatus(orderId, userId, models.Closed)
+	order, err := models.GetOrderById(orderId, userId)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve order", http.StatusInternalServerError)
+		return
+	}
+	if userId != 0 && order.CustomerID != userId {
+		http.Error(w, "Only whoever opened the order can close it", http.StatusForbidden)
+		return
+	}
+
+	balance, err := models.GetOrderBalance(orderId)
+	if err != nil {
+		http.Error(w, "Failed to retrieve order balance", http.StatusInternalServerError)
+		return
+	}
+	if !balance.Settled {
+		http.Error(w, "Order has an outstanding balance of "+(balance.Subtotal-balance.Paid).String(), http.StatusConflict)
+		return
+	}
+
+	err = models.EditOrderStatus(orderId, userId, models.Closed, actorId)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Order not found", http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "invalid status transition") {
+			http.Error(w, "Order is already closed", http.StatusConflict)
 		} else if strings.Contains(err.Error(), "forbidden") {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 		} else {
@@ -121,6 +246,170 @@ func (c *OrderController) CloseOrder(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// @Summary Get order status history
+// @ID getOrderTransitions
+// @Description Get every status change of an order with who made it and when
+// @Tags orders
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order ID"
+// @Success 200 {array} GetStatusTransitionResponse "List of status changes"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/{id}/transitions [get]
+func (c *OrderController) GetOrderTransitions(w http.ResponseWriter, r *http.Request) {
+	orderId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	userId := r.Context().Value("userid").(int64)
+	role := models.Role(r.Context().Value("role").(byte))
+	if role.HasFlag(models.Admin) {
+		userId = 0
+	}
+	if _, err := models.GetOrderById(orderId, userId); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve order", http.StatusInternalServerError)
+		return
+	}
+
+	writeStatusTransitions(w, models.OrderEntity, orderId)
+}
+
+type GetOrderBalanceResponse = models.OrderBalance // @name GetOrderBalanceResponse
+
+// @Summary Get order balance
+// @ID getOrderBalance
+// @Description Get how much of an order is paid. subtotal, pending, paid and outstanding are order item amounts
+// @Description before discounts and taxes; paid_total is what accepted payments collected and outstanding_total
+// @Description estimates what is left to pay including taxes and the service charge.
+// @Tags orders
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order ID"
+// @Success 200 {object} GetOrderBalanceResponse "Order balance"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/{id}/balance [get]
+func (c *OrderController) GetOrderBalance(w http.ResponseWriter, r *http.Request) {
+	orderId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	userId := r.Context().Value("userid").(int64)
+	role := models.Role(r.Context().Value("role").(byte))
+	if role.HasFlag(models.Admin) {
+		userId = 0
+	}
+	order, err := models.GetOrderById(orderId, userId)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve order", http.StatusInternalServerError)
+		return
+	}
+
+	balance, err := orderBalance(order)
+	if err != nil {
+		http.Error(w, "Failed to retrieve order balance", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(balance); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+type GetPickupSlotResponse = models.PickupSlot // @name GetPickupSlotResponse
+
+// @Summary Get pickup slots
+// @ID getPickupSlots
+// @Description Get the slots on a day takeaway and delivery orders can be promised for, with how many orders each
+// @Description already has and how many more it takes (-1 when slots are not limited). Slots ending before the kitchen
+// @Description could have a new order ready are left out.
+// @Tags orders
+// @Produce json
+// @Security jwt
+// @Param date query string false "Date (YYYY-MM-DD), today by default"
+// @Success 200 {array} GetPickupSlotResponse "Pickup slots"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/slots [get]
+func (c *OrderController) GetPickupSlots(w http.ResponseWriter, r *http.Request) {
+	dateStr := r.URL.Query().Get("date")
+	if dateStr == "" {
+		dateStr = time.Now().Format(time.DateOnly)
+	}
+	day, err := time.ParseInLocation(time.DateOnly, dateStr, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+
+	slots, err := models.GetPickupSlots(day, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to retrieve pickup slots", http.StatusInternalServerError)
+		return
+	}
+	if slots == nil {
+		slots = []models.PickupSlot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(slots); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// orderBalance is what has been paid of an order and an estimate of what is left to pay including
+// taxes, the service charge and any delivery fee.
+func orderBalance(order *models.Order) (*models.OrderBalance, error) {
+	balance, err := models.GetOrderBalance(order.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	orderItems, err := models.GetItemsByOrderId(order.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := billLines(orderItems)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := models.GetOrderItemBalances(order.ID)
+	if err != nil {
+		return nil, err
+	}
+	outstanding, _ := outstandingLines(lines, balances)
+	deliveryFee, err := models.GetOutstandingDeliveryFee(order)
+	if err != nil {
+		return nil, err
+	}
+	balance.OutstandingTotal = models.CalculateBill(outstanding, 0, serviceChargePercent(order), deliveryFee, 0).Total
+	return balance, nil
+}
+
 type GetOrderResponse = models.Order // @name GetOrderResponse
 
 // @Summary Get order by ID
@@ -174,9 +463,10 @@ func (c *OrderController) GetOrder(w http.ResponseWriter, r *http.Request) {
 
 // @Summary Get orders
 // @ID getOrders
-// @Description Get order filtered by table number, date, user, status
+// @Description Get order filtered by type, table number, date, user, status
 // @Tags orders
 // @Security jwt
+// @Param type query string false "Order type (dine_in, takeaway, delivery)"
 // @Param table_number query int false "Table number"
 // @Param date query string false "Date in format YYYY-MM-DD"
 // @Param user_id query int false "User ID"
@@ -195,6 +485,14 @@ func (c *OrderController) GetOrders(w http.ResponseWriter, r *http.Request) {
 	userIdStr := r.URL.Query().Get("user_id")
 	status := models.OrderStatus(r.URL.Query().Get("status"))
 
+	orderType := models.OrderType(r.URL.Query().Get("type"))
+	switch orderType {
+	case "", models.DineIn, models.Takeaway, models.Delivery:
+	default:
+		http.Error(w, "Invalid order type", http.StatusBadRequest)
+		return
+	}
+
 	var tableNumber int
 	if tableNumberStr != "" {
 		var err error
@@ -253,7 +551,7 @@ func (c *OrderController) GetOrders(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	orders, err := models.GetOrders(userId, status, tableNumber, date, limit, offset)
+	orders, err := models.GetOrders(userId, status, orderType, tableNumber, date, limit, offset)
 	if err != nil {
 		http.Error(w, "Failed to retrieve orders", http.StatusInternalServerError)
 		return
@@ -275,3 +573,336 @@ func (c *OrderController) GetOrders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type JoinOrderRequest struct {
+	Code       string `json:"code" example:"K7WQ2M"`
+	TableToken string `json:"table_token" example:""`
+} // @name JoinOrderRequest
+
+// @Summary Join an order
+// @ID joinOrder
+// @Description Join the open order of friends at the same table, by the join code one of them shared or the token
+// @Description in the table's QR code. Participants can view the order and add items to it.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param request body JoinOrderRequest true "Join code or table token"
+// @Success 200 {object} GetOrderResponse "Joined order"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "No open order with the code or at the table"
+// @Failure 429 {object} string "Too many requests"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/join [post]
+func (c *OrderController) JoinOrder(w http.ResponseWriter, r *http.Request) {
+	var req JoinOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Code = strings.TrimSpace(req.Code)
+	req.TableToken = strings.TrimSpace(req.TableToken)
+	if (req.Code == "") == (req.TableToken == "") {
+		http.Error(w, "Either a join code or a table token is required", http.StatusBadRequest)
+		return
+	}
+
+	userId := r.Context().Value("userid").(int64)
+	var order *models.Order
+	var err error
+	if req.Code != "" {
+		order, err = models.JoinOrderByCode(req.Code, userId, time.Now())
+	} else {
+		var table *models.Table
+		table, err = models.GetTableByToken(req.TableToken)
+		if err == nil {
+			order, err = models.GetOpenTableOrder(table.ID)
+		}
+		if err == nil {
+			err = models.JoinOrder(order.ID, userId, time.Now())
+		}
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") || strings.Contains(err.Error(), "order is closed") {
+			http.Error(w, "There is no open order to join", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to join order", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(order); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetOrderJoinCodeResponse struct {
+	Code string `json:"code" example:"K7WQ2M"`
+} // @name GetOrderJoinCodeResponse
+
+// @Summary Invite to an order
+// @ID getOrderJoinCode
+// @Description Get the code others at the table use to join the order. The code stops working once the order is closed.
+// @Tags orders
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order ID"
+// @Success 200 {object} GetOrderJoinCodeResponse "Join code"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not Found"
+// @Failure 409 {object} string "Conflict, the order is closed"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/{id}/invite [post]
+func (c *OrderController) GetOrderJoinCode(w http.ResponseWriter, r *http.Request) {
+	orderId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	userId := r.Context().Value("userid").(int64)
+	role := models.Role(r.Context().Value("role").(byte))
+	if role.HasFlag(models.Admin) {
+		userId = 0
+	}
+
+	code, err := models.GetOrderJoinCode(orderId, userId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "order is closed") {
+			http.Error(w, "Order is closed", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create join code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetOrderJoinCodeResponse{Code: code}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetOrderParticipantResponse = models.OrderParticipant // @name GetOrderParticipantResponse
+
+// @Summary Get order participants
+// @ID getOrderParticipants
+// @Description Get everyone taking part in an order with the number of items each added and what they come to
+// @Tags orders
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order ID"
+// @Success 200 {array} GetOrderParticipantResponse "List of participants"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not Found"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/{id}/participants [get]
+func (c *OrderController) GetOrderParticipants(w http.ResponseWriter, r *http.Request) {
+	orderId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	userId := r.Context().Value("userid").(int64)
+	role := models.Role(r.Context().Value("role").(byte))
+	if role.HasFlag(models.Admin) {
+		userId = 0
+	}
+	if _, err := models.GetOrderById(orderId, userId); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve order", http.StatusInternalServerError)
+		return
+	}
+
+	participants, err := models.GetOrderParticipants(orderId)
+	if err != nil {
+		http.Error(w, "Failed to retrieve participants", http.StatusInternalServerError)
+		return
+	}
+	if participants == nil {
+		participants = []models.OrderParticipant{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(participants); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type TransferOrderRequest struct {
+	TableID int64 `json:"table_id" example:"2"`
+} // @name TransferOrderRequest
+
+// @Summary Transfer an order to another table
+// @ID transferOrder
+// @Description Move an open order to a free or reserved table when the party moves. The table they leave needs
+// @Description cleaning and guests who scanned its QR code follow the order. The move is recorded in the audit log.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order ID"
+// @Param request body TransferOrderRequest true "Table to move to"
+// @Success 200 {object} GetOrderResponse "Transferred order"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Order or table not found"
+// @Failure 409 {object} string "Conflict, the order is closed or the table is not free"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/{id}/transfer [post]
+func (c *OrderController) TransferOrder(w http.ResponseWriter, r *http.Request) {
+	orderId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	var req TransferOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.TableID <= 0 {
+		http.Error(w, "Table ID is required", http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	order, err := models.TransferOrder(orderId, req.TableID, actorId)
+	if err != nil {
+		if strings.Contains(err.Error(), "order not found") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "table not found") {
+			http.Error(w, "Table not found", http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "is closed") || strings.Contains(err.Error(), "not free") || strings.Contains(err.Error(), "already at table") ||
+			strings.Contains(err.Error(), "not a dine-in") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to transfer order", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(order); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type MergeOrdersRequest struct {
+	OrderID int64 `json:"order_id" example:"2"`
+} // @name MergeOrdersRequest
+
+// @Summary Merge two orders
+// @ID mergeOrders
+// @Description Merge another open order into this one when two tables join. Its items, payments, participants and
+// @Description guests move over, it is closed and its table needs cleaning. Both orders record the merge in their
+// @Description audit log.
+// @Tags orders
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order ID to merge into"
+// @Param request body MergeOrdersRequest true "Order to merge"
+// @Success 200 {object} GetOrderResponse "Merged order"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Order not found"
+// @Failure 409 {object} string "Conflict, one of the orders is closed"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/{id}/merge [post]
+func (c *OrderController) MergeOrders(w http.ResponseWriter, r *http.Request) {
+	orderId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	var req MergeOrdersRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.OrderID <= 0 || req.OrderID == orderId {
+		http.Error(w, "Another order ID is required", http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	order, err := models.MergeOrders(orderId, req.OrderID, actorId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+		} else if strings.Contains(err.Error(), "is closed") {
+			http.Error(w, err.Error(), http.StatusConflict)
+		} else {
+			http.Error(w, "Failed to merge orders", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(order); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetAuditEntryResponse = models.AuditEntry // @name GetAuditEntryResponse
+
+// @Summary Get order audit log
+// @ID getOrderAuditLog
+// @Description Get the transfers and merges of an order with who made them and when
+// @Tags orders
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order ID"
+// @Success 200 {array} GetAuditEntryResponse "List of audit entries"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/{id}/audit [get]
+func (c *OrderController) GetOrderAuditLog(w http.ResponseWriter, r *http.Request) {
+	orderId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order ID", http.StatusBadRequest)
+		return
+	}
+
+	entries, err := models.GetAuditLog(models.OrderEntity, orderId)
+	if err != nil {
+		http.Error(w, "Failed to retrieve audit log", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
@@ -4,16 +4,20 @@ import (
 	"encoding/json"
 	"github.com/gorilla/mux"
 	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/printer"
+	"github.com/gqvz/mvc/pkg/services"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type OrderItemController struct {
+	spooler *printer.Spooler
 }
 
-func CreateOrderItemController() *OrderItemController {
-	return &OrderItemController{}
+func CreateOrderItemController(spooler *printer.Spooler) *OrderItemController {
+	return &OrderItemController{spooler: spooler}
 }
 
 type CreateOrderItemRequest struct {
@@ -28,18 +32,21 @@ type CreateOrderItemResponse struct {
 
 // @Summary Create a new order item
 // @ID createOrderItem
-// @Description Create a new order item
+// @Description Create a new order item. Anyone taking part in the order can add items; the item is attributed to them.
 // @Tags order_items
 // @Accept json
 // @Produce json
 // @Security jwt
 // @Param id path int true "Order ID"
 // @Param request body CreateOrderItemRequest true "Create Order Item Request"
+// @Param Idempotency-Key header string false "Replays the first response when the same request is retried with this key"
 // @Success 200 {object} CreateOrderItemResponse
 // @Failure 400 {object} string "Bad Request"
 // @Failure 401 {object} string "Unauthorized"
 // @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not Found"
 // @Failure 409 {object} string "Conflict"
+// @Failure 422 {object} string "Idempotency-Key was already used for a different request"
 // @Failure 500 {object} string "Internal Server Error"
 // @Router /orders/{id}/items [post]
 func (c *OrderItemController) CreateOrderItem(w http.ResponseWriter, r *http.Request) {
@@ -63,7 +70,23 @@ func (c *OrderItemController) CreateOrderItem(w http.ResponseWriter, r *http.Req
 
 	userId := r.Context().Value("userid").(int64)
 
-	orderItem, err := models.CreateOrderItem(orderId, userId, req.ItemID, req.Quantity, req.CustomInstructions)
+	item, err := models.GetItemById(req.ItemID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Item not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "Failed to retrieve item", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	prices, err := models.GetItemPrices([]models.Item{*item}, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to resolve item price", http.StatusInternalServerError)
+		return
+	}
+
+	orderItem, err := models.CreateOrderItem(orderId, userId, req.ItemID, req.Quantity, prices[item.ID].EffectivePrice, req.CustomInstructions)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Order not found", http.StatusNotFound)
@@ -73,6 +96,8 @@ func (c *OrderItemController) CreateOrderItem(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	printKitchenTicket(c.spooler, orderItem, item)
+
 	response := CreateOrderItemResponse{
 		OrderItemID: orderItem.ID,
 	}
@@ -91,7 +116,8 @@ type EditOrderItemStatusRequest struct {
 
 // @Summary Edit an order item status
 // @ID editOrderItemStatus
-// @Description Edit the status of an order item
+// @Description Edit the status of an order item. Items move from pending to preparing to completed, one step at a time.
+// @Description Items held for an order scheduled ahead can be released to pending before the scheduler does.
 // @Tags order_items
 // @Accept json
 // @Produce json
@@ -103,6 +129,7 @@ type EditOrderItemStatusRequest struct {
 // @Failure 401 {object} string "Unauthorized"
 // @Failure 403 {object} string "Forbidden"
 // @Failure 404 {object} string "Not Found"
+// @Failure 409 {object} string "Conflict, the item cannot move to that status"
 // @Failure 500 {object} string "Internal Server Error"
 // @Router /orders/items/{id}/ [patch]
 func (c *OrderItemController) EditOrderItemStatus(w http.ResponseWriter, r *http.Request) {
@@ -125,10 +152,20 @@ func (c *OrderItemController) EditOrderItemStatus(w http.ResponseWriter, r *http
 		return
 	}
 
-	err = models.EditOrderItemStatus(orderItemId, req.Status)
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = models.EditOrderItemStatus(orderItemId, req.Status, userId)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
-			http.Error(w, "Status invalid", http.StatusConflict)
+			http.Error(w, "Order item not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") {
+			http.Error(w, "Order items go from scheduled to pending to preparing to completed", http.StatusConflict)
 			return
 		}
 		http.Error(w, "Failed to update order item status: ", http.StatusInternalServerError)
@@ -143,6 +180,29 @@ func (c *OrderItemController) EditOrderItemStatus(w http.ResponseWriter, r *http
 	}
 }
 
+// @Summary Get order item status history
+// @ID getOrderItemTransitions
+// @Description Get every status change of an order item with who made it and when
+// @Tags order_items
+// @Produce json
+// @Security jwt
+// @Param id path int true "Order Item ID"
+// @Success 200 {array} GetStatusTransitionResponse "List of status changes"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /orders/items/{id}/transitions [get]
+func (c *OrderItemController) GetOrderItemTransitions(w http.ResponseWriter, r *http.Request) {
+	orderItemId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid order item ID", http.StatusBadRequest)
+		return
+	}
+
+	writeStatusTransitions(w, models.OrderItemEntity, orderItemId)
+}
+
 type GetOrderItemResponse = models.OrderItem // @name GetOrderItemResponse
 
 // @Summary Get order items
@@ -202,12 +262,14 @@ func (c *OrderItemController) GetOrderItems(w http.ResponseWriter, r *http.Reque
 
 // @Summary Get order items by status
 // @ID getOrderItemsByStatus
-// @Description Get all items in an order by status
+// @Description Get the order items in a status across orders, as the kitchen works through them: by when they were
+// @Description ordered or, for takeaway and delivery orders promised for a time, the kitchen prep time before it.
+// @Description Items of orders scheduled ahead are held as scheduled until the release lead time before their pickup.
 // @Tags order_items
 // @Security jwt
 // @Param limit query int false "Limit the number of items returned"
 // @Param offset query int false "Offset for pagination"
-// @Param status query string false "Filter by item status (preparing, completed)"
+// @Param status query string false "Filter by item status (pending, preparing, completed, scheduled)"
 // @Success 200 {array} GetOrderItemResponse "List of order items"
 // @Success 204 {object} string "No Content"
 // @Failure 400 {object} string "Bad Request"
@@ -256,3 +318,16 @@ func (c *OrderItemController) GetOrderItemsByStatus(w http.ResponseWriter, r *ht
 		return
 	}
 }
+
+// printKitchenTicket sends a new order item to the kitchen printer. Items held for an order scheduled
+// ahead are printed when they are released instead.
+func printKitchenTicket(spooler *printer.Spooler, orderItem *models.OrderItem, item *models.Item) {
+	if orderItem.Status == models.ItemScheduled {
+		return
+	}
+	services.PrintKitchenTicket(spooler, orderItem.OrderID, []printer.TicketItem{{
+		Name:         item.Name,
+		Quantity:     orderItem.Quantity,
+		Instructions: orderItem.CustomInstructions,
+	}})
+}
@@ -1,44 +1,91 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/gateway"
 	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"github.com/gqvz/mvc/pkg/printer"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type PaymentController struct {
+	provider gateway.PaymentProvider
+	spooler  *printer.Spooler
 }
 
-func CreatePaymentController() *PaymentController {
-	return &PaymentController{}
+func CreatePaymentController(provider gateway.PaymentProvider, spooler *printer.Spooler) *PaymentController {
+	return &PaymentController{provider: provider, spooler: spooler}
 }
 
 type CreatePaymentRequest struct {
-	OrderID   int64   `json:"order_id"`
-	Tip       float64 `json:"tip"`
-	CashierID int64   `json:"cashier_id"`
+	OrderID        int64                `json:"order_id"`
+	Tip            money.Money          `json:"tip" example:"2.50"`
+	CashierID      int64                `json:"cashier_id"`
+	PromoCode      string               `json:"promo_code" example:"SUMMER10"`
+	Discount       money.Money          `json:"discount" example:"0.00"`
+	DiscountReason string               `json:"discount_reason" example:"Cold food"`
+	Split          models.SplitMode     `json:"split" example:"even"`
+	Parts          int                  `json:"parts" example:"3"`
+	OrderItemIDs   []int64              `json:"order_item_ids"`
+	Amount         money.Money          `json:"amount" example:"25.00"`
+	PaymentToken   string               `json:"payment_token" example:"tok_visa"`
+	Method         models.PaymentMethod `json:"method" example:"card"`
+	GiftCardCode   string               `json:"gift_card_code" example:"K7QX4M2PZ9RTW3HV"`
+	GiftCardAmount money.Money          `json:"gift_card_amount" example:"20.00"`
+	RedeemPoints   int64                `json:"redeem_points" example:"500"`
 } // @name CreatePaymentRequest
 
 type CreatePaymentResponse struct {
-	PaymentID int64 `json:"payment_id"`
+	PaymentID int64                `json:"payment_id"`
+	Status    models.PaymentStatus `json:"status"`
 } // @name CreatePaymentResponse
 
 // @Summary Create a new payment
 // @ID createPayment
-// @Description Create a new payment
+// @Description Create a new payment, optionally applying a promo code and a manager discount (admins only, reason required).
+// @Description Taxes are resolved per item, tag or default tax category; large tables get the configured service charge.
+// @Description The first payment of a delivery order adds its delivery fee, which is neither discounted nor taxed.
+// @Description Amounts are decimal strings (numbers are accepted too) with at most two decimal places.
+// @Description A payment covers the whole outstanding balance unless split is set: "even" pays one of parts equal shares,
+// @Description "items" pays the listed order items and "amount" pays that much of the order subtotal (before discounts,
+// @Description taxes and tip). Shares are spread over the order items so that each share is taxed like the whole bill.
+// @Description The total is authorized and captured with the configured payment provider using payment_token;
+// @Description declined payments release their share of the order. When the provider cannot be reached the payment stays
+// @Description processing, since the hold may have been placed anyway, until a webhook carrying its id settles it.
+// @Description Cashiers can take "cash" or "other" payments at the till for any order; these are accepted without the
+// @Description provider and recorded against the cashier's open drawer session, which cash payments require.
+// @Description gift_card_code tenders a gift card or store credit for up to gift_card_amount (default: as much of the
+// @Description total as its balance covers); only the rest is charged with the method.
+// @Description Card payments are made by the caller, who may be the order's owner or a participant who joined it; payments
+// @Description taken at the till are made on behalf of the order's customer.
+// @Description redeem_points spends the payer's own loyalty points as a discount, up to what is left of the subtotal after
+// @Description other discounts. Accepted payments earn the payer points on their discounted subtotal.
 // @Tags payments
 // @Accept json
 // @Produce json
 // @Security jwt
 // @Param request body CreatePaymentRequest true "Create Payment Request"
-// @Success 201 {object} CreatePaymentResponse
+// @Param Idempotency-Key header string false "Replays the first response when the same request is retried with this key"
+// @Success 201 {object} CreatePaymentResponse "Payment captured"
+// @Success 202 {object} CreatePaymentResponse "Payment pending at the provider"
 // @Failure 400 {object} string "Bad Request"
 // @Failure 401 {object} string "Unauthorized"
+// @Failure 402 {object} string "Payment declined"
 // @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not Found"
+// @Failure 409 {object} string "Conflict"
+// @Failure 422 {object} string "Idempotency-Key was already used for a different request"
 // @Failure 500 {object} string "Internal Server Error"
+// @Failure 502 {object} string "Payment provider unavailable, the payment stays processing"
 // @Router /payments [post]
 func (c *PaymentController) CreatePaymentHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreatePaymentRequest
@@ -47,8 +94,49 @@ func (c *PaymentController) CreatePaymentHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	if req.Tip < 0 {
-		http.Error(w, "Tip cannot be negative", http.StatusBadRequest)
+	if req.Tip < 0 || req.Discount < 0 {
+		http.Error(w, "Tip and discount cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	if req.RedeemPoints < 0 {
+		http.Error(w, "Redeemed points cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	if req.Split == "" {
+		req.Split = models.FullSplit
+	}
+	switch req.Split {
+	case models.FullSplit:
+		req.Parts = 0
+	case models.EvenSplit:
+		if req.Parts < 2 || req.Parts > 100 {
+			http.Error(w, "An even split needs between 2 and 100 parts", http.StatusBadRequest)
+			return
+		}
+	case models.ItemsSplit:
+		if len(req.OrderItemIDs) == 0 {
+			http.Error(w, "order_item_ids are required when splitting by item", http.StatusBadRequest)
+			return
+		}
+		req.Parts = 0
+	case models.AmountSplit:
+		if req.Amount <= 0 {
+			http.Error(w, "A positive amount is required when splitting by amount", http.StatusBadRequest)
+			return
+		}
+		req.Parts = 0
+	default:
+		http.Error(w, "Split must be one of 'full', 'even', 'items' or 'amount'", http.StatusBadRequest)
+		return
+	}
+
+	if req.Method == "" {
+		req.Method = models.CardPayment
+	}
+	if req.Method != models.CardPayment && req.Method != models.CashPayment && req.Method != models.OtherPayment {
+		http.Error(w, "Method must be one of 'card', 'cash' or 'other'", http.StatusBadRequest)
 		return
 	}
 
@@ -57,8 +145,55 @@ func (c *PaymentController) CreatePaymentHandler(w http.ResponseWriter, r *http.
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
+	role := models.Role(r.Context().Value("role").(byte))
+
+	// payments taken at the till are made on behalf of the order's customer
+	ownerId := userId
+	var drawerSessionId int64
+	if req.Method != models.CardPayment {
+		if !role.HasFlag(models.Cashier) {
+			http.Error(w, "Only cashiers can take cash or other payments", http.StatusForbidden)
+			return
+		}
+		ownerId = 0
+		req.CashierID = userId
+
+		session, err := models.GetOpenDrawerSession(userId)
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Failed to retrieve drawer session", http.StatusInternalServerError)
+			return
+		}
+		if session != nil {
+			drawerSessionId = session.ID
+		} else if req.Method == models.CashPayment {
+			http.Error(w, "Open a drawer session before taking cash", http.StatusConflict)
+			return
+		}
+	}
 
-	orderItems, err := models.GetItemsByOrderId(req.OrderID, userId)
+	if req.Discount > 0 {
+		if !role.HasFlag(models.Admin) {
+			http.Error(w, "Only managers can apply discounts", http.StatusForbidden)
+			return
+		}
+		if req.DiscountReason == "" {
+			http.Error(w, "A reason is required for manager discounts", http.StatusBadRequest)
+			return
+		}
+	}
+
+	order, err := models.GetOrderById(req.OrderID, ownerId)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve order", http.StatusInternalServerError)
+		return
+	}
+	payerId := paymentPayer(ownerId, order)
+
+	orderItems, err := models.GetItemsByOrderId(req.OrderID, ownerId)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Order not found", http.StatusNotFound)
@@ -68,46 +203,197 @@ func (c *PaymentController) CreatePaymentHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	subtotal := 0.0
-	if orderItems != nil && len(*orderItems) != 0 {
-		itemIdToDetails := make(map[int64]*models.Item)
-		itemIdsSet := make(map[int64]struct{})
-		for _, item := range *orderItems {
-			itemIdsSet[item.ItemID] = struct{}{}
+	lines, err := billLines(orderItems)
+	if err != nil {
+		http.Error(w, "Failed to resolve taxes", http.StatusInternalServerError)
+		return
+	}
+
+	balances, err := models.GetOrderItemBalances(req.OrderID)
+	if err != nil {
+		http.Error(w, "Failed to retrieve order balance", http.StatusInternalServerError)
+		return
+	}
+
+	taken := 0
+	if req.Split == models.EvenSplit {
+		taken, err = models.CountSplitPayments(req.OrderID, models.EvenSplit, req.Parts)
+		if err != nil {
+			http.Error(w, "Failed to retrieve split payments", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	share, status, errMessage := paymentShare(&req, lines, balances, taken)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	subtotal := money.Money(0)
+	for _, line := range share {
+		subtotal += line.Amount
+	}
+
+	draft := &models.Payment{
+		OrderID:         req.OrderID,
+		Discount:        req.Discount,
+		CashierID:       req.CashierID,
+		Currency:        config.Config.Billing.Currency,
+		SplitMode:       req.Split,
+		SplitParts:      req.Parts,
+		Method:          req.Method,
+		DrawerSessionID: drawerSessionId,
+	}
+	if req.Discount > 0 {
+		draft.DiscountReason = req.DiscountReason
+		draft.DiscountedBy = userId
+	}
+	for _, line := range share {
+		draft.Items = append(draft.Items, models.PaymentItem{OrderItemID: line.OrderItemID, Amount: line.Amount})
+	}
+
+	if req.PromoCode != "" {
+		promo, err := models.GetPromoCodeByCode(req.PromoCode)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "Invalid promo code", http.StatusBadRequest)
+				return
+			}
+			http.Error(w, "Failed to retrieve promo code", http.StatusInternalServerError)
+			return
 		}
-		itemIds := make([]int64, 0, len(itemIdsSet))
-		for id := range itemIdsSet {
-			itemIds = append(itemIds, id)
+
+		if !promo.ValidAt(time.Now()) {
+			http.Error(w, "Promo code is not valid at this time", http.StatusBadRequest)
+			return
+		}
+
+		if subtotal < promo.MinSpend {
+			http.Error(w, "Payment does not meet the promo code's minimum spend", http.StatusBadRequest)
+			return
 		}
-		items, err := models.GetItemByIdBulk(itemIds)
+
+		eligible, err := promoEligibleSubtotal(promo, share, orderItems)
+		if err != nil {
+			http.Error(w, "Failed to retrieve items", http.StatusInternalServerError)
+			return
+		}
+
+		draft.PromoCodeID = promo.ID
+		draft.Discount += promo.DiscountFor(eligible)
+	}
+
+	if req.RedeemPoints > 0 {
+		balance, err := models.GetLoyaltyPoints(payerId)
 		if err != nil {
-			http.Error(w, "Failed to retrieve items: ", http.StatusInternalServerError)
+			http.Error(w, "Failed to retrieve loyalty points", http.StatusInternalServerError)
 			return
 		}
-		for _, item := range *items {
-			itemIdToDetails[item.ID] = &item
+		if req.RedeemPoints > balance {
+			http.Error(w, fmt.Sprintf("Only %d loyalty points are available", balance), http.StatusConflict)
+			return
+		}
+
+		points, discount := models.LoyaltyRedemption(req.RedeemPoints, config.Config.Loyalty.PointValue, subtotal-draft.Discount)
+		if points == 0 {
+			http.Error(w, "Nothing is left to pay with loyalty points", http.StatusBadRequest)
+			return
 		}
-		for _, orderItem := range *orderItems {
-			itemDetails, ok := itemIdToDetails[orderItem.ItemID]
-			if !ok {
-				http.Error(w, "Order contains invalid item", http.StatusBadRequest)
+		draft.LoyaltyPoints = points
+		draft.LoyaltyDiscount = discount
+		draft.Discount += discount
+	}
+
+	deliveryFee, err := models.GetOutstandingDeliveryFee(order)
+	if err != nil {
+		http.Error(w, "Failed to retrieve order balance", http.StatusInternalServerError)
+		return
+	}
+
+	bill := models.CalculateBill(share, draft.Discount, serviceChargePercent(order), deliveryFee, req.Tip)
+	draft.Subtotal = bill.Subtotal
+	draft.Discount = bill.Discount
+	draft.ServiceCharge = bill.ServiceCharge
+	draft.DeliveryFee = bill.DeliveryFee
+	draft.Tax = bill.Tax
+	draft.TaxIncluded = bill.TaxIncluded
+	draft.Tip = bill.Tip
+	draft.Taxes = bill.Taxes
+
+	if req.GiftCardCode != "" {
+		card, err := models.GetGiftCardByCode(models.NormalizeGiftCardCode(req.GiftCardCode))
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "Invalid gift card", http.StatusBadRequest)
 				return
 			}
-			subtotal += itemDetails.Price * float64(orderItem.Quantity)
+			http.Error(w, "Failed to retrieve gift card", http.StatusInternalServerError)
+			return
+		}
+		if card.Currency != draft.Currency {
+			http.Error(w, "Gift card is in a different currency", http.StatusBadRequest)
+			return
 		}
+
+		amount := bill.Total
+		if req.GiftCardAmount > 0 {
+			amount = money.Min(amount, req.GiftCardAmount)
+		}
+		amount = money.Min(amount, card.Balance)
+		if amount <= 0 {
+			http.Error(w, "Gift card has no balance", http.StatusConflict)
+			return
+		}
+		draft.GiftCardID = card.ID
+		draft.GiftCardAmount = amount
 	}
-	payment, err := models.CreatePayment(req.OrderID, subtotal, req.Tip, req.CashierID, userId)
 
+	payment, err := models.CreatePayment(draft, payerId)
 	if err != nil {
+		if strings.Contains(err.Error(), "drawer session") {
+			http.Error(w, "Drawer session is closed", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "gift card balance") {
+			http.Error(w, "Gift card balance is too low", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "loyalty points") {
+			http.Error(w, "Not enough loyalty points", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "usage limit") {
+			http.Error(w, "Promo code usage limit reached", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "already paid") {
+			http.Error(w, "Order items are already paid by another payment", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "delivery fee") {
+			http.Error(w, "Delivery fee is already paid by another payment", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to create payment", http.StatusInternalServerError)
 		return
 	}
 
+	paymentStatus, statusCode, errMessage := c.chargePayment(r.Context(), payment, req.PaymentToken)
+	if errMessage != "" {
+		http.Error(w, errMessage, statusCode)
+		return
+	}
+	if paymentStatus == models.Accepted {
+		paymentAccepted(c.spooler, payment.ID)
+	}
+
 	response := CreatePaymentResponse{
 		PaymentID: payment.ID,
+		Status:    paymentStatus,
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(statusCode)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
@@ -156,13 +442,38 @@ func (c *PaymentController) GetPaymentHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	response := GetPaymentResponse{
-		ID:        payment.ID,
-		OrderID:   payment.OrderID,
-		Subtotal:  payment.Subtotal,
-		Tip:       payment.Tip,
-		Total:     payment.Total,
-		Status:    payment.Status,
-		CashierID: payment.CashierID,
+		ID:              payment.ID,
+		OrderID:         payment.OrderID,
+		Subtotal:        payment.Subtotal,
+		Discount:        payment.Discount,
+		ServiceCharge:   payment.ServiceCharge,
+		DeliveryFee:     payment.DeliveryFee,
+		Tax:             payment.Tax,
+		TaxIncluded:     payment.TaxIncluded,
+		Tip:             payment.Tip,
+		Total:           payment.Total,
+		Status:          payment.Status,
+		CashierID:       payment.CashierID,
+		PromoCodeID:     payment.PromoCodeID,
+		DiscountReason:  payment.DiscountReason,
+		DiscountedBy:    payment.DiscountedBy,
+		Taxes:           payment.Taxes,
+		Currency:        payment.Currency,
+		SplitMode:       payment.SplitMode,
+		SplitParts:      payment.SplitParts,
+		Items:           payment.Items,
+		Provider:        payment.Provider,
+		ProviderRef:     payment.ProviderRef,
+		DeclineReason:   payment.DeclineReason,
+		Refunded:        payment.Refunded,
+		CreatedAt:       payment.CreatedAt,
+		Method:          payment.Method,
+		DrawerSessionID: payment.DrawerSessionID,
+		GiftCardID:      payment.GiftCardID,
+		GiftCardAmount:  payment.GiftCardAmount,
+		LoyaltyPoints:   payment.LoyaltyPoints,
+		LoyaltyDiscount: payment.LoyaltyDiscount,
+		LoyaltyEarned:   payment.LoyaltyEarned,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -261,7 +572,8 @@ type EditPaymentStatusRequest struct {
 
 // @Summary Edit payment status
 // @ID editPaymentStatus
-// @Description Edit payment status
+// @Description Settle a processing payment by hand as accepted, declined or voided. Other transitions are rejected;
+// @Description refunds are issued through the refunds endpoint.
 // @Tags payments
 // @Accept json
 // @Produce json
@@ -273,6 +585,7 @@ type EditPaymentStatusRequest struct {
 // @Failure 401 {object} string "Unauthorized"
 // @Failure 403 {object} string "Forbidden"
 // @Failure 404 {object} string "Not Found"
+// @Failure 409 {object} string "Conflict, the payment cannot move to that status"
 // @Failure 500 {object} string "Internal Server Error"
 // @Router /payments/{id} [patch]
 func (c *PaymentController) EditPaymentStatusHandler(w http.ResponseWriter, r *http.Request) {
@@ -289,20 +602,34 @@ func (c *PaymentController) EditPaymentStatusHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	if req.Status != models.Processing && req.Status != models.Accepted {
+	// refunds go through the refunds endpoint so the refunded amount is recorded
+	if req.Status != models.Accepted && req.Status != models.Declined && req.Status != models.Voided {
 		http.Error(w, "Invalid payment status", http.StatusBadRequest)
 		return
 	}
 
-	err = models.UpdatePaymentStatus(paymentId, req.Status)
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = models.UpdatePaymentStatus(paymentId, req.Status, userId)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Payment not found", http.StatusNotFound)
 			return
 		}
+		if strings.Contains(err.Error(), "invalid status transition") {
+			http.Error(w, "Only processing payments can be accepted, declined or voided", http.StatusConflict)
+			return
+		}
 		http.Error(w, "Failed to update payment status", http.StatusInternalServerError)
 		return
 	}
+	if req.Status == models.Accepted {
+		paymentAccepted(c.spooler, paymentId)
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
@@ -311,3 +638,282 @@ func (c *PaymentController) EditPaymentStatusHandler(w http.ResponseWriter, r *h
 		return
 	}
 }
+
+type GetStatusTransitionResponse = models.StatusTransition // @name GetStatusTransitionResponse
+
+// @Summary Get payment status history
+// @ID getPaymentTransitions
+// @Description Get every status change of a payment with who made it and when. Changes reported by the payment provider have no actor.
+// @Tags payments
+// @Produce json
+// @Security jwt
+// @Param id path int true "Payment ID"
+// @Success 200 {array} GetStatusTransitionResponse "List of status changes"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /payments/{id}/transitions [get]
+func (c *PaymentController) GetPaymentTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	paymentId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	writeStatusTransitions(w, models.PaymentEntity, paymentId)
+}
+
+// writeStatusTransitions writes the status history of an entity as a JSON array.
+func writeStatusTransitions(w http.ResponseWriter, entity models.StatusEntity, entityId int64) {
+	transitions, err := models.GetStatusTransitions(entity, entityId)
+	if err != nil {
+		http.Error(w, "Failed to retrieve status history", http.StatusInternalServerError)
+		return
+	}
+
+	if len(transitions) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(transitions); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// paymentAccepted follows up on a collected payment: its receipt is printed and the customer earns
+// loyalty points. Failures are logged rather than failing the payment.
+func paymentAccepted(spooler *printer.Spooler, paymentId int64) {
+	printPaymentReceipt(spooler, paymentId)
+
+	if _, err := models.AwardLoyaltyPoints(paymentId, config.Config.Loyalty, time.Now()); err != nil {
+		log.Printf("Error awarding loyalty points for payment %d: %v", paymentId, err)
+	}
+}
+
+// paymentPayer is who a payment is recorded under: the caller paying by card for an order they own
+// or joined, or the order's customer when a cashier takes the payment at the till (ownerId 0).
+func paymentPayer(ownerId int64, order *models.Order) int64 {
+	if ownerId == 0 {
+		return order.CustomerID
+	}
+	return ownerId
+}
+
+// chargePayment authorizes what the payment's gift card tender leaves of the total with the
+// provider and captures it straight away, recording the outcome on the payment.
+func (c *PaymentController) chargePayment(ctx context.Context, payment *models.Payment, token string) (models.PaymentStatus, int, string) {
+	// cash and other payments are collected at the till, not by the provider
+	if payment.Method != models.CardPayment {
+		if err := models.SetPaymentProviderResult(payment.ID, models.Accepted, "", "", ""); err != nil {
+			return "", http.StatusInternalServerError, "Failed to record payment result"
+		}
+		return models.Accepted, http.StatusCreated, ""
+	}
+
+	provider := c.provider.Name()
+	charge := payment.Total - payment.GiftCardAmount
+	if charge == 0 {
+		if err := models.SetPaymentProviderResult(payment.ID, models.Accepted, provider, "", ""); err != nil {
+			return "", http.StatusInternalServerError, "Failed to record payment result"
+		}
+		return models.Accepted, http.StatusCreated, ""
+	}
+
+	result, err := c.provider.Authorize(ctx, gateway.AuthorizeRequest{
+		PaymentID: payment.ID,
+		Amount:    charge,
+		Currency:  payment.Currency,
+		Token:     token,
+	})
+	if err != nil {
+		// the provider may have placed the hold anyway, so the payment stays processing until a
+		// webhook carrying its id settles it or staff void it
+		log.Printf("Payment provider %s failed to authorize payment %d: %v", provider, payment.ID, err)
+		if err := models.SetPaymentProviderResult(payment.ID, models.Processing, provider, "", ""); err != nil {
+			log.Printf("Error recording payment result: %v", err)
+		}
+		return "", http.StatusBadGateway, "Payment provider unavailable; the payment stays processing until the provider confirms it"
+	}
+
+	switch result.Status {
+	case gateway.Pending:
+		if err := models.SetPaymentProviderResult(payment.ID, models.Processing, provider, result.Reference, ""); err != nil {
+			return "", http.StatusInternalServerError, "Failed to record payment result"
+		}
+		return models.Processing, http.StatusAccepted, ""
+	case gateway.Declined:
+		if err := models.SetPaymentProviderResult(payment.ID, models.Declined, provider, result.Reference, result.Message); err != nil {
+			log.Printf("Error recording payment result: %v", err)
+		}
+		return "", http.StatusPaymentRequired, "Payment declined: " + result.Message
+	}
+
+	capture, err := c.provider.Capture(ctx, result.Reference, charge)
+	if err != nil || capture.Status != gateway.Approved {
+		reason := "capture failed"
+		if err == nil && capture.Message != "" {
+			reason = capture.Message
+		}
+		if _, err := c.provider.Void(ctx, result.Reference); err != nil {
+			log.Printf("Error voiding authorization %s: %v", result.Reference, err)
+		}
+		if err := models.SetPaymentProviderResult(payment.ID, models.Declined, provider, result.Reference, reason); err != nil {
+			log.Printf("Error recording payment result: %v", err)
+		}
+		return "", http.StatusPaymentRequired, "Payment declined: " + reason
+	}
+
+	if err := models.SetPaymentProviderResult(payment.ID, models.Accepted, provider, result.Reference, ""); err != nil {
+		log.Printf("Payment %d was captured as %s but could not be recorded: %v", payment.ID, result.Reference, err)
+		return "", http.StatusInternalServerError, "Failed to record payment result"
+	}
+	return models.Accepted, http.StatusCreated, ""
+}
+
+func promoEligibleSubtotal(promo *models.PromoCode, share []models.BillLine, orderItems *[]models.OrderItem) (money.Money, error) {
+	eligible := money.Money(0)
+	if !promo.Scoped() {
+		for _, line := range share {
+			eligible += line.Amount
+		}
+		return eligible, nil
+	}
+
+	if orderItems == nil || len(*orderItems) == 0 {
+		return 0, nil
+	}
+
+	itemIds := make([]int64, 0, len(*orderItems))
+	orderItemToItem := make(map[int64]int64, len(*orderItems))
+	for _, orderItem := range *orderItems {
+		itemIds = append(itemIds, orderItem.ItemID)
+		orderItemToItem[orderItem.ID] = orderItem.ItemID
+	}
+	items, err := models.GetItemByIdBulk(itemIds)
+	if err != nil {
+		return 0, err
+	}
+
+	itemIdToDetails := make(map[int64]*models.Item)
+	for i := range *items {
+		itemIdToDetails[(*items)[i].ID] = &(*items)[i]
+	}
+	for _, line := range share {
+		item, ok := itemIdToDetails[orderItemToItem[line.OrderItemID]]
+		if ok && promo.AppliesTo(item) {
+			eligible += line.Amount
+		}
+	}
+	return eligible, nil
+}
+
+// paymentShare picks the part of the outstanding order item amounts a payment covers. taken is
+// the number of shares of an even split that were already paid.
+func paymentShare(req *CreatePaymentRequest, lines []models.BillLine, balances map[int64]money.Money, taken int) ([]models.BillLine, int, string) {
+	subtotal := money.Money(0)
+	for _, line := range lines {
+		subtotal += line.Amount
+	}
+	outstanding, outstandingTotal := outstandingLines(lines, balances)
+
+	if outstandingTotal == 0 {
+		return nil, http.StatusConflict, "Order has no outstanding balance"
+	}
+
+	switch req.Split {
+	case models.EvenSplit:
+		if taken >= req.Parts {
+			return nil, http.StatusConflict, "All shares of this split are already paid"
+		}
+		amount := money.Min(subtotal.Split(req.Parts)[taken], outstandingTotal)
+		return models.ShareLines(outstanding, amount), http.StatusOK, ""
+	case models.ItemsSplit:
+		var share []models.BillLine
+		seen := make(map[int64]bool)
+		for _, orderItemId := range req.OrderItemIDs {
+			if seen[orderItemId] {
+				continue
+			}
+			seen[orderItemId] = true
+
+			found := false
+			for _, line := range lines {
+				found = found || line.OrderItemID == orderItemId
+			}
+			if !found {
+				return nil, http.StatusBadRequest, fmt.Sprintf("Order item %d is not part of this order", orderItemId)
+			}
+
+			paid := true
+			for _, line := range outstanding {
+				if line.OrderItemID == orderItemId {
+					share = append(share, line)
+					paid = false
+				}
+			}
+			if paid {
+				return nil, http.StatusConflict, fmt.Sprintf("Order item %d is already paid", orderItemId)
+			}
+		}
+		return share, http.StatusOK, ""
+	case models.AmountSplit:
+		if req.Amount > outstandingTotal {
+			return nil, http.StatusBadRequest, "Amount exceeds the outstanding balance of " + outstandingTotal.String()
+		}
+		return models.ShareLines(outstanding, req.Amount), http.StatusOK, ""
+	default:
+		return outstanding, http.StatusOK, ""
+	}
+}
+
+// outstandingLines narrows the bill lines down to the amounts no payment covers yet.
+func outstandingLines(lines []models.BillLine, balances map[int64]money.Money) ([]models.BillLine, money.Money) {
+	total := money.Money(0)
+	outstanding := make([]models.BillLine, 0, len(lines))
+	for _, line := range lines {
+		if balance := balances[line.OrderItemID]; balance > 0 {
+			line.Amount = balance
+			outstanding = append(outstanding, line)
+			total += balance
+		}
+	}
+	return outstanding, total
+}
+
+// serviceChargePercent is the service charge of a dine-in order, which large parties pay.
+func serviceChargePercent(order *models.Order) money.Percent {
+	if order.Type == models.DineIn && order.Guests >= config.Config.Billing.ServiceChargeMinGuests {
+		return config.Config.Billing.ServiceChargePercent
+	}
+	return 0
+}
+
+func billLines(orderItems *[]models.OrderItem) ([]models.BillLine, error) {
+	if orderItems == nil || len(*orderItems) == 0 {
+		return nil, nil
+	}
+
+	itemIds := make([]int64, 0, len(*orderItems))
+	for _, orderItem := range *orderItems {
+		itemIds = append(itemIds, orderItem.ItemID)
+	}
+	categories, err := models.GetTaxCategoriesForItems(itemIds)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]models.BillLine, 0, len(*orderItems))
+	for _, orderItem := range *orderItems {
+		lines = append(lines, models.BillLine{
+			OrderItemID: orderItem.ID,
+			Amount:      orderItem.UnitPrice.Mul(orderItem.Quantity),
+			TaxCategory: categories[orderItem.ItemID],
+		})
+	}
+	return lines, nil
+}
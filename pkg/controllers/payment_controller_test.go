@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"net/http"
+	"testing"
+)
+
+var salesTax = &models.TaxCategory{ID: 1, Name: "Sales tax", Rate: 10_000}
+
+// payShare takes a share out of the balances as a paid payment would.
+func payShare(balances map[int64]money.Money, share []models.BillLine) money.Money {
+	paid := money.Money(0)
+	for _, line := range share {
+		balances[line.OrderItemID] -= line.Amount
+		paid += line.Amount
+	}
+	return paid
+}
+
+func TestPaymentShareEvenSplit(t *testing.T) {
+	lines := []models.BillLine{
+		{OrderItemID: 1, Amount: 1000, TaxCategory: salesTax},
+		{OrderItemID: 2, Amount: 500},
+		{OrderItemID: 3, Amount: 500, TaxCategory: salesTax},
+	}
+	balances := map[int64]money.Money{1: 1000, 2: 500, 3: 500}
+	req := &CreatePaymentRequest{Split: models.EvenSplit, Parts: 3}
+
+	expected := []money.Money{667, 667, 666}
+	total := money.Money(0)
+	for taken := range expected {
+		share, status, errMessage := paymentShare(req, lines, balances, taken)
+		if errMessage != "" {
+			t.Fatalf("Part %d: expected no error, got %d %s", taken, status, errMessage)
+		}
+		paid := payShare(balances, share)
+		if paid != expected[taken] {
+			t.Errorf("Part %d: expected %s, got %s", taken, expected[taken], paid)
+		}
+		total += paid
+	}
+
+	if total != 2000 {
+		t.Errorf("Expected the parts to add up to 20.00, got %s", total)
+	}
+	for orderItemId, balance := range balances {
+		if balance != 0 {
+			t.Errorf("Expected order item %d to be paid, %s is left", orderItemId, balance)
+		}
+	}
+	if _, status, _ := paymentShare(req, lines, map[int64]money.Money{1: 1}, 3); status != http.StatusConflict {
+		t.Errorf("Expected a fourth part to be rejected with %d, got %d", http.StatusConflict, status)
+	}
+}
+
+func TestPaymentShareItemsSplit(t *testing.T) {
+	lines := []models.BillLine{
+		{OrderItemID: 1, Amount: 1000, TaxCategory: salesTax},
+		{OrderItemID: 2, Amount: 2500, TaxCategory: salesTax},
+		{OrderItemID: 3, Amount: 330, TaxCategory: salesTax},
+	}
+	balances := map[int64]money.Money{1: 1000, 2: 2500, 3: 330}
+	whole := models.CalculateBill(lines, 0, 0, 0, 0)
+
+	var subtotal, total money.Money
+	for _, orderItemIds := range [][]int64{{1}, {2, 2}, {3}} {
+		req := &CreatePaymentRequest{Split: models.ItemsSplit, OrderItemIDs: orderItemIds}
+		share, status, errMessage := paymentShare(req, lines, balances, 0)
+		if errMessage != "" {
+			t.Fatalf("Items %v: expected no error, got %d %s", orderItemIds, status, errMessage)
+		}
+		bill := models.CalculateBill(share, 0, 0, 0, 0)
+		subtotal += bill.Subtotal
+		total += bill.Total
+		payShare(balances, share)
+	}
+
+	if subtotal != whole.Subtotal || total != whole.Total {
+		t.Errorf("Expected the item shares to add up to %s (%s with tax), got %s (%s)", whole.Subtotal, whole.Total, subtotal, total)
+	}
+
+	balances[1] = 1000
+	tests := []struct {
+		orderItemIds []int64
+		status       int
+	}{
+		{[]int64{1, 2}, http.StatusConflict},
+		{[]int64{1, 99}, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		req := &CreatePaymentRequest{Split: models.ItemsSplit, OrderItemIDs: test.orderItemIds}
+		if _, status, _ := paymentShare(req, lines, balances, 0); status != test.status {
+			t.Errorf("Items %v: expected %d, got %d", test.orderItemIds, test.status, status)
+		}
+	}
+}
+
+func TestPaymentShareAmountSplit(t *testing.T) {
+	lines := []models.BillLine{{OrderItemID: 1, Amount: 1000}, {OrderItemID: 2, Amount: 2000}}
+	balances := map[int64]money.Money{1: 1000, 2: 2000}
+
+	share, _, errMessage := paymentShare(&CreatePaymentRequest{Split: models.AmountSplit, Amount: 1001}, lines, balances, 0)
+	if errMessage != "" {
+		t.Fatalf("Expected no error, got %s", errMessage)
+	}
+	if paid := payShare(balances, share); paid != 1001 {
+		t.Errorf("Expected the share to be 10.01, got %s", paid)
+	}
+
+	if _, status, _ := paymentShare(&CreatePaymentRequest{Split: models.AmountSplit, Amount: 2000}, lines, balances, 0); status != http.StatusBadRequest {
+		t.Errorf("Expected an amount above the outstanding balance to be rejected with %d, got %d", http.StatusBadRequest, status)
+	}
+}
+
+func TestPaymentPayer(t *testing.T) {
+	order := &models.Order{ID: 1, CustomerID: 3}
+
+	if payer := paymentPayer(3, order); payer != 3 {
+		t.Errorf("Expected the owner to pay for their order, got user %d", payer)
+	}
+	if payer := paymentPayer(5, order); payer != 5 {
+		t.Errorf("Expected a participant to pay as themselves, got user %d", payer)
+	}
+	if payer := paymentPayer(0, order); payer != 3 {
+		t.Errorf("Expected a payment taken at the till to be made for the customer, got user %d", payer)
+	}
+}
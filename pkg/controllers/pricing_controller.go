@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"github.com/gqvz/mvc/pkg/services"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PricingController struct{}
+
+func CreatePricingController() *PricingController {
+	return &PricingController{}
+}
+
+type CreatePriceScheduleRequest struct {
+	Price       money.Money `json:"price" example:"79.99"`
+	EffectiveAt time.Time   `json:"effective_at" example:"2025-09-01T00:00:00Z"`
+} // @name CreatePriceScheduleRequest
+
+type CreatePriceScheduleResponse struct {
+	ID int64 `json:"id"`
+} // @name CreatePriceScheduleResponse
+
+// @Summary Schedule a price change
+// @ID createPriceSchedule
+// @Description Schedule a new list price for an item starting at the given time
+// @Tags pricing
+// @Accept json
+// @Produce json
+// @Param id path int true "Item ID"
+// @Param schedule body CreatePriceScheduleRequest true "Price schedule request"
+// @Security jwt
+// @Success 201 {object} CreatePriceScheduleResponse "Created price schedule"
+// @Failure 400 {object} string "Bad request, invalid price or time"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to schedule prices"
+// @Failure 404 {object} string "Item not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /items/{id}/prices [post]
+func (c *PricingController) CreatePriceScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	itemId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CreatePriceScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Price <= 0 || req.EffectiveAt.IsZero() {
+		http.Error(w, "Price and effective_at are required", http.StatusBadRequest)
+		return
+	}
+
+	userId := r.Context().Value("userid").(int64)
+	schedule, err := models.CreatePriceSchedule(itemId, req.Price, req.EffectiveAt, userId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Item not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to create price schedule", http.StatusInternalServerError)
+		return
+	}
+
+	services.ClearItemsCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(CreatePriceScheduleResponse{ID: schedule.ID})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetPriceScheduleResponse = models.PriceSchedule // @name GetPriceScheduleResponse
+
+// @Summary Get price schedules
+// @ID getPriceSchedules
+// @Description Get all scheduled price changes of an item, newest first
+// @Tags pricing
+// @Produce json
+// @Param id path int true "Item ID"
+// @Security jwt
+// @Success 200 {array} GetPriceScheduleResponse "List of price schedules"
+// @Failure 400 {object} string "Bad request, invalid item ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view price schedules"
+// @Failure 500 {object} string "Internal server error"
+// @Router /items/{id}/prices [get]
+func (c *PricingController) GetPriceSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	itemId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid item ID", http.StatusBadRequest)
+		return
+	}
+
+	schedules, err := models.GetPriceSchedules(itemId)
+	if err != nil {
+		http.Error(w, "Failed to retrieve price schedules", http.StatusInternalServerError)
+		return
+	}
+
+	if len(schedules) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(schedules); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type CreateDiscountRequest struct {
+	Name      string         `json:"name" example:"Happy hour"`
+	Percent   money.Percent  `json:"percent" example:"20"`
+	ItemID    int64          `json:"item_id" example:"0"`
+	Tag       string         `json:"tag" example:"cocktail"`
+	Days      []time.Weekday `json:"days" example:"1,2,3,4,5"`
+	StartTime string         `json:"start_time" example:"17:00"`
+	EndTime   string         `json:"end_time" example:"19:00"`
+	StartsAt  *time.Time     `json:"starts_at"`
+	EndsAt    *time.Time     `json:"ends_at"`
+	Active    bool           `json:"active" example:"true"`
+} // @name CreateDiscountRequest
+
+type CreateDiscountResponse struct {
+	ID int64 `json:"id"`
+} // @name CreateDiscountResponse
+
+// @Summary Create discount
+// @ID createDiscount
+// @Description Create a recurring time-bound discount for an item, a tag or the whole menu.
+// @Description Days are weekday numbers (0 = Sunday); an empty list means every day.
+// @Description Equal start and end times mean all day.
+// @Tags pricing
+// @Accept json
+// @Produce json
+// @Param discount body CreateDiscountRequest true "Discount request"
+// @Security jwt
+// @Success 201 {object} CreateDiscountResponse "Created discount"
+// @Failure 400 {object} string "Bad request, invalid discount data"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to create discounts"
+// @Failure 500 {object} string "Internal server error"
+// @Router /discounts [post]
+func (c *PricingController) CreateDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateDiscountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	discount, status, errMessage := discountFromRequest(&req)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	discount, err := models.CreateDiscount(discount)
+	if err != nil {
+		http.Error(w, "Failed to create discount", http.StatusInternalServerError)
+		return
+	}
+
+	services.ClearItemsCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(CreateDiscountResponse{ID: discount.ID})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetDiscountResponse = models.Discount // @name GetDiscountResponse
+
+// @Summary Get discounts
+// @ID getDiscounts
+// @Description Get all discounts
+// @Tags pricing
+// @Produce json
+// @Param active query bool false "Only return active discounts"
+// @Security jwt
+// @Success 200 {array} GetDiscountResponse "List of discounts"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view discounts"
+// @Failure 500 {object} string "Internal server error"
+// @Router /discounts [get]
+func (c *PricingController) GetDiscountsHandler(w http.ResponseWriter, r *http.Request) {
+	activeOnly := false
+	if activeParam := r.URL.Query().Get("active"); activeParam != "" {
+		var err error
+		activeOnly, err = strconv.ParseBool(activeParam)
+		if err != nil {
+			http.Error(w, "Invalid value for 'active' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	discounts, err := models.GetDiscounts(activeOnly)
+	if err != nil {
+		http.Error(w, "Failed to retrieve discounts", http.StatusInternalServerError)
+		return
+	}
+
+	if len(discounts) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(discounts); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type EditDiscountRequest = CreateDiscountRequest // @name EditDiscountRequest
+
+// @Summary Edit discount
+// @ID editDiscount
+// @Description Edit an existing discount; set active to false to disable it
+// @Tags pricing
+// @Accept json
+// @Param id path int true "Discount ID"
+// @Param discount body EditDiscountRequest true "Discount request"
+// @Security jwt
+// @Success 200 "Edited discount"
+// @Failure 400 {object} string "Bad request, invalid discount data"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to edit discounts"
+// @Failure 404 {object} string "Discount not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /discounts/{id} [put]
+func (c *PricingController) EditDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid discount ID", http.StatusBadRequest)
+		return
+	}
+
+	var req EditDiscountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	discount, status, errMessage := discountFromRequest(&req)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+	discount.ID = id
+
+	if err := models.EditDiscount(discount); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Discount not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to edit discount", http.StatusInternalServerError)
+		return
+	}
+
+	services.ClearItemsCache()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func discountFromRequest(req *CreateDiscountRequest) (*models.Discount, int, string) {
+	if req.Name == "" || req.Percent <= 0 || req.Percent > money.OneHundredPercent {
+		return nil, http.StatusBadRequest, "Name and a percent between 0 and 100 are required"
+	}
+
+	for _, day := range req.Days {
+		if day < time.Sunday || day > time.Saturday {
+			return nil, http.StatusBadRequest, "Days must be between 0 (Sunday) and 6 (Saturday)"
+		}
+	}
+
+	startTime, err := models.ParseClock(req.StartTime)
+	if err != nil {
+		return nil, http.StatusBadRequest, "Invalid start_time, expected HH:MM"
+	}
+	endTime, err := models.ParseClock(req.EndTime)
+	if err != nil {
+		return nil, http.StatusBadRequest, "Invalid end_time, expected HH:MM"
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return nil, http.StatusBadRequest, "ends_at must be after starts_at"
+	}
+
+	discount := &models.Discount{
+		Name:      req.Name,
+		Percent:   req.Percent,
+		ItemID:    req.ItemID,
+		Days:      req.Days,
+		StartTime: startTime,
+		EndTime:   endTime,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Active:    req.Active,
+	}
+
+	if req.Tag != "" {
+		tags, err := models.GetTags()
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Failed to get tags"
+		}
+		for _, tag := range tags {
+			if tag.Name == req.Tag {
+				discount.TagID = tag.ID
+				break
+			}
+		}
+		if discount.TagID == 0 {
+			return nil, http.StatusBadRequest, "Tag not found: " + req.Tag
+		}
+	}
+
+	return discount, http.StatusOK, ""
+}
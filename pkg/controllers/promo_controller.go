@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PromoController struct{}
+
+func CreatePromoController() *PromoController {
+	return &PromoController{}
+}
+
+type CreatePromoCodeRequest struct {
+	Code           string           `json:"code" example:"SUMMER10"`
+	Kind           models.PromoKind `json:"kind" example:"percentage"`
+	Value          json.Number      `json:"value" example:"10"`
+	MinSpend       money.Money      `json:"min_spend" example:"20"`
+	StartsAt       *time.Time       `json:"starts_at"`
+	EndsAt         *time.Time       `json:"ends_at"`
+	MaxUses        int              `json:"max_uses" example:"100"`
+	MaxUsesPerUser int              `json:"max_uses_per_user" example:"1"`
+	ItemID         int64            `json:"item_id" example:"0"`
+	Tag            string           `json:"tag" example:""`
+	Active         bool             `json:"active" example:"true"`
+} // @name CreatePromoCodeRequest
+
+type CreatePromoCodeResponse struct {
+	ID int64 `json:"id"`
+} // @name CreatePromoCodeResponse
+
+// @Summary Create promo code
+// @ID createPromoCode
+// @Description Create a percentage or fixed promo code. Zero usage limits mean unlimited;
+// @Description item_id or tag restrict the discount to matching order items. The value is a percentage
+// @Description for percentage codes and an amount for fixed codes; it is returned as percent or amount.
+// @Tags promos
+// @Accept json
+// @Produce json
+// @Param promo body CreatePromoCodeRequest true "Promo code request"
+// @Security jwt
+// @Success 201 {object} CreatePromoCodeResponse "Created promo code"
+// @Failure 400 {object} string "Bad request, invalid promo code data"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to create promo codes"
+// @Failure 409 {object} string "Conflict, promo code already exists"
+// @Failure 500 {object} string "Internal server error"
+// @Router /promos [post]
+func (c *PromoController) CreatePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreatePromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	promo, status, errMessage := promoCodeFromRequest(&req)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	promo, err := models.CreatePromoCode(promo)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			http.Error(w, "Promo code already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create promo code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(CreatePromoCodeResponse{ID: promo.ID})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetPromoCodeResponse = models.PromoCode // @name GetPromoCodeResponse
+
+// @Summary Get promo codes
+// @ID getPromoCodes
+// @Description Get all promo codes with their usage counts
+// @Tags promos
+// @Produce json
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Security jwt
+// @Success 200 {array} GetPromoCodeResponse "List of promo codes"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view promo codes"
+// @Failure 500 {object} string "Internal server error"
+// @Router /promos [get]
+func (c *PromoController) GetPromoCodesHandler(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit := 10
+	if limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 20 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	promos, err := models.GetPromoCodes(limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve promo codes", http.StatusInternalServerError)
+		return
+	}
+
+	if len(promos) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(promos); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type EditPromoCodeRequest = CreatePromoCodeRequest // @name EditPromoCodeRequest
+
+// @Summary Edit promo code
+// @ID editPromoCode
+// @Description Edit an existing promo code; set active to false to disable it
+// @Tags promos
+// @Accept json
+// @Param id path int true "Promo code ID"
+// @Param promo body EditPromoCodeRequest true "Promo code request"
+// @Security jwt
+// @Success 200 "Edited promo code"
+// @Failure 400 {object} string "Bad request, invalid promo code data"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to edit promo codes"
+// @Failure 404 {object} string "Promo code not found"
+// @Failure 409 {object} string "Conflict, promo code already exists"
+// @Failure 500 {object} string "Internal server error"
+// @Router /promos/{id} [put]
+func (c *PromoController) EditPromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid promo code ID", http.StatusBadRequest)
+		return
+	}
+
+	var req EditPromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	promo, status, errMessage := promoCodeFromRequest(&req)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+	promo.ID = id
+
+	if err := models.EditPromoCode(promo); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Promo code not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "already exists") {
+			http.Error(w, "Promo code already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to edit promo code", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func promoCodeFromRequest(req *CreatePromoCodeRequest) (*models.PromoCode, int, string) {
+	if req.Code == "" || req.Value == "" {
+		return nil, http.StatusBadRequest, "Code and value are required"
+	}
+
+	promo := &models.PromoCode{Kind: req.Kind}
+	switch req.Kind {
+	case models.PercentagePromo:
+		percent, err := money.ParsePercent(req.Value.String())
+		if err != nil || percent <= 0 {
+			return nil, http.StatusBadRequest, "Value must be a positive percentage"
+		}
+		if percent > money.OneHundredPercent {
+			return nil, http.StatusBadRequest, "Percentage cannot be greater than 100"
+		}
+		promo.Percent = percent
+	case models.FixedPromo:
+		amount, err := money.ParseMoney(req.Value.String())
+		if err != nil || amount <= 0 {
+			return nil, http.StatusBadRequest, "Value must be a positive amount"
+		}
+		promo.Amount = amount
+	default:
+		return nil, http.StatusBadRequest, "Kind must be 'percentage' or 'fixed'"
+	}
+
+	if req.MinSpend < 0 || req.MaxUses < 0 || req.MaxUsesPerUser < 0 {
+		return nil, http.StatusBadRequest, "Minimum spend and usage limits cannot be negative"
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return nil, http.StatusBadRequest, "ends_at must be after starts_at"
+	}
+
+	promo.Code = req.Code
+	promo.MinSpend = req.MinSpend
+	promo.StartsAt = req.StartsAt
+	promo.EndsAt = req.EndsAt
+	promo.MaxUses = req.MaxUses
+	promo.MaxUsesPerUser = req.MaxUsesPerUser
+	promo.ItemID = req.ItemID
+	promo.Active = req.Active
+
+	if req.Tag != "" {
+		tags, err := models.GetTags()
+		if err != nil {
+			return nil, http.StatusInternalServerError, "Failed to get tags"
+		}
+		for _, tag := range tags {
+			if tag.Name == req.Tag {
+				promo.TagID = tag.ID
+				break
+			}
+		}
+		if promo.TagID == 0 {
+			return nil, http.StatusBadRequest, "Tag not found: " + req.Tag
+		}
+	}
+
+	return promo, http.StatusOK, ""
+}
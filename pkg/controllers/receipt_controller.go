@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/printer"
+	"github.com/gqvz/mvc/pkg/receipt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ReceiptController struct{}
+
+func CreateReceiptController() *ReceiptController {
+	return &ReceiptController{}
+}
+
+// @Summary Get payment receipt
+// @ID getPaymentReceipt
+// @Description Render an itemised receipt for a collected payment. The first request gives the payment the next
+// @Description sequential receipt number. text is fixed width for thermal printers; width sets its columns.
+// @Tags payments
+// @Produce html
+// @Produce plain
+// @Produce application/pdf
+// @Security jwt
+// @Param id path int true "Payment ID"
+// @Param format query string false "html (default), text or pdf"
+// @Param width query int false "Columns of the text receipt (24-64, default 42)"
+// @Success 200 {string} string "Rendered receipt"
+// @Failure 400 {object} string "Bad Request"
+// @Failure 401 {object} string "Unauthorized"
+// @Failure 403 {object} string "Forbidden"
+// @Failure 404 {object} string "Not Found"
+// @Failure 409 {object} string "Conflict, the payment has not been collected"
+// @Failure 500 {object} string "Internal Server Error"
+// @Router /payments/{id}/receipt [get]
+func (c *ReceiptController) GetPaymentReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	paymentId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "text" && format != "pdf" {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	width := receipt.DefaultWidth
+	if widthStr := r.URL.Query().Get("width"); widthStr != "" {
+		width, err = strconv.Atoi(widthStr)
+		if err != nil || width < receipt.MinWidth || width > receipt.MaxWidth {
+			http.Error(w, "Invalid width", http.StatusBadRequest)
+			return
+		}
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	role := models.Role(r.Context().Value("role").(byte))
+	if role.HasFlag(models.Admin) || role.HasFlag(models.Cashier) {
+		userId = 0
+	}
+
+	payment, err := models.GetPaymentByID(paymentId, userId)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve payment", http.StatusInternalServerError)
+		return
+	}
+
+	if payment.Status != models.Accepted && payment.Status != models.PartiallyRefunded && payment.Status != models.Refunded {
+		http.Error(w, "Receipts are only issued for collected payments", http.StatusConflict)
+		return
+	}
+
+	rendered, err := buildReceipt(payment)
+	if err != nil {
+		log.Printf("Error building receipt for payment %d: %v", payment.ID, err)
+		http.Error(w, "Failed to build receipt", http.StatusInternalServerError)
+		return
+	}
+
+	switch format {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = receipt.RenderText(w, rendered, width)
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"receipt-%d.pdf\"", rendered.Number))
+		err = receipt.RenderPDF(w, rendered)
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = receipt.RenderHTML(w, rendered)
+	}
+	if err != nil {
+		log.Printf("Error rendering receipt: %v", err)
+	}
+}
+
+// buildReceipt collects what a receipt shows for a payment and issues its receipt number.
+func buildReceipt(payment *models.Payment) (*receipt.Receipt, error) {
+	order, err := models.GetOrderById(payment.OrderID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	orderItems, err := models.GetItemsByOrderId(order.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	itemIds := make([]int64, 0, len(*orderItems))
+	orderItemById := make(map[int64]models.OrderItem, len(*orderItems))
+	for _, orderItem := range *orderItems {
+		itemIds = append(itemIds, orderItem.ItemID)
+		orderItemById[orderItem.ID] = orderItem
+	}
+
+	itemNames := make(map[int64]string)
+	if len(itemIds) > 0 {
+		items, err := models.GetItemByIdBulk(itemIds)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range *items {
+			itemNames[item.ID] = item.Name
+		}
+	}
+
+	issued, err := models.IssueReceipt(payment.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	restaurant := config.Config.Restaurant
+	rendered := &receipt.Receipt{
+		Number:   issued.Number,
+		IssuedAt: issued.IssuedAt,
+		Restaurant: receipt.Restaurant{
+			Name:    restaurant.Name,
+			Address: restaurant.Address,
+			Phone:   restaurant.Phone,
+			TaxID:   restaurant.TaxID,
+			Footer:  restaurant.ReceiptFooter,
+		},
+		OrderID:        order.ID,
+		TableNumber:    order.TableNumber,
+		OrderType:      order.TypeLabel(),
+		PaymentID:      payment.ID,
+		Subtotal:       payment.Subtotal,
+		Discount:       payment.Discount,
+		DiscountReason: payment.DiscountReason,
+		ServiceCharge:  payment.ServiceCharge,
+		DeliveryFee:    payment.DeliveryFee,
+		Tip:            payment.Tip,
+		Total:          payment.Total,
+		Refunded:       payment.Refunded,
+		Currency:       payment.Currency,
+		Reference:      payment.ProviderRef,
+	}
+
+	for _, paymentItem := range payment.Items {
+		orderItem, ok := orderItemById[paymentItem.OrderItemID]
+		if !ok {
+			continue
+		}
+		line := receipt.Line{
+			Name:      itemNames[orderItem.ItemID],
+			Quantity:  orderItem.Quantity,
+			UnitPrice: orderItem.UnitPrice,
+			Amount:    paymentItem.Amount,
+			Share:     paymentItem.Amount != orderItem.UnitPrice.Mul(orderItem.Quantity),
+		}
+		if orderItem.CustomInstructions != "" {
+			line.Modifiers = []string{orderItem.CustomInstructions}
+		}
+		rendered.Lines = append(rendered.Lines, line)
+	}
+
+	for _, tax := range payment.Taxes {
+		rendered.Taxes = append(rendered.Taxes, receipt.Tax{
+			Name:      tax.Name,
+			Rate:      tax.Rate,
+			Inclusive: tax.Inclusive,
+			Amount:    tax.Amount,
+		})
+	}
+
+	return rendered, nil
+}
+
+// printPaymentReceipt queues the receipt of an accepted payment on the receipt printer. Printing
+// problems are logged rather than failing the payment.
+func printPaymentReceipt(spooler *printer.Spooler, paymentId int64) {
+	if !spooler.PrintsReceipts() {
+		return
+	}
+
+	payment, err := models.GetPaymentByID(paymentId, 0)
+	if err != nil {
+		log.Printf("Error loading payment %d for receipt: %v", paymentId, err)
+		return
+	}
+
+	rendered, err := buildReceipt(payment)
+	if err != nil {
+		log.Printf("Error building receipt for payment %d: %v", paymentId, err)
+		return
+	}
+
+	if err := spooler.PrintReceipt(rendered); err != nil {
+		log.Printf("Error printing receipt for payment %d: %v", paymentId, err)
+	}
+}
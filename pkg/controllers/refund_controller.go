@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/gateway"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type RefundController struct {
+	provider gateway.PaymentProvider
+}
+
+func CreateRefundController(provider gateway.PaymentProvider) *RefundController {
+	return &RefundController{provider: provider}
+}
+
+type CreateRefundRequest struct {
+	Amount      money.Money `json:"amount" example:"5.00"`
+	Reason      string      `json:"reason" example:"Dish sent back"`
+	StoreCredit bool        `json:"store_credit" example:"false"`
+} // @name CreateRefundRequest
+
+type CreateRefundResponse = models.Refund // @name CreateRefundResponse
+
+// @Summary Refund a payment
+// @ID createRefund
+// @Description Refund all or part of an accepted payment. Without an amount the remaining refundable amount is refunded.
+// @Description The payment becomes partially_refunded until refunds add up to its total, then refunded.
+// @Description Cash is paid back out of the refunding cashier's open drawer session. A payment's gift card tender is
+// @Description put back on its card before the rest is refunded. With store_credit the whole refund is loaded onto a
+// @Description new store credit card for the customer instead. Refunds through the payment provider are reserved as
+// @Description pending while the provider is asked, and voided if it does not issue them.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Payment ID"
+// @Param request body CreateRefundRequest true "Refund"
+// @Param Idempotency-Key header string false "Replays the first response when the same request is retried with this key"
+// @Success 201 {object} CreateRefundResponse "Refund created"
+// @Failure 400 {object} string "Bad request, invalid amount or missing reason"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 402 {object} string "Refund declined by the payment provider"
+// @Failure 403 {object} string "Forbidden, you are not allowed to refund payments"
+// @Failure 404 {object} string "Payment not found"
+// @Failure 409 {object} string "Payment cannot be refunded or the amount exceeds what is refundable"
+// @Failure 422 {object} string "Idempotency-Key was already used for a different request"
+// @Failure 500 {object} string "Internal server error"
+// @Failure 502 {object} string "Payment provider unavailable"
+// @Router /payments/{id}/refunds [post]
+func (c *RefundController) CreateRefundHandler(w http.ResponseWriter, r *http.Request) {
+	paymentId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateRefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "Reason is required", http.StatusBadRequest)
+		return
+	}
+	if req.Amount < 0 {
+		http.Error(w, "Invalid amount", http.StatusBadRequest)
+		return
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	payment, err := models.GetPaymentByID(paymentId, 0)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve payment", http.StatusInternalServerError)
+		return
+	}
+
+	if payment.Status != models.Accepted && payment.Status != models.PartiallyRefunded {
+		http.Error(w, "Payment is "+string(payment.Status)+" and cannot be refunded", http.StatusConflict)
+		return
+	}
+
+	refundable := payment.Total - payment.Refunded
+	if req.Amount == 0 {
+		req.Amount = refundable
+	}
+	if req.Amount == 0 || req.Amount > refundable {
+		http.Error(w, "Refund exceeds the refundable amount of "+refundable.String(), http.StatusConflict)
+		return
+	}
+
+	refund := &models.Refund{
+		PaymentID:   paymentId,
+		Amount:      req.Amount,
+		Reason:      req.Reason,
+		CreatedBy:   userId,
+		StoreCredit: req.StoreCredit,
+	}
+
+	// what goes back to the original tender rather than onto a card
+	tender := money.Money(0)
+	if !req.StoreCredit {
+		refund.GiftCardAmount = money.Min(req.Amount, payment.GiftCardRefundable())
+		tender = req.Amount - refund.GiftCardAmount
+	}
+
+	if payment.Method == models.CashPayment && tender > 0 {
+		session, err := models.GetOpenDrawerSession(userId)
+		if err != nil {
+			if strings.Contains(err.Error(), "not found") {
+				http.Error(w, "Open a drawer session before refunding cash", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to retrieve drawer session", http.StatusInternalServerError)
+			return
+		}
+		refund.DrawerSessionID = session.ID
+	}
+
+	if payment.ProviderRef == "" || tender == 0 {
+		created, err := models.CreateRefund(refund)
+		if err != nil {
+			writeRefundError(w, err)
+			return
+		}
+		writeRefund(w, created)
+		return
+	}
+
+	// the refund is reserved before the provider is asked so a concurrent refund of the same money
+	// is rejected instead of being issued twice
+	reserved, err := models.ReserveRefund(refund)
+	if err != nil {
+		writeRefundError(w, err)
+		return
+	}
+
+	result, err := c.provider.Refund(r.Context(), payment.ProviderRef, tender)
+	if err != nil || result.Status != gateway.Approved {
+		if err1 := models.VoidRefund(reserved.ID); err1 != nil {
+			log.Printf("Error voiding refund %d of payment %d: %v", reserved.ID, paymentId, err1)
+		}
+		if err != nil {
+			log.Printf("Payment provider %s failed to refund payment %d: %v", c.provider.Name(), paymentId, err)
+			http.Error(w, "Payment provider unavailable", http.StatusBadGateway)
+			return
+		}
+		http.Error(w, "Refund declined: "+result.Message, http.StatusPaymentRequired)
+		return
+	}
+
+	created, err := models.CompleteRefund(reserved.ID, result.Reference)
+	if err != nil {
+		// the money has left, so the pending refund stays to hold the amount until it is recorded by hand
+		log.Printf("Refund %s of payment %d was issued by the provider but refund %d could not be completed: %v", result.Reference, paymentId, reserved.ID, err)
+		http.Error(w, "Refund was issued but could not be recorded", http.StatusInternalServerError)
+		return
+	}
+	writeRefund(w, created)
+}
+
+// writeRefundError maps an error recording a refund to a response.
+func writeRefundError(w http.ResponseWriter, err error) {
+	if strings.Contains(err.Error(), "drawer session") {
+		http.Error(w, "Drawer session is closed", http.StatusConflict)
+		return
+	}
+	if strings.Contains(err.Error(), "not found") {
+		http.Error(w, "Payment not found", http.StatusNotFound)
+		return
+	}
+	if strings.Contains(err.Error(), "refund") {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	log.Printf("Error recording refund: %v", err)
+	http.Error(w, "Failed to record refund", http.StatusInternalServerError)
+}
+
+func writeRefund(w http.ResponseWriter, created *models.Refund) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(created); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetRefundResponse = models.Refund // @name GetRefundResponse
+
+// @Summary Get refunds of a payment
+// @ID getRefunds
+// @Description Get the refunds issued against a payment
+// @Tags payments
+// @Produce json
+// @Security jwt
+// @Param id path int true "Payment ID"
+// @Success 200 {array} GetRefundResponse "List of refunds"
+// @Failure 400 {object} string "Bad request, invalid payment ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view refunds"
+// @Failure 500 {object} string "Internal server error"
+// @Router /payments/{id}/refunds [get]
+func (c *RefundController) GetRefundsHandler(w http.ResponseWriter, r *http.Request) {
+	paymentId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	refunds, err := models.GetRefunds(paymentId)
+	if err != nil {
+		http.Error(w, "Failed to retrieve refunds", http.StatusInternalServerError)
+		return
+	}
+
+	if len(refunds) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(refunds); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Void a payment
+// @ID voidPayment
+// @Description Cancel a payment that is still processing, releasing the authorization with the provider
+// @Description and the order items it covered. Accepted payments have to be refunded instead.
+// @Tags payments
+// @Produce json
+// @Security jwt
+// @Param id path int true "Payment ID"
+// @Success 200 {object} string "Payment voided"
+// @Failure 400 {object} string "Bad request, invalid payment ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 402 {object} string "Void declined by the payment provider"
+// @Failure 403 {object} string "Forbidden, you are not allowed to void payments"
+// @Failure 404 {object} string "Payment not found"
+// @Failure 409 {object} string "Payment is not processing"
+// @Failure 500 {object} string "Internal server error"
+// @Failure 502 {object} string "Payment provider unavailable"
+// @Router /payments/{id}/void [post]
+func (c *RefundController) VoidPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	paymentId, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid payment ID", http.StatusBadRequest)
+		return
+	}
+
+	payment, err := models.GetPaymentByID(paymentId, 0)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			http.Error(w, "Payment not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve payment", http.StatusInternalServerError)
+		return
+	}
+
+	if payment.Status != models.Processing {
+		http.Error(w, "Payment is "+string(payment.Status)+" and cannot be voided", http.StatusConflict)
+		return
+	}
+
+	if payment.ProviderRef != "" {
+		result, err := c.provider.Void(r.Context(), payment.ProviderRef)
+		if err != nil {
+			log.Printf("Payment provider %s failed to void payment %d: %v", c.provider.Name(), paymentId, err)
+			http.Error(w, "Payment provider unavailable", http.StatusBadGateway)
+			return
+		}
+		if result.Status != gateway.Approved {
+			http.Error(w, "Void declined: "+result.Message, http.StatusPaymentRequired)
+			return
+		}
+	}
+
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := models.UpdatePaymentStatus(paymentId, models.Voided, userId); err != nil {
+		if strings.Contains(err.Error(), "invalid status transition") {
+			http.Error(w, "Payment is no longer processing", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update payment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode("Payment voided"); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"log"
+	"net/http"
+	"time"
+)
+
+const maxReportDays = 366
+
+type ReportController struct{}
+
+func CreateReportController() *ReportController {
+	return &ReportController{}
+}
+
+type GetRevenueReportResponse = models.RevenueReport // @name GetRevenueReportResponse
+
+// @Summary Get revenue report
+// @ID getRevenueReport
+// @Description Get collected revenue per day. Refunds are subtracted on the day they were issued, so net is what was kept.
+// @Description Both dates are inclusive and default to today.
+// @Tags reports
+// @Produce json
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Security jwt
+// @Success 200 {object} GetRevenueReportResponse "Revenue report"
+// @Failure 400 {object} string "Bad request, invalid date range"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view reports"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reports/revenue [get]
+func (c *ReportController) GetRevenueReportHandler(w http.ResponseWriter, r *http.Request) {
+	from, to, status, errMessage := reportRange(r)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	report, err := models.GetRevenueReport(from, to)
+	if err != nil {
+		http.Error(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+	report.Currency = config.Config.Billing.Currency
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// reportRange reads the inclusive from and to dates of a report, both defaulting to today.
+func reportRange(r *http.Request) (time.Time, time.Time, int, string) {
+	today, err := time.ParseInLocation(time.DateOnly, time.Now().Format(time.DateOnly), time.Local)
+	if err != nil {
+		return today, today, http.StatusInternalServerError, "Failed to build report"
+	}
+
+	from, to := today, today
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		from, err = time.ParseInLocation(time.DateOnly, fromStr, time.Local)
+		if err != nil {
+			return from, to, http.StatusBadRequest, "Invalid from date"
+		}
+	}
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		to, err = time.ParseInLocation(time.DateOnly, toStr, time.Local)
+		if err != nil {
+			return from, to, http.StatusBadRequest, "Invalid to date"
+		}
+	}
+
+	if to.Before(from) {
+		return from, to, http.StatusBadRequest, "From date must not be after to date"
+	}
+	if to.Sub(from) > maxReportDays*24*time.Hour {
+		return from, to, http.StatusBadRequest, "Date range is too long"
+	}
+	return from, to, http.StatusOK, ""
+}
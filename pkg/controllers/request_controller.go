@@ -17,7 +17,7 @@ func CreateRequestController() *RequestController {
 }
 
 type CreateRequestRequest struct {
-	Role models.Role `json:"role" example:"3"`
+	Role models.Role `json:"role" example:"7"`
 } // @name CreateRequestRequest
 
 // @Summary Create request
@@ -0,0 +1,559 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/ical"
+	"github.com/gqvz/mvc/pkg/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// calendarFeedDays is how far ahead the calendar feed lists reservations.
+	calendarFeedDays = 90
+	// maxCalendarImportSize caps the size of an imported .ics file.
+	maxCalendarImportSize = 1 << 20
+)
+
+type ReservationController struct{}
+
+func CreateReservationController() *ReservationController {
+	return &ReservationController{}
+}
+
+type CreateReservationRequest struct {
+	PartySize int       `json:"party_size" example:"4"`
+	StartsAt  time.Time `json:"starts_at" example:"2025-09-12T19:30:00Z"`
+	// Duration in minutes, the configured reservation duration when left out
+	Duration int    `json:"duration" example:"90"`
+	Name     string `json:"name" example:"Jane Doe"`
+	Phone    string `json:"phone" example:"+1 555 0100"`
+	Email    string `json:"email" example:"jane@example.com"`
+	Notes    string `json:"notes" example:"Window seat, one high chair"`
+} // @name CreateReservationRequest
+
+type GetReservationResponse = models.Reservation // @name GetReservationResponse
+
+type GetReservationSlotResponse = models.ReservationSlot // @name GetReservationSlotResponse
+
+// @Summary Create reservation
+// @ID createReservation
+// @Description Book a party in. It gets the smallest free table that seats it for its time, or combinable tables of
+// @Description one section pushed together, keeping the turn time clear between bookings.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param reservation body CreateReservationRequest true "Reservation request"
+// @Success 201 {object} GetReservationResponse "Created reservation"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 409 {object} string "Conflict, no tables are available for the party at that time"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations [post]
+func (c *ReservationController) CreateReservationHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	reservation, ok := reservationFromRequest(w, &req)
+	if !ok {
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	reservation, err := models.CreateReservation(reservation, actorId)
+	if err != nil {
+		if strings.Contains(err.Error(), "no tables available") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create reservation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(reservation); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get reservations
+// @ID getReservations
+// @Description Get the reservations of a day by time
+// @Tags reservations
+// @Produce json
+// @Security jwt
+// @Param date query string false "Date (YYYY-MM-DD), today by default"
+// @Param status query string false "Reservation status (confirmed, seated, no_show, cancelled)"
+// @Success 200 {array} GetReservationResponse "List of reservations"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations [get]
+func (c *ReservationController) GetReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	day, ok := reservationDay(w, r)
+	if !ok {
+		return
+	}
+
+	status := models.ReservationStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.ReservationConfirmed, models.ReservationSeated, models.ReservationNoShow, models.ReservationCancelled:
+	default:
+		http.Error(w, "Invalid reservation status", http.StatusBadRequest)
+		return
+	}
+
+	reservations, err := models.GetReservations(day, day.AddDate(0, 0, 1), status)
+	if err != nil {
+		http.Error(w, "Failed to retrieve reservations", http.StatusInternalServerError)
+		return
+	}
+	if reservations == nil {
+		reservations = []models.Reservation{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reservations); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Search reservation availability
+// @ID getReservationAvailability
+// @Description Get the times on a day a party can still be booked, with the tables it would get. Bookings, seated
+// @Description parties and walk-ins hold their tables, each followed by the turn time.
+// @Tags reservations
+// @Produce json
+// @Security jwt
+// @Param date query string false "Date (YYYY-MM-DD), today by default"
+// @Param party_size query int true "Party size"
+// @Param duration query int false "Duration in minutes, the configured reservation duration by default"
+// @Success 200 {array} GetReservationSlotResponse "Available times"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations/availability [get]
+func (c *ReservationController) GetReservationAvailabilityHandler(w http.ResponseWriter, r *http.Request) {
+	day, ok := reservationDay(w, r)
+	if !ok {
+		return
+	}
+
+	partySize, err := strconv.Atoi(r.URL.Query().Get("party_size"))
+	if err != nil || partySize <= 0 {
+		http.Error(w, "Party size must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	minutes := 0
+	if durationStr := r.URL.Query().Get("duration"); durationStr != "" {
+		if minutes, err = strconv.Atoi(durationStr); err != nil {
+			http.Error(w, "Invalid duration", http.StatusBadRequest)
+			return
+		}
+	}
+	duration, ok := reservationDuration(w, minutes)
+	if !ok {
+		return
+	}
+
+	slots, err := models.GetReservationAvailability(day, partySize, duration, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to search availability", http.StatusInternalServerError)
+		return
+	}
+	if slots == nil {
+		slots = []models.ReservationSlot{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(slots); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get reservation by ID
+// @ID getReservationById
+// @Description Get a reservation with its tables and, once seated, its order
+// @Tags reservations
+// @Produce json
+// @Security jwt
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} GetReservationResponse "Reservation details"
+// @Failure 400 {object} string "Bad request, invalid reservation ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 404 {object} string "Reservation not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations/{id} [get]
+func (c *ReservationController) GetReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	reservation, err := models.GetReservationById(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Reservation not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve reservation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reservation); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Edit reservation
+// @ID editReservation
+// @Description Edit a confirmed reservation. Its tables are assigned again for the new time and party size.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Reservation ID"
+// @Param reservation body CreateReservationRequest true "Reservation request"
+// @Success 200 {object} GetReservationResponse "Edited reservation"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 404 {object} string "Reservation not found"
+// @Failure 409 {object} string "Conflict, the reservation is no longer confirmed or no tables are available"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations/{id} [put]
+func (c *ReservationController) EditReservationHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	reservation, ok := reservationFromRequest(w, &req)
+	if !ok {
+		return
+	}
+	reservation.ID = id
+
+	actorId := r.Context().Value("userid").(int64)
+	reservation, err = models.EditReservation(reservation, actorId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Reservation not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "no tables available") || strings.Contains(err.Error(), "reservation is") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to edit reservation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reservation); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type EditReservationStatusRequest struct {
+	Status models.ReservationStatus `json:"status" example:"seated"`
+} // @name EditReservationStatusRequest
+
+// @Summary Change reservation status
+// @ID editReservationStatus
+// @Description Seat a confirmed reservation, mark it a no-show or cancel it. Seating opens an order for the party at
+// @Description its first table and marks all of its tables occupied. A no-show who turns up late can still be seated.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Reservation ID"
+// @Param status body EditReservationStatusRequest true "New status"
+// @Success 200 {object} GetReservationResponse "Reservation with its order once seated"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 404 {object} string "Reservation not found"
+// @Failure 409 {object} string "Conflict, the reservation cannot move to that status or its tables are not free"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations/{id}/status [patch]
+func (c *ReservationController) EditReservationStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	var req EditReservationStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch req.Status {
+	case models.ReservationSeated, models.ReservationNoShow, models.ReservationCancelled:
+	default:
+		http.Error(w, "Status must be seated, no_show or cancelled", http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	reservation, err := models.SetReservationStatus(id, req.Status, actorId)
+	if err != nil {
+		if strings.Contains(err.Error(), "reservation not found") {
+			http.Error(w, "Reservation not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") || strings.Contains(err.Error(), "already") ||
+			strings.Contains(err.Error(), "not free") || strings.Contains(err.Error(), "needs cleaning") || strings.Contains(err.Error(), "no tables") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update reservation status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(reservation); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get reservation status history
+// @ID getReservationTransitions
+// @Description Get every status change of a reservation with who made it and when
+// @Tags reservations
+// @Produce json
+// @Security jwt
+// @Param id path int true "Reservation ID"
+// @Success 200 {array} GetStatusTransitionResponse "List of status changes"
+// @Failure 400 {object} string "Bad request, invalid reservation ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations/{id}/transitions [get]
+func (c *ReservationController) GetReservationTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid reservation ID", http.StatusBadRequest)
+		return
+	}
+
+	transitions, err := models.GetStatusTransitions(models.ReservationEntity, id)
+	if err != nil {
+		http.Error(w, "Failed to retrieve status history", http.StatusInternalServerError)
+		return
+	}
+	if transitions == nil {
+		transitions = []models.StatusTransition{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(transitions); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get reservations calendar feed
+// @ID getReservationCalendar
+// @Description Get today's and the coming 90 days' confirmed and seated reservations as an iCalendar feed that
+// @Description calendar apps can subscribe to. Calendar apps cannot sign in, so the feed takes the restaurant's feed
+// @Description token in the URL instead. The feed is off while no token is configured.
+// @Tags reservations
+// @Produce text/calendar
+// @Param token query string true "Feed token"
+// @Success 200 {string} string "iCalendar feed"
+// @Failure 401 {object} string "Unauthorized, invalid feed token"
+// @Failure 404 {object} string "The calendar feed is off"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations/calendar.ics [get]
+func (c *ReservationController) GetReservationCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	feedToken := config.Config.Reservations.FeedToken
+	if feedToken == "" {
+		http.Error(w, "Calendar feed is off", http.StatusNotFound)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(feedToken)) != 1 {
+		http.Error(w, "Invalid feed token", http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+	today, err := time.ParseInLocation(time.DateOnly, now.Format(time.DateOnly), time.Local)
+	if err != nil {
+		http.Error(w, "Failed to build calendar", http.StatusInternalServerError)
+		return
+	}
+	reservations, err := models.GetUpcomingReservations(today, today.AddDate(0, 0, calendarFeedDays+1))
+	if err != nil {
+		http.Error(w, "Failed to retrieve reservations", http.StatusInternalServerError)
+		return
+	}
+
+	calendar := &ical.Calendar{
+		ProdID: "-//" + config.Config.Restaurant.Name + "//Reservations//EN",
+		Name:   config.Config.Restaurant.Name + " reservations",
+	}
+	for i := range reservations {
+		calendar.Events = append(calendar.Events, models.ReservationEvent(&reservations[i], now))
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", "inline; filename=\"reservations.ics\"")
+	if err := ical.Encode(w, calendar); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetReservationImportResponse = models.ReservationImport // @name GetReservationImportResponse
+
+// @Summary Import reservations
+// @ID importReservations
+// @Description Book in the events of an .ics file from another booking tool. Events are matched to reservations by
+// @Description UID, so a file can be imported again to add what is new, and cancelled events cancel the reservation
+// @Description they match. The party size is read from X-PARTY-SIZE or the event title, such as "Jane (party of 4)".
+// @Description Each event is reported as created, duplicate, cancelled, skipped or failed with the reason.
+// @Tags reservations
+// @Accept text/calendar
+// @Produce json
+// @Security jwt
+// @Param calendar body string true "iCalendar file"
+// @Success 200 {array} GetReservationImportResponse "What became of each event"
+// @Failure 400 {object} string "Bad request, invalid iCalendar file"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage reservations"
+// @Failure 413 {object} string "The file is larger than 1 MB"
+// @Failure 500 {object} string "Internal server error"
+// @Router /reservations/import [post]
+func (c *ReservationController) ImportReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	calendar, err := ical.Parse(http.MaxBytesReader(w, r.Body, maxCalendarImportSize), time.Local)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			http.Error(w, "Calendar file is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid iCalendar file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	results, err := models.ImportReservations(calendar.Events, config.Config.Reservations.Duration, actorId, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to import reservations", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// reservationFromRequest validates a reservation request, filling in the default duration.
+func reservationFromRequest(w http.ResponseWriter, req *CreateReservationRequest) (*models.Reservation, bool) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.Email = strings.TrimSpace(req.Email)
+	req.Notes = strings.TrimSpace(req.Notes)
+
+	if req.PartySize <= 0 {
+		http.Error(w, "Party size must be positive", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.StartsAt.IsZero() {
+		http.Error(w, "Start time is required", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.StartsAt.Before(time.Now()) {
+		http.Error(w, "Start time cannot be in the past", http.StatusBadRequest)
+		return nil, false
+	}
+	if req.Name == "" || len(req.Name) > 255 {
+		http.Error(w, "Name is required and cannot be longer than 255 characters", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(req.Phone) > 32 {
+		http.Error(w, "Phone cannot be longer than 32 characters", http.StatusBadRequest)
+		return nil, false
+	}
+	if len(req.Email) > 255 {
+		http.Error(w, "Email cannot be longer than 255 characters", http.StatusBadRequest)
+		return nil, false
+	}
+
+	duration, ok := reservationDuration(w, req.Duration)
+	if !ok {
+		return nil, false
+	}
+
+	return &models.Reservation{
+		PartySize: req.PartySize,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.StartsAt.Add(duration),
+		Name:      req.Name,
+		Phone:     req.Phone,
+		Email:     req.Email,
+		Notes:     req.Notes,
+	}, true
+}
+
+// reservationDuration turns a duration in minutes into how long a booking holds its tables, the
+// configured duration when it is 0.
+func reservationDuration(w http.ResponseWriter, minutes int) (time.Duration, bool) {
+	if minutes == 0 {
+		return config.Config.Reservations.Duration, true
+	}
+	duration := time.Duration(minutes) * time.Minute
+	if minutes < 0 || duration > models.MaxReservationDuration {
+		http.Error(w, "Duration must be between 1 and 720 minutes", http.StatusBadRequest)
+		return 0, false
+	}
+	return duration, true
+}
+
+// reservationDay reads the date query parameter as a local day, today when it is left out.
+func reservationDay(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	dateStr := r.URL.Query().Get("date")
+	if dateStr == "" {
+		dateStr = time.Now().Format(time.DateOnly)
+	}
+	day, err := time.ParseInLocation(time.DateOnly, dateStr, time.Local)
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return day, false
+	}
+	return day, true
+}
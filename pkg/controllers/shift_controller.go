@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gqvz/mvc/pkg/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ShiftController struct{}
+
+func CreateShiftController() *ShiftController {
+	return &ShiftController{}
+}
+
+type GetShiftResponse = models.Shift // @name GetShiftResponse
+
+// @Summary Clock in
+// @ID clockIn
+// @Description Start a shift. Hours worked are used by tip pools that share tips by time.
+// @Tags shifts
+// @Produce json
+// @Security jwt
+// @Success 201 {object} GetShiftResponse "Shift started"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, only staff can clock in"
+// @Failure 409 {object} string "You are already clocked in"
+// @Failure 500 {object} string "Internal server error"
+// @Router /shifts/clock-in [post]
+func (c *ShiftController) ClockInHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := staffUserId(w, r)
+	if !ok {
+		return
+	}
+
+	shift, err := models.ClockIn(userId, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "already clocked in") {
+			http.Error(w, "You are already clocked in", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to clock in", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(shift); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Clock out
+// @ID clockOut
+// @Description End the current shift
+// @Tags shifts
+// @Produce json
+// @Security jwt
+// @Success 200 {object} GetShiftResponse "Shift ended"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, only staff can clock out"
+// @Failure 409 {object} string "You are not clocked in"
+// @Failure 500 {object} string "Internal server error"
+// @Router /shifts/clock-out [post]
+func (c *ShiftController) ClockOutHandler(w http.ResponseWriter, r *http.Request) {
+	userId, ok := staffUserId(w, r)
+	if !ok {
+		return
+	}
+
+	shift, err := models.ClockOut(userId, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "not clocked in") {
+			http.Error(w, "You are not clocked in", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to clock out", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(shift); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get shifts
+// @ID getShifts
+// @Description Get shifts overlapping a period, newest first. Staff see their own shifts; admins can see everyone's.
+// @Tags shifts
+// @Produce json
+// @Security jwt
+// @Param user_id query int false "User ID (admins only)"
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Success 200 {array} GetShiftResponse "List of shifts"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, only staff have shifts"
+// @Failure 500 {object} string "Internal server error"
+// @Router /shifts [get]
+func (c *ShiftController) GetShiftsHandler(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+	userIdStr := r.URL.Query().Get("user_id")
+
+	var userId int64
+	if userIdStr != "" {
+		var err error
+		userId, err = strconv.ParseInt(userIdStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	limit := 10
+	if limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 20 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	from, to, status, errMessage := reportRange(r)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	currentUserId, ok := staffUserId(w, r)
+	if !ok {
+		return
+	}
+
+	role := models.Role(r.Context().Value("role").(byte))
+	if !role.HasFlag(models.Admin) {
+		userId = currentUserId
+	}
+
+	shifts, err := models.GetShifts(userId, from, to.AddDate(0, 0, 1), limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve shifts", http.StatusInternalServerError)
+		return
+	}
+
+	if len(shifts) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(shifts); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// staffUserId returns the id of the logged-in user if they are a chef or a cashier.
+func staffUserId(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	userId, ok := r.Context().Value("userid").(int64)
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	role := models.Role(r.Context().Value("role").(byte))
+	if !role.HasFlag(models.Chef) && !role.HasFlag(models.Cashier) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return 0, false
+	}
+	return userId, true
+}
@@ -0,0 +1,450 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/notify"
+	"github.com/gqvz/mvc/pkg/qrcode"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TableController struct {
+	notifier notify.Notifier
+}
+
+func CreateTableController(notifier notify.Notifier) *TableController {
+	return &TableController{notifier: notifier}
+}
+
+type CreateTableRequest struct {
+	Number     int    `json:"number" example:"12"`
+	Section    string `json:"section" example:"patio"`
+	Capacity   int    `json:"capacity" example:"4"`
+	Combinable bool   `json:"combinable" example:"true"`
+} // @name CreateTableRequest
+
+type GetTableResponse struct {
+	models.Table
+	QRURL string `json:"qr_url"`
+} // @name GetTableResponse
+
+// @Summary Create table
+// @ID createTable
+// @Description Create a table. It starts free, with a new token for its QR code. Combinable tables of a section
+// @Description may be pushed together for reservations too big for one table.
+// @Tags tables
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param table body CreateTableRequest true "Table request"
+// @Success 201 {object} GetTableResponse "Created table"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tables"
+// @Failure 409 {object} string "Conflict, a table with the same number already exists"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables [post]
+func (c *TableController) CreateTableHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateTableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validateTableRequest(w, &req) {
+		return
+	}
+
+	table, err := models.CreateTable(req.Number, req.Section, req.Capacity, req.Combinable)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate") {
+			http.Error(w, "Table with the same number already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create table", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(tableResponse(table)); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get tables
+// @ID getTables
+// @Description Get tables ordered by section and number
+// @Tags tables
+// @Produce json
+// @Security jwt
+// @Param section query string false "Section"
+// @Param status query string false "Table status (free, occupied, reserved, needs_cleaning)"
+// @Success 200 {array} GetTableResponse "List of tables"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tables"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables [get]
+func (c *TableController) GetTablesHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.TableStatus(r.URL.Query().Get("status"))
+	switch status {
+	case "", models.TableFree, models.TableOccupied, models.TableReserved, models.TableNeedsCleaning:
+	default:
+		http.Error(w, "Invalid table status", http.StatusBadRequest)
+		return
+	}
+
+	tables, err := models.GetTables(r.URL.Query().Get("section"), status)
+	if err != nil {
+		http.Error(w, "Failed to retrieve tables", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]GetTableResponse, 0, len(tables))
+	for i := range tables {
+		response = append(response, tableResponse(&tables[i]))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get table by ID
+// @ID getTableById
+// @Description Get a table with the link its QR code opens
+// @Tags tables
+// @Produce json
+// @Security jwt
+// @Param id path int true "Table ID"
+// @Success 200 {object} GetTableResponse "Table details"
+// @Failure 400 {object} string "Bad request, invalid table ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tables"
+// @Failure 404 {object} string "Table not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables/{id} [get]
+func (c *TableController) GetTableHandler(w http.ResponseWriter, r *http.Request) {
+	table, ok := c.table(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tableResponse(table)); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Edit table
+// @ID editTable
+// @Description Edit a table's number, section, capacity and whether it is combinable. The open order at the table
+// @Description takes the new number.
+// @Tags tables
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Table ID"
+// @Param table body CreateTableRequest true "Table request"
+// @Success 200 {object} GetTableResponse "Edited table"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tables"
+// @Failure 404 {object} string "Table not found"
+// @Failure 409 {object} string "Conflict, a table with the same number already exists"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables/{id} [put]
+func (c *TableController) EditTableHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid table ID", http.StatusBadRequest)
+		return
+	}
+
+	var req CreateTableRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !validateTableRequest(w, &req) {
+		return
+	}
+
+	table, err := models.EditTable(id, req.Number, req.Section, req.Capacity, req.Combinable)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "Duplicate") {
+			http.Error(w, "Table with the same number already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to edit table", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tableResponse(table)); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Delete table
+// @ID deleteTable
+// @Description Delete a table. Tables that orders were taken at are kept for the order history.
+// @Tags tables
+// @Security jwt
+// @Param id path int true "Table ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} string "Bad request, invalid table ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tables"
+// @Failure 404 {object} string "Table not found"
+// @Failure 409 {object} string "Conflict, the table has orders"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables/{id} [delete]
+func (c *TableController) DeleteTableHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid table ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteTable(id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "has orders") {
+			http.Error(w, "Table has orders and cannot be deleted", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to delete table", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type EditTableStatusRequest struct {
+	Status models.TableStatus `json:"status" example:"free"`
+} // @name EditTableStatusRequest
+
+// @Summary Edit table status
+// @ID editTableStatus
+// @Description Move a table between free, reserved, occupied and needs_cleaning. Tables become occupied when an
+// @Description order is created at them and need cleaning once it is closed; a table with an open order stays occupied.
+// @Description A table marked free is offered to the first party on the waitlist it seats, who is notified.
+// @Tags tables
+// @Accept json
+// @Security jwt
+// @Param id path int true "Table ID"
+// @Param status body EditTableStatusRequest true "New status"
+// @Success 204 "No Content"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to change table statuses"
+// @Failure 404 {object} string "Table not found"
+// @Failure 409 {object} string "Conflict, the table cannot move to that status"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables/{id}/status [patch]
+func (c *TableController) EditTableStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid table ID", http.StatusBadRequest)
+		return
+	}
+
+	var req EditTableStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	if err := models.SetTableStatus(id, req.Status, actorId); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") || strings.Contains(err.Error(), "open order") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update table status", http.StatusInternalServerError)
+		return
+	}
+
+	if req.Status == models.TableFree {
+		entry, err := models.OfferTableToWaitlist(id, actorId, time.Now())
+		if err != nil {
+			log.Printf("Error offering table %d to the waitlist: %v", id, err)
+		} else if entry != nil {
+			notifyTableReady(r.Context(), c.notifier, entry)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get table status history
+// @ID getTableTransitions
+// @Description Get every status change of a table with who made it and when
+// @Tags tables
+// @Produce json
+// @Security jwt
+// @Param id path int true "Table ID"
+// @Success 200 {array} GetStatusTransitionResponse "List of status changes"
+// @Failure 400 {object} string "Bad request, invalid table ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view table statuses"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables/{id}/transitions [get]
+func (c *TableController) GetTableTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid table ID", http.StatusBadRequest)
+		return
+	}
+
+	writeStatusTransitions(w, models.TableEntity, id)
+}
+
+type GetFloorPlanSectionResponse = models.FloorPlanSection // @name GetFloorPlanSectionResponse
+
+// @Summary Get floor plan
+// @ID getFloorPlan
+// @Description Get every table by section with its status and the open order seated at it
+// @Tags tables
+// @Produce json
+// @Security jwt
+// @Success 200 {array} GetFloorPlanSectionResponse "Floor plan"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view the floor plan"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables/floor-plan [get]
+func (c *TableController) GetFloorPlanHandler(w http.ResponseWriter, r *http.Request) {
+	sections, err := models.GetFloorPlan()
+	if err != nil {
+		http.Error(w, "Failed to retrieve floor plan", http.StatusInternalServerError)
+		return
+	}
+	if sections == nil {
+		sections = []models.FloorPlanSection{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sections); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get table QR code
+// @ID getTableQRCode
+// @Description Get a PNG of the QR code to put on a table. It opens the guest ordering page for the table.
+// @Tags tables
+// @Produce png
+// @Security jwt
+// @Param id path int true "Table ID"
+// @Param size query int false "Pixels per module (1-32, default 8)"
+// @Success 200 {file} binary "QR code"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tables"
+// @Failure 404 {object} string "Table not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tables/{id}/qr.png [get]
+func (c *TableController) GetTableQRCodeHandler(w http.ResponseWriter, r *http.Request) {
+	size := 8
+	if sizeStr := r.URL.Query().Get("size"); sizeStr != "" {
+		var err error
+		size, err = strconv.Atoi(sizeStr)
+		if err != nil || size < 1 || size > 32 {
+			http.Error(w, "Size must be between 1 and 32", http.StatusBadRequest)
+			return
+		}
+	}
+
+	table, ok := c.table(w, r)
+	if !ok {
+		return
+	}
+
+	code, err := qrcode.Encode(guestOrderingURL(table), qrcode.Medium)
+	if err != nil {
+		log.Printf("Error encoding QR code for table %d: %v", table.ID, err)
+		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"table-%d.png\"", table.Number))
+	if err := code.WritePNG(w, size); err != nil {
+		log.Printf("Error writing QR code: %v", err)
+		return
+	}
+}
+
+func (c *TableController) table(w http.ResponseWriter, r *http.Request) (*models.Table, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid table ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	table, err := models.GetTableById(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Table not found", http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, "Failed to retrieve table", http.StatusInternalServerError)
+		return nil, false
+	}
+	return table, true
+}
+
+func validateTableRequest(w http.ResponseWriter, req *CreateTableRequest) bool {
+	req.Section = strings.TrimSpace(req.Section)
+	if req.Number <= 0 {
+		http.Error(w, "Table number must be positive", http.StatusBadRequest)
+		return false
+	}
+	if req.Capacity <= 0 {
+		http.Error(w, "Capacity must be positive", http.StatusBadRequest)
+		return false
+	}
+	if len(req.Section) > 64 {
+		http.Error(w, "Section cannot be longer than 64 characters", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// guestOrderingURL is the link a table's QR code opens.
+func guestOrderingURL(table *models.Table) string {
+	link, _ := url.Parse(config.Config.Tables.GuestOrderingURL)
+	query := link.Query()
+	query.Set("table", table.QRToken)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+func tableResponse(table *models.Table) GetTableResponse {
+	return GetTableResponse{Table: *table, QRURL: guestOrderingURL(table)}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
 	"net/http"
 	"strconv"
 	"strings"
@@ -17,7 +18,9 @@ func CreateTagController() *TagController {
 }
 
 type CreateTagRequest struct {
-	Name string `json:"name" example:"real"`
+	Name              string         `json:"name" example:"real"`
+	TaxCategoryID     int64          `json:"tax_category_id" example:"0"`
+	LoyaltyMultiplier *money.Percent `json:"loyalty_multiplier" example:"200"`
 } // @name CreateTagRequest
 
 type CreateTagResponse struct {
@@ -26,7 +29,8 @@ type CreateTagResponse struct {
 
 // @Summary Create tag
 // @ID createTag
-// @Description Create a new tag
+// @Description Create a new tag. Items with the tag earn loyalty points at loyalty_multiplier percent of the
+// @Description earn rate; an item with several tags uses the highest multiplier.
 // @Tags tags
 // @Accept json
 // @Produce json
@@ -51,7 +55,13 @@ func (c *TagController) CreateTagHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	tag, err := models.CreateTag(req.Name)
+	loyaltyMultiplier, ok := tagLoyaltyMultiplier(req.LoyaltyMultiplier)
+	if !ok {
+		http.Error(w, "Loyalty multiplier must be between 0 and "+models.MaxLoyaltyMultiplier.String(), http.StatusBadRequest)
+		return
+	}
+
+	tag, err := models.CreateTag(req.Name, req.TaxCategoryID, loyaltyMultiplier)
 	if err != nil {
 		if strings.Contains(err.Error(), "Duplicate") {
 			http.Error(w, "Tag with the same name already exists", http.StatusConflict)
@@ -73,8 +83,10 @@ func (c *TagController) CreateTagHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type GetTagResponse struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID                int64         `json:"id"`
+	Name              string        `json:"name"`
+	TaxCategoryID     int64         `json:"tax_category_id"`
+	LoyaltyMultiplier money.Percent `json:"loyalty_multiplier"`
 } // @name GetTagResponse
 
 // @Summary Get tag by ID
@@ -112,8 +124,10 @@ func (c *TagController) GetTagHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := GetTagResponse{
-		ID:   tag.ID,
-		Name: tag.Name,
+		ID:                tag.ID,
+		Name:              tag.Name,
+		TaxCategoryID:     tag.TaxCategoryID,
+		LoyaltyMultiplier: tag.LoyaltyMultiplier,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -146,8 +160,10 @@ func (c *TagController) GetTagsHandler(w http.ResponseWriter, r *http.Request) {
 	var tagResponses []GetTagResponse
 	for _, tag := range tags {
 		tagResponses = append(tagResponses, GetTagResponse{
-			ID:   tag.ID,
-			Name: tag.Name,
+			ID:                tag.ID,
+			Name:              tag.Name,
+			TaxCategoryID:     tag.TaxCategoryID,
+			LoyaltyMultiplier: tag.LoyaltyMultiplier,
 		})
 	}
 
@@ -199,7 +215,13 @@ func (c *TagController) EditTagHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tag, err := models.EditTag(id, req.Name)
+	loyaltyMultiplier, ok := tagLoyaltyMultiplier(req.LoyaltyMultiplier)
+	if !ok {
+		http.Error(w, "Loyalty multiplier must be between 0 and "+models.MaxLoyaltyMultiplier.String(), http.StatusBadRequest)
+		return
+	}
+
+	tag, err := models.EditTag(id, req.Name, req.TaxCategoryID, loyaltyMultiplier)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			http.Error(w, "Tag not found", http.StatusNotFound)
@@ -213,8 +235,10 @@ func (c *TagController) EditTagHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := EditTagResponse{
-		ID:   tag.ID,
-		Name: tag.Name,
+		ID:                tag.ID,
+		Name:              tag.Name,
+		TaxCategoryID:     tag.TaxCategoryID,
+		LoyaltyMultiplier: tag.LoyaltyMultiplier,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -224,3 +248,11 @@ func (c *TagController) EditTagHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// tagLoyaltyMultiplier defaults a missing multiplier to the plain earn rate and checks its range.
+func tagLoyaltyMultiplier(multiplier *money.Percent) (money.Percent, bool) {
+	if multiplier == nil {
+		return money.OneHundredPercent, true
+	}
+	return *multiplier, *multiplier >= 0 && *multiplier <= models.MaxLoyaltyMultiplier
+}
@@ -0,0 +1,173 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/money"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type TaxController struct{}
+
+func CreateTaxController() *TaxController {
+	return &TaxController{}
+}
+
+type CreateTaxCategoryRequest struct {
+	Name      string        `json:"name" example:"food"`
+	Rate      money.Percent `json:"rate" example:"8.875"`
+	Inclusive bool          `json:"inclusive" example:"false"`
+	IsDefault bool          `json:"is_default" example:"true"`
+} // @name CreateTaxCategoryRequest
+
+type CreateTaxCategoryResponse struct {
+	ID int64 `json:"id"`
+} // @name CreateTaxCategoryResponse
+
+// @Summary Create tax category
+// @ID createTaxCategory
+// @Description Create a tax category. Rates are percentages; inclusive categories are already part of the menu price.
+// @Description Marking a category as default unmarks the previous default.
+// @Tags taxes
+// @Accept json
+// @Produce json
+// @Param category body CreateTaxCategoryRequest true "Tax category request"
+// @Security jwt
+// @Success 201 {object} CreateTaxCategoryResponse "Created tax category"
+// @Failure 400 {object} string "Bad request, invalid tax category data"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to create tax categories"
+// @Failure 409 {object} string "Conflict, tax category already exists"
+// @Failure 500 {object} string "Internal server error"
+// @Router /taxes [post]
+func (c *TaxController) CreateTaxCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateTaxCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.Rate < 0 || req.Rate >= money.OneHundredPercent {
+		http.Error(w, "Name and a rate between 0 and 100 are required", http.StatusBadRequest)
+		return
+	}
+
+	category, err := models.CreateTaxCategory(&models.TaxCategory{
+		Name:      req.Name,
+		Rate:      req.Rate,
+		Inclusive: req.Inclusive,
+		IsDefault: req.IsDefault,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			http.Error(w, "Tax category already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to create tax category", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(CreateTaxCategoryResponse{ID: category.ID})
+	if err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type GetTaxCategoryResponse = models.TaxCategory // @name GetTaxCategoryResponse
+
+// @Summary Get tax categories
+// @ID getTaxCategories
+// @Description Get all tax categories
+// @Tags taxes
+// @Produce json
+// @Security jwt
+// @Success 200 {array} GetTaxCategoryResponse "List of tax categories"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view tax categories"
+// @Failure 500 {object} string "Internal server error"
+// @Router /taxes [get]
+func (c *TaxController) GetTaxCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := models.GetTaxCategories()
+	if err != nil {
+		http.Error(w, "Failed to retrieve tax categories", http.StatusInternalServerError)
+		return
+	}
+
+	if len(categories) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(categories); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type EditTaxCategoryRequest = CreateTaxCategoryRequest // @name EditTaxCategoryRequest
+
+// @Summary Edit tax category
+// @ID editTaxCategory
+// @Description Edit an existing tax category; payments already created keep their tax breakdown
+// @Tags taxes
+// @Accept json
+// @Param id path int true "Tax category ID"
+// @Param category body EditTaxCategoryRequest true "Tax category request"
+// @Security jwt
+// @Success 200 "Edited tax category"
+// @Failure 400 {object} string "Bad request, invalid tax category data"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to edit tax categories"
+// @Failure 404 {object} string "Tax category not found"
+// @Failure 409 {object} string "Conflict, tax category already exists"
+// @Failure 500 {object} string "Internal server error"
+// @Router /taxes/{id} [put]
+func (c *TaxController) EditTaxCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tax category ID", http.StatusBadRequest)
+		return
+	}
+
+	var req EditTaxCategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.Rate < 0 || req.Rate >= money.OneHundredPercent {
+		http.Error(w, "Name and a rate between 0 and 100 are required", http.StatusBadRequest)
+		return
+	}
+
+	err = models.EditTaxCategory(&models.TaxCategory{
+		ID:        id,
+		Name:      req.Name,
+		Rate:      req.Rate,
+		Inclusive: req.Inclusive,
+		IsDefault: req.IsDefault,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Tax category not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "already exists") {
+			http.Error(w, "Tax category already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to edit tax category", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type TipController struct{}
+
+func CreateTipController() *TipController {
+	return &TipController{}
+}
+
+type CreateTipPoolRequest struct {
+	Name    string                 `json:"name" example:"Front and kitchen"`
+	Method  models.TipPoolMethod   `json:"method" example:"role"`
+	Roles   []models.TipPoolRole   `json:"roles"`
+	Members []models.TipPoolMember `json:"members"`
+} // @name CreateTipPoolRequest
+
+type CreateTipPoolResponse struct {
+	ID int64 `json:"id"`
+} // @name CreateTipPoolResponse
+
+type GetTipPoolResponse = models.TipPool // @name GetTipPoolResponse
+
+type GetTipPayoutReportResponse = models.TipPayoutReport // @name GetTipPayoutReportResponse
+
+// @Summary Create tip pool
+// @ID createTipPool
+// @Description Create a tip pool. "role" pools give the cashier (4) and chef (2) roles a percentage each, adding up to 100;
+// @Description "hours" pools share tips by time clocked in; "points" pools share them by each member's points.
+// @Tags tips
+// @Accept json
+// @Produce json
+// @Param pool body CreateTipPoolRequest true "Tip pool request"
+// @Security jwt
+// @Success 201 {object} CreateTipPoolResponse "Created tip pool"
+// @Failure 400 {object} string "Bad request, invalid tip pool"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tip pools"
+// @Failure 404 {object} string "Member not found"
+// @Failure 409 {object} string "Conflict, tip pool already exists"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tips/pools [post]
+func (c *TipController) CreateTipPoolHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateTipPoolRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 64 {
+		http.Error(w, "A name of at most 64 characters is required", http.StatusBadRequest)
+		return
+	}
+
+	pool := &models.TipPool{
+		Name:   req.Name,
+		Method: req.Method,
+	}
+	switch req.Method {
+	case models.RoleTipPool:
+		pool.Roles = req.Roles
+	case models.PointsTipPool:
+		pool.Members = req.Members
+	}
+	if err := pool.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := models.CreateTipPool(pool)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			http.Error(w, "Tip pool already exists", http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to create tip pool", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(CreateTipPoolResponse{ID: created.ID}); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get tip pools
+// @ID getTipPools
+// @Description Get all tip pools
+// @Tags tips
+// @Produce json
+// @Security jwt
+// @Success 200 {array} GetTipPoolResponse "List of tip pools"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view tip pools"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tips/pools [get]
+func (c *TipController) GetTipPoolsHandler(w http.ResponseWriter, r *http.Request) {
+	pools, err := models.GetTipPools()
+	if err != nil {
+		http.Error(w, "Failed to retrieve tip pools", http.StatusInternalServerError)
+		return
+	}
+
+	if len(pools) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pools); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get tip pool
+// @ID getTipPool
+// @Description Get a tip pool with its role percentages or members
+// @Tags tips
+// @Produce json
+// @Param id path int true "Tip pool ID"
+// @Security jwt
+// @Success 200 {object} GetTipPoolResponse "Tip pool"
+// @Failure 400 {object} string "Bad request, invalid tip pool ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view tip pools"
+// @Failure 404 {object} string "Tip pool not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tips/pools/{id} [get]
+func (c *TipController) GetTipPoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tip pool ID", http.StatusBadRequest)
+		return
+	}
+
+	pool, err := models.GetTipPoolById(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Tip pool not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve tip pool", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(pool); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Delete tip pool
+// @ID deleteTipPool
+// @Description Delete a tip pool. Payouts are calculated on demand, so nothing already paid out changes.
+// @Tags tips
+// @Param id path int true "Tip pool ID"
+// @Security jwt
+// @Success 204 "No Content"
+// @Failure 400 {object} string "Bad request, invalid tip pool ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage tip pools"
+// @Failure 404 {object} string "Tip pool not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tips/pools/{id} [delete]
+func (c *TipController) DeleteTipPoolHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tip pool ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteTipPool(id); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Tip pool not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete tip pool", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Get tip payouts
+// @ID getTipPayouts
+// @Description Distribute the tips of payments collected in a period with a tip pool. Each tip is attributed evenly to
+// @Description the payment's cashier and the chefs who prepared its items; only that staff shares in the pool.
+// @Description Fully refunded payments are left out. Both dates are inclusive and default to today.
+// @Description format=csv returns the payouts as CSV for payroll.
+// @Tags tips
+// @Produce json
+// @Produce text/csv
+// @Param pool_id query int true "Tip pool ID"
+// @Param from query string false "First day (YYYY-MM-DD)"
+// @Param to query string false "Last day (YYYY-MM-DD)"
+// @Param format query string false "json (default) or csv"
+// @Security jwt
+// @Success 200 {object} GetTipPayoutReportResponse "Tip payouts"
+// @Failure 400 {object} string "Bad request, invalid pool or date range"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view tip payouts"
+// @Failure 404 {object} string "Tip pool not found"
+// @Failure 500 {object} string "Internal server error"
+// @Router /tips/payouts [get]
+func (c *TipController) GetTipPayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	poolId, err := strconv.ParseInt(r.URL.Query().Get("pool_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid tip pool ID", http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "Invalid format", http.StatusBadRequest)
+		return
+	}
+
+	from, to, status, errMessage := reportRange(r)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	pool, err := models.GetTipPoolById(poolId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Tip pool not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve tip pool", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := models.GetTipPayoutReport(pool, from, to)
+	if err != nil {
+		http.Error(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+	report.Currency = config.Config.Billing.Currency
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"tips-%s-%s.csv\"", report.From, report.To))
+		if err := writeTipPayoutsCSV(w, report); err != nil {
+			log.Printf("Error writing tip payouts: %v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+func writeTipPayoutsCSV(w http.ResponseWriter, report *models.TipPayoutReport) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"user_id", "name", "roles", "payments", "hours", "points", "attributed", "payout", "currency", "from", "to"}); err != nil {
+		return err
+	}
+
+	for _, payout := range report.Payouts {
+		var roles []string
+		if payout.Roles.HasFlag(models.Cashier) {
+			roles = append(roles, "cashier")
+		}
+		if payout.Roles.HasFlag(models.Chef) {
+			roles = append(roles, "chef")
+		}
+		err := writer.Write([]string{
+			strconv.FormatInt(payout.UserID, 10),
+			payout.Name,
+			strings.Join(roles, ";"),
+			strconv.Itoa(payout.Payments),
+			strconv.FormatFloat(payout.Hours, 'f', 2, 64),
+			strconv.Itoa(payout.Points),
+			payout.Attributed.String(),
+			payout.Payout.String(),
+			report.Currency,
+			report.From,
+			report.To,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
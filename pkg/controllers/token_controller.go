@@ -74,18 +74,7 @@ func (ac *TokenController) CreateTokenHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, middlewares.Claims{
-		UserID: user.ID,
-		Role:   byte(user.Role),
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    "mvc",
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(2 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
-	}, nil)
-
-	ss, err := token.SignedString([]byte(config.Config.JwtSecret))
+	ss, err := signToken(user.ID, user.Role, time.Now().Add(2*time.Hour))
 	if err != nil {
 		http.Error(w, "Error signing token", http.StatusInternalServerError)
 		return
@@ -103,3 +92,20 @@ func (ac *TokenController) CreateTokenHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 }
+
+// signToken issues the JWT the authentication middleware accepts for the user until expiresAt.
+func signToken(userId int64, role models.Role, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, middlewares.Claims{
+		UserID: userId,
+		Role:   byte(role),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "mvc",
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}, nil)
+
+	return token.SignedString([]byte(config.Config.JwtSecret))
+}
@@ -0,0 +1,409 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/notify"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type WaitlistController struct {
+	notifier notify.Notifier
+}
+
+func CreateWaitlistController(notifier notify.Notifier) *WaitlistController {
+	return &WaitlistController{notifier: notifier}
+}
+
+type CreateWaitlistEntryRequest struct {
+	Name      string `json:"name" example:"Jane Doe"`
+	PartySize int    `json:"party_size" example:"4"`
+	Phone     string `json:"phone" example:"+1 555 0100"`
+	// Quoted wait in minutes, the estimate rounded up to 5 minutes when left out
+	QuotedWait *int `json:"quoted_wait" example:"20"`
+} // @name CreateWaitlistEntryRequest
+
+type GetWaitlistEntryResponse = models.WaitlistEntry // @name GetWaitlistEntryResponse
+
+type GetWaitEstimateResponse struct {
+	PartySize int `json:"party_size" example:"4"`
+	// Estimated wait in minutes, null when no table seats the party
+	EstimatedWait *int `json:"estimated_wait" example:"17"`
+} // @name GetWaitEstimateResponse
+
+// @Summary Add party to waitlist
+// @ID createWaitlistEntry
+// @Description Add a walk-in party to the end of the waitlist. Without a quoted wait the party is quoted the estimate
+// @Description rounded up to 5 minutes.
+// @Tags waitlist
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param entry body CreateWaitlistEntryRequest true "Waitlist request"
+// @Success 201 {object} GetWaitlistEntryResponse "Created waitlist entry"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage the waitlist"
+// @Failure 409 {object} string "Conflict, no table seats the party and no wait was quoted"
+// @Failure 500 {object} string "Internal server error"
+// @Router /waitlist [post]
+func (c *WaitlistController) CreateWaitlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateWaitlistEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Phone = strings.TrimSpace(req.Phone)
+
+	if req.Name == "" || len(req.Name) > 255 {
+		http.Error(w, "Name is required and cannot be longer than 255 characters", http.StatusBadRequest)
+		return
+	}
+	if req.PartySize <= 0 {
+		http.Error(w, "Party size must be positive", http.StatusBadRequest)
+		return
+	}
+	if len(req.Phone) > 32 {
+		http.Error(w, "Phone cannot be longer than 32 characters", http.StatusBadRequest)
+		return
+	}
+	entry := &models.WaitlistEntry{Name: req.Name, PartySize: req.PartySize, Phone: req.Phone, QuotedWait: -1}
+	if req.QuotedWait != nil {
+		if *req.QuotedWait < 0 || *req.QuotedWait > 24*60 {
+			http.Error(w, "Quoted wait must be between 0 and 1440 minutes", http.StatusBadRequest)
+			return
+		}
+		entry.QuotedWait = *req.QuotedWait
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	entry, err := models.CreateWaitlistEntry(entry, actorId, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "no table seats") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to add party to waitlist", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get waitlist
+// @ID getWaitlist
+// @Description Get the parties waiting or told their table is ready, in the order they joined. Waiting parties have
+// @Description an estimated wait from the age of the open orders and the average time a table is taken.
+// @Tags waitlist
+// @Produce json
+// @Security jwt
+// @Success 200 {array} GetWaitlistEntryResponse "Waitlist"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage the waitlist"
+// @Failure 500 {object} string "Internal server error"
+// @Router /waitlist [get]
+func (c *WaitlistController) GetWaitlistHandler(w http.ResponseWriter, r *http.Request) {
+	entries, err := models.GetWaitlist(time.Now())
+	if err != nil {
+		http.Error(w, "Failed to retrieve waitlist", http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []models.WaitlistEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entries); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Estimate wait
+// @ID getWaitEstimate
+// @Description Estimate how long a party joining the waitlist now would wait, behind the parties already waiting
+// @Tags waitlist
+// @Produce json
+// @Security jwt
+// @Param party_size query int true "Party size"
+// @Success 200 {object} GetWaitEstimateResponse "Estimated wait"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage the waitlist"
+// @Failure 500 {object} string "Internal server error"
+// @Router /waitlist/estimate [get]
+func (c *WaitlistController) GetWaitEstimateHandler(w http.ResponseWriter, r *http.Request) {
+	partySize, err := strconv.Atoi(r.URL.Query().Get("party_size"))
+	if err != nil || partySize <= 0 {
+		http.Error(w, "Party size must be a positive number", http.StatusBadRequest)
+		return
+	}
+
+	minutes, fits, err := models.EstimateWait(partySize, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to estimate wait", http.StatusInternalServerError)
+		return
+	}
+	response := GetWaitEstimateResponse{PartySize: partySize}
+	if fits {
+		response.EstimatedWait = &minutes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type NotifyWaitlistEntryRequest struct {
+	// Table offered to the party, optional
+	TableID int64 `json:"table_id" example:"3"`
+} // @name NotifyWaitlistEntryRequest
+
+// @Summary Notify waiting party
+// @ID notifyWaitlistEntry
+// @Description Tell a party their table is ready through the configured notifier, optionally holding a table for them.
+// @Description Parties are told on their own when a table they fit is marked free; this sends the message again.
+// @Tags waitlist
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Waitlist entry ID"
+// @Param notification body NotifyWaitlistEntryRequest false "Table offered"
+// @Success 200 {object} GetWaitlistEntryResponse "Notified waitlist entry"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage the waitlist"
+// @Failure 404 {object} string "Waitlist entry not found"
+// @Failure 409 {object} string "Conflict, the party is no longer waiting"
+// @Failure 500 {object} string "Internal server error"
+// @Router /waitlist/{id}/notify [post]
+func (c *WaitlistController) NotifyWaitlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+		return
+	}
+
+	var req NotifyWaitlistEntryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.TableID < 0 {
+		http.Error(w, "Invalid table ID", http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	entry, err := models.NotifyWaitlistEntry(id, req.TableID, actorId, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if strings.Contains(err.Error(), "foreign key") {
+			http.Error(w, "Table not found", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to notify party", http.StatusInternalServerError)
+		return
+	}
+	notifyTableReady(r.Context(), c.notifier, entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type SeatWaitlistEntryRequest struct {
+	// Table to seat the party at, the table offered to them when left out
+	TableID int64 `json:"table_id" example:"3"`
+} // @name SeatWaitlistEntryRequest
+
+// @Summary Seat waiting party
+// @ID seatWaitlistEntry
+// @Description Seat a party from the waitlist, opening an order for them at a free table
+// @Tags waitlist
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Waitlist entry ID"
+// @Param seating body SeatWaitlistEntryRequest false "Table to seat the party at"
+// @Success 200 {object} GetWaitlistEntryResponse "Seated waitlist entry with its order"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage the waitlist"
+// @Failure 404 {object} string "Waitlist entry not found"
+// @Failure 409 {object} string "Conflict, the party is no longer waiting or the table is not free"
+// @Failure 500 {object} string "Internal server error"
+// @Router /waitlist/{id}/seat [post]
+func (c *WaitlistController) SeatWaitlistEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+		return
+	}
+
+	var req SeatWaitlistEntryRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.TableID < 0 {
+		http.Error(w, "Invalid table ID", http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	entry, err := models.SeatWaitlistEntry(id, req.TableID, actorId, time.Now())
+	if err != nil {
+		if strings.Contains(err.Error(), "waitlist") && strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "table not found") || strings.Contains(err.Error(), "no table was offered") {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") || strings.Contains(err.Error(), "already") ||
+			strings.Contains(err.Error(), "not free") || strings.Contains(err.Error(), "needs cleaning") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to seat party", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+type EditWaitlistStatusRequest struct {
+	Status models.WaitlistStatus `json:"status" example:"cancelled"`
+} // @name EditWaitlistStatusRequest
+
+// @Summary Change waitlist status
+// @ID editWaitlistStatus
+// @Description Take a party off the waitlist, or put a party told their table is ready back to waiting when they do not
+// @Description turn up yet, letting the table go
+// @Tags waitlist
+// @Accept json
+// @Produce json
+// @Security jwt
+// @Param id path int true "Waitlist entry ID"
+// @Param status body EditWaitlistStatusRequest true "New status"
+// @Success 200 {object} GetWaitlistEntryResponse "Waitlist entry"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage the waitlist"
+// @Failure 404 {object} string "Waitlist entry not found"
+// @Failure 409 {object} string "Conflict, the party cannot move to that status"
+// @Failure 500 {object} string "Internal server error"
+// @Router /waitlist/{id}/status [patch]
+func (c *WaitlistController) EditWaitlistStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+		return
+	}
+
+	var req EditWaitlistStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	switch req.Status {
+	case models.WaitlistWaiting, models.WaitlistCancelled:
+	default:
+		http.Error(w, "Status must be waiting or cancelled", http.StatusBadRequest)
+		return
+	}
+
+	actorId := r.Context().Value("userid").(int64)
+	entry, err := models.SetWaitlistStatus(id, req.Status, actorId)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Waitlist entry not found", http.StatusNotFound)
+			return
+		}
+		if strings.Contains(err.Error(), "invalid status transition") {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to update waitlist status", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(entry); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Get waitlist status history
+// @ID getWaitlistTransitions
+// @Description Get every status change of a waitlist entry with who made it and when
+// @Tags waitlist
+// @Produce json
+// @Security jwt
+// @Param id path int true "Waitlist entry ID"
+// @Success 200 {array} GetStatusTransitionResponse "List of status changes"
+// @Failure 400 {object} string "Bad request, invalid waitlist entry ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to manage the waitlist"
+// @Failure 500 {object} string "Internal server error"
+// @Router /waitlist/{id}/transitions [get]
+func (c *WaitlistController) GetWaitlistTransitionsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid waitlist entry ID", http.StatusBadRequest)
+		return
+	}
+
+	writeStatusTransitions(w, models.WaitlistEntity, id)
+}
+
+// notifyTableReady tells a party their table is ready. The party is already marked notified, so a
+// failed message is only logged; staff see who was told on the waitlist and can send it again.
+func notifyTableReady(ctx context.Context, notifier notify.Notifier, entry *models.WaitlistEntry) {
+	text := fmt.Sprintf("Hi %s, your table for %d at %s is ready. Please come to the host stand.",
+		entry.Name, entry.PartySize, config.Config.Restaurant.Name)
+	if entry.TableNumber != 0 {
+		text = fmt.Sprintf("Hi %s, table %d for your party of %d at %s is ready. Please come to the host stand.",
+			entry.Name, entry.TableNumber, entry.PartySize, config.Config.Restaurant.Name)
+	}
+
+	if err := notifier.Send(ctx, notify.Message{To: entry.Phone, Text: text}); err != nil {
+		log.Printf("Error sending %s notification for waitlist entry %d: %v", notifier.Name(), entry.ID, err)
+	}
+}
@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/gateway"
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/printer"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxWebhookSize = 1 << 20
+
+type WebhookController struct {
+	provider gateway.PaymentProvider
+	spooler  *printer.Spooler
+}
+
+func CreateWebhookController(provider gateway.PaymentProvider, spooler *printer.Spooler) *WebhookController {
+	return &WebhookController{provider: provider, spooler: spooler}
+}
+
+// @Summary Receive payment webhook
+// @ID receivePaymentWebhook
+// @Description Receive an asynchronous status change from the payment provider. The X-Signature header must be
+// @Description "t=<unix seconds>,v1=<hex HMAC-SHA256 of '<t>.<body>'>" signed with the webhook secret and the
+// @Description timestamp must be within the configured tolerance. Events are deduplicated by their id and stored for replay;
+// @Description a retry of an event that failed to apply is applied again. Events are matched to payments by reference, or
+// @Description by payment_id for payments whose authorization never returned a reference.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "Payment provider"
+// @Param X-Signature header string true "Webhook signature"
+// @Param event body gateway.WebhookEvent true "Webhook event"
+// @Success 200 {object} string "Event processed or already received"
+// @Failure 400 {object} string "Bad request, invalid event"
+// @Failure 401 {object} string "Invalid signature"
+// @Failure 404 {object} string "Unknown provider or payment"
+// @Failure 409 {object} string "Payment is no longer in a state the event applies to"
+// @Failure 500 {object} string "Internal server error"
+// @Failure 503 {object} string "Webhooks are not configured"
+// @Router /payments/webhooks/{provider} [post]
+func (c *WebhookController) ReceiveWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	provider := mux.Vars(r)["provider"]
+	if provider != c.provider.Name() {
+		http.Error(w, "Unknown payment provider", http.StatusNotFound)
+		return
+	}
+
+	secret := config.Config.Payments.WebhookSecret
+	if secret == "" {
+		http.Error(w, "Webhooks are not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookSize))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	err = gateway.VerifySignature([]byte(secret), r.Header.Get(gateway.SignatureHeader), payload, time.Now(), config.Config.Payments.WebhookTolerance)
+	if err != nil {
+		http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	var event gateway.WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil || event.ID == "" || event.Type == "" {
+		http.Error(w, "Invalid webhook event", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := models.CreateWebhookEvent(&models.WebhookEvent{
+		Provider:   provider,
+		EventID:    event.ID,
+		Type:       event.Type,
+		Payload:    string(payload),
+		ReceivedAt: time.Now(),
+	})
+	if err != nil {
+		if !strings.Contains(err.Error(), "already exists") {
+			http.Error(w, "Failed to store webhook event", http.StatusInternalServerError)
+			return
+		}
+
+		// only events that were applied are duplicates; a retry of one that failed, for example
+		// because it arrived before the payment was saved, is applied again
+		stored, err = models.GetWebhookEventByEventId(provider, event.ID)
+		if err != nil {
+			http.Error(w, "Failed to retrieve webhook event", http.StatusInternalServerError)
+			return
+		}
+		if stored.ProcessedAt != nil && stored.Error == "" {
+			writeWebhookResponse(w, "Event already received")
+			return
+		}
+	}
+
+	status, errMessage := c.applyWebhookEvent(stored, &event)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	writeWebhookResponse(w, "Event processed")
+}
+
+type GetWebhookEventResponse = models.WebhookEvent // @name GetWebhookEventResponse
+
+// @Summary Get webhook events
+// @ID getWebhookEvents
+// @Description Get received payment webhook events with their raw payloads
+// @Tags payments
+// @Produce json
+// @Param provider query string false "Payment provider"
+// @Param failed query bool false "Only events that failed to apply"
+// @Param limit query int false "Limit"
+// @Param offset query int false "Offset"
+// @Security jwt
+// @Success 200 {array} GetWebhookEventResponse "List of webhook events"
+// @Failure 400 {object} string "Bad request, invalid parameters"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to view webhook events"
+// @Failure 500 {object} string "Internal server error"
+// @Router /payments/webhooks/events [get]
+func (c *WebhookController) GetWebhookEventsHandler(w http.ResponseWriter, r *http.Request) {
+	limitStr := r.URL.Query().Get("limit")
+	offsetStr := r.URL.Query().Get("offset")
+
+	limit := 10
+	if limitStr != "" {
+		var err error
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 20 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if offsetStr != "" {
+		var err error
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	events, err := models.GetWebhookEvents(r.URL.Query().Get("provider"), r.URL.Query().Get("failed") == "true", limit, offset)
+	if err != nil {
+		http.Error(w, "Failed to retrieve webhook events", http.StatusInternalServerError)
+		return
+	}
+
+	if len(events) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		log.Printf("Error encoding response: %v", err)
+		return
+	}
+}
+
+// @Summary Replay webhook event
+// @ID replayWebhookEvent
+// @Description Apply a stored webhook event again, for example after fixing the payment it refers to.
+// @Description The stored payload was verified when it was received, so it is not signed again.
+// @Tags payments
+// @Produce json
+// @Param id path int true "Webhook event ID"
+// @Security jwt
+// @Success 200 {object} string "Event processed"
+// @Failure 400 {object} string "Bad request, invalid event ID"
+// @Failure 401 {object} string "Unauthorized, invalid token"
+// @Failure 403 {object} string "Forbidden, you are not allowed to replay webhook events"
+// @Failure 404 {object} string "Webhook event or payment not found"
+// @Failure 409 {object} string "Payment is no longer in a state the event applies to"
+// @Failure 500 {object} string "Internal server error"
+// @Router /payments/webhooks/events/{id}/replay [post]
+func (c *WebhookController) ReplayWebhookEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid webhook event ID", http.StatusBadRequest)
+		return
+	}
+
+	stored, err := models.GetWebhookEventById(id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			http.Error(w, "Webhook event not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to retrieve webhook event", http.StatusInternalServerError)
+		return
+	}
+
+	var event gateway.WebhookEvent
+	if err := json.Unmarshal([]byte(stored.Payload), &event); err != nil {
+		http.Error(w, "Stored webhook event is invalid", http.StatusInternalServerError)
+		return
+	}
+
+	status, errMessage := c.applyWebhookEvent(stored, &event)
+	if errMessage != "" {
+		http.Error(w, errMessage, status)
+		return
+	}
+
+	writeWebhookResponse(w, "Event processed")
+}
+
+// applyWebhookEvent moves the payment the event refers to into the status the event reports and
+// records the outcome on the stored event.
+func (c *WebhookController) applyWebhookEvent(stored *models.WebhookEvent, event *gateway.WebhookEvent) (int, string) {
+	status, errMessage := http.StatusOK, ""
+	defer func() {
+		if err := models.MarkWebhookEventProcessed(stored.ID, time.Now(), errMessage); err != nil {
+			log.Printf("Error marking webhook event %d as processed: %v", stored.ID, err)
+		}
+	}()
+
+	var target models.PaymentStatus
+	switch event.Type {
+	case gateway.EventPaymentSucceeded:
+		target = models.Accepted
+	case gateway.EventPaymentFailed:
+		target = models.Declined
+	case gateway.EventPaymentPending:
+		target = models.Processing
+	default:
+		// unknown event types are acknowledged so the provider stops retrying them
+		return status, errMessage
+	}
+
+	payment, err := models.GetPaymentByProviderRef(stored.Provider, event.Reference)
+	if err != nil && strings.Contains(err.Error(), "not found") && event.PaymentID != 0 {
+		// the authorization may have gone through while the provider could not be reached
+		payment, err = models.GetUnconfirmedPayment(stored.Provider, event.PaymentID)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			status, errMessage = http.StatusNotFound, "Payment not found"
+			return status, errMessage
+		}
+		status, errMessage = http.StatusInternalServerError, "Failed to retrieve payment"
+		return status, errMessage
+	}
+
+	if payment.Status == target {
+		return status, errMessage
+	}
+	if payment.Status != models.Processing {
+		status, errMessage = http.StatusConflict, "Payment is already "+string(payment.Status)
+		return status, errMessage
+	}
+
+	reason := ""
+	if target == models.Declined {
+		reason = event.Message
+	}
+	if err := models.SetPaymentProviderResult(payment.ID, target, stored.Provider, event.Reference, reason); err != nil {
+		if strings.Contains(err.Error(), "invalid status transition") {
+			status, errMessage = http.StatusConflict, "Payment is no longer processing"
+			return status, errMessage
+		}
+		status, errMessage = http.StatusInternalServerError, "Failed to update payment"
+		return status, errMessage
+	}
+	if target == models.Accepted {
+		paymentAccepted(c.spooler, payment.ID)
+	}
+
+	return status, errMessage
+}
+
+func writeWebhookResponse(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(message); err != nil {
+		log.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+	"sync"
+	"time"
+)
+
+// Tokens understood by the mock provider; any other token is approved straight away.
+const (
+	MockDeclineToken           = "tok_decline"
+	MockInsufficientFundsToken = "tok_insufficient_funds"
+	MockPendingToken           = "tok_pending"
+	MockSlowToken              = "tok_slow"
+	MockErrorToken             = "tok_error"
+)
+
+type mockCharge struct {
+	authorized money.Money
+	captured   money.Money
+	refunded   money.Money
+	status     Status
+	voided     bool
+}
+
+// MockProvider simulates a card processor in memory, so payments can be exercised locally
+// and in tests without network access.
+type MockProvider struct {
+	delay   time.Duration
+	mutex   sync.Mutex
+	next    int64
+	charges map[string]*mockCharge
+}
+
+func NewMockProvider(delay time.Duration) *MockProvider {
+	return &MockProvider{delay: delay, charges: make(map[string]*mockCharge)}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if req.Amount <= 0 {
+		return &Result{Status: Declined, Message: "invalid amount"}, nil
+	}
+
+	switch req.Token {
+	case MockErrorToken:
+		return nil, fmt.Errorf("mock provider unavailable")
+	case MockSlowToken:
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.next++
+	reference := fmt.Sprintf("mock_%d", p.next)
+	charge := &mockCharge{authorized: req.Amount, status: Approved}
+	p.charges[reference] = charge
+
+	switch req.Token {
+	case MockDeclineToken:
+		charge.status = Declined
+		return &Result{Reference: reference, Status: Declined, Message: "card declined"}, nil
+	case MockInsufficientFundsToken:
+		charge.status = Declined
+		return &Result{Reference: reference, Status: Declined, Message: "insufficient funds"}, nil
+	case MockPendingToken:
+		charge.status = Pending
+		return &Result{Reference: reference, Status: Pending, Message: "authorization pending"}, nil
+	}
+	return &Result{Reference: reference, Status: Approved}, nil
+}
+
+func (p *MockProvider) Capture(ctx context.Context, reference string, amount money.Money) (*Result, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge, err := p.charge(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case charge.status != Approved || charge.voided:
+		return &Result{Reference: reference, Status: Declined, Message: "authorization is not approved"}, nil
+	case charge.captured > 0:
+		return &Result{Reference: reference, Status: Declined, Message: "already captured"}, nil
+	case amount <= 0 || amount > charge.authorized:
+		return &Result{Reference: reference, Status: Declined, Message: "amount exceeds authorization"}, nil
+	}
+
+	charge.captured = amount
+	return &Result{Reference: reference, Status: Approved}, nil
+}
+
+func (p *MockProvider) Void(ctx context.Context, reference string) (*Result, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge, err := p.charge(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if charge.captured > 0 {
+		return &Result{Reference: reference, Status: Declined, Message: "captured charges must be refunded"}, nil
+	}
+
+	charge.voided = true
+	return &Result{Reference: reference, Status: Approved}, nil
+}
+
+func (p *MockProvider) Refund(ctx context.Context, reference string, amount money.Money) (*Result, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge, err := p.charge(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount <= 0 || charge.refunded+amount > charge.captured {
+		return &Result{Reference: reference, Status: Declined, Message: "amount exceeds captured funds"}, nil
+	}
+
+	charge.refunded += amount
+	return &Result{Reference: reference, Status: Approved}, nil
+}
+
+// Settle decides a pending authorization, standing in for the processor finishing it asynchronously.
+func (p *MockProvider) Settle(reference string, status Status) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	charge, err := p.charge(reference)
+	if err != nil {
+		return err
+	}
+	if charge.status != Pending {
+		return fmt.Errorf("charge '%s' is not pending", reference)
+	}
+	charge.status = status
+	return nil
+}
+
+func (p *MockProvider) charge(reference string) (*mockCharge, error) {
+	charge, ok := p.charges[reference]
+	if !ok {
+		return nil, fmt.Errorf("charge '%s' not found", reference)
+	}
+	return charge, nil
+}
@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMockProviderApprovesAndCaptures(t *testing.T) {
+	provider := NewMockProvider(0)
+	ctx := context.Background()
+
+	result, err := provider.Authorize(ctx, AuthorizeRequest{PaymentID: 1, Amount: 2500, Currency: "USD", Token: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if result.Status != Approved || result.Reference == "" {
+		t.Fatalf("Expected an approved authorization with a reference, got %+v", result)
+	}
+
+	capture, err := provider.Capture(ctx, result.Reference, 2600)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if capture.Status != Declined {
+		t.Errorf("Expected capturing more than authorized to be declined, got %+v", capture)
+	}
+
+	capture, err = provider.Capture(ctx, result.Reference, 2500)
+	if err != nil || capture.Status != Approved {
+		t.Fatalf("Expected capture to be approved, got %+v, %v", capture, err)
+	}
+
+	void, err := provider.Void(ctx, result.Reference)
+	if err != nil || void.Status != Declined {
+		t.Errorf("Expected voiding a captured charge to be declined, got %+v, %v", void, err)
+	}
+
+	refund, err := provider.Refund(ctx, result.Reference, 1000)
+	if err != nil || refund.Status != Approved {
+		t.Fatalf("Expected partial refund to be approved, got %+v, %v", refund, err)
+	}
+	refund, err = provider.Refund(ctx, result.Reference, 1501)
+	if err != nil || refund.Status != Declined {
+		t.Errorf("Expected refunding more than captured to be declined, got %+v, %v", refund, err)
+	}
+}
+
+func TestMockProviderDeclines(t *testing.T) {
+	provider := NewMockProvider(0)
+	for _, token := range []string{MockDeclineToken, MockInsufficientFundsToken} {
+		result, err := provider.Authorize(context.Background(), AuthorizeRequest{Amount: 1000, Token: token})
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", token, err)
+		}
+		if result.Status != Declined || result.Message == "" {
+			t.Errorf("Expected %s to be declined with a message, got %+v", token, result)
+		}
+
+		capture, err := provider.Capture(context.Background(), result.Reference, 1000)
+		if err != nil || capture.Status != Declined {
+			t.Errorf("Expected capturing a declined authorization to fail, got %+v, %v", capture, err)
+		}
+	}
+}
+
+func TestMockProviderPendingAndSettle(t *testing.T) {
+	provider := NewMockProvider(0)
+	result, err := provider.Authorize(context.Background(), AuthorizeRequest{Amount: 1000, Token: MockPendingToken})
+	if err != nil || result.Status != Pending {
+		t.Fatalf("Expected a pending authorization, got %+v, %v", result, err)
+	}
+
+	capture, _ := provider.Capture(context.Background(), result.Reference, 1000)
+	if capture.Status != Declined {
+		t.Errorf("Expected capturing a pending authorization to fail, got %+v", capture)
+	}
+
+	if err := provider.Settle(result.Reference, Approved); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	capture, _ = provider.Capture(context.Background(), result.Reference, 1000)
+	if capture.Status != Approved {
+		t.Errorf("Expected capture after settling to be approved, got %+v", capture)
+	}
+
+	if err := provider.Settle("mock_unknown", Approved); err == nil {
+		t.Errorf("Expected settling an unknown charge to fail")
+	}
+}
+
+func TestMockProviderDelayAndErrors(t *testing.T) {
+	provider := NewMockProvider(50 * time.Millisecond)
+
+	start := time.Now()
+	result, err := provider.Authorize(context.Background(), AuthorizeRequest{Amount: 1000, Token: MockSlowToken})
+	if err != nil || result.Status != Approved {
+		t.Fatalf("Expected slow authorization to be approved, got %+v, %v", result, err)
+	}
+	if time.Since(start) < 50*time.Millisecond {
+		t.Errorf("Expected the slow token to be delayed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := provider.Authorize(ctx, AuthorizeRequest{Amount: 1000, Token: MockSlowToken}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the delay to respect the context, got %v", err)
+	}
+
+	if _, err := provider.Authorize(context.Background(), AuthorizeRequest{Amount: 1000, Token: MockErrorToken}); err == nil {
+		t.Errorf("Expected the error token to fail")
+	}
+}
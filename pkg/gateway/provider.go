@@ -0,0 +1,50 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/money"
+)
+
+type Status string
+
+const (
+	Approved Status = "approved"
+	Declined Status = "declined"
+	// Pending means the processor has not decided yet; the outcome arrives later.
+	Pending Status = "pending"
+)
+
+type Result struct {
+	Reference string
+	Status    Status
+	Message   string
+}
+
+type AuthorizeRequest struct {
+	PaymentID int64
+	Amount    money.Money
+	Currency  string
+	// Token identifies the card or wallet; it is issued to the client by the provider.
+	Token string
+}
+
+// PaymentProvider talks to a card processor. Errors are reserved for failures to reach the
+// processor; declines are reported through the result.
+type PaymentProvider interface {
+	Name() string
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	Capture(ctx context.Context, reference string, amount money.Money) (*Result, error)
+	Void(ctx context.Context, reference string) (*Result, error)
+	Refund(ctx context.Context, reference string, amount money.Money) (*Result, error)
+}
+
+func NewProvider(paymentsConfig config.PaymentsConfig) (PaymentProvider, error) {
+	switch paymentsConfig.Provider {
+	case "mock":
+		return NewMockProvider(paymentsConfig.MockDelay), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider '%s'", paymentsConfig.Provider)
+	}
+}
@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("whsec_test")
+	payload := []byte(`{"id":"evt_1","type":"payment.succeeded","reference":"mock_1"}`)
+	now := time.Unix(1_750_000_000, 0)
+
+	header := Sign(secret, now, payload)
+	if err := VerifySignature(secret, header, payload, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("Expected signature to verify, got %v", err)
+	}
+
+	if err := VerifySignature([]byte("other"), header, payload, now, 5*time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a wrong secret to be rejected, got %v", err)
+	}
+
+	tampered := []byte(`{"id":"evt_1","type":"payment.succeeded","reference":"mock_2"}`)
+	if err := VerifySignature(secret, header, tampered, now, 5*time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Expected a tampered payload to be rejected, got %v", err)
+	}
+
+	if err := VerifySignature(secret, header, payload, now.Add(10*time.Minute), 5*time.Minute); !errors.Is(err, ErrStaleSignature) {
+		t.Errorf("Expected an old timestamp to be rejected, got %v", err)
+	}
+
+	if err := VerifySignature(secret, header, payload, now.Add(-10*time.Minute), 5*time.Minute); !errors.Is(err, ErrStaleSignature) {
+		t.Errorf("Expected a future timestamp to be rejected, got %v", err)
+	}
+}
+
+func TestVerifySignatureMalformedHeaders(t *testing.T) {
+	secret := []byte("whsec_test")
+	payload := []byte(`{}`)
+	now := time.Unix(1_750_000_000, 0)
+
+	for _, header := range []string{"", "t=1750000000", "v1=abcd", "t=abc,v1=abcd", "garbage"} {
+		if err := VerifySignature(secret, header, payload, now, time.Minute); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("Expected header %q to be rejected, got %v", header, err)
+		}
+	}
+
+	// a rotated secret may send several signatures; one valid signature is enough
+	header := Sign(secret, now, payload) + ",v1=00ff"
+	if err := VerifySignature(secret, header, payload, now, time.Minute); err != nil {
+		t.Errorf("Expected header with an extra signature to verify, got %v", err)
+	}
+}
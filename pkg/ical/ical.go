@@ -0,0 +1,389 @@
+// Package ical reads and writes the part of iCalendar (RFC 5545) calendar apps and booking tools
+// use to exchange events: VEVENTs with their times, text properties and X- extensions.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLength is the longest a content line may be, in octets, before it is folded.
+const maxLineLength = 75
+
+const (
+	utcFormat      = "20060102T150405Z"
+	localFormat    = "20060102T150405"
+	dateOnlyFormat = "20060102"
+)
+
+var ErrNoCalendar = errors.New("ical: no VCALENDAR found")
+
+type Calendar struct {
+	ProdID string
+	// Name is shown by calendar apps that subscribe to the calendar.
+	Name   string
+	Events []Event
+}
+
+type Event struct {
+	UID         string
+	Stamp       time.Time
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	// Status is TENTATIVE, CONFIRMED or CANCELLED.
+	Status   string
+	Contacts []string
+	// Attendees holds the calendar addresses of the attendees, such as "mailto:jane@example.com".
+	Attendees []string
+	// Extra holds X- properties by upper case name.
+	Extra map[string]string
+}
+
+// Encode writes the calendar with CRLF line endings, folding lines longer than 75 octets.
+func Encode(w io.Writer, calendar *Calendar) error {
+	bw := bufio.NewWriter(w)
+	write := func(name string, value string) {
+		writeLine(bw, name+":"+value)
+	}
+
+	write("BEGIN", "VCALENDAR")
+	write("VERSION", "2.0")
+	write("PRODID", escapeText(calendar.ProdID))
+	write("CALSCALE", "GREGORIAN")
+	if calendar.Name != "" {
+		write("X-WR-CALNAME", escapeText(calendar.Name))
+	}
+
+	for _, event := range calendar.Events {
+		write("BEGIN", "VEVENT")
+		write("UID", escapeText(event.UID))
+		write("DTSTAMP", event.Stamp.UTC().Format(utcFormat))
+		write("DTSTART", event.Start.UTC().Format(utcFormat))
+		write("DTEND", event.End.UTC().Format(utcFormat))
+		if event.Summary != "" {
+			write("SUMMARY", escapeText(event.Summary))
+		}
+		if event.Description != "" {
+			write("DESCRIPTION", escapeText(event.Description))
+		}
+		if event.Location != "" {
+			write("LOCATION", escapeText(event.Location))
+		}
+		if event.Status != "" {
+			write("STATUS", event.Status)
+		}
+		for _, contact := range event.Contacts {
+			write("CONTACT", escapeText(contact))
+		}
+		for _, attendee := range event.Attendees {
+			write("ATTENDEE", attendee)
+		}
+
+		names := make([]string, 0, len(event.Extra))
+		for name := range event.Extra {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			write(strings.ToUpper(name), escapeText(event.Extra[name]))
+		}
+		write("END", "VEVENT")
+	}
+
+	write("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+// writeLine folds a content line into lines of at most 75 octets, each continuation starting with a
+// space, without splitting a UTF-8 character.
+func writeLine(w *bufio.Writer, line string) {
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// the space starting a continuation line counts towards its length
+		limit = maxLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+// escapeText escapes a TEXT value.
+func escapeText(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(value)
+}
+
+// unescapeText reverses escapeText.
+func unescapeText(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// property is a parsed content line.
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads every VEVENT of the first VCALENDAR. Times with a TZID are read in that zone when
+// it is known and in loc otherwise, as are floating times. An event without an end lasts for its
+// DURATION, or to the end of the day for all day events, or ends when it starts.
+func Parse(r io.Reader, loc *time.Location) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var calendar *Calendar
+	var event *Event
+	var duration time.Duration
+	var hasDuration, allDay bool
+	depth := 0
+
+	for number, line := range lines {
+		if line == "" {
+			continue
+		}
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %w", number+1, err)
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCALENDAR") && calendar == nil:
+			calendar = &Calendar{}
+			continue
+		case calendar == nil:
+			continue
+		case prop.name == "END" && strings.EqualFold(prop.value, "VCALENDAR") && depth == 0:
+			return calendar, nil
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT") && depth == 0:
+			event = &Event{}
+			duration, hasDuration, allDay = 0, false, false
+			depth++
+			continue
+		case prop.name == "BEGIN":
+			// components other than VEVENT, and those nested in one such as VALARM, are skipped
+			depth++
+			continue
+		case prop.name == "END" && event != nil && depth == 1:
+			if event.End.IsZero() {
+				switch {
+				case hasDuration:
+					event.End = event.Start.Add(duration)
+				case allDay:
+					event.End = event.Start.AddDate(0, 0, 1)
+				default:
+					event.End = event.Start
+				}
+			}
+			calendar.Events = append(calendar.Events, *event)
+			event = nil
+			depth--
+			continue
+		case prop.name == "END":
+			depth--
+			continue
+		}
+
+		if depth == 0 {
+			switch prop.name {
+			case "PRODID":
+				calendar.ProdID = unescapeText(prop.value)
+			case "X-WR-CALNAME":
+				calendar.Name = unescapeText(prop.value)
+			}
+			continue
+		}
+		if event == nil || depth != 1 {
+			continue
+		}
+
+		switch prop.name {
+		case "UID":
+			event.UID = unescapeText(prop.value)
+		case "DTSTAMP":
+			event.Stamp, _, err = parseTime(prop, loc)
+		case "DTSTART":
+			event.Start, allDay, err = parseTime(prop, loc)
+		case "DTEND":
+			event.End, _, err = parseTime(prop, loc)
+		case "DURATION":
+			duration, err = ParseDuration(prop.value)
+			hasDuration = err == nil
+		case "SUMMARY":
+			event.Summary = unescapeText(prop.value)
+		case "DESCRIPTION":
+			event.Description = unescapeText(prop.value)
+		case "LOCATION":
+			event.Location = unescapeText(prop.value)
+		case "STATUS":
+			event.Status = strings.ToUpper(prop.value)
+		case "CONTACT":
+			event.Contacts = append(event.Contacts, unescapeText(prop.value))
+		case "ATTENDEE":
+			event.Attendees = append(event.Attendees, prop.value)
+		default:
+			if strings.HasPrefix(prop.name, "X-") {
+				if event.Extra == nil {
+					event.Extra = map[string]string{}
+				}
+				event.Extra[prop.name] = unescapeText(prop.value)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ical: line %d: %s: %w", number+1, prop.name, err)
+		}
+	}
+
+	if calendar == nil {
+		return nil, ErrNoCalendar
+	}
+	return nil, fmt.Errorf("ical: VCALENDAR is not closed")
+}
+
+// unfold joins folded lines back together. Both CRLF and bare LF line endings are accepted.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1<<20)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its upper case name, parameters and value. Parameter values
+// may be quoted, and quoted ones may contain ':', ';' and ','.
+func parseLine(line string) (property, error) {
+	prop := property{params: map[string]string{}}
+
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return prop, fmt.Errorf("invalid content line %q", line)
+	}
+	prop.name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		rest := line[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return prop, fmt.Errorf("invalid parameter in %q", line)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+
+		var value string
+		var consumed int
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return prop, fmt.Errorf("unterminated quoted parameter in %q", line)
+			}
+			value = rest[1 : end+1]
+			consumed = end + 2
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return prop, fmt.Errorf("missing value in %q", line)
+			}
+			value = rest[:end]
+			consumed = end
+		}
+		prop.params[name] = value
+
+		i += 1 + eq + 1 + consumed
+		if i >= len(line) || (line[i] != ';' && line[i] != ':') {
+			return prop, fmt.Errorf("invalid parameter in %q", line)
+		}
+	}
+
+	prop.value = line[i+1:]
+	return prop, nil
+}
+
+// parseTime reads a DATE-TIME or DATE value, reporting whether it was a DATE.
+func parseTime(prop property, loc *time.Location) (time.Time, bool, error) {
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(prop.value) == len(dateOnlyFormat) {
+		t, err := time.ParseInLocation(dateOnlyFormat, prop.value, loc)
+		return t, true, err
+	}
+
+	if strings.HasSuffix(prop.value, "Z") {
+		t, err := time.Parse(utcFormat, prop.value)
+		return t, false, err
+	}
+
+	zone := loc
+	if tzid := strings.Trim(prop.params["TZID"], "/"); tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			zone = tz
+		}
+	}
+	t, err := time.ParseInLocation(localFormat, prop.value, zone)
+	return t, false, err
+}
+
+var durationPattern = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// ParseDuration reads a DURATION value such as "PT1H30M" or "P1D".
+func ParseDuration(value string) (time.Duration, error) {
+	match := durationPattern.FindStringSubmatch(strings.ToUpper(value))
+	if match == nil || strings.HasSuffix(strings.ToUpper(value), "T") || strings.Join(match[2:], "") == "" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var duration time.Duration
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+2])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		duration += time.Duration(n) * unit
+	}
+	if match[1] == "-" {
+		duration = -duration
+	}
+	return duration, nil
+}
@@ -0,0 +1,213 @@
+package ical
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleCalendar() *Calendar {
+	return &Calendar{
+		ProdID: "-//MVC Restaurant//Reservations//EN",
+		Name:   "Cafe; Main, Reservations",
+		Events: []Event{
+			{
+				UID:         "0c7d0b0e-4f0a-4a57-9d3c-2a1f4b7e9a10",
+				Stamp:       time.Date(2025, 9, 8, 9, 0, 0, 0, time.UTC),
+				Start:       time.Date(2025, 9, 12, 19, 30, 0, 0, time.UTC),
+				End:         time.Date(2025, 9, 12, 21, 0, 0, 0, time.UTC),
+				Summary:     "Jane Doe (party of 4)",
+				Description: "Window seat\nOne high chair, please; thanks \\ cheers",
+				Location:    "Tables 3, 4",
+				Status:      "CONFIRMED",
+				Contacts:    []string{"+1 555 0100", "jane@example.com"},
+				Attendees:   []string{"mailto:jane@example.com"},
+				Extra:       map[string]string{"X-PARTY-SIZE": "4"},
+			},
+			{
+				UID:     "reservation-2",
+				Stamp:   time.Date(2025, 9, 8, 9, 0, 0, 0, time.UTC),
+				Start:   time.Date(2025, 9, 13, 12, 0, 0, 0, time.UTC),
+				End:     time.Date(2025, 9, 13, 13, 30, 0, 0, time.UTC),
+				Summary: strings.Repeat("Ünïcödé ", 30),
+			},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	calendar := sampleCalendar()
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, calendar); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	parsed, err := Parse(&buf, time.UTC)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, calendar) {
+		t.Errorf("Round trip changed the calendar\nwant %+v\ngot  %+v", calendar, parsed)
+	}
+}
+
+func TestEncodeFoldsLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, sampleCalendar()); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	output := buf.String()
+
+	if !strings.HasSuffix(output, "END:VCALENDAR\r\n") {
+		t.Errorf("Expected the calendar to end with a CRLF terminated END line")
+	}
+	if strings.Contains(strings.ReplaceAll(output, "\r\n", ""), "\n") {
+		t.Errorf("Expected every line to end with CRLF")
+	}
+
+	folded := false
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\r\n"), "\r\n") {
+		if len(line) > maxLineLength {
+			t.Errorf("Line is %d octets long: %q", len(line), line)
+		}
+		if strings.ToValidUTF8(line, "?") != line {
+			t.Errorf("Folding split a character: %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			folded = true
+		}
+	}
+	if !folded {
+		t.Error("Expected the long summary to be folded")
+	}
+
+	if !strings.Contains(output, `X-WR-CALNAME:Cafe\; Main\, Reservations`) {
+		t.Errorf("Expected the calendar name to be escaped, got\n%s", output)
+	}
+}
+
+func TestParseThirdPartyCalendar(t *testing.T) {
+	// LF line endings, a time zone definition, an alarm, quoted parameters, a TZID start with a
+	// duration and an all day event, as booking tools send them
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//Booking Tool//EN",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Berlin",
+		"BEGIN:STANDARD",
+		"DTSTART:19701025T030000",
+		"TZOFFSETFROM:+0200",
+		"TZOFFSETTO:+0100",
+		"END:STANDARD",
+		"END:VTIMEZONE",
+		"BEGIN:VEVENT",
+		"UID:abc@booking.example",
+		"DTSTAMP:20250908T090000Z",
+		"DTSTART;TZID=Europe/Berlin:20250912T193000",
+		"DURATION:PT2H",
+		"SUMMARY:Booking for 6 guests - Max",
+		"  Mustermann",
+		"ATTENDEE;CN=\"Mustermann, Max\";ROLE=REQ-PARTICIPANT:mailto:max@example.com",
+		"BEGIN:VALARM",
+		"TRIGGER:-PT30M",
+		"DESCRIPTION:Reminder",
+		"END:VALARM",
+		"X-PARTY-SIZE:6",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:all-day@booking.example",
+		"DTSTART;VALUE=DATE:20250914",
+		"SUMMARY:Private event",
+		"STATUS:cancelled",
+		"END:VEVENT",
+		"END:VCALENDAR",
+	}, "\n")
+
+	calendar, err := Parse(strings.NewReader(input), time.UTC)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if calendar.ProdID != "-//Booking Tool//EN" || len(calendar.Events) != 2 {
+		t.Fatalf("Expected 2 events from the booking tool, got %+v", calendar)
+	}
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Time zone data is not available: %v", err)
+	}
+	event := calendar.Events[0]
+	if want := time.Date(2025, 9, 12, 19, 30, 0, 0, berlin); !event.Start.Equal(want) {
+		t.Errorf("Expected the start in Berlin time %s, got %s", want, event.Start)
+	}
+	if event.End.Sub(event.Start) != 2*time.Hour {
+		t.Errorf("Expected the duration to set the end, got %s", event.End.Sub(event.Start))
+	}
+	if event.Summary != "Booking for 6 guests - Max Mustermann" {
+		t.Errorf("Expected the folded summary to be joined, got %q", event.Summary)
+	}
+	if len(event.Attendees) != 1 || event.Attendees[0] != "mailto:max@example.com" {
+		t.Errorf("Expected the attendee address, got %v", event.Attendees)
+	}
+	if event.Description != "" {
+		t.Errorf("Expected the alarm's description to be skipped, got %q", event.Description)
+	}
+	if event.Extra["X-PARTY-SIZE"] != "6" {
+		t.Errorf("Expected X-PARTY-SIZE 6, got %v", event.Extra)
+	}
+
+	allDay := calendar.Events[1]
+	if !allDay.Start.Equal(time.Date(2025, 9, 14, 0, 0, 0, 0, time.UTC)) || !allDay.End.Equal(time.Date(2025, 9, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the all day event to last the day, got %s to %s", allDay.Start, allDay.End)
+	}
+	if allDay.Status != "CANCELLED" {
+		t.Errorf("Expected status CANCELLED, got %q", allDay.Status)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"no calendar", "hello"},
+		{"not closed", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VEVENT\r\n"},
+		{"invalid line", "BEGIN:VCALENDAR\r\nno colon here\r\nEND:VCALENDAR\r\n"},
+		{"invalid time", "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"},
+		{"unterminated quote", "BEGIN:VCALENDAR\r\nATTENDEE;CN=\"Max:mailto:max@example.com\r\nEND:VCALENDAR\r\n"},
+	}
+	for _, test := range tests {
+		if _, err := Parse(strings.NewReader(test.input), time.UTC); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"PT1H30M", 90 * time.Minute},
+		{"P1D", 24 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P1DT2H3M4S", 26*time.Hour + 3*time.Minute + 4*time.Second},
+		{"-PT15M", -15 * time.Minute},
+		{"pt45m", 45 * time.Minute},
+	}
+	for _, test := range tests {
+		duration, err := ParseDuration(test.value)
+		if err != nil || duration != test.expected {
+			t.Errorf("ParseDuration(%q) = %s, %v; want %s", test.value, duration, err, test.expected)
+		}
+	}
+
+	for _, value := range []string{"", "P", "PT", "-P", "P1DT", "1H", "PT1.5H"} {
+		if _, err := ParseDuration(value); err == nil {
+			t.Errorf("ParseDuration(%q) should fail", value)
+		}
+	}
+}
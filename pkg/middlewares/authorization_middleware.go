@@ -5,6 +5,8 @@ import (
 	"net/http"
 )
 
+// Authorize lets requests through when the caller has every flag of the required role. Guests
+// only pass routes that require the guest role, since models.Any would otherwise admit them.
 func Authorize(requiredRole models.Role) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -16,6 +18,9 @@ func Authorize(requiredRole models.Role) func(next http.Handler) http.Handler {
 			}
 
 			hasPerms := role.HasFlag(requiredRole)
+			if role == models.Guest && requiredRole != models.Guest {
+				hasPerms = false
+			}
 
 			if !hasPerms {
 				http.Error(w, "Forbidden", http.StatusForbidden)
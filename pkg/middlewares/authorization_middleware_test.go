@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gqvz/mvc/pkg/models"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		role     models.Role
+		required models.Role
+		status   int
+	}{
+		{models.Customer, models.Any, http.StatusCreated},
+		{models.Chef, models.Any, http.StatusCreated},
+		{models.Admin, models.Cashier, http.StatusCreated},
+		{models.Cashier, models.Admin, http.StatusForbidden},
+		{models.Guest, models.Any, http.StatusForbidden},
+		{models.Guest, models.Customer, http.StatusForbidden},
+		{models.Guest, models.Guest, http.StatusCreated},
+		{models.Customer, models.Guest, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		next := &countingHandler{}
+		req := httptest.NewRequest(http.MethodGet, "/api/users/1", nil)
+		req = req.WithContext(context.WithValue(req.Context(), "role", byte(test.role)))
+
+		if rec := serve(Authorize(test.required)(next), req); rec.Code != test.status {
+			t.Errorf("Role %d on a route requiring %d: expected %d, got %d", test.role, test.required, test.status, rec.Code)
+		}
+	}
+
+	rec := serve(Authorize(models.Any)(&countingHandler{}), httptest.NewRequest(http.MethodGet, "/api/users/1", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Expected requests without a role to be forbidden, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,177 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gqvz/mvc/pkg/models"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	IdempotencyKeyHeader      = "Idempotency-Key"
+	IdempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// idempotencyStore keeps reserved keys and their stored responses.
+type idempotencyStore interface {
+	CreateIdempotencyKey(userId int64, key string, requestHash string, createdAt time.Time) (*models.IdempotencyKey, error)
+	GetIdempotencyKey(userId int64, key string) (*models.IdempotencyKey, error)
+	CompleteIdempotencyKey(id int64, statusCode int, contentType string, body []byte) error
+	DeleteIdempotencyKey(id int64) error
+}
+
+// modelsIdempotencyStore keeps the keys in the database.
+type modelsIdempotencyStore struct{}
+
+func (modelsIdempotencyStore) CreateIdempotencyKey(userId int64, key string, requestHash string, createdAt time.Time) (*models.IdempotencyKey, error) {
+	return models.CreateIdempotencyKey(userId, key, requestHash, createdAt)
+}
+
+func (modelsIdempotencyStore) GetIdempotencyKey(userId int64, key string) (*models.IdempotencyKey, error) {
+	return models.GetIdempotencyKey(userId, key)
+}
+
+func (modelsIdempotencyStore) CompleteIdempotencyKey(id int64, statusCode int, contentType string, body []byte) error {
+	return models.CompleteIdempotencyKey(id, statusCode, contentType, body)
+}
+
+func (modelsIdempotencyStore) DeleteIdempotencyKey(id int64) error {
+	return models.DeleteIdempotencyKey(id)
+}
+
+// CreateIdempotencyMiddleware makes POST requests that carry an Idempotency-Key header safe to
+// retry. The first response for a key is stored for window and replayed for repeats of the same
+// request; reusing the key for a different request is rejected. Keys are scoped to the user, so it
+// only goes on routes that require one; anonymous requests pass through untouched.
+func CreateIdempotencyMiddleware(window time.Duration) func(next http.Handler) http.Handler {
+	return newIdempotencyMiddleware(modelsIdempotencyStore{}, window, time.Now)
+}
+
+func newIdempotencyMiddleware(store idempotencyStore, window time.Duration, now func() time.Time) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+			userId, _ := r.Context().Value("userid").(int64)
+			if r.Method != http.MethodPost || key == "" || userId == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes+1))
+			if err != nil || len(body) > maxIdempotentRequestBytes {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			hash := sha256.New()
+			hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+			hash.Write(body)
+			requestHash := hex.EncodeToString(hash.Sum(nil))
+
+			record, err := reserveIdempotencyKey(store, userId, key, requestHash, now(), window)
+			if err != nil {
+				if !strings.Contains(err.Error(), "already exists") {
+					log.Printf("Error reserving idempotency key: %v", err)
+					http.Error(w, "Failed to process Idempotency-Key", http.StatusInternalServerError)
+					return
+				}
+
+				existing, err := store.GetIdempotencyKey(userId, key)
+				if err != nil {
+					http.Error(w, "Failed to process Idempotency-Key", http.StatusInternalServerError)
+					return
+				}
+				replayIdempotentResponse(w, existing, requestHash)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			// server errors are not stored so the client can retry them with the same key
+			if recorder.statusCode >= http.StatusInternalServerError {
+				if err := store.DeleteIdempotencyKey(record.ID); err != nil {
+					log.Printf("Error releasing idempotency key: %v", err)
+				}
+				return
+			}
+
+			if err := store.CompleteIdempotencyKey(record.ID, recorder.statusCode, recorder.Header().Get("Content-Type"), recorder.body.Bytes()); err != nil {
+				log.Printf("Error storing idempotent response: %v", err)
+			}
+		})
+	}
+}
+
+// reserveIdempotencyKey claims the key, first dropping a stored response that is older than the
+// window so the key can be used again.
+func reserveIdempotencyKey(store idempotencyStore, userId int64, key string, requestHash string, now time.Time, window time.Duration) (*models.IdempotencyKey, error) {
+	record, err := store.CreateIdempotencyKey(userId, key, requestHash, now)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		return record, err
+	}
+
+	existing, err1 := store.GetIdempotencyKey(userId, key)
+	if err1 != nil || existing.CreatedAt.After(now.Add(-window)) {
+		return nil, err
+	}
+	if err := store.DeleteIdempotencyKey(existing.ID); err != nil {
+		return nil, err
+	}
+	return store.CreateIdempotencyKey(userId, key, requestHash, now)
+}
+
+func replayIdempotentResponse(w http.ResponseWriter, record *models.IdempotencyKey, requestHash string) {
+	if record.RequestHash != requestHash {
+		http.Error(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+		return
+	}
+	if record.StatusCode == 0 {
+		http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+		return
+	}
+
+	if record.ContentType != "" {
+		w.Header().Set("Content-Type", record.ContentType)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(record.StatusCode)
+	if _, err := w.Write(record.Body); err != nil {
+		log.Printf("Error writing idempotent response: %v", err)
+	}
+}
+
+// responseRecorder passes the response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
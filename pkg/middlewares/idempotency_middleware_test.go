@@ -0,0 +1,186 @@
+package middlewares
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gqvz/mvc/pkg/models"
+)
+
+type memoryIdempotencyStore struct {
+	nextId  int64
+	records map[string]*models.IdempotencyKey
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*models.IdempotencyKey)}
+}
+
+func (s *memoryIdempotencyStore) CreateIdempotencyKey(userId int64, key string, requestHash string, createdAt time.Time) (*models.IdempotencyKey, error) {
+	name := fmt.Sprintf("%d/%s", userId, key)
+	if _, ok := s.records[name]; ok {
+		return nil, fmt.Errorf("idempotency key '%s' already exists", key)
+	}
+	s.nextId++
+	record := &models.IdempotencyKey{ID: s.nextId, UserID: userId, Key: key, RequestHash: requestHash, CreatedAt: createdAt}
+	s.records[name] = record
+	copied := *record
+	return &copied, nil
+}
+
+func (s *memoryIdempotencyStore) GetIdempotencyKey(userId int64, key string) (*models.IdempotencyKey, error) {
+	record, ok := s.records[fmt.Sprintf("%d/%s", userId, key)]
+	if !ok {
+		return nil, fmt.Errorf("idempotency key not found")
+	}
+	copied := *record
+	return &copied, nil
+}
+
+func (s *memoryIdempotencyStore) CompleteIdempotencyKey(id int64, statusCode int, contentType string, body []byte) error {
+	for _, record := range s.records {
+		if record.ID == id {
+			record.StatusCode = statusCode
+			record.ContentType = contentType
+			record.Body = append([]byte(nil), body...)
+		}
+	}
+	return nil
+}
+
+func (s *memoryIdempotencyStore) DeleteIdempotencyKey(id int64) error {
+	for name, record := range s.records {
+		if record.ID == id {
+			delete(s.records, name)
+		}
+	}
+	return nil
+}
+
+// countingHandler answers with the number of times it ran, or with status once it is set.
+type countingHandler struct {
+	calls  int
+	status int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	if h.status != 0 {
+		http.Error(w, "failed", h.status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, `{"call":%d}`, h.calls)
+}
+
+func idempotentRequest(userId int64, key string, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(body))
+	req.Header.Set(IdempotencyKeyHeader, key)
+	return req.WithContext(context.WithValue(req.Context(), "userid", userId))
+}
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	next := &countingHandler{}
+	now := time.Date(2025, 9, 4, 12, 0, 0, 0, time.UTC)
+	handler := newIdempotencyMiddleware(newMemoryIdempotencyStore(), time.Hour, func() time.Time { return now })(next)
+
+	first := serve(handler, idempotentRequest(1, "abc", `{"table_id":1}`))
+	second := serve(handler, idempotentRequest(1, "abc", `{"table_id":1}`))
+
+	if next.calls != 1 {
+		t.Fatalf("Expected the handler to run once, ran %d times", next.calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("Expected the replay to match %d %q, got %d %q", first.Code, first.Body.String(), second.Code, second.Body.String())
+	}
+	if second.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Error("Expected the replay to be marked as replayed")
+	}
+	if second.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the replay to keep its content type, got %q", second.Header().Get("Content-Type"))
+	}
+
+	serve(handler, idempotentRequest(2, "abc", `{"table_id":1}`))
+	if next.calls != 2 {
+		t.Error("Expected keys to be scoped to the user")
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequest(t *testing.T) {
+	next := &countingHandler{}
+	handler := newIdempotencyMiddleware(newMemoryIdempotencyStore(), time.Hour, time.Now)(next)
+
+	serve(handler, idempotentRequest(1, "abc", `{"table_id":1}`))
+	rec := serve(handler, idempotentRequest(1, "abc", `{"table_id":2}`))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected %d, got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if next.calls != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	next := &countingHandler{status: http.StatusInternalServerError}
+	store := newMemoryIdempotencyStore()
+	handler := newIdempotencyMiddleware(store, time.Hour, time.Now)(next)
+
+	if rec := serve(handler, idempotentRequest(1, "abc", `{}`)); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected %d, got %d", http.StatusInternalServerError, rec.Code)
+	}
+	if len(store.records) != 0 {
+		t.Error("Expected the key to be released after a server error")
+	}
+
+	next.status = 0
+	if rec := serve(handler, idempotentRequest(1, "abc", `{}`)); rec.Code != http.StatusCreated {
+		t.Errorf("Expected the retry to run, got %d", rec.Code)
+	}
+	if next.calls != 2 {
+		t.Errorf("Expected the handler to run twice, ran %d times", next.calls)
+	}
+}
+
+func TestIdempotencyKeyExpires(t *testing.T) {
+	next := &countingHandler{}
+	now := time.Date(2025, 9, 4, 12, 0, 0, 0, time.UTC)
+	handler := newIdempotencyMiddleware(newMemoryIdempotencyStore(), time.Hour, func() time.Time { return now })(next)
+
+	serve(handler, idempotentRequest(1, "abc", `{}`))
+	now = now.Add(59 * time.Minute)
+	serve(handler, idempotentRequest(1, "abc", `{}`))
+	if next.calls != 1 {
+		t.Fatalf("Expected a replay within the window, handler ran %d times", next.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	rec := serve(handler, idempotentRequest(1, "abc", `{"table_id":2}`))
+	if next.calls != 2 || rec.Code != http.StatusCreated {
+		t.Errorf("Expected the key to be usable again after the window, got %d after %d calls", rec.Code, next.calls)
+	}
+}
+
+func TestIdempotencySkipsAnonymousRequests(t *testing.T) {
+	next := &countingHandler{}
+	store := newMemoryIdempotencyStore()
+	handler := newIdempotencyMiddleware(store, time.Hour, time.Now)(next)
+
+	serve(handler, idempotentRequest(0, "abc", `{}`))
+	serve(handler, idempotentRequest(0, "abc", `{}`))
+	if next.calls != 2 || len(store.records) != 0 {
+		t.Errorf("Expected anonymous requests to pass through, handler ran %d times with %d keys stored", next.calls, len(store.records))
+	}
+}
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CreateRateLimitMiddleware allows each client limit requests per window and answers the rest with
+// 429 Too Many Requests until the window is over. Signed in users are counted by user id and
+// everyone else by IP address.
+func CreateRateLimitMiddleware(limit int, window time.Duration) func(next http.Handler) http.Handler {
+	limiter := newRateLimiter(limit, window)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if allowed, retryAfter := limiter.allow(rateLimitKey(r), time.Now()); !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				http.Error(w, "Too many requests, try again later", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rateLimitKey(r *http.Request) string {
+	if userId, ok := r.Context().Value("userid").(int64); ok {
+		return "user:" + strconv.FormatInt(userId, 10)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// rateLimiter counts requests per key in fixed windows.
+type rateLimiter struct {
+	mu        sync.Mutex
+	limit     int
+	window    time.Duration
+	counts    map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{
+		limit:  limit,
+		window: window,
+		counts: make(map[string]*rateWindow),
+	}
+}
+
+// allow counts a request and reports whether it is within the limit, and if not how long until
+// the key's window is over.
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// windows that are over are dropped once per window so keys that stop sending do not pile up
+	if now.Sub(l.lastSweep) >= l.window {
+		for k, w := range l.counts {
+			if now.Sub(w.start) >= l.window {
+				delete(l.counts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	w, ok := l.counts[key]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.counts[key] = w
+	}
+	if w.count >= l.limit {
+		return false, w.start.Add(l.window).Sub(now)
+	}
+	w.count++
+	return true, 0
+}
@@ -0,0 +1,75 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(2, time.Minute)
+	start := time.Date(2025, 9, 4, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.allow("a", start.Add(time.Duration(i)*time.Second)); !allowed {
+			t.Fatalf("Request %d should be allowed", i+1)
+		}
+	}
+
+	allowed, retryAfter := limiter.allow("a", start.Add(20*time.Second))
+	if allowed {
+		t.Fatal("Third request in the window should be limited")
+	}
+	if retryAfter != 40*time.Second {
+		t.Errorf("Expected to retry after 40s, got %s", retryAfter)
+	}
+
+	if allowed, _ := limiter.allow("b", start.Add(20*time.Second)); !allowed {
+		t.Error("Other keys should have their own limit")
+	}
+
+	if allowed, _ := limiter.allow("a", start.Add(time.Minute)); !allowed {
+		t.Error("A new window should allow requests again")
+	}
+
+	limiter.allow("c", start.Add(3*time.Minute))
+	if _, ok := limiter.counts["b"]; ok {
+		t.Error("Windows that are over should be swept")
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	handler := CreateRateLimitMiddleware(1, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	request := func(remoteAddr string, userId int64) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/guest/sessions", nil)
+		r.RemoteAddr = remoteAddr
+		if userId != 0 {
+			r = r.WithContext(context.WithValue(r.Context(), "userid", userId))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request("10.0.0.1:5000", 0); w.Code != http.StatusNoContent {
+		t.Fatalf("First request returned %d", w.Code)
+	}
+	w := request("10.0.0.1:5001", 0)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Second request from the same address returned %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected Retry-After 60, got %q", w.Header().Get("Retry-After"))
+	}
+	if w := request("10.0.0.1:5002", 7); w.Code != http.StatusNoContent {
+		t.Errorf("Signed in users should be counted by user, got %d", w.Code)
+	}
+	if w := request("10.0.0.2:5000", 0); w.Code != http.StatusNoContent {
+		t.Errorf("Other addresses should have their own limit, got %d", w.Code)
+	}
+}
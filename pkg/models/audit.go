@@ -0,0 +1,46 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// recordAudit adds an entry to the audit log in the same transaction as the change it records.
+func recordAudit(tx *sql.Tx, entity StatusEntity, entityId int64, action AuditAction, actorId int64, details map[string]any) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO AuditLog (entity, entity_id, action, actor_id, details, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		entity, entityId, action, nullableId(actorId), string(encoded), time.Now())
+	return err
+}
+
+// GetAuditLog lists the audit entries of an entity, oldest first.
+func GetAuditLog(entity StatusEntity, entityId int64) ([]AuditEntry, error) {
+	rows, err := DB.Query("SELECT id, entity, entity_id, action, actor_id, details, created_at FROM AuditLog WHERE entity = ? AND entity_id = ? ORDER BY id", entity, entityId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var actorId sql.NullInt64
+		var details []byte
+		if err := rows.Scan(&entry.ID, &entry.Entity, &entry.EntityID, &entry.Action, &actorId, &details, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		if err := json.Unmarshal(details, &entry.Details); err != nil {
+			return nil, fmt.Errorf("failed to decode audit entry %d: %w", entry.ID, err)
+		}
+		entry.ActorID = actorId.Int64
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
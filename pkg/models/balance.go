@@ -0,0 +1,130 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+)
+
+// payments in these statuses hold their share of the order; failed, voided and fully refunded ones release it
+const activePaymentStatuses = "'processing', 'accepted', 'partially_refunded'"
+
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// GetOrderItemBalances returns, for every item of the order, the part of its amount that no
+// processing or accepted payment covers yet.
+func GetOrderItemBalances(orderId int64) (map[int64]money.Money, error) {
+	return orderItemBalances(DB, orderId)
+}
+
+func orderItemBalances(q queryer, orderId int64) (map[int64]money.Money, error) {
+	rows, err := q.Query(`SELECT oi.id, oi.unit_price * oi.count - COALESCE((SELECT SUM(pi.amount) FROM PaymentItems pi
+				JOIN Payments p ON p.id = pi.payment_id
+				WHERE pi.order_item_id = oi.id AND p.status IN (`+activePaymentStatuses+`)), 0)
+			FROM OrderItems oi WHERE oi.order_id = ?`, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make(map[int64]money.Money)
+	for rows.Next() {
+		var orderItemId int64
+		var balance money.Money
+		if err := rows.Scan(&orderItemId, &balance); err != nil {
+			return nil, fmt.Errorf("failed to scan order item balance: %w", err)
+		}
+		balances[orderItemId] = balance
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+// GetOrderBalance sums the order items and the payments made against them. OutstandingTotal is
+// left to the caller because it depends on taxes and the service charge.
+func GetOrderBalance(orderId int64) (*OrderBalance, error) {
+	balance := &OrderBalance{OrderID: orderId}
+	err := DB.QueryRow("SELECT COALESCE(SUM(unit_price * count), 0) FROM OrderItems WHERE order_id = ?", orderId).Scan(&balance.Subtotal)
+	if err != nil {
+		return nil, err
+	}
+
+	var covered money.Money
+	err = DB.QueryRow(`SELECT COALESCE(SUM(order_subtotal), 0),
+			COALESCE(SUM(CASE WHEN status <> 'processing' THEN order_subtotal END), 0),
+			COALESCE(SUM(CASE WHEN status <> 'processing' THEN total END), 0)
+		FROM Payments WHERE order_id = ? AND status IN (`+activePaymentStatuses+`)`, orderId).Scan(&covered, &balance.Paid, &balance.PaidTotal)
+	if err != nil {
+		return nil, err
+	}
+
+	settleOrderBalance(balance, covered)
+	return balance, nil
+}
+
+// settleOrderBalance works out what is pending and outstanding from the subtotal covered by
+// processing and paid payments. Only paid payments settle the order.
+func settleOrderBalance(balance *OrderBalance, covered money.Money) {
+	balance.Pending = covered - balance.Paid
+	balance.Outstanding = max(balance.Subtotal-covered, 0)
+	balance.Settled = balance.Paid >= balance.Subtotal
+}
+
+// GetOutstandingDeliveryFee returns the delivery fee of an order unless a processing or accepted
+// payment already charges it. The first payment of a delivery order charges the whole fee.
+func GetOutstandingDeliveryFee(order *Order) (money.Money, error) {
+	if order.DeliveryFee <= 0 {
+		return 0, nil
+	}
+
+	var charged money.Money
+	err := DB.QueryRow("SELECT COALESCE(SUM(delivery_fee), 0) FROM Payments WHERE order_id = ? AND status IN ("+activePaymentStatuses+")", order.ID).Scan(&charged)
+	if err != nil {
+		return 0, err
+	}
+	if charged > 0 {
+		return 0, nil
+	}
+	return order.DeliveryFee, nil
+}
+
+// CountSplitPayments returns how many shares of an even split into the given number of parts
+// have already been taken.
+func CountSplitPayments(orderId int64, mode SplitMode, parts int) (int, error) {
+	var count int
+	err := DB.QueryRow("SELECT COUNT(*) FROM Payments WHERE order_id = ? AND split_mode = ? AND split_parts = ? AND status IN ("+activePaymentStatuses+")",
+		orderId, mode, parts).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func GetPaymentItems(paymentId int64) ([]PaymentItem, error) {
+	rows, err := DB.Query("SELECT order_item_id, amount FROM PaymentItems WHERE payment_id = ? ORDER BY order_item_id", paymentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []PaymentItem
+	for rows.Next() {
+		var item PaymentItem
+		if err := rows.Scan(&item.OrderItemID, &item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan payment item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
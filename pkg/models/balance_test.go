@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/gqvz/mvc/pkg/money"
+	"testing"
+)
+
+func TestSettleOrderBalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		subtotal money.Money
+		covered  money.Money
+		paid     money.Money
+		expected OrderBalance
+	}{
+		{"nothing paid", 2000, 0, 0, OrderBalance{Subtotal: 2000, Outstanding: 2000}},
+		{"processing payment is pending", 2000, 2000, 0, OrderBalance{Subtotal: 2000, Pending: 2000}},
+		{"partly paid", 2000, 1500, 1000, OrderBalance{Subtotal: 2000, Paid: 1000, Pending: 500, Outstanding: 500}},
+		{"fully paid", 2000, 2000, 2000, OrderBalance{Subtotal: 2000, Paid: 2000, Settled: true}},
+		{"items removed after paying", 1500, 2000, 2000, OrderBalance{Subtotal: 1500, Paid: 2000, Settled: true}},
+	}
+
+	for _, test := range tests {
+		balance := OrderBalance{Subtotal: test.subtotal, Paid: test.paid}
+		settleOrderBalance(&balance, test.covered)
+		if balance != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, balance)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"github.com/gqvz/mvc/pkg/money"
+	"sort"
+)
+
+type BillLine struct {
+	OrderItemID int64
+	Amount      money.Money
+	TaxCategory *TaxCategory
+}
+
+type Bill struct {
+	Subtotal      money.Money
+	Discount      money.Money
+	ServiceCharge money.Money
+	DeliveryFee   money.Money
+	Tax           money.Money
+	TaxIncluded   money.Money
+	Tip           money.Money
+	Total         money.Money
+	Taxes         []PaymentTax
+}
+
+// CalculateBill spreads the discount over the lines in proportion to their amounts before
+// taxing them per category. Exclusive taxes are added to the total, inclusive taxes are only
+// reported. The service charge is computed on the discounted subtotal and is not taxed, and
+// the delivery fee is added as it is. Every percentage is rounded half away from zero to the
+// cent once per category, and the discount is allocated cent-exactly so the lines always add
+// back up to the subtotal.
+func CalculateBill(lines []BillLine, discount money.Money, serviceChargePercent money.Percent, deliveryFee money.Money, tip money.Money) Bill {
+	bill := Bill{DeliveryFee: max(deliveryFee, 0), Tip: tip}
+	weights := make([]int64, len(lines))
+	for i, line := range lines {
+		bill.Subtotal += line.Amount
+		weights[i] = int64(line.Amount)
+	}
+	bill.Discount = money.Min(max(discount, 0), bill.Subtotal)
+	lineDiscounts := bill.Discount.Allocate(weights)
+
+	byCategory := make(map[int64]*PaymentTax)
+	for i, line := range lines {
+		if line.TaxCategory == nil {
+			continue
+		}
+		tax, ok := byCategory[line.TaxCategory.ID]
+		if !ok {
+			tax = &PaymentTax{
+				TaxCategoryID: line.TaxCategory.ID,
+				Name:          line.TaxCategory.Name,
+				Rate:          line.TaxCategory.Rate,
+				Inclusive:     line.TaxCategory.Inclusive,
+			}
+			byCategory[line.TaxCategory.ID] = tax
+		}
+		tax.Taxable += line.Amount - lineDiscounts[i]
+	}
+
+	for _, tax := range byCategory {
+		if tax.Inclusive {
+			tax.Amount = tax.Taxable.IncludedTax(tax.Rate)
+			tax.Taxable -= tax.Amount
+			bill.TaxIncluded += tax.Amount
+		} else {
+			tax.Amount = tax.Taxable.Percent(tax.Rate)
+			bill.Tax += tax.Amount
+		}
+		bill.Taxes = append(bill.Taxes, *tax)
+	}
+	sort.Slice(bill.Taxes, func(i, j int) bool {
+		return bill.Taxes[i].TaxCategoryID < bill.Taxes[j].TaxCategoryID
+	})
+
+	if serviceChargePercent > 0 {
+		bill.ServiceCharge = (bill.Subtotal - bill.Discount).Percent(serviceChargePercent)
+	}
+
+	bill.Total = bill.Subtotal - bill.Discount + bill.ServiceCharge + bill.DeliveryFee + bill.Tax + bill.Tip
+	return bill
+}
+
+// ShareLines spreads the amount over the lines in proportion to their amounts. Lines keep their
+// tax category so that a share of the bill is taxed exactly like the whole bill; lines that
+// receive nothing are left out.
+func ShareLines(lines []BillLine, amount money.Money) []BillLine {
+	weights := make([]int64, len(lines))
+	for i, line := range lines {
+		weights[i] = int64(line.Amount)
+	}
+
+	shares := amount.Allocate(weights)
+	shared := make([]BillLine, 0, len(lines))
+	for i, line := range lines {
+		if shares[i] == 0 {
+			continue
+		}
+		line.Amount = shares[i]
+		shared = append(shared, line)
+	}
+	return shared
+}
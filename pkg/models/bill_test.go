@@ -0,0 +1,114 @@
+package models
+
+import (
+	"github.com/gqvz/mvc/pkg/money"
+	"reflect"
+	"testing"
+)
+
+var (
+	salesTax = &TaxCategory{ID: 1, Name: "Sales tax", Rate: 10_000}
+	vat      = &TaxCategory{ID: 2, Name: "VAT", Rate: 20_000, Inclusive: true}
+	cityTax  = &TaxCategory{ID: 3, Name: "City tax", Rate: 8_875}
+)
+
+func TestCalculateBill(t *testing.T) {
+	tests := []struct {
+		name          string
+		lines         []BillLine
+		discount      money.Money
+		serviceCharge money.Percent
+		deliveryFee   money.Money
+		tip           money.Money
+		expected      Bill
+	}{
+		{
+			name:  "exclusive tax is added to the total",
+			lines: []BillLine{{Amount: 1000, TaxCategory: salesTax}, {Amount: 500}},
+			expected: Bill{Subtotal: 1500, Tax: 100, Total: 1600, Taxes: []PaymentTax{
+				{TaxCategoryID: 1, Name: "Sales tax", Rate: 10_000, Taxable: 1000, Amount: 100},
+			}},
+		},
+		{
+			name:  "inclusive tax is only reported",
+			lines: []BillLine{{Amount: 1200, TaxCategory: vat}},
+			expected: Bill{Subtotal: 1200, TaxIncluded: 200, Total: 1200, Taxes: []PaymentTax{
+				{TaxCategoryID: 2, Name: "VAT", Rate: 20_000, Inclusive: true, Taxable: 1000, Amount: 200},
+			}},
+		},
+		{
+			name:          "discount is spread before taxing and service charge rounds half up",
+			lines:         []BillLine{{Amount: 2000, TaxCategory: salesTax}, {Amount: 1000, TaxCategory: vat}},
+			discount:      300,
+			serviceCharge: 12_500,
+			expected: Bill{Subtotal: 3000, Discount: 300, ServiceCharge: 338, Tax: 180, TaxIncluded: 150, Total: 3218, Taxes: []PaymentTax{
+				{TaxCategoryID: 1, Name: "Sales tax", Rate: 10_000, Taxable: 1800, Amount: 180},
+				{TaxCategoryID: 2, Name: "VAT", Rate: 20_000, Inclusive: true, Taxable: 750, Amount: 150},
+			}},
+		},
+		{
+			name:          "discount larger than the subtotal is capped",
+			lines:         []BillLine{{Amount: 1000, TaxCategory: salesTax}},
+			discount:      5000,
+			serviceCharge: 10_000,
+			deliveryFee:   300,
+			tip:           200,
+			expected: Bill{Subtotal: 1000, Discount: 1000, DeliveryFee: 300, Tip: 200, Total: 500, Taxes: []PaymentTax{
+				{TaxCategoryID: 1, Name: "Sales tax", Rate: 10_000},
+			}},
+		},
+		{
+			name:        "fractional tax rounds once per category",
+			lines:       []BillLine{{Amount: 111, TaxCategory: cityTax}, {Amount: 222, TaxCategory: cityTax}},
+			deliveryFee: -100,
+			expected: Bill{Subtotal: 333, Tax: 30, Total: 363, Taxes: []PaymentTax{
+				{TaxCategoryID: 3, Name: "City tax", Rate: 8_875, Taxable: 333, Amount: 30},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		bill := CalculateBill(test.lines, test.discount, test.serviceCharge, test.deliveryFee, test.tip)
+		if !reflect.DeepEqual(bill, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.expected, bill)
+		}
+	}
+}
+
+func TestShareLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []BillLine
+		amount   money.Money
+		expected []money.Money
+	}{
+		{"equal lines give the residue to the first", []BillLine{{Amount: 100}, {Amount: 100}, {Amount: 100}}, 100, []money.Money{34, 33, 33}},
+		{"residue goes to the largest remainder", []BillLine{{Amount: 100}, {Amount: 300}, {Amount: 600}}, 7, []money.Money{1, 2, 4}},
+		{"lines that receive nothing are left out", []BillLine{{Amount: 100}, {Amount: 300}}, 1, []money.Money{1}},
+		{"the whole amount is not shared again", []BillLine{{Amount: 250}, {Amount: 750}}, 1000, []money.Money{250, 750}},
+	}
+
+	for _, test := range tests {
+		shared := ShareLines(test.lines, test.amount)
+		if len(shared) != len(test.expected) {
+			t.Errorf("%s: expected %d lines, got %+v", test.name, len(test.expected), shared)
+			continue
+		}
+		var sum money.Money
+		for i, line := range shared {
+			if line.Amount != test.expected[i] {
+				t.Errorf("%s: line %d = %s, want %s", test.name, i, line.Amount, test.expected[i])
+			}
+			sum += line.Amount
+		}
+		if sum != test.amount {
+			t.Errorf("%s: expected the shares to add up to %s, got %s", test.name, test.amount, sum)
+		}
+	}
+
+	lines := []BillLine{{OrderItemID: 1, Amount: 1000, TaxCategory: salesTax}, {OrderItemID: 2, Amount: 1000, TaxCategory: vat}}
+	shared := ShareLines(lines, 501)
+	if len(shared) != 2 || shared[0].OrderItemID != 1 || shared[0].TaxCategory != salesTax || shared[1].TaxCategory != vat {
+		t.Errorf("Expected shared lines to keep their order item and tax category, got %+v", shared)
+	}
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"fmt"
+	"github.com/gqvz/mvc/pkg/ical"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// partySizePattern finds the party size booking tools put in event titles, such as "Jane Doe
+// (party of 4)", "Booking for 6 guests - Max" or "Lee, 2 pax", along with the words around it.
+var partySizePattern = regexp.MustCompile(`(?i)(?:\b(?:booking|reservation|table)\s+)?\b(?:for|party of)\s+(\d+)(?:\s+(?:people|persons|guests|pax|covers))?\b` +
+	`|\(\s*(?:party of\s+)?(\d+)(?:\s+(?:people|persons|guests|pax|covers))?\s*\)` +
+	`|\b(\d+)\s*(?:people|persons|guests|pax|covers)\b`)
+
+// ReservationEvent describes a reservation as a calendar event. Seated reservations stay
+// confirmed in calendars; those that did not happen are cancelled.
+func ReservationEvent(reservation *Reservation, stamp time.Time) ical.Event {
+	event := ical.Event{
+		UID:         reservation.UID,
+		Stamp:       stamp,
+		Start:       reservation.StartsAt,
+		End:         reservation.EndsAt,
+		Summary:     fmt.Sprintf("%s (party of %d)", reservation.Name, reservation.PartySize),
+		Description: reservation.Notes,
+		Status:      "CONFIRMED",
+		Extra:       map[string]string{"X-PARTY-SIZE": strconv.Itoa(reservation.PartySize)},
+	}
+	if reservation.Status == ReservationNoShow || reservation.Status == ReservationCancelled {
+		event.Status = "CANCELLED"
+	}
+
+	if len(reservation.Tables) > 0 {
+		numbers := make([]string, 0, len(reservation.Tables))
+		for _, table := range reservation.Tables {
+			numbers = append(numbers, strconv.Itoa(table.Number))
+		}
+		event.Location = "Table " + strings.Join(numbers, ", ")
+		if len(numbers) > 1 {
+			event.Location = "Tables " + strings.Join(numbers, ", ")
+		}
+	}
+
+	if reservation.Phone != "" {
+		event.Contacts = append(event.Contacts, reservation.Phone)
+	}
+	if reservation.Email != "" {
+		event.Contacts = append(event.Contacts, reservation.Email)
+		event.Attendees = append(event.Attendees, "mailto:"+reservation.Email)
+	}
+	return event
+}
+
+// ReservationFromEvent reads a reservation from a calendar event, from this restaurant's feed or
+// another booking tool. The party size comes from X-PARTY-SIZE or else the title, and the name is
+// what is left of the title. Events without an end, or longer than a booking can be, last duration.
+func ReservationFromEvent(event *ical.Event, duration time.Duration) (*Reservation, error) {
+	if strings.TrimSpace(event.UID) == "" {
+		return nil, fmt.Errorf("event has no UID")
+	}
+	if event.Start.IsZero() {
+		return nil, fmt.Errorf("event has no start time")
+	}
+
+	reservation := &Reservation{
+		UID:      strings.TrimSpace(event.UID),
+		StartsAt: event.Start,
+		EndsAt:   event.End,
+		Name:     strings.TrimSpace(event.Summary),
+		Notes:    truncate(strings.TrimSpace(event.Description), 65535),
+	}
+	if length := reservation.EndsAt.Sub(reservation.StartsAt); length <= 0 || length > MaxReservationDuration {
+		reservation.EndsAt = reservation.StartsAt.Add(duration)
+	}
+
+	if size, ok := event.Extra["X-PARTY-SIZE"]; ok {
+		partySize, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil {
+			return nil, fmt.Errorf("invalid party size %q", size)
+		}
+		reservation.PartySize = partySize
+	}
+	if match := partySizePattern.FindStringSubmatchIndex(reservation.Name); match != nil {
+		if reservation.PartySize == 0 {
+			for group := 1; group < len(match)/2; group++ {
+				if match[2*group] >= 0 {
+					reservation.PartySize, _ = strconv.Atoi(reservation.Name[match[2*group]:match[2*group+1]])
+					break
+				}
+			}
+		}
+		reservation.Name = reservation.Name[:match[0]] + " " + reservation.Name[match[1]:]
+	}
+	if reservation.PartySize <= 0 {
+		return nil, fmt.Errorf("party size not found")
+	}
+
+	reservation.Name = strings.Trim(strings.Join(strings.Fields(reservation.Name), " "), " -–:,|/")
+	if reservation.Name == "" {
+		reservation.Name = "Reservation"
+	}
+	reservation.Name = truncate(reservation.Name, 255)
+
+	for _, contact := range event.Contacts {
+		contact = strings.TrimSpace(strings.TrimPrefix(contact, "mailto:"))
+		if strings.Contains(contact, "@") {
+			if reservation.Email == "" {
+				reservation.Email = truncate(contact, 255)
+			}
+		} else if reservation.Phone == "" {
+			reservation.Phone = truncate(contact, 32)
+		}
+	}
+	for _, attendee := range event.Attendees {
+		if reservation.Email == "" && strings.HasPrefix(strings.ToLower(attendee), "mailto:") {
+			reservation.Email = truncate(attendee[len("mailto:"):], 255)
+		}
+	}
+
+	return reservation, nil
+}
+
+// truncate cuts a string to at most limit bytes without splitting a character.
+func truncate(value string, limit int) string {
+	if len(value) <= limit {
+		return value
+	}
+	for limit > 0 && !utf8.RuneStart(value[limit]) {
+		limit--
+	}
+	return value[:limit]
+}
+
+// ImportReservations books the events of an imported calendar in as reservations. Events are
+// matched to reservations by UID, so importing a calendar again only adds what is new. Cancelled
+// events cancel the confirmed reservation they match, and events that start before now are
+// skipped. Only database errors stop the import; the rest are reported per event.
+func ImportReservations(events []ical.Event, duration time.Duration, actorId int64, now time.Time) ([]ReservationImport, error) {
+	results := make([]ReservationImport, 0, len(events))
+	for i := range events {
+		event := &events[i]
+		result := ReservationImport{UID: event.UID}
+
+		existing, err := GetReservationByUID(strings.TrimSpace(event.UID))
+		if err != nil && !strings.Contains(err.Error(), "not found") {
+			return results, err
+		}
+
+		switch {
+		case existing != nil && event.Status == "CANCELLED" && existing.Status == ReservationConfirmed:
+			if _, err := SetReservationStatus(existing.ID, ReservationCancelled, actorId); err != nil {
+				return results, err
+			}
+			result.Result, result.ReservationID = ImportCancelled, existing.ID
+		case existing != nil:
+			result.Result, result.ReservationID = ImportDuplicate, existing.ID
+		case event.Status == "CANCELLED":
+			result.Result = ImportSkipped
+		default:
+			result.Result, result.ReservationID, result.Error, err = importReservation(event, duration, actorId, now)
+			if err != nil {
+				return results, err
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func importReservation(event *ical.Event, duration time.Duration, actorId int64, now time.Time) (ReservationImportResult, int64, string, error) {
+	reservation, err := ReservationFromEvent(event, duration)
+	if err != nil {
+		return ImportFailed, 0, err.Error(), nil
+	}
+	if reservation.StartsAt.Before(now) {
+		return ImportSkipped, 0, "event starts in the past", nil
+	}
+
+	created, err := CreateReservation(reservation, actorId)
+	if err != nil {
+		if strings.Contains(err.Error(), "no tables available") {
+			return ImportFailed, 0, err.Error(), nil
+		}
+		if strings.Contains(err.Error(), "Duplicate") {
+			return ImportDuplicate, 0, "", nil
+		}
+		return "", 0, "", err
+	}
+	return ImportCreated, created.ID, "", nil
+}
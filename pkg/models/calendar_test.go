@@ -0,0 +1,138 @@
+package models
+
+import (
+	"bytes"
+	"github.com/gqvz/mvc/pkg/ical"
+	"testing"
+	"time"
+)
+
+func TestReservationCalendarRoundTrip(t *testing.T) {
+	reservations := []Reservation{
+		{
+			UID:       "0c7d0b0e-4f0a-4a57-9d3c-2a1f4b7e9a10",
+			PartySize: 6,
+			StartsAt:  time.Date(2025, 9, 12, 19, 30, 0, 0, time.UTC),
+			EndsAt:    time.Date(2025, 9, 12, 21, 0, 0, 0, time.UTC),
+			Name:      "Jane Doe",
+			Phone:     "+1 555 0100",
+			Email:     "jane@example.com",
+			Notes:     "Window seat, one high chair;\nbirthday cake at 20:30",
+			Status:    ReservationConfirmed,
+			Tables:    []ReservedTable{{ID: 3, Number: 3, Capacity: 4}, {ID: 4, Number: 4, Capacity: 2}},
+		},
+		{
+			UID:       "b1d6f3a2-7c55-4e0e-8f43-1d2c3b4a5e6f",
+			PartySize: 2,
+			StartsAt:  time.Date(2025, 9, 13, 12, 0, 0, 0, time.UTC),
+			EndsAt:    time.Date(2025, 9, 13, 13, 0, 0, 0, time.UTC),
+			Name:      "Lee for two",
+			Status:    ReservationSeated,
+		},
+	}
+
+	calendar := &ical.Calendar{ProdID: "-//MVC//Reservations//EN", Name: "Reservations"}
+	stamp := time.Date(2025, 9, 8, 9, 0, 0, 0, time.UTC)
+	for i := range reservations {
+		calendar.Events = append(calendar.Events, ReservationEvent(&reservations[i], stamp))
+	}
+	if calendar.Events[0].Location != "Tables 3, 4" {
+		t.Errorf("Expected the location to list the tables, got %q", calendar.Events[0].Location)
+	}
+
+	var buf bytes.Buffer
+	if err := ical.Encode(&buf, calendar); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	parsed, err := ical.Parse(&buf, time.UTC)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(parsed.Events) != len(reservations) {
+		t.Fatalf("Expected %d events, got %d", len(reservations), len(parsed.Events))
+	}
+
+	for i, want := range reservations {
+		if parsed.Events[i].Status != "CONFIRMED" {
+			t.Errorf("Expected %s to be confirmed in the calendar, got %q", want.Name, parsed.Events[i].Status)
+		}
+
+		got, err := ReservationFromEvent(&parsed.Events[i], 90*time.Minute)
+		if err != nil {
+			t.Fatalf("ReservationFromEvent failed for %s: %v", want.Name, err)
+		}
+		if got.UID != want.UID || got.PartySize != want.PartySize || !got.StartsAt.Equal(want.StartsAt) || !got.EndsAt.Equal(want.EndsAt) ||
+			got.Name != want.Name || got.Phone != want.Phone || got.Email != want.Email || got.Notes != want.Notes {
+			t.Errorf("Round trip changed the reservation\nwant %+v\ngot  %+v", want, *got)
+		}
+	}
+
+	cancelled := reservations[1]
+	cancelled.Status = ReservationNoShow
+	if event := ReservationEvent(&cancelled, stamp); event.Status != "CANCELLED" {
+		t.Errorf("Expected a no-show to be cancelled in the calendar, got %q", event.Status)
+	}
+}
+
+func TestReservationFromEvent(t *testing.T) {
+	start := time.Date(2025, 9, 12, 19, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		summary   string
+		partySize int
+		name      string
+	}{
+		{"Jane Doe (party of 4)", 4, "Jane Doe"},
+		{"Booking for 6 guests - Max Mustermann", 6, "Max Mustermann"},
+		{"Lee, 2 pax", 2, "Lee"},
+		{"Table for 3: Garcia", 3, "Garcia"},
+		{"Smith (5)", 5, "Smith"},
+		{"Reservation for 8", 8, "Reservation"},
+	}
+	for _, test := range tests {
+		event := &ical.Event{UID: "uid", Start: start, Summary: test.summary}
+		reservation, err := ReservationFromEvent(event, 90*time.Minute)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", test.summary, err)
+			continue
+		}
+		if reservation.PartySize != test.partySize || reservation.Name != test.name {
+			t.Errorf("%q: expected %q for %d, got %q for %d", test.summary, test.name, test.partySize, reservation.Name, reservation.PartySize)
+		}
+		if !reservation.EndsAt.Equal(start.Add(90 * time.Minute)) {
+			t.Errorf("%q: expected the default duration for an event without an end, got %s", test.summary, reservation.EndsAt.Sub(start))
+		}
+	}
+
+	// X-PARTY-SIZE wins over the title, and attendees give an email when no contact does
+	event := &ical.Event{
+		UID:       "uid",
+		Start:     start,
+		End:       start.Add(24 * time.Hour),
+		Summary:   "Dinner for 2",
+		Attendees: []string{"MAILTO:max@example.com"},
+		Extra:     map[string]string{"X-PARTY-SIZE": "3"},
+	}
+	reservation, err := ReservationFromEvent(event, time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if reservation.PartySize != 3 || reservation.Name != "Dinner" || reservation.Email != "max@example.com" {
+		t.Errorf("Expected Dinner for 3 with max@example.com, got %+v", *reservation)
+	}
+	if !reservation.EndsAt.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected an event longer than a booking to last the default duration, got %s", reservation.EndsAt.Sub(start))
+	}
+
+	failures := []*ical.Event{
+		{Start: start, Summary: "Jane (party of 2)"},
+		{UID: "uid", Summary: "Jane (party of 2)"},
+		{UID: "uid", Start: start, Summary: "Jane"},
+		{UID: "uid", Start: start, Summary: "Jane", Extra: map[string]string{"X-PARTY-SIZE": "two"}},
+	}
+	for _, event := range failures {
+		if _, err := ReservationFromEvent(event, time.Hour); err == nil {
+			t.Errorf("Expected an error for %+v", *event)
+		}
+	}
+}
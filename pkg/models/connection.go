@@ -58,14 +58,8 @@ func InitDatabase(config config.DBConfig) (*sql.DB, error) {
 	DB.SetMaxOpenConns(25)
 	DB.SetMaxIdleConns(5)
 
-	driver, _ := mysql.WithInstance(DB, &mysql.Config{})
-	m, err := migrate.NewWithDatabaseInstance("file://database/migrations", "mysql", driver)
-	if err != nil {
-		return nil, fmt.Errorf("error creating migration instance: %v", err)
-	}
-	err = m.Up()
-	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return nil, fmt.Errorf("error applying migrations: %v", err)
+	if err := migrateDatabase(config); err != nil {
+		return nil, err
 	}
 
 	var count int
@@ -90,6 +84,50 @@ func InitDatabase(config config.DBConfig) (*sql.DB, error) {
 
 }
 
+// rowExists tells an UPDATE that matched a row without changing it from one that matched none,
+// since MySQL only counts changed rows as affected.
+func rowExists(q interface {
+	QueryRow(query string, args ...any) *sql.Row
+}, table string, id int64) (bool, error) {
+	var exists int
+	err := q.QueryRow("SELECT 1 FROM "+table+" WHERE id = ?", id).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// migrateDatabase applies the migrations over a connection of its own. Migrations hold several
+// statements each, which the application's connections do not accept.
+func migrateDatabase(config config.DBConfig) error {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&multiStatements=true",
+		config.User, config.Password, config.Host, config.Port, config.Database)
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return fmt.Errorf("error opening the migration connection: %v", err)
+	}
+
+	driver, err := mysql.WithInstance(db, &mysql.Config{})
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("error creating migration driver: %v", err)
+	}
+	m, err := migrate.NewWithDatabaseInstance("file://database/migrations", "mysql", driver)
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("error creating migration instance: %v", err)
+	}
+	// closing the migration instance closes its connection
+	defer m.Close()
+
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("error applying migrations: %v", err)
+	}
+	return nil
+}
+
 func CloseDatabase() error {
 	if DB != nil {
 		fmt.Println("Closing the database connection...")
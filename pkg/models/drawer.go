@@ -0,0 +1,289 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+	"strings"
+	"time"
+)
+
+const drawerSessionColumns = "id, cashier_id, opened_at, closed_at, opening_float, expected_cash, counted_cash, variance, notes"
+
+// collectedPaymentStatuses are the statuses of payments whose money was taken, whatever was
+// refunded later; refunds are counted separately on the session that paid them out.
+const collectedPaymentStatuses = "'accepted', 'partially_refunded', 'refunded'"
+
+// OpenDrawerSession starts a cashier's shift with the cash already in the drawer. A cashier can
+// only have one open session, which the unique open_cashier_id column enforces.
+func OpenDrawerSession(cashierId int64, openingFloat money.Money, openedAt time.Time) (*DrawerSession, error) {
+	res, err := DB.Exec("INSERT INTO DrawerSessions (cashier_id, opened_at, opening_float) VALUES (?, ?, ?)", cashierId, openedAt, openingFloat)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate") {
+			return nil, fmt.Errorf("cashier %d already has an open drawer session", cashierId)
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DrawerSession{
+		ID:           id,
+		CashierID:    cashierId,
+		OpenedAt:     openedAt,
+		OpeningFloat: openingFloat,
+	}, nil
+}
+
+func GetDrawerSessionById(id int64) (*DrawerSession, error) {
+	row := DB.QueryRow("SELECT "+drawerSessionColumns+" FROM DrawerSessions WHERE id = ?", id)
+	session := &DrawerSession{}
+	if err := scanDrawerSession(row, session); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("drawer session not found")
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func GetOpenDrawerSession(cashierId int64) (*DrawerSession, error) {
+	row := DB.QueryRow("SELECT "+drawerSessionColumns+" FROM DrawerSessions WHERE cashier_id = ? AND closed_at IS NULL", cashierId)
+	session := &DrawerSession{}
+	if err := scanDrawerSession(row, session); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("drawer session not found")
+		}
+		return nil, err
+	}
+	return session, nil
+}
+
+func GetDrawerSessions(cashierId int64, openOnly bool, limit int, offset int) ([]DrawerSession, error) {
+	rows, err := DB.Query("SELECT "+drawerSessionColumns+" FROM DrawerSessions WHERE (cashier_id = ? OR ? = 0) AND (closed_at IS NULL OR NOT ?) ORDER BY id DESC LIMIT ? OFFSET ?",
+		cashierId, cashierId, openOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []DrawerSession
+	for rows.Next() {
+		var session DrawerSession
+		if err := scanDrawerSession(rows, &session); err != nil {
+			return nil, fmt.Errorf("failed to scan drawer session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// CloseDrawerSession ends a session with the cash the cashier counted and records how far it is
+// from what the drawer should hold. cashierId 0 lets a manager close anyone's session.
+func CloseDrawerSession(id int64, cashierId int64, countedCash money.Money, notes string, closedAt time.Time) (*DrawerSession, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &DrawerSession{}
+	err = scanDrawerSession(tx.QueryRow("SELECT "+drawerSessionColumns+" FROM DrawerSessions WHERE id = ? AND (cashier_id = ? OR ? = 0) FOR UPDATE", id, cashierId, cashierId), session)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("drawer session not found")
+		}
+		return nil, err
+	}
+
+	if session.ClosedAt != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("drawer session is already closed")
+	}
+
+	expected, err := expectedCash(tx, session)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+	variance := countedCash - expected
+
+	_, err = tx.Exec("UPDATE DrawerSessions SET closed_at = ?, expected_cash = ?, counted_cash = ?, variance = ?, notes = ? WHERE id = ?",
+		closedAt, expected, countedCash, variance, sql.NullString{String: notes, Valid: notes != ""}, id)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	session.ClosedAt = &closedAt
+	session.ExpectedCash = &expected
+	session.CountedCash = &countedCash
+	session.Variance = &variance
+	session.Notes = notes
+	return session, nil
+}
+
+// GetZReport totals a drawer session by payment method. Method amounts leave out what was paid
+// with gift cards, which is reported on its own. For an open session it shows the takings so far.
+func GetZReport(id int64) (*ZReport, error) {
+	session, err := GetDrawerSessionById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ZReport{Session: *session, Methods: []MethodTotal{}}
+	methods := make(map[PaymentMethod]*MethodTotal)
+	for _, method := range []PaymentMethod{CashPayment, CardPayment, OtherPayment} {
+		report.Methods = append(report.Methods, MethodTotal{Method: method})
+	}
+	for i := range report.Methods {
+		methods[report.Methods[i].Method] = &report.Methods[i]
+	}
+
+	rows, err := DB.Query(`SELECT method, COUNT(*), COALESCE(SUM(total), 0), COALESCE(SUM(gift_card_amount), 0), COALESCE(SUM(tip), 0), COALESCE(SUM(discount), 0), COALESCE(SUM(service_charge), 0), COALESCE(SUM(tax), 0)
+		FROM Payments WHERE drawer_session_id = ? AND status IN (`+collectedPaymentStatuses+`) GROUP BY method`, id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var method PaymentMethod
+		var total MethodTotal
+		var gross, giftCards, discounts, serviceCharge, tax money.Money
+		if err := rows.Scan(&method, &total.Payments, &gross, &giftCards, &total.Tips, &discounts, &serviceCharge, &tax); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan payment totals: %w", err)
+		}
+		if methodTotal, ok := methods[method]; ok {
+			methodTotal.Payments, methodTotal.Amount, methodTotal.Tips = total.Payments, gross-giftCards, total.Tips
+		}
+		report.Payments += total.Payments
+		report.Gross += gross
+		report.GiftCards += giftCards
+		report.Tips += total.Tips
+		report.Discounts += discounts
+		report.ServiceCharge += serviceCharge
+		report.Tax += tax
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`SELECT Payments.method, COALESCE(SUM(Refunds.amount), 0), COALESCE(SUM(Refunds.gift_card_amount), 0) FROM Refunds JOIN Payments ON Payments.id = Refunds.payment_id
+		WHERE Refunds.drawer_session_id = ? AND Refunds.status = 'completed' GROUP BY Payments.method`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var method PaymentMethod
+		var refunds, giftCards money.Money
+		if err := rows.Scan(&method, &refunds, &giftCards); err != nil {
+			return nil, fmt.Errorf("failed to scan refund totals: %w", err)
+		}
+		if methodTotal, ok := methods[method]; ok {
+			methodTotal.Refunds = refunds - giftCards
+		}
+		report.Refunds += refunds
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var cashSold money.Money
+	err = DB.QueryRow("SELECT COALESCE(SUM(amount), 0), COALESCE(SUM(IF(method = 'cash', amount, 0)), 0) FROM GiftCardTransactions WHERE drawer_session_id = ? AND kind = 'issue'", id).Scan(&report.GiftCardsSold, &cashSold)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Net = report.Gross - report.Refunds
+	report.ExpectedCash = session.OpeningFloat + methods[CashPayment].Amount - methods[CashPayment].Refunds + cashSold
+	if session.ExpectedCash != nil {
+		report.ExpectedCash = *session.ExpectedCash
+	}
+	return report, nil
+}
+
+// expectedCash is the opening float plus cash taken, including gift cards sold for cash, minus cash
+// paid back out during the session. Gift card tenders and store credit never pass through the drawer.
+func expectedCash(tx *sql.Tx, session *DrawerSession) (money.Money, error) {
+	var taken, refunded, sold money.Money
+	err := tx.QueryRow("SELECT COALESCE(SUM(total - gift_card_amount), 0) FROM Payments WHERE drawer_session_id = ? AND method = 'cash' AND status IN ("+collectedPaymentStatuses+")", session.ID).Scan(&taken)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow("SELECT COALESCE(SUM(Refunds.amount - Refunds.gift_card_amount), 0) FROM Refunds JOIN Payments ON Payments.id = Refunds.payment_id WHERE Refunds.drawer_session_id = ? AND Refunds.status = 'completed' AND Payments.method = 'cash'", session.ID).Scan(&refunded)
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM GiftCardTransactions WHERE drawer_session_id = ? AND kind = 'issue' AND method = 'cash'", session.ID).Scan(&sold)
+	if err != nil {
+		return 0, err
+	}
+
+	return session.OpeningFloat + taken - refunded + sold, nil
+}
+
+// lockOpenDrawerSession makes sure a session is still open and keeps it from closing until the
+// transaction that records money against it commits.
+func lockOpenDrawerSession(tx *sql.Tx, id int64) error {
+	var closedAt sql.NullTime
+	err := tx.QueryRow("SELECT closed_at FROM DrawerSessions WHERE id = ? LOCK IN SHARE MODE", id).Scan(&closedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("drawer session not found")
+		}
+		return err
+	}
+	if closedAt.Valid {
+		return fmt.Errorf("drawer session is closed")
+	}
+	return nil
+}
+
+func scanDrawerSession(row interface{ Scan(dest ...any) error }, session *DrawerSession) error {
+	var closedAt sql.NullTime
+	var expected, counted, variance sql.NullString
+	var notes sql.NullString
+	if err := row.Scan(&session.ID, &session.CashierID, &session.OpenedAt, &closedAt, &session.OpeningFloat, &expected, &counted, &variance, &notes); err != nil {
+		return err
+	}
+	if closedAt.Valid {
+		session.ClosedAt = &closedAt.Time
+	}
+	for _, column := range []struct {
+		value  sql.NullString
+		target **money.Money
+	}{{expected, &session.ExpectedCash}, {counted, &session.CountedCash}, {variance, &session.Variance}} {
+		if !column.value.Valid {
+			continue
+		}
+		amount, err := money.ParseMoney(column.value.String)
+		if err != nil {
+			return err
+		}
+		*column.target = &amount
+	}
+	session.Notes = notes.String
+	return nil
+}
@@ -0,0 +1,245 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+	"strings"
+	"time"
+)
+
+const (
+	giftCardCodeLength   = 16
+	giftCardCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	giftCardColumns      = "id, code, kind, balance, currency, customer_id, issued_by, created_at"
+)
+
+// GenerateGiftCardCode returns a random code of 16 letters and digits, leaving out the ones that are
+// easily misread (0, O, 1 and I).
+func GenerateGiftCardCode() (string, error) {
+	random := make([]byte, giftCardCodeLength)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	code := make([]byte, giftCardCodeLength)
+	for i, b := range random {
+		code[i] = giftCardCodeAlphabet[int(b)%len(giftCardCodeAlphabet)]
+	}
+	return string(code), nil
+}
+
+// NormalizeGiftCardCode accepts codes typed in lower case or grouped with spaces or dashes.
+func NormalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+}
+
+// GiftCardRefundable is how much of the payment's gift card tender has not been put back on the
+// card by refunds yet.
+func (p *Payment) GiftCardRefundable() money.Money {
+	return max(p.GiftCardAmount-p.GiftCardRefunded, 0)
+}
+
+// IssueGiftCard creates a card with a new code and loads it with the amount, recording the issue
+// in its ledger together with how it was paid for.
+func IssueGiftCard(card *GiftCard, issue *GiftCardTransaction) (*GiftCard, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := createGiftCard(tx, card)
+	if err == nil {
+		issue.GiftCardID = created.ID
+		issue.Kind = GiftCardIssue
+		err = postGiftCardTransaction(tx, issue)
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+	created.Balance = issue.BalanceAfter
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func GetGiftCardById(id int64) (*GiftCard, error) {
+	card := &GiftCard{}
+	if err := scanGiftCard(DB.QueryRow("SELECT "+giftCardColumns+" FROM GiftCards WHERE id = ?", id), card); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("gift card not found")
+		}
+		return nil, err
+	}
+	return card, nil
+}
+
+func GetGiftCardByCode(code string) (*GiftCard, error) {
+	card := &GiftCard{}
+	if err := scanGiftCard(DB.QueryRow("SELECT "+giftCardColumns+" FROM GiftCards WHERE code = ?", code), card); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("gift card not found")
+		}
+		return nil, err
+	}
+	return card, nil
+}
+
+// GetGiftCards lists cards newest first. customerId 0 returns every card.
+func GetGiftCards(customerId int64, limit int, offset int) ([]GiftCard, error) {
+	rows, err := DB.Query("SELECT "+giftCardColumns+" FROM GiftCards WHERE (customer_id = ? OR ? = 0) ORDER BY id DESC LIMIT ? OFFSET ?", customerId, customerId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []GiftCard
+	for rows.Next() {
+		var card GiftCard
+		if err := scanGiftCard(rows, &card); err != nil {
+			return nil, fmt.Errorf("failed to scan gift card: %w", err)
+		}
+		cards = append(cards, card)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return cards, nil
+}
+
+func GetGiftCardTransactions(giftCardId int64) ([]GiftCardTransaction, error) {
+	rows, err := DB.Query("SELECT id, gift_card_id, kind, amount, balance_after, payment_id, refund_id, drawer_session_id, method, created_by, created_at FROM GiftCardTransactions WHERE gift_card_id = ? ORDER BY id", giftCardId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []GiftCardTransaction
+	for rows.Next() {
+		var transaction GiftCardTransaction
+		var paymentId, refundId, drawerSessionId, createdBy sql.NullInt64
+		var method sql.NullString
+		if err := rows.Scan(&transaction.ID, &transaction.GiftCardID, &transaction.Kind, &transaction.Amount, &transaction.BalanceAfter,
+			&paymentId, &refundId, &drawerSessionId, &method, &createdBy, &transaction.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan gift card transaction: %w", err)
+		}
+		transaction.PaymentID = paymentId.Int64
+		transaction.RefundID = refundId.Int64
+		transaction.DrawerSessionID = drawerSessionId.Int64
+		transaction.Method = PaymentMethod(method.String)
+		transaction.CreatedBy = createdBy.Int64
+		transactions = append(transactions, transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// createGiftCard inserts an empty card with a fresh code, retrying the rare code collision.
+func createGiftCard(tx *sql.Tx, card *GiftCard) (*GiftCard, error) {
+	created := *card
+	created.CreatedAt = time.Now()
+	for attempt := 0; ; attempt++ {
+		code, err := GenerateGiftCardCode()
+		if err != nil {
+			return nil, err
+		}
+
+		res, err := tx.Exec("INSERT INTO GiftCards (code, kind, balance, currency, customer_id, issued_by, created_at) VALUES (?, ?, 0, ?, ?, ?, ?)",
+			code, created.Kind, created.Currency, nullableId(created.CustomerID), nullableId(created.IssuedBy), created.CreatedAt)
+		if err != nil {
+			if strings.Contains(err.Error(), "Duplicate") && attempt < 3 {
+				continue
+			}
+			if strings.Contains(err.Error(), "foreign key") {
+				return nil, fmt.Errorf("customer not found")
+			}
+			return nil, err
+		}
+
+		created.ID, err = res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		created.Code = code
+		break
+	}
+
+	created.Balance = 0
+	return &created, nil
+}
+
+// postGiftCardTransaction applies a signed amount to the card's balance and appends it to the
+// ledger. The card row is locked, and a debit larger than the balance is refused.
+func postGiftCardTransaction(tx *sql.Tx, entry *GiftCardTransaction) error {
+	var balance money.Money
+	err := tx.QueryRow("SELECT balance FROM GiftCards WHERE id = ? FOR UPDATE", entry.GiftCardID).Scan(&balance)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("gift card not found")
+		}
+		return err
+	}
+
+	if balance+entry.Amount < 0 {
+		return fmt.Errorf("insufficient gift card balance of %s", balance)
+	}
+	entry.BalanceAfter = balance + entry.Amount
+	entry.CreatedAt = time.Now()
+
+	if _, err := tx.Exec("UPDATE GiftCards SET balance = ? WHERE id = ?", entry.BalanceAfter, entry.GiftCardID); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("INSERT INTO GiftCardTransactions (gift_card_id, kind, amount, balance_after, payment_id, refund_id, drawer_session_id, method, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.GiftCardID, entry.Kind, entry.Amount, entry.BalanceAfter, nullableId(entry.PaymentID), nullableId(entry.RefundID), nullableId(entry.DrawerSessionID),
+		sql.NullString{String: string(entry.Method), Valid: entry.Method != ""}, nullableId(entry.CreatedBy), entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+// reverseGiftCardRedemption puts the gift card tender of a payment that did not go through back on
+// the card.
+func reverseGiftCardRedemption(tx *sql.Tx, paymentId int64, actorId int64) error {
+	var giftCardId sql.NullInt64
+	var amount money.Money
+	err := tx.QueryRow("SELECT gift_card_id, gift_card_amount FROM Payments WHERE id = ?", paymentId).Scan(&giftCardId, &amount)
+	if err != nil {
+		return err
+	}
+	if !giftCardId.Valid || amount == 0 {
+		return nil
+	}
+
+	return postGiftCardTransaction(tx, &GiftCardTransaction{
+		GiftCardID: giftCardId.Int64,
+		Kind:       GiftCardReversal,
+		Amount:     amount,
+		PaymentID:  paymentId,
+		CreatedBy:  actorId,
+	})
+}
+
+func scanGiftCard(row interface{ Scan(dest ...any) error }, card *GiftCard) error {
+	var customerId, issuedBy sql.NullInt64
+	if err := row.Scan(&card.ID, &card.Code, &card.Kind, &card.Balance, &card.Currency, &customerId, &issuedBy, &card.CreatedAt); err != nil {
+		return err
+	}
+	card.CustomerID = customerId.Int64
+	card.IssuedBy = issuedBy.Int64
+	return nil
+}
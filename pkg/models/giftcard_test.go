@@ -0,0 +1,57 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateGiftCardCode(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		code, err := GenerateGiftCardCode()
+		if err != nil {
+			t.Fatalf("GenerateGiftCardCode returned error %v", err)
+		}
+		if len(code) != 16 {
+			t.Fatalf("Expected a 16 character code, got %q", code)
+		}
+		if strings.ContainsAny(code, "01IO") || NormalizeGiftCardCode(code) != code {
+			t.Fatalf("Code %q contains ambiguous or lower case characters", code)
+		}
+		if seen[code] {
+			t.Fatalf("Code %q was generated twice", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestNormalizeGiftCardCode(t *testing.T) {
+	tests := map[string]string{
+		"K7QX4M2PZ9RTW3HV":      "K7QX4M2PZ9RTW3HV",
+		"k7qx-4m2p-z9rt-w3hv":   "K7QX4M2PZ9RTW3HV",
+		" K7QX 4M2P Z9RT W3HV ": "K7QX4M2PZ9RTW3HV",
+		"":                      "",
+	}
+	for input, want := range tests {
+		if got := NormalizeGiftCardCode(input); got != want {
+			t.Errorf("NormalizeGiftCardCode(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestGiftCardRefundable(t *testing.T) {
+	tests := []struct {
+		payment Payment
+		want    int64
+	}{
+		{Payment{}, 0},
+		{Payment{GiftCardAmount: 2000}, 2000},
+		{Payment{GiftCardAmount: 2000, GiftCardRefunded: 500}, 1500},
+		{Payment{GiftCardAmount: 2000, GiftCardRefunded: 2000}, 0},
+	}
+	for _, test := range tests {
+		if got := test.payment.GiftCardRefundable(); int64(got) != test.want {
+			t.Errorf("GiftCardRefundable() of %+v = %d, want %d", test.payment, got, test.want)
+		}
+	}
+}
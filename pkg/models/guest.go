@@ -0,0 +1,112 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const guestSessionColumns = "id, user_id, table_id, created_at, expires_at, converted_at"
+
+// StartGuestSession creates a guest user for a walk-in at the table. The guest has no credentials;
+// the session is all that identifies them until it expires or they register.
+func StartGuestSession(tableId int64, now time.Time, lifetime time.Duration) (*GuestSession, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &GuestSession{
+		TableID:   tableId,
+		CreatedAt: now,
+		ExpiresAt: now.Add(lifetime),
+	}
+	res, err := tx.Exec("INSERT INTO Users (name, email, password_hash, role) VALUES (?, '', '', ?)", "Guest", Guest)
+	if err == nil {
+		session.UserID, err = res.LastInsertId()
+	}
+	if err == nil {
+		res, err = tx.Exec("INSERT INTO GuestSessions (user_id, table_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+			session.UserID, session.TableID, session.CreatedAt, session.ExpiresAt)
+	}
+	if err == nil {
+		session.ID, err = res.LastInsertId()
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetGuestSession returns the live session of a guest user. Sessions end when they expire or the
+// guest converts to a full account.
+func GetGuestSession(userId int64, now time.Time) (*GuestSession, error) {
+	session := &GuestSession{}
+	if err := scanGuestSession(DB.QueryRow("SELECT "+guestSessionColumns+" FROM GuestSessions WHERE user_id = ?", userId), session); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("guest session not found")
+		}
+		return nil, err
+	}
+	if session.ConvertedAt != nil || !now.Before(session.ExpiresAt) {
+		return nil, fmt.Errorf("guest session has ended")
+	}
+	return session, nil
+}
+
+// ConvertGuestSession turns a guest into a registered customer. The user keeps their id, so the
+// orders they placed as a guest stay theirs.
+func ConvertGuestSession(userId int64, name string, email string, passwordHash string, now time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = convertGuestSession(tx, userId, name, email, passwordHash, now)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("%v %v", err1, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func convertGuestSession(tx *sql.Tx, userId int64, name string, email string, passwordHash string, now time.Time) error {
+	session := &GuestSession{}
+	if err := scanGuestSession(tx.QueryRow("SELECT "+guestSessionColumns+" FROM GuestSessions WHERE user_id = ? FOR UPDATE", userId), session); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("guest session not found")
+		}
+		return err
+	}
+	if session.ConvertedAt != nil || !now.Before(session.ExpiresAt) {
+		return fmt.Errorf("guest session has ended")
+	}
+
+	if _, err := tx.Exec("UPDATE Users SET name = ?, email = ?, password_hash = ?, role = ? WHERE id = ?", name, email, passwordHash, Customer, userId); err != nil {
+		return err
+	}
+	_, err := tx.Exec("UPDATE GuestSessions SET converted_at = ? WHERE id = ?", now, session.ID)
+	return err
+}
+
+func scanGuestSession(row interface{ Scan(dest ...any) error }, session *GuestSession) error {
+	var convertedAt sql.NullTime
+	if err := row.Scan(&session.ID, &session.UserID, &session.TableID, &session.CreatedAt, &session.ExpiresAt, &convertedAt); err != nil {
+		return err
+	}
+	if convertedAt.Valid {
+		session.ConvertedAt = &convertedAt.Time
+	}
+	return nil
+}
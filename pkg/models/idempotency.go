@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CreateIdempotencyKey reserves a key for a request that is about to run. The reservation has no
+// status code until CompleteIdempotencyKey stores the response.
+func CreateIdempotencyKey(userId int64, key string, requestHash string, createdAt time.Time) (*IdempotencyKey, error) {
+	res, err := DB.Exec("INSERT INTO IdempotencyKeys (user_id, idempotency_key, request_hash, created_at) VALUES (?, ?, ?, ?)",
+		userId, key, requestHash, createdAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate") {
+			return nil, fmt.Errorf("idempotency key '%s' already exists", key)
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &IdempotencyKey{
+		ID:          id,
+		UserID:      userId,
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+func GetIdempotencyKey(userId int64, key string) (*IdempotencyKey, error) {
+	var record IdempotencyKey
+	var statusCode sql.NullInt64
+	var contentType sql.NullString
+	err := DB.QueryRow("SELECT id, user_id, idempotency_key, request_hash, status_code, content_type, response_body, created_at FROM IdempotencyKeys WHERE user_id = ? AND idempotency_key = ?", userId, key).Scan(
+		&record.ID, &record.UserID, &record.Key, &record.RequestHash, &statusCode, &contentType, &record.Body, &record.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("idempotency key not found")
+		}
+		return nil, err
+	}
+	record.StatusCode = int(statusCode.Int64)
+	record.ContentType = contentType.String
+	return &record, nil
+}
+
+func CompleteIdempotencyKey(id int64, statusCode int, contentType string, body []byte) error {
+	_, err := DB.Exec("UPDATE IdempotencyKeys SET status_code = ?, content_type = ?, response_body = ? WHERE id = ?", statusCode, contentType, body, id)
+	return err
+}
+
+func DeleteIdempotencyKey(id int64) error {
+	_, err := DB.Exec("DELETE FROM IdempotencyKeys WHERE id = ?", id)
+	return err
+}
+
+// DeleteIdempotencyKeysBefore removes keys created before the given time, making them usable again.
+func DeleteIdempotencyKeysBefore(before time.Time) (int64, error) {
+	res, err := DB.Exec("DELETE FROM IdempotencyKeys WHERE created_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -5,14 +5,15 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
 )
 
-func CreateItem(ctx context.Context, name string, description string, price float64, tags []Tag, imageURL string, available bool) (*Item, error) {
+func CreateItem(ctx context.Context, name string, description string, price money.Money, tags []Tag, imageURL string, available bool, taxCategoryId int64) (*Item, error) {
 	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
-	res, err := tx.Exec("INSERT INTO Items (name, description, price, is_available, image_url) SELECT ?, ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM Items WHERE name = ?)", name, description, price, available, imageURL, name)
+	res, err := tx.Exec("INSERT INTO Items (name, description, price, is_available, image_url, tax_category_id) SELECT ?, ?, ?, ?, ?, ? WHERE NOT EXISTS (SELECT 1 FROM Items WHERE name = ?)", name, description, price, available, imageURL, nullableId(taxCategoryId), name)
 	if err != nil {
 		if err1 := tx.Rollback(); err1 != nil {
 			return nil, fmt.Errorf("%v %v", err1, err)
@@ -33,13 +34,14 @@ func CreateItem(ctx context.Context, name string, description string, price floa
 	}
 
 	item := &Item{
-		ID:          id,
-		Name:        name,
-		Description: description,
-		Price:       price,
-		ImageURL:    imageURL,
-		Available:   available,
-		Tags:        tags,
+		ID:            id,
+		Name:          name,
+		Description:   description,
+		Price:         price,
+		ImageURL:      imageURL,
+		Available:     available,
+		Tags:          tags,
+		TaxCategoryID: taxCategoryId,
 	}
 
 	query := "INSERT INTO ItemTags (item_id, tag_id) VALUES "
@@ -68,13 +70,13 @@ func CreateItem(ctx context.Context, name string, description string, price floa
 	return item, nil
 }
 
-func EditItem(ctx context.Context, id int64, name string, description string, price float64, tags []Tag, imageURL string, available bool) (*Item, error) {
+func EditItem(ctx context.Context, id int64, name string, description string, price money.Money, tags []Tag, imageURL string, available bool, taxCategoryId int64) (*Item, error) {
 	tx, err := DB.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = tx.Exec("UPDATE Items SET name = ?, description = ?, price = ?, is_available = ?, image_url = ? WHERE id = ? AND NOT EXISTS (SELECT 1 FROM (SELECT 1 FROM Items WHERE name = ? AND id != ?) AS temp_table);", name, description, price, available, imageURL, id, name, id)
+	_, err = tx.Exec("UPDATE Items SET name = ?, description = ?, price = ?, is_available = ?, image_url = ?, tax_category_id = ? WHERE id = ? AND NOT EXISTS (SELECT 1 FROM (SELECT 1 FROM Items WHERE name = ? AND id != ?) AS temp_table);", name, description, price, available, imageURL, nullableId(taxCategoryId), id, name, id)
 	if err != nil {
 		if err1 := tx.Rollback(); err1 != nil {
 			return nil, fmt.Errorf("%v %v", err1, err)
@@ -114,13 +116,14 @@ func EditItem(ctx context.Context, id int64, name string, description string, pr
 	}
 
 	item := &Item{
-		ID:          id,
-		Name:        name,
-		Description: description,
-		Price:       price,
-		ImageURL:    imageURL,
-		Available:   available,
-		Tags:        tags,
+		ID:            id,
+		Name:          name,
+		Description:   description,
+		Price:         price,
+		ImageURL:      imageURL,
+		Available:     available,
+		Tags:          tags,
+		TaxCategoryID: taxCategoryId,
 	}
 
 	return item, nil
@@ -128,7 +131,7 @@ func EditItem(ctx context.Context, id int64, name string, description string, pr
 
 func GetItemById(id int64) (*Item, error) {
 	rows, err := DB.Query(`
-								SELECT Items.id, Items.name, description, price, is_available, image_url,
+								SELECT Items.id, Items.name, description, price, is_available, image_url, Items.tax_category_id,
 									CONCAT('[', 
 										GROUP_CONCAT(
          									JSON_OBJECT('id', Tags.id, 'name', Tags.name)
@@ -158,7 +161,7 @@ func GetItemById(id int64) (*Item, error) {
 
 func GetItems(tags []Tag, search string, available bool, limit int, offset int) ([]Item, error) {
 	query := `
-				SELECT Items.id, Items.name, description, price, is_available, image_url,
+				SELECT Items.id, Items.name, description, price, is_available, image_url, Items.tax_category_id,
 					CONCAT('[', 
 						GROUP_CONCAT(
 							JSON_OBJECT('id', Tags.id, 'name', Tags.name)
@@ -206,7 +209,7 @@ func GetItems(tags []Tag, search string, available bool, limit int, offset int)
 
 func GetItemByIdBulk(ids []int64) (*[]Item, error) {
 
-	query := "SELECT Items.id, Items.name, description, price, is_available, image_url, CONCAT('[', GROUP_CONCAT(JSON_OBJECT('id', Tags.id, 'name', Tags.name)), ']') as tags FROM Items LEFT JOIN ItemTags ON ItemTags.item_id = Items.id LEFT JOIN Tags ON ItemTags.tag_id = Tags.id WHERE Items.id IN ("
+	query := "SELECT Items.id, Items.name, description, price, is_available, image_url, Items.tax_category_id, CONCAT('[', GROUP_CONCAT(JSON_OBJECT('id', Tags.id, 'name', Tags.name)), ']') as tags FROM Items LEFT JOIN ItemTags ON ItemTags.item_id = Items.id LEFT JOIN Tags ON ItemTags.tag_id = Tags.id WHERE Items.id IN ("
 
 	args := make([]any, len(ids))
 	for i, id := range ids {
@@ -236,9 +239,11 @@ func GetItemByIdBulk(ids []int64) (*[]Item, error) {
 
 func scanItem(rows *sql.Rows, item *Item) error {
 	var tagsJSON string
-	if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.Price, &item.Available, &item.ImageURL, &tagsJSON); err != nil {
+	var taxCategoryId sql.NullInt64
+	if err := rows.Scan(&item.ID, &item.Name, &item.Description, &item.Price, &item.Available, &item.ImageURL, &taxCategoryId, &tagsJSON); err != nil {
 		return fmt.Errorf("failed to scan item: %w", err)
 	}
+	item.TaxCategoryID = taxCategoryId.Int64
 
 	if tagsJSON != "[]" {
 		if err := json.Unmarshal([]byte(tagsJSON), &item.Tags); err != nil {
@@ -0,0 +1,313 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/money"
+	"strings"
+	"time"
+)
+
+// MaxLoyaltyMultiplier caps the earn rate bonus a tag can give.
+const MaxLoyaltyMultiplier = 10 * money.OneHundredPercent
+
+// CalculateLoyaltyPoints works out the points a payment earns. The discount is spread over the lines
+// first so that discounted and redeemed amounts earn nothing, each line is weighted by its tag
+// multiplier and the total by the customer's tier. Points are per whole unit of currency and
+// rounded down; taxes, service charge and tip do not earn points.
+func CalculateLoyaltyPoints(lines []LoyaltyLine, discount money.Money, earnRate int, tierMultiplier money.Percent) int64 {
+	weights := make([]int64, len(lines))
+	for i, line := range lines {
+		weights[i] = int64(line.Amount)
+	}
+	shares := discount.Allocate(weights)
+
+	eligible := money.Money(0)
+	for i, line := range lines {
+		net := line.Amount - shares[i]
+		if net > 0 {
+			eligible += net.Percent(line.Multiplier)
+		}
+	}
+	eligible = eligible.Percent(tierMultiplier)
+
+	return int64(eligible) * int64(earnRate) / 100
+}
+
+// LoyaltyRedemption returns how many of the points can be redeemed against an amount of at most
+// limit and the discount they give. Points that would discount more than the limit are not used.
+func LoyaltyRedemption(points int64, pointValue money.Money, limit money.Money) (int64, money.Money) {
+	if points <= 0 || pointValue <= 0 || limit <= 0 {
+		return 0, 0
+	}
+	points = min(points, int64(limit/pointValue))
+	return points, pointValue * money.Money(points)
+}
+
+// GetLoyaltyPoints returns the points balance of a user.
+func GetLoyaltyPoints(userId int64) (int64, error) {
+	var points int64
+	err := DB.QueryRow("SELECT loyalty_points FROM Users WHERE id = ?", userId).Scan(&points)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return 0, fmt.Errorf("user not found")
+		}
+		return 0, err
+	}
+	return points, nil
+}
+
+// GetTrailingSpend returns what a user paid since the given time, less refunds.
+func GetTrailingSpend(userId int64, since time.Time) (money.Money, error) {
+	return trailingSpend(DB, userId, since, 0)
+}
+
+func trailingSpend(q queryer, userId int64, since time.Time, excludePaymentId int64) (money.Money, error) {
+	rows, err := q.Query(`SELECT COALESCE(SUM(total - (SELECT COALESCE(SUM(amount), 0) FROM Refunds WHERE payment_id = Payments.id AND status = 'completed')), 0)
+		FROM Payments WHERE user_id = ? AND id <> ? AND created_at >= ? AND status IN (`+collectedPaymentStatuses+`)`, userId, excludePaymentId, since)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	spend := money.Money(0)
+	if rows.Next() {
+		if err := rows.Scan(&spend); err != nil {
+			return 0, err
+		}
+	}
+	return spend, rows.Err()
+}
+
+func GetLoyaltyTransactions(userId int64, limit int, offset int) ([]LoyaltyTransaction, error) {
+	rows, err := DB.Query("SELECT id, user_id, kind, points, balance_after, payment_id, refund_id, created_by, created_at FROM LoyaltyTransactions WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		userId, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []LoyaltyTransaction
+	for rows.Next() {
+		var transaction LoyaltyTransaction
+		var paymentId, refundId, createdBy sql.NullInt64
+		if err := rows.Scan(&transaction.ID, &transaction.UserID, &transaction.Kind, &transaction.Points, &transaction.BalanceAfter,
+			&paymentId, &refundId, &createdBy, &transaction.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan loyalty transaction: %w", err)
+		}
+		transaction.PaymentID = paymentId.Int64
+		transaction.RefundID = refundId.Int64
+		transaction.CreatedBy = createdBy.Int64
+		transactions = append(transactions, transaction)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transactions, nil
+}
+
+// AwardLoyaltyPoints credits the points an accepted payment earns to its customer at the tier the
+// customer held before the payment. A payment earns once; later calls return the points it earned
+// the first time. Payments of users without the customer role earn nothing.
+func AwardLoyaltyPoints(paymentId int64, program config.LoyaltyConfig, now time.Time) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var userId int64
+	var status PaymentStatus
+	var discount money.Money
+	var earned sql.NullInt64
+	err = tx.QueryRow("SELECT user_id, status, discount, loyalty_earned FROM Payments WHERE id = ? FOR UPDATE", paymentId).Scan(&userId, &status, &discount, &earned)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, fmt.Errorf("%v %v", err1, err)
+		}
+		if strings.Contains(err.Error(), "no rows") {
+			return 0, fmt.Errorf("payment not found")
+		}
+		return 0, err
+	}
+
+	if earned.Valid || status != Accepted {
+		_ = tx.Rollback()
+		return earned.Int64, nil
+	}
+
+	var role Role
+	if err := tx.QueryRow("SELECT role FROM Users WHERE id = ?", userId).Scan(&role); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, fmt.Errorf("%v %v", err1, err)
+		}
+		return 0, err
+	}
+
+	points := int64(0)
+	if role.HasFlag(Customer) {
+		lines, err := loyaltyLines(tx, paymentId)
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return 0, fmt.Errorf("%v %v", err1, err)
+			}
+			return 0, err
+		}
+
+		spend, err := trailingSpend(tx, userId, now.Add(-program.TierWindow), paymentId)
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return 0, fmt.Errorf("%v %v", err1, err)
+			}
+			return 0, err
+		}
+		tier, _ := program.Tiers.For(spend)
+
+		points = CalculateLoyaltyPoints(lines, discount, program.EarnRate, tier.Multiplier)
+	}
+
+	if _, err := tx.Exec("UPDATE Payments SET loyalty_earned = ? WHERE id = ?", points, paymentId); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, fmt.Errorf("%v %v", err1, err)
+		}
+		return 0, err
+	}
+
+	if points > 0 {
+		err = postLoyaltyTransaction(tx, &LoyaltyTransaction{UserID: userId, Kind: LoyaltyEarn, Points: points, PaymentID: paymentId})
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return 0, fmt.Errorf("%v %v", err1, err)
+			}
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return points, nil
+}
+
+// loyaltyLines returns the order item amounts a payment covers with the highest loyalty multiplier
+// of each item's tags. Untagged items earn at the plain rate.
+func loyaltyLines(q queryer, paymentId int64) ([]LoyaltyLine, error) {
+	rows, err := q.Query(`SELECT pi.amount, COALESCE(MAX(t.loyalty_multiplier), 100) FROM PaymentItems pi
+			JOIN OrderItems oi ON oi.id = pi.order_item_id
+			LEFT JOIN ItemTags it ON it.item_id = oi.item_id
+			LEFT JOIN Tags t ON t.id = it.tag_id
+			WHERE pi.payment_id = ? GROUP BY pi.order_item_id, pi.amount ORDER BY pi.order_item_id`, paymentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []LoyaltyLine
+	for rows.Next() {
+		var line LoyaltyLine
+		if err := rows.Scan(&line.Amount, &line.Multiplier); err != nil {
+			return nil, fmt.Errorf("failed to scan loyalty line: %w", err)
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// postLoyaltyTransaction adds the entry's points to the user's balance, which cannot go negative,
+// and records it in the ledger.
+func postLoyaltyTransaction(tx *sql.Tx, entry *LoyaltyTransaction) error {
+	var balance int64
+	err := tx.QueryRow("SELECT loyalty_points FROM Users WHERE id = ? FOR UPDATE", entry.UserID).Scan(&balance)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("user not found")
+		}
+		return err
+	}
+
+	if balance+entry.Points < 0 {
+		return fmt.Errorf("insufficient loyalty points balance of %d", balance)
+	}
+	entry.BalanceAfter = balance + entry.Points
+	entry.CreatedAt = time.Now()
+
+	if _, err := tx.Exec("UPDATE Users SET loyalty_points = ? WHERE id = ?", entry.BalanceAfter, entry.UserID); err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("INSERT INTO LoyaltyTransactions (user_id, kind, points, balance_after, payment_id, refund_id, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		entry.UserID, entry.Kind, entry.Points, entry.BalanceAfter, nullableId(entry.PaymentID), nullableId(entry.RefundID), nullableId(entry.CreatedBy), entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, err = res.LastInsertId()
+	return err
+}
+
+// reverseLoyaltyRedemption gives back the points redeemed on a payment that did not go through.
+func reverseLoyaltyRedemption(tx *sql.Tx, paymentId int64, actorId int64) error {
+	var userId, points int64
+	err := tx.QueryRow("SELECT user_id, loyalty_points FROM Payments WHERE id = ?", paymentId).Scan(&userId, &points)
+	if err != nil {
+		return err
+	}
+	if points == 0 {
+		return nil
+	}
+
+	return postLoyaltyTransaction(tx, &LoyaltyTransaction{
+		UserID:    userId,
+		Kind:      LoyaltyReversal,
+		Points:    points,
+		PaymentID: paymentId,
+		CreatedBy: actorId,
+	})
+}
+
+// clawBackLoyaltyPoints takes back the share of the points a payment earned that its refunds cover,
+// refundedAfter being everything refunded including the new refund. Points the customer has
+// already spent are not taken back, so the balance never goes negative.
+func clawBackLoyaltyPoints(tx *sql.Tx, paymentId int64, refundId int64, refundedAfter money.Money, total money.Money, actorId int64) error {
+	var userId int64
+	var earned sql.NullInt64
+	err := tx.QueryRow("SELECT user_id, loyalty_earned FROM Payments WHERE id = ?", paymentId).Scan(&userId, &earned)
+	if err != nil {
+		return err
+	}
+	if earned.Int64 == 0 || total <= 0 {
+		return nil
+	}
+
+	var clawedBack int64
+	err = tx.QueryRow("SELECT COALESCE(-SUM(points), 0) FROM LoyaltyTransactions WHERE payment_id = ? AND kind = ?", paymentId, LoyaltyRefund).Scan(&clawedBack)
+	if err != nil {
+		return err
+	}
+
+	var balance int64
+	if err := tx.QueryRow("SELECT loyalty_points FROM Users WHERE id = ? FOR UPDATE", userId).Scan(&balance); err != nil {
+		return err
+	}
+
+	points := min(earned.Int64*int64(refundedAfter)/int64(total)-clawedBack, balance)
+	if points <= 0 {
+		return nil
+	}
+
+	return postLoyaltyTransaction(tx, &LoyaltyTransaction{
+		UserID:    userId,
+		Kind:      LoyaltyRefund,
+		Points:    -points,
+		PaymentID: paymentId,
+		RefundID:  refundId,
+		CreatedBy: actorId,
+	})
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"github.com/gqvz/mvc/pkg/money"
+	"testing"
+)
+
+func TestCalculateLoyaltyPoints(t *testing.T) {
+	lines := []LoyaltyLine{
+		{Amount: 1000, Multiplier: money.OneHundredPercent},
+		{Amount: 2000, Multiplier: 2 * money.OneHundredPercent},
+	}
+
+	tests := []struct {
+		name     string
+		discount money.Money
+		earnRate int
+		tier     money.Percent
+		expected int64
+	}{
+		{"tag multipliers", 0, 1, money.OneHundredPercent, 50},
+		{"discount spread over lines", 300, 1, money.OneHundredPercent, 45},
+		{"tier multiplier", 300, 1, money.Percent(150_000), 67},
+		{"earn rate", 0, 3, money.OneHundredPercent, 150},
+		{"fully discounted", 3000, 1, money.OneHundredPercent, 0},
+		{"no earn rate", 0, 0, money.OneHundredPercent, 0},
+	}
+	for _, test := range tests {
+		if points := CalculateLoyaltyPoints(lines, test.discount, test.earnRate, test.tier); points != test.expected {
+			t.Errorf("%s: expected %d points, got %d", test.name, test.expected, points)
+		}
+	}
+
+	// partial currency units are rounded down
+	if points := CalculateLoyaltyPoints([]LoyaltyLine{{Amount: 199, Multiplier: money.OneHundredPercent}}, 0, 1, money.OneHundredPercent); points != 1 {
+		t.Errorf("Expected 1.99 to earn 1 point, got %d", points)
+	}
+}
+
+func TestLoyaltyRedemption(t *testing.T) {
+	tests := []struct {
+		points     int64
+		pointValue money.Money
+		limit      money.Money
+		expected   int64
+		discount   money.Money
+	}{
+		{500, 1, 1000, 500, 500},
+		{500, 1, 300, 300, 300},
+		{500, 5, 1001, 200, 1000},
+		{500, 5, 4, 0, 0},
+		{0, 1, 1000, 0, 0},
+		{500, 1, 0, 0, 0},
+	}
+	for _, test := range tests {
+		points, discount := LoyaltyRedemption(test.points, test.pointValue, test.limit)
+		if points != test.expected || discount != test.discount {
+			t.Errorf("LoyaltyRedemption(%d, %s, %s) = %d, %s; want %d, %s", test.points, test.pointValue, test.limit, points, discount, test.expected, test.discount)
+		}
+	}
+}
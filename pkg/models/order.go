@@ -1,69 +1,206 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"strings"
 	"time"
 )
 
-func CreateOrder(userId int64, tableNumber int) (*Order, error) {
-	result, err := DB.Exec("INSERT INTO Orders (customer_id, status, table_number, ordered_at) SELECT ?, 'open', ?, ? WHERE NOT EXISTS (SELECT 1 FROM Orders WHERE table_number = ? AND status = 'open')", userId, tableNumber, time.Now(), tableNumber)
+const orderColumns = "id, customer_id, status, type, table_id, table_number, guests, pickup_at, delivery_address, delivery_fee, ordered_at, bill_requested_at, join_code"
+
+// orderAccess limits a query on Orders to the orders a user opened or joined. It takes the user id
+// three times; 0 is staff, who see every order.
+const orderAccess = "(? = 0 OR Orders.customer_id = ? OR EXISTS (SELECT 1 FROM OrderParticipants WHERE OrderParticipants.order_id = Orders.id AND OrderParticipants.user_id = ?))"
+
+// CreateOrder seats an order at a table, which becomes occupied. A table takes one open order at a
+// time and has to be cleaned after the last one closed before the next guests sit down.
+func CreateOrder(userId int64, tableId int64, guests int) (*Order, error) {
+	tx, err := DB.Begin()
 	if err != nil {
 		return nil, err
 	}
 
-	id, err := result.LastInsertId()
+	order, err := createOrder(tx, userId, tableId, guests)
 	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
 		return nil, err
 	}
 
-	if id == 0 {
-		return nil, fmt.Errorf("order for table %d already exists", tableNumber)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func createOrder(tx *sql.Tx, userId int64, tableId int64, guests int) (*Order, error) {
+	var tableNumber int
+	var tableStatus TableStatus
+	if err := tx.QueryRow("SELECT number, status FROM DiningTables WHERE id = ? FOR UPDATE", tableId).Scan(&tableNumber, &tableStatus); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("table not found")
+		}
+		return nil, err
+	}
+	if tableStatus == TableNeedsCleaning {
+		return nil, fmt.Errorf("table %d needs cleaning", tableNumber)
 	}
 
-	return &Order{
-		ID:          id,
+	order := &Order{
 		CustomerID:  userId,
 		Status:      Open,
+		Type:        DineIn,
+		TableID:     tableId,
 		TableNumber: tableNumber,
+		Guests:      guests,
 		OrderedAt:   time.Now(),
-	}, nil
+	}
+	result, err := tx.Exec("INSERT INTO Orders (customer_id, status, type, table_id, table_number, guests, ordered_at) SELECT ?, 'open', 'dine_in', ?, ?, ?, ? FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM Orders WHERE table_id = ? AND status = 'open')",
+		order.CustomerID, order.TableID, order.TableNumber, order.Guests, order.OrderedAt, tableId)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, fmt.Errorf("order for table %d already exists", tableNumber)
+	}
+
+	if order.ID, err = result.LastInsertId(); err != nil {
+		return nil, err
+	}
+
+	if err := joinOrder(tx, order.ID, userId, order.OrderedAt); err != nil {
+		return nil, err
+	}
+	if err := changeStatus(tx, TableEntity, tableId, string(TableOccupied), userId); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// CreateTakeawayOrder takes a takeaway or delivery order, which is not seated at a table. pickupAt
+// is when the order was promised to be ready, or nil for as soon as possible; it has to fall within
+// pickup hours in a slot that takes another order. Delivery orders carry the configured delivery fee.
+func CreateTakeawayOrder(userId int64, orderType OrderType, guests int, pickupAt *time.Time, deliveryAddress string) (*Order, error) {
+	if orderType != Takeaway && orderType != Delivery {
+		return nil, fmt.Errorf("invalid order type %s", orderType)
+	}
+
+	order := &Order{
+		CustomerID: userId,
+		Status:     Open,
+		Type:       orderType,
+		Guests:     guests,
+		PickupAt:   pickupAt,
+		OrderedAt:  time.Now(),
+	}
+	if orderType == Delivery {
+		order.DeliveryAddress = deliveryAddress
+		order.DeliveryFee = config.Config.Billing.DeliveryFee
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := insertTakeawayOrder(tx, order); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func insertTakeawayOrder(tx *sql.Tx, order *Order) error {
+	if order.PickupAt != nil {
+		if err := bookPickupSlot(tx, *order.PickupAt, order.OrderedAt); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec("INSERT INTO Orders (customer_id, status, type, guests, pickup_at, delivery_address, delivery_fee, ordered_at) VALUES (?, 'open', ?, ?, ?, ?, ?, ?)",
+		order.CustomerID, order.Type, order.Guests, order.PickupAt, sql.NullString{String: order.DeliveryAddress, Valid: order.Type == Delivery}, order.DeliveryFee, order.OrderedAt)
+	if err != nil {
+		return err
+	}
+	if order.ID, err = result.LastInsertId(); err != nil {
+		return err
+	}
+	return joinOrder(tx, order.ID, order.CustomerID, order.OrderedAt)
+}
+
+// TypeLabel names the type of orders not seated at a table on tickets and receipts, and is empty for
+// dine-in orders.
+func (o *Order) TypeLabel() string {
+	switch o.Type {
+	case Takeaway:
+		return "Takeaway"
+	case Delivery:
+		return "Delivery"
+	default:
+		return ""
+	}
 }
 
 func GetOrderById(id int64, userId int64) (*Order, error) {
 	var order Order
-	err := DB.QueryRow("SELECT id, customer_id, status, table_number, ordered_at FROM Orders WHERE id = ? AND (customer_id = ? or ? = 0) LIMIT 1;", id, userId, userId).Scan(
-		&order.ID, &order.CustomerID, &order.Status, &order.TableNumber, &order.OrderedAt)
+	err := scanOrder(DB.QueryRow("SELECT "+orderColumns+" FROM Orders WHERE id = ? AND "+orderAccess+" LIMIT 1;", id, userId, userId, userId), &order)
 	if err != nil {
 		return nil, err
 	}
 	return &order, nil
 }
 
-func EditOrderStatus(id int64, userId int64, status OrderStatus) error {
-	result, err := DB.Exec("UPDATE Orders SET status = ? WHERE id = ? AND (customer_id = ? OR ? = 0)", status, id, userId, userId)
+func EditOrderStatus(id int64, userId int64, status OrderStatus, actorId int64) error {
+	tx, err := DB.Begin()
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM Orders WHERE id = ? AND (customer_id = ? OR ? = 0)", id, userId, userId).Scan(&exists)
+	if err == nil {
+		err = changeStatus(tx, OrderEntity, id, string(status), actorId)
+	} else if strings.Contains(err.Error(), "no rows") {
+		err = fmt.Errorf("order not found")
+	}
+	if err == nil && status == Closed {
+		err = clearTable(tx, id, actorId)
+	}
+	if err == nil && status == Closed {
+		// a closed order cannot be joined, which frees its code for another order
+		_, err = tx.Exec("UPDATE Orders SET join_code = NULL WHERE id = ?", id)
+	}
 	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("%v %v", err1, err)
+		}
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("order not found")
-	}
-
-	return nil
+	return tx.Commit()
 }
 
-func GetOrders(userId int64, status OrderStatus, tableNumber int, date time.Time, limit int, offset int) ([]*Order, error) {
-	query := "SELECT id, customer_id, status, table_number, ordered_at FROM Orders WHERE 1=1"
+func GetOrders(userId int64, status OrderStatus, orderType OrderType, tableNumber int, date time.Time, limit int, offset int) ([]*Order, error) {
+	query := "SELECT " + orderColumns + " FROM Orders WHERE 1=1"
 	var args []any
 
 	if userId > 0 {
-		query += " AND customer_id = ?"
-		args = append(args, userId)
+		query += " AND " + orderAccess
+		args = append(args, userId, userId, userId)
 	}
 
 	if status != "" {
@@ -71,6 +208,11 @@ func GetOrders(userId int64, status OrderStatus, tableNumber int, date time.Time
 		args = append(args, status)
 	}
 
+	if orderType != "" {
+		query += " AND type = ?"
+		args = append(args, orderType)
+	}
+
 	if tableNumber > 0 {
 		query += " AND table_number = ?"
 		args = append(args, tableNumber)
@@ -93,7 +235,7 @@ func GetOrders(userId int64, status OrderStatus, tableNumber int, date time.Time
 	var orders []*Order
 	for rows.Next() {
 		var order Order
-		if err := rows.Scan(&order.ID, &order.CustomerID, &order.Status, &order.TableNumber, &order.OrderedAt); err != nil {
+		if err := scanOrder(rows, &order); err != nil {
 			return nil, err
 		}
 		orders = append(orders, &order)
@@ -101,3 +243,95 @@ func GetOrders(userId int64, status OrderStatus, tableNumber int, date time.Time
 
 	return orders, nil
 }
+
+// clearTable marks the tables of a closed order as needing cleaning before they seat anyone else:
+// the order's own table and any others its reservation pushed together with it. Tables that were
+// already freed, or that seated a new order since, are left alone.
+func clearTable(tx *sql.Tx, orderId int64, actorId int64) error {
+	rows, err := tx.Query(`SELECT t.id FROM DiningTables t
+		WHERE (t.id = (SELECT table_id FROM Orders WHERE id = ?)
+			OR t.id IN (SELECT rt.table_id FROM ReservationTables rt JOIN Reservations r ON r.id = rt.reservation_id WHERE r.order_id = ?))
+		AND t.status = 'occupied'
+		AND NOT EXISTS (SELECT 1 FROM Orders WHERE table_id = t.id AND status = 'open')`, orderId, orderId)
+	if err != nil {
+		return err
+	}
+
+	var tableIds []int64
+	for rows.Next() {
+		var tableId int64
+		if err := rows.Scan(&tableId); err != nil {
+			rows.Close()
+			return err
+		}
+		tableIds = append(tableIds, tableId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, tableId := range tableIds {
+		if err := changeStatus(tx, TableEntity, tableId, string(TableNeedsCleaning), actorId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetOpenTableOrder returns the order currently seated at a table.
+func GetOpenTableOrder(tableId int64) (*Order, error) {
+	var order Order
+	if err := scanOrder(DB.QueryRow("SELECT "+orderColumns+" FROM Orders WHERE table_id = ? AND status = 'open'", tableId), &order); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, err
+	}
+	return &order, nil
+}
+
+// RequestBill flags an open order so the floor staff bring the bill. Asking again keeps the time
+// of the first request.
+func RequestBill(orderId int64, now time.Time) error {
+	res, err := DB.Exec("UPDATE Orders SET bill_requested_at = COALESCE(bill_requested_at, ?) WHERE id = ? AND status = 'open'", now, orderId)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// asking again changes nothing, which is not the same as the order not being open
+		var open int
+		err := DB.QueryRow("SELECT 1 FROM Orders WHERE id = ? AND status = 'open'", orderId).Scan(&open)
+		if err != nil && strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("order not found")
+		}
+		return err
+	}
+	return nil
+}
+
+func scanOrder(row interface{ Scan(dest ...any) error }, order *Order) error {
+	var tableId, tableNumber sql.NullInt64
+	var pickupAt, billRequestedAt sql.NullTime
+	var deliveryAddress, joinCode sql.NullString
+	if err := row.Scan(&order.ID, &order.CustomerID, &order.Status, &order.Type, &tableId, &tableNumber, &order.Guests, &pickupAt, &deliveryAddress, &order.DeliveryFee,
+		&order.OrderedAt, &billRequestedAt, &joinCode); err != nil {
+		return err
+	}
+	order.TableID = tableId.Int64
+	order.TableNumber = int(tableNumber.Int64)
+	order.DeliveryAddress = deliveryAddress.String
+	order.JoinCode = joinCode.String
+	if pickupAt.Valid {
+		order.PickupAt = &pickupAt.Time
+	}
+	if billRequestedAt.Valid {
+		order.BillRequestedAt = &billRequestedAt.Time
+	}
+	return nil
+}
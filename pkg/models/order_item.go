@@ -3,10 +3,20 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/money"
+	"time"
 )
 
-func CreateOrderItem(orderId int64, userId int64, itemId int64, quantity int, customInstructions string) (*OrderItem, error) {
-	res, err := DB.Exec("INSERT INTO OrderItems (order_id, item_id, count, status, custom_instructions) SELECT ?, ?, ?, ?, ? FROM Orders WHERE id = ? AND customer_id = ? AND status = 'open'", orderId, itemId, quantity, ItemPending, customInstructions, orderId, userId)
+const orderItemColumns = "id, order_id, item_id, count, unit_price, custom_instructions, status, added_by, created_at"
+
+// CreateOrderItem adds an item to an open order the user takes part in, attributed to them. userId 0
+// adds it to the order whoever it belongs to, unattributed. Items of orders scheduled further ahead
+// than the release lead time are held out of the kitchen queue until then.
+func CreateOrderItem(orderId int64, userId int64, itemId int64, quantity int, unitPrice money.Money, customInstructions string) (*OrderItem, error) {
+	createdAt := time.Now()
+	res, err := DB.Exec("INSERT INTO OrderItems (order_id, item_id, count, unit_price, status, custom_instructions, added_by, created_at) SELECT ?, ?, ?, ?, IF(pickup_at > ?, ?, ?), ?, ?, ? FROM Orders WHERE id = ? AND status = 'open' AND "+orderAccess,
+		orderId, itemId, quantity, unitPrice, createdAt.Add(config.Config.Kitchen.ReleaseLeadTime), ItemScheduled, ItemPending, customInstructions, nullableId(userId), createdAt, orderId, userId, userId, userId)
 	if err != nil {
 		return nil, err
 	}
@@ -20,33 +30,31 @@ func CreateOrderItem(orderId int64, userId int64, itemId int64, quantity int, cu
 		return nil, fmt.Errorf("order not found")
 	}
 
+	var status ItemStatus
+	if err := DB.QueryRow("SELECT status FROM OrderItems WHERE id = ?", id).Scan(&status); err != nil {
+		return nil, err
+	}
+
 	return &OrderItem{
 		ID:                 id,
 		OrderID:            orderId,
 		ItemID:             itemId,
 		Quantity:           quantity,
+		UnitPrice:          unitPrice,
 		CustomInstructions: customInstructions,
-		Status:             ItemPending,
+		Status:             status,
+		AddedBy:            userId,
+		CreatedAt:          createdAt,
 	}, nil
 }
 
-func EditOrderItemStatus(orderItemId int64, status ItemStatus) error {
-	res, err := DB.Exec("UPDATE OrderItems SET status = ? WHERE id = ?", status, orderItemId)
-	if err != nil {
-		return fmt.Errorf("failed to update order item status: %w", err)
-	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("order item not found")
-	}
-	return nil
+// EditOrderItemStatus moves an order item along pending, preparing and completed.
+func EditOrderItemStatus(orderItemId int64, status ItemStatus, actorId int64) error {
+	return updateStatus(OrderItemEntity, orderItemId, string(status), actorId, nil)
 }
 
 func GetItemsByOrderId(orderId int64, userId int64) (*[]OrderItem, error) {
-	rows, err := DB.Query("SELECT id, order_id, item_id, count, custom_instructions, status FROM OrderItems WHERE order_id = ? AND EXISTS (SELECT 1 FROM Orders WHERE id = ? AND (customer_id = ? OR ? = 0))", orderId, orderId, userId, userId)
+	rows, err := DB.Query("SELECT "+orderItemColumns+" FROM OrderItems WHERE order_id = ? AND EXISTS (SELECT 1 FROM Orders WHERE id = ? AND "+orderAccess+")", orderId, orderId, userId, userId, userId)
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve order items: %w", err)
 	}
@@ -68,8 +76,13 @@ func GetItemsByOrderId(orderId int64, userId int64) (*[]OrderItem, error) {
 	return &items, nil
 }
 
+// GetOrderItems lists the order items in a status as the kitchen works through them: by when they
+// are due, which is when they were ordered or, for orders promised for a pickup time, the kitchen
+// prep time before it. Scheduled items only show up as pending once they are released.
 func GetOrderItems(status ItemStatus, limit int, offset int) ([]OrderItem, error) {
-	rows, err := DB.Query("SELECT id, order_id, item_id, count, custom_instructions, status FROM OrderItems WHERE status = ? LIMIT ? OFFSET ?", status, limit, offset)
+	rows, err := DB.Query("SELECT "+orderItemColumns+` FROM OrderItems WHERE status = ?
+		ORDER BY COALESCE((SELECT pickup_at FROM Orders WHERE Orders.id = OrderItems.order_id) - INTERVAL ? SECOND, created_at), id
+		LIMIT ? OFFSET ?`, status, int64(config.Config.Kitchen.PrepTime/time.Second), limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +105,10 @@ func GetOrderItems(status ItemStatus, limit int, offset int) ([]OrderItem, error
 }
 
 func scanOrderItem(rows *sql.Rows, item *OrderItem) error {
-	if err := rows.Scan(&item.ID, &item.OrderID, &item.ItemID, &item.Quantity, &item.CustomInstructions, &item.Status); err != nil {
+	var addedBy sql.NullInt64
+	if err := rows.Scan(&item.ID, &item.OrderID, &item.ItemID, &item.Quantity, &item.UnitPrice, &item.CustomInstructions, &item.Status, &addedBy, &item.CreatedAt); err != nil {
 		return fmt.Errorf("failed to scan order item: %w", err)
 	}
+	item.AddedBy = addedBy.Int64
 	return nil
 }
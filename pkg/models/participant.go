@@ -0,0 +1,132 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	joinCodeLength   = 6
+	joinCodeAttempts = 5
+)
+
+// GenerateJoinCode returns a short code friends at the table type in to join an order. It uses the
+// gift card alphabet so codes read out loud are not misheard.
+func GenerateJoinCode() (string, error) {
+	code, err := GenerateGiftCardCode()
+	if err != nil {
+		return "", err
+	}
+	return code[:joinCodeLength], nil
+}
+
+// GetOrderJoinCode returns the code to invite others to an open order the user takes part in,
+// giving the order one the first time it is asked for.
+func GetOrderJoinCode(orderId int64, userId int64) (string, error) {
+	for attempt := 0; attempt < joinCodeAttempts; attempt++ {
+		var status OrderStatus
+		var joinCode sql.NullString
+		err := DB.QueryRow("SELECT status, join_code FROM Orders WHERE id = ? AND "+orderAccess, orderId, userId, userId, userId).Scan(&status, &joinCode)
+		if err != nil {
+			if strings.Contains(err.Error(), "no rows") {
+				return "", fmt.Errorf("order not found")
+			}
+			return "", err
+		}
+		if status != Open {
+			return "", fmt.Errorf("order is closed")
+		}
+		if joinCode.Valid {
+			return joinCode.String, nil
+		}
+
+		code, err := GenerateJoinCode()
+		if err != nil {
+			return "", err
+		}
+		// a concurrent request may have set a code first, in which case the next round returns it
+		_, err = DB.Exec("UPDATE Orders SET join_code = ? WHERE id = ? AND status = 'open' AND join_code IS NULL", code, orderId)
+		if err != nil && !strings.Contains(err.Error(), "Duplicate") {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("failed to generate a unique join code")
+}
+
+// JoinOrderByCode adds the user to the open order with the join code.
+func JoinOrderByCode(code string, userId int64, now time.Time) (*Order, error) {
+	var orderId int64
+	if err := DB.QueryRow("SELECT id FROM Orders WHERE join_code = ? AND status = 'open'", strings.ToUpper(strings.TrimSpace(code))).Scan(&orderId); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, err
+	}
+
+	if err := JoinOrder(orderId, userId, now); err != nil {
+		return nil, err
+	}
+	return GetOrderById(orderId, userId)
+}
+
+// JoinOrder adds the user to an open order. Joining an order twice keeps the first join.
+func JoinOrder(orderId int64, userId int64, now time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	var status OrderStatus
+	err = tx.QueryRow("SELECT status FROM Orders WHERE id = ? FOR UPDATE", orderId).Scan(&status)
+	if err == nil && status != Open {
+		err = fmt.Errorf("order is closed")
+	} else if err == nil {
+		err = joinOrder(tx, orderId, userId, now)
+	} else if strings.Contains(err.Error(), "no rows") {
+		err = fmt.Errorf("order not found")
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("%v %v", err1, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func joinOrder(tx *sql.Tx, orderId int64, userId int64, now time.Time) error {
+	_, err := tx.Exec("INSERT INTO OrderParticipants (order_id, user_id, joined_at) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE joined_at = joined_at", orderId, userId, now)
+	return err
+}
+
+// GetOrderParticipants lists who takes part in an order in the order they joined, with the items
+// each of them added.
+func GetOrderParticipants(orderId int64) ([]OrderParticipant, error) {
+	rows, err := DB.Query(`SELECT p.user_id, u.name, p.user_id = o.customer_id, p.joined_at,
+			COALESCE(SUM(oi.count), 0), COALESCE(SUM(oi.unit_price * oi.count), 0)
+		FROM OrderParticipants p
+		JOIN Orders o ON o.id = p.order_id
+		JOIN Users u ON u.id = p.user_id
+		LEFT JOIN OrderItems oi ON oi.order_id = p.order_id AND oi.added_by = p.user_id
+		WHERE p.order_id = ?
+		GROUP BY p.user_id, u.name, o.customer_id, p.joined_at
+		ORDER BY p.joined_at, p.user_id`, orderId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []OrderParticipant
+	for rows.Next() {
+		var participant OrderParticipant
+		if err := rows.Scan(&participant.UserID, &participant.Name, &participant.Owner, &participant.JoinedAt, &participant.Items, &participant.Subtotal); err != nil {
+			return nil, fmt.Errorf("failed to scan order participant: %w", err)
+		}
+		participants = append(participants, participant)
+	}
+
+	return participants, rows.Err()
+}
@@ -1,45 +1,233 @@
 package models
 
 import (
+	"database/sql"
 	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
 	"strings"
+	"time"
 )
 
-func CreatePayment(orderId int64, subtotal float64, tip float64, cashierId int64, userId int64) (*Payment, error) {
-	res, err := DB.Exec("INSERT INTO Payments (order_id, user_id, order_subtotal, tip, status, cashier_id) VALUES (?, ?, ?, ?, ?, ?)", orderId, userId, subtotal, tip, Processing, cashierId)
+const paymentColumns = "id, order_id, order_subtotal, discount, service_charge, delivery_fee, tax, tax_included, tip, total, currency, status, cashier_id, promo_code_id, discount_reason, discounted_by, split_mode, split_parts, provider, provider_ref, decline_reason, (SELECT COALESCE(SUM(amount), 0) FROM Refunds WHERE payment_id = Payments.id AND status = 'completed'), created_at, method, drawer_session_id, gift_card_id, gift_card_amount, (SELECT COALESCE(SUM(gift_card_amount), 0) FROM Refunds WHERE payment_id = Payments.id AND status = 'completed' AND store_credit = FALSE), loyalty_points, loyalty_discount, loyalty_earned"
+
+// CreatePayment stores the payment together with the order item amounts it covers. The order row is
+// locked while the items are checked so that concurrent split payments cannot pay the same amount twice.
+// A gift card tender is debited from the card and redeemed loyalty points from the customer in the
+// same transaction.
+func CreatePayment(payment *Payment, userId int64) (*Payment, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var orderId int64
+	err = tx.QueryRow("SELECT id FROM Orders WHERE id = ? FOR UPDATE", payment.OrderID).Scan(&orderId)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	balances, err := orderItemBalances(tx, payment.OrderID)
 	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+	for _, item := range payment.Items {
+		if item.Amount > balances[item.OrderItemID] {
+			_ = tx.Rollback()
+			return nil, fmt.Errorf("order item %d is already paid", item.OrderItemID)
+		}
+	}
+
+	if payment.DeliveryFee > 0 {
+		var charged money.Money
+		err := tx.QueryRow("SELECT COALESCE(SUM(delivery_fee), 0) FROM Payments WHERE order_id = ? AND status IN ("+activePaymentStatuses+")", payment.OrderID).Scan(&charged)
+		if err == nil && charged > 0 {
+			err = fmt.Errorf("delivery fee was charged by another payment")
+		}
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	if payment.DrawerSessionID != 0 {
+		if err := lockOpenDrawerSession(tx, payment.DrawerSessionID); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	if payment.PromoCodeID != 0 {
+		if err := reservePromoCode(tx, payment.PromoCodeID, userId); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	createdAt := time.Now()
+	res, err := tx.Exec("INSERT INTO Payments (order_id, user_id, order_subtotal, discount, service_charge, delivery_fee, tax, tax_included, tip, currency, method, status, cashier_id, drawer_session_id, gift_card_id, gift_card_amount, loyalty_points, loyalty_discount, promo_code_id, discount_reason, discounted_by, split_mode, split_parts, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		payment.OrderID, userId, payment.Subtotal, payment.Discount, payment.ServiceCharge, payment.DeliveryFee, payment.Tax, payment.TaxIncluded, payment.Tip, payment.Currency, payment.Method, Processing, payment.CashierID, nullableId(payment.DrawerSessionID),
+		nullableId(payment.GiftCardID), payment.GiftCardAmount, payment.LoyaltyPoints, payment.LoyaltyDiscount,
+		nullableId(payment.PromoCodeID), sql.NullString{String: payment.DiscountReason, Valid: payment.DiscountReason != ""}, nullableId(payment.DiscountedBy),
+		payment.SplitMode, nullableLimit(payment.SplitParts), createdAt)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
 		return nil, err
 	}
 
 	id, err := res.LastInsertId()
 	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	for _, tax := range payment.Taxes {
+		_, err = tx.Exec("INSERT INTO PaymentTaxes (payment_id, tax_category_id, name, rate, inclusive, taxable, amount) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			id, tax.TaxCategoryID, tax.Name, tax.Rate, tax.Inclusive, tax.Taxable, tax.Amount)
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	for _, item := range payment.Items {
+		_, err = tx.Exec("INSERT INTO PaymentItems (payment_id, order_item_id, amount) VALUES (?, ?, ?)", id, item.OrderItemID, item.Amount)
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	if payment.GiftCardID != 0 && payment.GiftCardAmount > 0 {
+		err = postGiftCardTransaction(tx, &GiftCardTransaction{
+			GiftCardID: payment.GiftCardID,
+			Kind:       GiftCardRedeem,
+			Amount:     -payment.GiftCardAmount,
+			PaymentID:  id,
+		})
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	if payment.LoyaltyPoints > 0 {
+		err = postLoyaltyTransaction(tx, &LoyaltyTransaction{
+			UserID:    userId,
+			Kind:      LoyaltyRedeem,
+			Points:    -payment.LoyaltyPoints,
+			PaymentID: id,
+		})
+		if err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	return &Payment{
-		ID:        id,
-		OrderID:   orderId,
-		Subtotal:  subtotal,
-		Tip:       tip,
-		Total:     subtotal + tip,
-		Status:    Processing,
-		CashierID: cashierId,
-	}, nil
+	created := *payment
+	created.ID = id
+	created.Total = payment.Subtotal - payment.Discount + payment.ServiceCharge + payment.DeliveryFee + payment.Tax + payment.Tip
+	created.Status = Processing
+	created.CreatedAt = createdAt
+	return &created, nil
 }
 
 func GetPaymentByID(paymentId int64, userId int64) (*Payment, error) {
-	row := DB.QueryRow("SELECT id, order_id, order_subtotal, tip, status, cashier_id FROM Payments WHERE id = ? AND (user_id = ? OR ? = 0)", paymentId, userId, userId)
+	row := DB.QueryRow("SELECT "+paymentColumns+" FROM Payments WHERE id = ? AND (user_id = ? OR ? = 0)", paymentId, userId, userId)
 	payment := &Payment{}
-	err := row.Scan(&payment.ID, &payment.OrderID, &payment.Subtotal, &payment.Tip, &payment.Status, &payment.CashierID)
+	err := scanPayment(row, payment)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Taxes, err = GetPaymentTaxes(payment.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Items, err = GetPaymentItems(payment.ID)
 	if err != nil {
 		return nil, err
 	}
-	payment.Total = payment.Subtotal + payment.Tip
 	return payment, nil
 }
 
+func GetPaymentByProviderRef(provider string, reference string) (*Payment, error) {
+	row := DB.QueryRow("SELECT "+paymentColumns+" FROM Payments WHERE provider = ? AND provider_ref = ?", provider, reference)
+	payment := &Payment{}
+	if err := scanPayment(row, payment); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("payment not found")
+		}
+		return nil, err
+	}
+	return payment, nil
+}
+
+// GetUnconfirmedPayment finds a payment that was sent to the provider without getting a reference
+// back, because the provider could not be reached.
+func GetUnconfirmedPayment(provider string, paymentId int64) (*Payment, error) {
+	row := DB.QueryRow("SELECT "+paymentColumns+" FROM Payments WHERE id = ? AND provider = ? AND provider_ref IS NULL", paymentId, provider)
+	payment := &Payment{}
+	if err := scanPayment(row, payment); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("payment not found")
+		}
+		return nil, err
+	}
+	return payment, nil
+}
+
+func GetPaymentTaxes(paymentId int64) ([]PaymentTax, error) {
+	rows, err := DB.Query("SELECT tax_category_id, name, rate, inclusive, taxable, amount FROM PaymentTaxes WHERE payment_id = ? ORDER BY tax_category_id", paymentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var taxes []PaymentTax
+	for rows.Next() {
+		var tax PaymentTax
+		if err := rows.Scan(&tax.TaxCategoryID, &tax.Name, &tax.Rate, &tax.Inclusive, &tax.Taxable, &tax.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan payment tax: %w", err)
+		}
+		taxes = append(taxes, tax)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return taxes, nil
+}
+
 func GetPayments(userId int64, status PaymentStatus, limit int, offset int) ([]*Payment, error) {
-	query := "SELECT id, order_id, order_subtotal, tip, status, cashier_id FROM Payments WHERE 1=1"
+	query := "SELECT " + paymentColumns + " FROM Payments WHERE 1=1"
 	var args []any
 	if userId > 0 {
 		query += " AND user_id = ?"
@@ -63,11 +251,10 @@ func GetPayments(userId int64, status PaymentStatus, limit int, offset int) ([]*
 	var payments []*Payment
 	for rows.Next() {
 		payment := &Payment{}
-		err := rows.Scan(&payment.ID, &payment.OrderID, &payment.Subtotal, &payment.Tip, &payment.Status, &payment.CashierID)
+		err := scanPayment(rows, payment)
 		if err != nil {
 			return nil, err
 		}
-		payment.Total = payment.Subtotal + payment.Tip
 		payments = append(payments, payment)
 	}
 
@@ -78,21 +265,57 @@ func GetPayments(userId int64, status PaymentStatus, limit int, offset int) ([]*
 	return payments, nil
 }
 
-func UpdatePaymentStatus(paymentId int64, status PaymentStatus) error {
-	res, err := DB.Exec("UPDATE Payments SET status = ? WHERE id = ?", status, paymentId)
-	if err != nil {
-		return err
-	}
-	rowsAffected, err := res.RowsAffected()
-	if err != nil {
-		if strings.Contains(err.Error(), "no rows") {
-			return fmt.Errorf("payment not found")
+// UpdatePaymentStatus moves a payment to another status if its current status allows it. Declined
+// and voided payments give their gift card tender and redeemed loyalty points back.
+func UpdatePaymentStatus(paymentId int64, status PaymentStatus, actorId int64) error {
+	return updateStatus(PaymentEntity, paymentId, string(status), actorId, func(tx *sql.Tx) error {
+		if status == Declined || status == Voided {
+			return reversePaymentTenders(tx, paymentId, actorId)
+		}
+		return nil
+	})
+}
+
+// SetPaymentProviderResult records the processor's answer for a payment. The provider decides the
+// outcome, so the transition is recorded without an actor.
+func SetPaymentProviderResult(paymentId int64, status PaymentStatus, provider string, reference string, reason string) error {
+	return updateStatus(PaymentEntity, paymentId, string(status), 0, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Payments SET provider = ?, provider_ref = ?, decline_reason = ? WHERE id = ?", sql.NullString{String: provider, Valid: provider != ""},
+			sql.NullString{String: reference, Valid: reference != ""}, sql.NullString{String: reason, Valid: reason != ""}, paymentId)
+		if err == nil && status == Declined {
+			err = reversePaymentTenders(tx, paymentId, 0)
 		}
 		return err
+	})
+}
+
+// reversePaymentTenders gives back the gift card tender and loyalty points a payment that did not go
+// through was going to use.
+func reversePaymentTenders(tx *sql.Tx, paymentId int64, actorId int64) error {
+	if err := reverseGiftCardRedemption(tx, paymentId, actorId); err != nil {
+		return err
 	}
-	if rowsAffected == 0 {
-		return fmt.Errorf("payment not found")
-	}
+	return reverseLoyaltyRedemption(tx, paymentId, actorId)
+}
 
+func scanPayment(row interface{ Scan(dest ...any) error }, payment *Payment) error {
+	var promoCodeId, discountedBy, splitParts, drawerSessionId, giftCardId, loyaltyEarned sql.NullInt64
+	var discountReason, provider, providerRef, declineReason sql.NullString
+	if err := row.Scan(&payment.ID, &payment.OrderID, &payment.Subtotal, &payment.Discount, &payment.ServiceCharge, &payment.DeliveryFee, &payment.Tax, &payment.TaxIncluded, &payment.Tip, &payment.Total, &payment.Currency,
+		&payment.Status, &payment.CashierID, &promoCodeId, &discountReason, &discountedBy, &payment.SplitMode, &splitParts,
+		&provider, &providerRef, &declineReason, &payment.Refunded, &payment.CreatedAt, &payment.Method, &drawerSessionId,
+		&giftCardId, &payment.GiftCardAmount, &payment.GiftCardRefunded, &payment.LoyaltyPoints, &payment.LoyaltyDiscount, &loyaltyEarned); err != nil {
+		return err
+	}
+	payment.PromoCodeID = promoCodeId.Int64
+	payment.DiscountReason = discountReason.String
+	payment.DiscountedBy = discountedBy.Int64
+	payment.SplitParts = int(splitParts.Int64)
+	payment.Provider = provider.String
+	payment.ProviderRef = providerRef.String
+	payment.DeclineReason = declineReason.String
+	payment.DrawerSessionID = drawerSessionId.Int64
+	payment.GiftCardID = giftCardId.Int64
+	payment.LoyaltyEarned = loyaltyEarned.Int64
 	return nil
 }
@@ -0,0 +1,350 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+	"strings"
+	"time"
+)
+
+func CreatePriceSchedule(itemId int64, price money.Money, effectiveAt time.Time, createdBy int64) (*PriceSchedule, error) {
+	res, err := DB.Exec("INSERT INTO PriceSchedules (item_id, price, effective_at, created_by) SELECT ?, ?, ?, ? FROM Items WHERE id = ?", itemId, price, effectiveAt, createdBy, itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	if id == 0 {
+		return nil, fmt.Errorf("item with id '%d' not found", itemId)
+	}
+
+	return &PriceSchedule{
+		ID:          id,
+		ItemID:      itemId,
+		Price:       price,
+		EffectiveAt: effectiveAt,
+		CreatedBy:   createdBy,
+	}, nil
+}
+
+func GetPriceSchedules(itemId int64) ([]PriceSchedule, error) {
+	rows, err := DB.Query("SELECT id, item_id, price, effective_at, applied, created_by FROM PriceSchedules WHERE item_id = ? ORDER BY effective_at DESC", itemId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []PriceSchedule
+	for rows.Next() {
+		var schedule PriceSchedule
+		if err := rows.Scan(&schedule.ID, &schedule.ItemID, &schedule.Price, &schedule.EffectiveAt, &schedule.Applied, &schedule.CreatedBy); err != nil {
+			return nil, fmt.Errorf("failed to scan price schedule: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return schedules, nil
+}
+
+// ApplyDuePriceSchedules copies the latest due schedule of every item into Items.price
+// and marks all due schedules as applied. It returns the number of items updated.
+func ApplyDuePriceSchedules(at time.Time) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE Items
+		JOIN PriceSchedules ps ON ps.item_id = Items.id
+		SET Items.price = ps.price
+		WHERE ps.applied = false AND ps.effective_at <= ?
+		  AND ps.id = (SELECT latest.id FROM PriceSchedules latest
+		      WHERE latest.item_id = ps.item_id AND latest.applied = false AND latest.effective_at <= ?
+		      ORDER BY latest.effective_at DESC, latest.id DESC LIMIT 1)`, at, at)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, fmt.Errorf("%v %v", err1, err)
+		}
+		return 0, err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.Exec("UPDATE PriceSchedules SET applied = true WHERE applied = false AND effective_at <= ?", at)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return 0, fmt.Errorf("%v %v", err1, err)
+		}
+		return 0, err
+	}
+
+	return updated, tx.Commit()
+}
+
+func CreateDiscount(discount *Discount) (*Discount, error) {
+	res, err := DB.Exec("INSERT INTO Discounts (name, percent, item_id, tag_id, days, start_time, end_time, starts_at, ends_at, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		discount.Name, discount.Percent, nullableId(discount.ItemID), nullableId(discount.TagID), weekdayMask(discount.Days),
+		discount.StartTime, discount.EndTime, discount.StartsAt, discount.EndsAt, discount.Active)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	created := *discount
+	created.ID = id
+	return &created, nil
+}
+
+func EditDiscount(discount *Discount) error {
+	res, err := DB.Exec("UPDATE Discounts SET name = ?, percent = ?, item_id = ?, tag_id = ?, days = ?, start_time = ?, end_time = ?, starts_at = ?, ends_at = ?, active = ? WHERE id = ?",
+		discount.Name, discount.Percent, nullableId(discount.ItemID), nullableId(discount.TagID), weekdayMask(discount.Days),
+		discount.StartTime, discount.EndTime, discount.StartsAt, discount.EndsAt, discount.Active, discount.ID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		if exists, err := rowExists(DB, "Discounts", discount.ID); err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("discount not found")
+		}
+	}
+	return nil
+}
+
+func GetDiscounts(activeOnly bool) ([]Discount, error) {
+	query := "SELECT id, name, percent, item_id, tag_id, days, start_time, end_time, starts_at, ends_at, active FROM Discounts"
+	if activeOnly {
+		query += " WHERE active = true"
+	}
+	query += " ORDER BY id"
+
+	rows, err := DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var discounts []Discount
+	for rows.Next() {
+		var discount Discount
+		if err := scanDiscount(rows, &discount); err != nil {
+			return nil, err
+		}
+		discounts = append(discounts, discount)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return discounts, nil
+}
+
+// GetItemPrices resolves the list price (including schedules that are due but not yet
+// applied) and the discounted effective price of every item at the given time.
+func GetItemPrices(items []Item, at time.Time) (map[int64]ItemPrice, error) {
+	prices := make(map[int64]ItemPrice, len(items))
+	if len(items) == 0 {
+		return prices, nil
+	}
+
+	query := `SELECT ps.item_id, ps.price FROM PriceSchedules ps
+				WHERE ps.applied = false AND ps.effective_at <= ? AND ps.item_id IN (`
+	args := []any{at}
+	for _, item := range items {
+		query += "?,"
+		args = append(args, item.ID)
+	}
+	query = query[:len(query)-1] + ") ORDER BY ps.effective_at, ps.id"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheduled := make(map[int64]money.Money)
+	for rows.Next() {
+		var itemId int64
+		var price money.Money
+		if err := rows.Scan(&itemId, &price); err != nil {
+			return nil, fmt.Errorf("failed to scan price schedule: %w", err)
+		}
+		scheduled[itemId] = price
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	discounts, err := GetDiscounts(true)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		listPrice, ok := scheduled[items[i].ID]
+		if !ok {
+			listPrice = items[i].Price
+		}
+		prices[items[i].ID] = ResolveItemPrice(&items[i], listPrice, discounts, at)
+	}
+
+	return prices, nil
+}
+
+// ResolveItemPrice applies the largest discount active at the given time; discounts do not stack.
+// When several discounts are equally large the oldest one is reported.
+func ResolveItemPrice(item *Item, listPrice money.Money, discounts []Discount, at time.Time) ItemPrice {
+	price := ItemPrice{ListPrice: listPrice, EffectivePrice: listPrice}
+	best := money.Percent(0)
+	for i := range discounts {
+		if !discounts[i].AppliesTo(item) || !discounts[i].ActiveAt(at) {
+			continue
+		}
+		if discounts[i].Percent > best || (discounts[i].Percent == best && best > 0 && discounts[i].ID < price.DiscountID) {
+			best = discounts[i].Percent
+			price.DiscountID = discounts[i].ID
+		}
+	}
+	if best > 0 {
+		price.EffectivePrice = listPrice - listPrice.Percent(best)
+	}
+	return price
+}
+
+func (d *Discount) AppliesTo(item *Item) bool {
+	if d.ItemID != 0 && d.ItemID != item.ID {
+		return false
+	}
+	if d.TagID != 0 {
+		for _, tag := range item.Tags {
+			if tag.ID == d.TagID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ActiveAt reports whether the discount window covers the given time. Day and time-of-day
+// checks use the server's local time; a window whose end is before its start wraps past midnight.
+func (d *Discount) ActiveAt(at time.Time) bool {
+	if !d.Active {
+		return false
+	}
+	if d.StartsAt != nil && at.Before(*d.StartsAt) {
+		return false
+	}
+	if d.EndsAt != nil && !at.Before(*d.EndsAt) {
+		return false
+	}
+
+	local := at.In(time.Local)
+	start, _ := parseClock(d.StartTime)
+	end, _ := parseClock(d.EndTime)
+	minute := local.Hour()*60 + local.Minute()
+	day := local.Weekday()
+
+	if start != end {
+		switch {
+		case start < end:
+			if minute < start || minute >= end {
+				return false
+			}
+		case minute < end:
+			day = (day + 6) % 7
+		case minute < start:
+			return false
+		}
+	}
+
+	mask := weekdayMask(d.Days)
+	return mask == 0 || mask&(1<<day) != 0
+}
+
+func ParseClock(value string) (string, error) {
+	minutes, err := parseClock(value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60), nil
+}
+
+func parseClock(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	layout := "15:04"
+	if strings.Count(value, ":") == 2 {
+		layout = "15:04:05"
+	}
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day '%s'", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func weekdayMask(days []time.Weekday) int {
+	mask := 0
+	for _, day := range days {
+		mask |= 1 << day
+	}
+	return mask
+}
+
+func nullableId(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func scanDiscount(rows *sql.Rows, discount *Discount) error {
+	var itemId, tagId sql.NullInt64
+	var startsAt, endsAt sql.NullTime
+	var days int
+	if err := rows.Scan(&discount.ID, &discount.Name, &discount.Percent, &itemId, &tagId, &days,
+		&discount.StartTime, &discount.EndTime, &startsAt, &endsAt, &discount.Active); err != nil {
+		return fmt.Errorf("failed to scan discount: %w", err)
+	}
+
+	discount.ItemID = itemId.Int64
+	discount.TagID = tagId.Int64
+	if startsAt.Valid {
+		discount.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		discount.EndsAt = &endsAt.Time
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if days&(1<<day) != 0 {
+			discount.Days = append(discount.Days, day)
+		}
+	}
+	discount.StartTime, _ = ParseClock(discount.StartTime)
+	discount.EndTime, _ = ParseClock(discount.EndTime)
+	return nil
+}
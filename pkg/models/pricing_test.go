@@ -0,0 +1,71 @@
+package models
+
+import (
+	"github.com/gqvz/mvc/pkg/money"
+	"testing"
+	"time"
+)
+
+func TestDiscountActiveAtOvernight(t *testing.T) {
+	// 12 September 2025 is a Friday
+	friday := time.Date(2025, 9, 12, 0, 0, 0, 0, time.Local)
+	discount := &Discount{Active: true, Days: []time.Weekday{time.Friday}, StartTime: "22:00", EndTime: "02:00"}
+
+	tests := []struct {
+		at       time.Time
+		expected bool
+	}{
+		{friday.Add(21*time.Hour + 59*time.Minute), false},
+		{friday.Add(22 * time.Hour), true},
+		{friday.Add(23*time.Hour + 30*time.Minute), true},
+		{friday.Add(24 * time.Hour), true},
+		{friday.Add(25*time.Hour + 59*time.Minute), true},
+		{friday.Add(26 * time.Hour), false},
+		{friday.Add(1 * time.Hour), false},
+		{friday.Add(47 * time.Hour), false},
+	}
+	for _, test := range tests {
+		if active := discount.ActiveAt(test.at); active != test.expected {
+			t.Errorf("ActiveAt(%s) = %v, want %v", test.at.Format("Mon 15:04"), active, test.expected)
+		}
+	}
+
+	thursday := &Discount{Active: true, Days: []time.Weekday{time.Thursday}, StartTime: "22:00", EndTime: "02:00"}
+	if !thursday.ActiveAt(friday.Add(1 * time.Hour)) {
+		t.Error("Expected Friday 01:00 to fall in Thursday's window")
+	}
+}
+
+func TestResolveItemPrice(t *testing.T) {
+	friday := time.Date(2025, 9, 12, 23, 0, 0, 0, time.Local)
+	item := &Item{ID: 1, Tags: []Tag{{ID: 5}}}
+
+	tests := []struct {
+		name       string
+		discounts  []Discount
+		effective  money.Money
+		discountId int64
+	}{
+		{"no discount", nil, 1000, 0},
+		{"largest discount wins", []Discount{
+			{ID: 1, Active: true, Percent: 10_000},
+			{ID: 2, Active: true, Percent: 25_000, TagID: 5},
+			{ID: 3, Active: true, Percent: 50_000, ItemID: 2},
+		}, 750, 2},
+		{"equal discounts go to the oldest", []Discount{
+			{ID: 4, Active: true, Percent: 20_000, StartTime: "22:00", EndTime: "02:00"},
+			{ID: 3, Active: true, Percent: 20_000, ItemID: 1},
+			{ID: 6, Active: true, Percent: 20_000},
+		}, 800, 3},
+		{"inactive window is skipped", []Discount{
+			{ID: 1, Active: true, Percent: 10_000},
+			{ID: 2, Active: true, Percent: 30_000, StartTime: "11:00", EndTime: "14:00"},
+		}, 900, 1},
+	}
+	for _, test := range tests {
+		price := ResolveItemPrice(item, 1000, test.discounts, friday)
+		if price.ListPrice != 1000 || price.EffectivePrice != test.effective || price.DiscountID != test.discountId {
+			t.Errorf("%s: expected %s with discount %d, got %+v", test.name, test.effective, test.discountId, price)
+		}
+	}
+}
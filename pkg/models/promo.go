@@ -0,0 +1,205 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+	"strings"
+	"time"
+)
+
+// declined and voided payments give their promo code use back
+const promoUseStatuses = "status NOT IN ('declined', 'voided')"
+
+const promoCodeColumns = "id, code, kind, value, min_spend, starts_at, ends_at, max_uses, max_uses_per_user, item_id, tag_id, active, (SELECT COUNT(*) FROM Payments WHERE promo_code_id = PromoCodes.id AND " + promoUseStatuses + ")"
+
+func CreatePromoCode(promo *PromoCode) (*PromoCode, error) {
+	res, err := DB.Exec("INSERT INTO PromoCodes (code, kind, value, min_spend, starts_at, ends_at, max_uses, max_uses_per_user, item_id, tag_id, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		promo.Code, promo.Kind, promo.value(), promo.MinSpend, promo.StartsAt, promo.EndsAt, nullableLimit(promo.MaxUses),
+		nullableLimit(promo.MaxUsesPerUser), nullableId(promo.ItemID), nullableId(promo.TagID), promo.Active)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate") {
+			return nil, fmt.Errorf("promo code '%s' already exists", promo.Code)
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	created := *promo
+	created.ID = id
+	return &created, nil
+}
+
+func EditPromoCode(promo *PromoCode) error {
+	res, err := DB.Exec("UPDATE PromoCodes SET code = ?, kind = ?, value = ?, min_spend = ?, starts_at = ?, ends_at = ?, max_uses = ?, max_uses_per_user = ?, item_id = ?, tag_id = ?, active = ? WHERE id = ?",
+		promo.Code, promo.Kind, promo.value(), promo.MinSpend, promo.StartsAt, promo.EndsAt, nullableLimit(promo.MaxUses),
+		nullableLimit(promo.MaxUsesPerUser), nullableId(promo.ItemID), nullableId(promo.TagID), promo.Active, promo.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate") {
+			return fmt.Errorf("promo code '%s' already exists", promo.Code)
+		}
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		if exists, err := rowExists(DB, "PromoCodes", promo.ID); err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("promo code not found")
+		}
+	}
+	return nil
+}
+
+func GetPromoCodeByCode(code string) (*PromoCode, error) {
+	rows, err := DB.Query("SELECT "+promoCodeColumns+" FROM PromoCodes WHERE code = ? LIMIT 1", code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promo PromoCode
+	if rows.Next() {
+		if err := scanPromoCode(rows, &promo); err != nil {
+			return nil, err
+		}
+		return &promo, nil
+	}
+	return nil, fmt.Errorf("promo code '%s' not found", code)
+}
+
+func GetPromoCodes(limit int, offset int) ([]PromoCode, error) {
+	rows, err := DB.Query("SELECT "+promoCodeColumns+" FROM PromoCodes ORDER BY id DESC LIMIT ? OFFSET ?", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var promos []PromoCode
+	for rows.Next() {
+		var promo PromoCode
+		if err := scanPromoCode(rows, &promo); err != nil {
+			return nil, err
+		}
+		promos = append(promos, promo)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return promos, nil
+}
+
+func (p *PromoCode) ValidAt(at time.Time) bool {
+	if !p.Active {
+		return false
+	}
+	if p.StartsAt != nil && at.Before(*p.StartsAt) {
+		return false
+	}
+	if p.EndsAt != nil && !at.Before(*p.EndsAt) {
+		return false
+	}
+	return true
+}
+
+func (p *PromoCode) AppliesTo(item *Item) bool {
+	if p.ItemID != 0 && p.ItemID != item.ID {
+		return false
+	}
+	if p.TagID != 0 {
+		for _, tag := range item.Tags {
+			if tag.ID == p.TagID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (p *PromoCode) Scoped() bool {
+	return p.ItemID != 0 || p.TagID != 0
+}
+
+// DiscountFor returns the discount the promo code grants on the eligible part of the subtotal.
+func (p *PromoCode) DiscountFor(eligible money.Money) money.Money {
+	discount := p.Amount
+	if p.Kind == PercentagePromo {
+		discount = eligible.Percent(p.Percent)
+	}
+	return money.Min(discount, eligible)
+}
+
+// value is what is stored in the value column: the percentage or the fixed amount, depending on the kind.
+func (p *PromoCode) value() any {
+	if p.Kind == PercentagePromo {
+		return p.Percent
+	}
+	return p.Amount
+}
+
+// reservePromoCode locks the promo code row so concurrent payments cannot exceed its usage limits.
+func reservePromoCode(tx *sql.Tx, promoCodeId int64, userId int64) error {
+	var maxUses, maxUsesPerUser sql.NullInt64
+	err := tx.QueryRow("SELECT max_uses, max_uses_per_user FROM PromoCodes WHERE id = ? FOR UPDATE", promoCodeId).Scan(&maxUses, &maxUsesPerUser)
+	if err != nil {
+		return err
+	}
+
+	var uses, userUses int64
+	err = tx.QueryRow("SELECT COUNT(*), COALESCE(SUM(user_id = ?), 0) FROM Payments WHERE promo_code_id = ? AND "+promoUseStatuses, userId, promoCodeId).Scan(&uses, &userUses)
+	if err != nil {
+		return err
+	}
+
+	if (maxUses.Valid && uses >= maxUses.Int64) || (maxUsesPerUser.Valid && userUses >= maxUsesPerUser.Int64) {
+		return fmt.Errorf("promo code usage limit reached")
+	}
+	return nil
+}
+
+func nullableLimit(limit int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(limit), Valid: limit > 0}
+}
+
+func scanPromoCode(rows *sql.Rows, promo *PromoCode) error {
+	var startsAt, endsAt sql.NullTime
+	var maxUses, maxUsesPerUser, itemId, tagId sql.NullInt64
+	var value []byte
+	if err := rows.Scan(&promo.ID, &promo.Code, &promo.Kind, &value, &promo.MinSpend, &startsAt, &endsAt,
+		&maxUses, &maxUsesPerUser, &itemId, &tagId, &promo.Active, &promo.Uses); err != nil {
+		return fmt.Errorf("failed to scan promo code: %w", err)
+	}
+
+	var err error
+	if promo.Kind == PercentagePromo {
+		err = promo.Percent.Scan(value)
+	} else {
+		err = promo.Amount.Scan(value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to scan promo code value: %w", err)
+	}
+
+	if startsAt.Valid {
+		promo.StartsAt = &startsAt.Time
+	}
+	if endsAt.Valid {
+		promo.EndsAt = &endsAt.Time
+	}
+	promo.MaxUses = int(maxUses.Int64)
+	promo.MaxUsesPerUser = int(maxUsesPerUser.Int64)
+	promo.ItemID = itemId.Int64
+	promo.TagID = tagId.Int64
+	return nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IssueReceipt gives a payment the next receipt number the first time its receipt is requested
+// and returns the same number every time after that. Numbers are taken as MAX + 1 rather than
+// from AUTO_INCREMENT so failed inserts do not leave gaps in the sequence.
+func IssueReceipt(paymentId int64, issuedAt time.Time) (*Receipt, error) {
+	receipt, err := GetReceiptByPaymentId(paymentId)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		return receipt, err
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		_, err = DB.Exec("INSERT INTO Receipts (number, payment_id, issued_at) SELECT COALESCE(MAX(number), 0) + 1, ?, ? FROM Receipts", paymentId, issuedAt)
+		if err == nil || !strings.Contains(err.Error(), "Duplicate") {
+			break
+		}
+		// another receipt took the number, or another request issued this payment's receipt
+	}
+	if err != nil && !strings.Contains(err.Error(), "Duplicate") {
+		return nil, err
+	}
+
+	return GetReceiptByPaymentId(paymentId)
+}
+
+func GetReceiptByPaymentId(paymentId int64) (*Receipt, error) {
+	var receipt Receipt
+	err := DB.QueryRow("SELECT number, payment_id, issued_at FROM Receipts WHERE payment_id = ?", paymentId).Scan(
+		&receipt.Number, &receipt.PaymentID, &receipt.IssuedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("receipt not found")
+		}
+		return nil, err
+	}
+	return &receipt, nil
+}
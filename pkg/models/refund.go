@@ -0,0 +1,274 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+	"strings"
+	"time"
+)
+
+// CreateRefund records a refund that needs nothing from the payment provider and moves the payment
+// to refunded or partially_refunded. The payment row is locked so concurrent refunds cannot exceed
+// what was paid. Store credit refunds are loaded onto a new store credit card for the customer;
+// other refunds put the payment's gift card tender back on its card first, and refund.GiftCardAmount
+// must match that share. The loyalty points the payment earned are taken back in proportion to what
+// has been refunded.
+func CreateRefund(refund *Refund) (*Refund, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = reserveRefund(tx, refund)
+	if err == nil {
+		err = completeRefund(tx, refund)
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	created := *refund
+	return &created, nil
+}
+
+// ReserveRefund records a pending refund before the payment provider is asked for it. A pending
+// refund counts against what is left to refund, so a concurrent refund of the same money is
+// rejected here instead of being issued twice. It is finished with CompleteRefund or VoidRefund.
+func ReserveRefund(refund *Refund) (*Refund, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reserveRefund(tx, refund); err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	reserved := *refund
+	return &reserved, nil
+}
+
+// CompleteRefund finishes a pending refund the payment provider issued under providerRef, as
+// CreateRefund does.
+func CompleteRefund(id int64, providerRef string) (*Refund, error) {
+	var paymentId int64
+	if err := DB.QueryRow("SELECT payment_id FROM Refunds WHERE id = ?", id).Scan(&paymentId); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("refund not found")
+		}
+		return nil, err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	// the payment is locked before the refund, in the same order as reserveRefund
+	var refund *Refund
+	err = tx.QueryRow("SELECT id FROM Payments WHERE id = ? FOR UPDATE", paymentId).Scan(&paymentId)
+	if err == nil {
+		refund, err = scanRefund(tx.QueryRow("SELECT "+refundColumns+" FROM Refunds WHERE id = ? FOR UPDATE", id))
+	}
+	if err == nil && refund.Status != RefundPending {
+		err = fmt.Errorf("refund %d is %s, not pending", id, refund.Status)
+	}
+	if err == nil {
+		refund.ProviderRef = providerRef
+		err = completeRefund(tx, refund)
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return refund, nil
+}
+
+// VoidRefund releases a pending refund the payment provider did not issue.
+func VoidRefund(id int64) error {
+	res, err := DB.Exec("UPDATE Refunds SET status = ? WHERE id = ? AND status = ?", RefundVoided, id, RefundPending)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return fmt.Errorf("refund %d is not pending", id)
+	}
+	return nil
+}
+
+// reserveRefund locks the payment, checks the refund against what is left to refund, including
+// other pending refunds, and records it as pending.
+func reserveRefund(tx *sql.Tx, refund *Refund) error {
+	var total, giftCardAmount money.Money
+	var status PaymentStatus
+	var giftCardId sql.NullInt64
+	err := tx.QueryRow("SELECT total, status, gift_card_id, gift_card_amount FROM Payments WHERE id = ? FOR UPDATE", refund.PaymentID).Scan(
+		&total, &status, &giftCardId, &giftCardAmount)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("payment not found")
+		}
+		return err
+	}
+
+	if status != Accepted && status != PartiallyRefunded {
+		return fmt.Errorf("payment is %s and cannot be refunded", status)
+	}
+
+	var refunded, giftCardReturned money.Money
+	err = tx.QueryRow("SELECT COALESCE(SUM(amount), 0), COALESCE(SUM(IF(store_credit, 0, gift_card_amount)), 0) FROM Refunds WHERE payment_id = ? AND status <> ?",
+		refund.PaymentID, RefundVoided).Scan(&refunded, &giftCardReturned)
+	if err != nil {
+		return err
+	}
+
+	if refund.Amount > total-refunded {
+		return fmt.Errorf("refund exceeds the refundable amount of %s", total-refunded)
+	}
+
+	if refund.DrawerSessionID != 0 {
+		if err := lockOpenDrawerSession(tx, refund.DrawerSessionID); err != nil {
+			return err
+		}
+	}
+
+	// store credit goes onto a card that is only issued once the refund completes
+	refund.GiftCardID = 0
+	if refund.StoreCredit {
+		refund.GiftCardAmount = refund.Amount
+	} else {
+		share := money.Min(refund.Amount, max(giftCardAmount-giftCardReturned, 0))
+		if refund.GiftCardAmount != share {
+			return fmt.Errorf("gift card share of the refund changed to %s", share)
+		}
+		if share > 0 {
+			refund.GiftCardID = giftCardId.Int64
+		}
+	}
+
+	refund.Status = RefundPending
+	refund.CreatedAt = time.Now()
+	res, err := tx.Exec("INSERT INTO Refunds (payment_id, amount, status, reason, provider_ref, created_by, drawer_session_id, store_credit, gift_card_id, gift_card_amount, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		refund.PaymentID, refund.Amount, refund.Status, refund.Reason, sql.NullString{String: refund.ProviderRef, Valid: refund.ProviderRef != ""}, refund.CreatedBy, nullableId(refund.DrawerSessionID),
+		refund.StoreCredit, nullableId(refund.GiftCardID), refund.GiftCardAmount, refund.CreatedAt)
+	if err != nil {
+		return err
+	}
+	refund.ID, err = res.LastInsertId()
+	return err
+}
+
+// completeRefund moves the money of a pending refund, with the payment already locked: it issues
+// store credit or puts the gift card share back, takes back loyalty points and moves the payment to
+// refunded or partially_refunded.
+func completeRefund(tx *sql.Tx, refund *Refund) error {
+	var total money.Money
+	var customerId int64
+	var currency string
+	if err := tx.QueryRow("SELECT total, user_id, currency FROM Payments WHERE id = ? FOR UPDATE", refund.PaymentID).Scan(&total, &customerId, &currency); err != nil {
+		return err
+	}
+
+	var refunded money.Money
+	if err := tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM Refunds WHERE payment_id = ? AND status = ?", refund.PaymentID, RefundCompleted).Scan(&refunded); err != nil {
+		return err
+	}
+
+	if refund.StoreCredit {
+		card, err := createGiftCard(tx, &GiftCard{Kind: StoreCredit, Currency: currency, CustomerID: customerId, IssuedBy: refund.CreatedBy})
+		if err != nil {
+			return err
+		}
+		refund.GiftCardID = card.ID
+	}
+
+	refund.Status = RefundCompleted
+	if _, err := tx.Exec("UPDATE Refunds SET status = ?, provider_ref = ?, gift_card_id = ? WHERE id = ?",
+		refund.Status, sql.NullString{String: refund.ProviderRef, Valid: refund.ProviderRef != ""}, nullableId(refund.GiftCardID), refund.ID); err != nil {
+		return err
+	}
+
+	if refund.GiftCardAmount > 0 {
+		err := postGiftCardTransaction(tx, &GiftCardTransaction{
+			GiftCardID: refund.GiftCardID,
+			Kind:       GiftCardRefund,
+			Amount:     refund.GiftCardAmount,
+			PaymentID:  refund.PaymentID,
+			RefundID:   refund.ID,
+			CreatedBy:  refund.CreatedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := clawBackLoyaltyPoints(tx, refund.PaymentID, refund.ID, refunded+refund.Amount, total, refund.CreatedBy); err != nil {
+		return err
+	}
+
+	status := PartiallyRefunded
+	if refunded+refund.Amount == total {
+		status = Refunded
+	}
+	return changeStatus(tx, PaymentEntity, refund.PaymentID, string(status), refund.CreatedBy)
+}
+
+const refundColumns = "id, payment_id, amount, status, reason, provider_ref, created_by, drawer_session_id, store_credit, gift_card_id, gift_card_amount, created_at"
+
+func GetRefunds(paymentId int64) ([]Refund, error) {
+	rows, err := DB.Query("SELECT "+refundColumns+" FROM Refunds WHERE payment_id = ? ORDER BY id", paymentId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refunds []Refund
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan refund: %w", err)
+		}
+		refunds = append(refunds, *refund)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return refunds, nil
+}
+
+func scanRefund(row interface{ Scan(dest ...any) error }) (*Refund, error) {
+	var refund Refund
+	var providerRef sql.NullString
+	var drawerSessionId, giftCardId sql.NullInt64
+	if err := row.Scan(&refund.ID, &refund.PaymentID, &refund.Amount, &refund.Status, &refund.Reason, &providerRef, &refund.CreatedBy, &drawerSessionId,
+		&refund.StoreCredit, &giftCardId, &refund.GiftCardAmount, &refund.CreatedAt); err != nil {
+		return nil, err
+	}
+	refund.ProviderRef = providerRef.String
+	refund.DrawerSessionID = drawerSessionId.Int64
+	refund.GiftCardID = giftCardId.Int64
+	return &refund, nil
+}
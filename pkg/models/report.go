@@ -0,0 +1,83 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// GetRevenueReport sums collected payments per day between from and to (inclusive dates) and nets
+// out refunds on the day they were issued, so a refund lowers the day it happened rather than
+// rewriting the day of the original sale.
+func GetRevenueReport(from time.Time, to time.Time) (*RevenueReport, error) {
+	end := to.AddDate(0, 0, 1)
+	days := make(map[string]*RevenueDay)
+	day := func(date string) *RevenueDay {
+		if _, ok := days[date]; !ok {
+			days[date] = &RevenueDay{Date: date}
+		}
+		return days[date]
+	}
+
+	rows, err := DB.Query(`SELECT DATE_FORMAT(created_at, '%Y-%m-%d'), COUNT(*), COALESCE(SUM(total), 0), COALESCE(SUM(tip), 0), COALESCE(SUM(tax), 0)
+		FROM Payments WHERE status IN ('accepted', 'partially_refunded', 'refunded') AND created_at >= ? AND created_at < ?
+		GROUP BY 1`, from, end)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var date string
+		var sales RevenueDay
+		if err := rows.Scan(&date, &sales.Payments, &sales.Gross, &sales.Tips, &sales.Tax); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan revenue: %w", err)
+		}
+		d := day(date)
+		d.Payments, d.Gross, d.Tips, d.Tax = sales.Payments, sales.Gross, sales.Tips, sales.Tax
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`SELECT DATE_FORMAT(created_at, '%Y-%m-%d'), COALESCE(SUM(amount), 0)
+		FROM Refunds WHERE status = 'completed' AND created_at >= ? AND created_at < ? GROUP BY 1`, from, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var date string
+		var refunds RevenueDay
+		if err := rows.Scan(&date, &refunds.Refunds); err != nil {
+			return nil, fmt.Errorf("failed to scan refunds: %w", err)
+		}
+		day(date).Refunds = refunds.Refunds
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	report := &RevenueReport{
+		From:  from.Format(time.DateOnly),
+		To:    to.Format(time.DateOnly),
+		Total: RevenueDay{Date: "total"},
+		Days:  []RevenueDay{},
+	}
+	for date := from; date.Before(end); date = date.AddDate(0, 0, 1) {
+		d, ok := days[date.Format(time.DateOnly)]
+		if !ok {
+			continue
+		}
+		d.Net = d.Gross - d.Refunds
+		report.Days = append(report.Days, *d)
+
+		report.Total.Payments += d.Payments
+		report.Total.Gross += d.Gross
+		report.Total.Tips += d.Tips
+		report.Total.Tax += d.Tax
+		report.Total.Refunds += d.Refunds
+		report.Total.Net += d.Net
+	}
+
+	return report, nil
+}
@@ -0,0 +1,518 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"slices"
+	"strings"
+	"time"
+)
+
+const reservationColumns = "id, uid, party_size, starts_at, ends_at, name, phone, email, notes, status, order_id, created_by, created_at"
+
+// MaxReservationDuration caps how long a booking may hold its tables.
+const MaxReservationDuration = 12 * time.Hour
+
+// tableBooking is a span a table is held for, not counting the turn time after it.
+type tableBooking struct {
+	TableID  int64
+	StartsAt time.Time
+	EndsAt   time.Time
+}
+
+// assignTables picks the tables for a party from those not held between startsAt and endsAt,
+// keeping turnTime clear around every booking. The smallest single table that seats the party
+// wins. Failing that, the fewest combinable tables of one section that seat it together, with the
+// fewest empty seats. It returns nil when the party cannot be seated.
+func assignTables(tables []Table, bookings []tableBooking, partySize int, startsAt time.Time, endsAt time.Time, turnTime time.Duration, maxCombined int) []Table {
+	var free []Table
+	for _, table := range tables {
+		held := slices.ContainsFunc(bookings, func(booking tableBooking) bool {
+			return booking.TableID == table.ID && booking.StartsAt.Before(endsAt.Add(turnTime)) && startsAt.Before(booking.EndsAt.Add(turnTime))
+		})
+		if !held {
+			free = append(free, table)
+		}
+	}
+	slices.SortFunc(free, func(a, b Table) int {
+		if a.Capacity != b.Capacity {
+			return a.Capacity - b.Capacity
+		}
+		return a.Number - b.Number
+	})
+
+	for _, table := range free {
+		if table.Capacity >= partySize {
+			return []Table{table}
+		}
+	}
+
+	sections := map[string][]Table{}
+	var names []string
+	for _, table := range free {
+		if !table.Combinable {
+			continue
+		}
+		if _, ok := sections[table.Section]; !ok {
+			names = append(names, table.Section)
+		}
+		sections[table.Section] = append(sections[table.Section], table)
+	}
+	slices.Sort(names)
+
+	for count := 2; count <= maxCombined; count++ {
+		var best []Table
+		bestSeats := 0
+		for _, name := range names {
+			combination, seats := bestCombination(sections[name], count, partySize)
+			if combination != nil && (best == nil || seats < bestSeats) {
+				best, bestSeats = combination, seats
+			}
+		}
+		if best != nil {
+			slices.SortFunc(best, func(a, b Table) int { return a.Number - b.Number })
+			return best
+		}
+	}
+	return nil
+}
+
+// bestCombination finds count tables that seat the party with the fewest seats between them.
+func bestCombination(tables []Table, count int, partySize int) ([]Table, int) {
+	var best []Table
+	bestSeats := 0
+	chosen := make([]Table, 0, count)
+
+	var choose func(from int, seats int)
+	choose = func(from int, seats int) {
+		if len(chosen) == count {
+			if seats >= partySize && (best == nil || seats < bestSeats) {
+				best, bestSeats = slices.Clone(chosen), seats
+			}
+			return
+		}
+		for i := from; i <= len(tables)-(count-len(chosen)); i++ {
+			chosen = append(chosen, tables[i])
+			choose(i+1, seats+tables[i].Capacity)
+			chosen = chosen[:len(chosen)-1]
+		}
+	}
+	choose(0, 0)
+
+	return best, bestSeats
+}
+
+// findReservationSlots lists the seating times from starts at which the party can be booked for
+// duration, with the tables they would get.
+func findReservationSlots(tables []Table, bookings []tableBooking, partySize int, starts []time.Time, duration time.Duration, turnTime time.Duration, maxCombined int) []ReservationSlot {
+	var slots []ReservationSlot
+	for _, startsAt := range starts {
+		endsAt := startsAt.Add(duration)
+		if assigned := assignTables(tables, bookings, partySize, startsAt, endsAt, turnTime, maxCombined); assigned != nil {
+			slots = append(slots, ReservationSlot{StartsAt: startsAt, EndsAt: endsAt, Tables: reservedTables(assigned)})
+		}
+	}
+	return slots
+}
+
+// seatingTimes lists the times on a day from the first to the last seating ("15:04"), every interval.
+func seatingTimes(day time.Time, first string, last string, interval time.Duration) ([]time.Time, error) {
+	firstTime, err := time.Parse("15:04", first)
+	if err != nil {
+		return nil, err
+	}
+	lastTime, err := time.Parse("15:04", last)
+	if err != nil {
+		return nil, err
+	}
+
+	year, month, date := day.Date()
+	from := time.Date(year, month, date, firstTime.Hour(), firstTime.Minute(), 0, 0, day.Location())
+	to := time.Date(year, month, date, lastTime.Hour(), lastTime.Minute(), 0, 0, day.Location())
+
+	var times []time.Time
+	for at := from; !at.After(to); at = at.Add(interval) {
+		times = append(times, at)
+	}
+	return times, nil
+}
+
+func reservedTables(tables []Table) []ReservedTable {
+	reserved := make([]ReservedTable, 0, len(tables))
+	for _, table := range tables {
+		reserved = append(reserved, ReservedTable{ID: table.ID, Number: table.Number, Capacity: table.Capacity})
+	}
+	return reserved
+}
+
+// GetReservationAvailability lists the times on a day a party can be booked for duration, leaving
+// out those already past.
+func GetReservationAvailability(day time.Time, partySize int, duration time.Duration, now time.Time) ([]ReservationSlot, error) {
+	cfg := config.Config.Reservations
+	starts, err := seatingTimes(day, cfg.FirstSeating, cfg.LastSeating, cfg.SlotInterval)
+	if err != nil {
+		return nil, err
+	}
+	starts = slices.DeleteFunc(starts, func(at time.Time) bool { return at.Before(now) })
+	if len(starts) == 0 {
+		return nil, nil
+	}
+
+	tables, bookings, err := loadTableBookings(DB, "", starts[0], starts[len(starts)-1].Add(duration), 0, now)
+	if err != nil {
+		return nil, err
+	}
+	return findReservationSlots(tables, bookings, partySize, starts, duration, cfg.TurnTime, cfg.MaxCombinedTables), nil
+}
+
+// loadTableBookings loads the tables and what holds them between from and to: confirmed
+// reservations other than exclude, seated ones whose order is still open, and walk-in orders,
+// which are expected to stay for the usual duration. Tables waiting to be cleaned are held now.
+func loadTableBookings(q queryer, lock string, from time.Time, to time.Time, exclude int64, now time.Time) ([]Table, []tableBooking, error) {
+	cfg := config.Config.Reservations
+
+	rows, err := q.Query("SELECT " + tableColumns + " FROM DiningTables ORDER BY id" + lock)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tables []Table
+	var bookings []tableBooking
+	for rows.Next() {
+		var table Table
+		if err := scanTable(rows, &table); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables = append(tables, table)
+		if table.Status == TableNeedsCleaning {
+			bookings = append(bookings, tableBooking{TableID: table.ID, StartsAt: now, EndsAt: now})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = q.Query(`SELECT rt.table_id, r.starts_at, r.ends_at, r.status FROM Reservations r
+		JOIN ReservationTables rt ON rt.reservation_id = r.id
+		WHERE r.id <> ? AND r.starts_at < ?
+		AND ((r.status = 'confirmed' AND r.ends_at > ?)
+			OR (r.status = 'seated' AND EXISTS (SELECT 1 FROM Orders o WHERE o.id = r.order_id AND o.status = 'open')))`,
+		exclude, to.Add(cfg.TurnTime), from.Add(-cfg.TurnTime))
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var booking tableBooking
+		var status ReservationStatus
+		if err := rows.Scan(&booking.TableID, &booking.StartsAt, &booking.EndsAt, &status); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan reservation booking: %w", err)
+		}
+		// a seated party that stays past its booking keeps the tables until it leaves
+		if status == ReservationSeated && booking.EndsAt.Before(now) {
+			booking.EndsAt = now
+		}
+		bookings = append(bookings, booking)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = q.Query("SELECT table_id, ordered_at FROM Orders WHERE status = 'open' AND table_id IS NOT NULL")
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var booking tableBooking
+		if err := rows.Scan(&booking.TableID, &booking.StartsAt); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan open order: %w", err)
+		}
+		booking.EndsAt = booking.StartsAt.Add(cfg.Duration)
+		if booking.EndsAt.Before(now) {
+			booking.EndsAt = now
+		}
+		bookings = append(bookings, booking)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return tables, bookings, nil
+}
+
+// CreateReservation books a party in at the best tables free for its time. Tables are locked
+// while they are assigned so two bookings made at once cannot take the same table.
+func CreateReservation(reservation *Reservation, actorId int64) (*Reservation, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = bookReservation(tx, reservation, actorId)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetReservationById(reservation.ID)
+}
+
+// EditReservation changes a confirmed reservation, moving it to other tables if the ones it has
+// no longer fit its new time or party size.
+func EditReservation(reservation *Reservation, actorId int64) (*Reservation, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var status ReservationStatus
+	err = tx.QueryRow("SELECT status FROM Reservations WHERE id = ? FOR UPDATE", reservation.ID).Scan(&status)
+	if err == nil && status != ReservationConfirmed {
+		err = fmt.Errorf("reservation is %s", strings.ReplaceAll(string(status), "_", " "))
+	} else if err == nil {
+		err = bookReservation(tx, reservation, actorId)
+	} else if strings.Contains(err.Error(), "no rows") {
+		err = fmt.Errorf("reservation not found")
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetReservationById(reservation.ID)
+}
+
+// bookReservation assigns tables to a new reservation, or a confirmed one with an ID, and saves it.
+func bookReservation(tx *sql.Tx, reservation *Reservation, actorId int64) error {
+	cfg := config.Config.Reservations
+	tables, bookings, err := loadTableBookings(tx, " FOR UPDATE", reservation.StartsAt, reservation.EndsAt, reservation.ID, time.Now())
+	if err != nil {
+		return err
+	}
+	assigned := assignTables(tables, bookings, reservation.PartySize, reservation.StartsAt, reservation.EndsAt, cfg.TurnTime, cfg.MaxCombinedTables)
+	if assigned == nil {
+		return fmt.Errorf("no tables available for %d guests at %s", reservation.PartySize, reservation.StartsAt.Format("15:04"))
+	}
+
+	if reservation.ID == 0 {
+		if reservation.UID == "" {
+			if reservation.UID, err = GenerateReservationUID(); err != nil {
+				return err
+			}
+		}
+		res, err := tx.Exec("INSERT INTO Reservations (uid, party_size, starts_at, ends_at, name, phone, email, notes, status, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			reservation.UID, reservation.PartySize, reservation.StartsAt, reservation.EndsAt, reservation.Name, reservation.Phone, reservation.Email, reservation.Notes, ReservationConfirmed, nullableId(actorId), time.Now())
+		if err != nil {
+			return err
+		}
+		if reservation.ID, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	} else {
+		if _, err := tx.Exec("UPDATE Reservations SET party_size = ?, starts_at = ?, ends_at = ?, name = ?, phone = ?, email = ?, notes = ? WHERE id = ?",
+			reservation.PartySize, reservation.StartsAt, reservation.EndsAt, reservation.Name, reservation.Phone, reservation.Email, reservation.Notes, reservation.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM ReservationTables WHERE reservation_id = ?", reservation.ID); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range assigned {
+		if _, err := tx.Exec("INSERT INTO ReservationTables (reservation_id, table_id) VALUES (?, ?)", reservation.ID, table.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GenerateReservationUID returns a random (version 4) UUID to identify a reservation by in calendars.
+func GenerateReservationUID() (string, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return "", err
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
+}
+
+func GetReservationById(id int64) (*Reservation, error) {
+	return getReservation("r.id = ?", id)
+}
+
+func GetReservationByUID(uid string) (*Reservation, error) {
+	return getReservation("r.uid = ?", uid)
+}
+
+func getReservation(condition string, arg any) (*Reservation, error) {
+	reservations, err := getReservations(condition, []any{arg})
+	if err != nil {
+		return nil, err
+	}
+	if len(reservations) == 0 {
+		return nil, fmt.Errorf("reservation not found")
+	}
+	return &reservations[0], nil
+}
+
+// GetUpcomingReservations lists the confirmed and seated reservations that have not ended by from
+// and start before to.
+func GetUpcomingReservations(from time.Time, to time.Time) ([]Reservation, error) {
+	return getReservations("r.ends_at > ? AND r.starts_at < ? AND r.status IN ('confirmed', 'seated')", []any{from, to})
+}
+
+// GetReservations lists the reservations starting between from and to by time. An empty status
+// matches every reservation.
+func GetReservations(from time.Time, to time.Time, status ReservationStatus) ([]Reservation, error) {
+	condition := "r.starts_at >= ? AND r.starts_at < ?"
+	args := []any{from, to}
+	if status != "" {
+		condition += " AND r.status = ?"
+		args = append(args, status)
+	}
+	return getReservations(condition, args)
+}
+
+func getReservations(condition string, args []any) ([]Reservation, error) {
+	columns := "r." + strings.ReplaceAll(reservationColumns, ", ", ", r.")
+	rows, err := DB.Query(`SELECT `+columns+`, t.id, t.number, t.capacity FROM Reservations r
+		LEFT JOIN ReservationTables rt ON rt.reservation_id = r.id
+		LEFT JOIN DiningTables t ON t.id = rt.table_id
+		WHERE `+condition+`
+		ORDER BY r.starts_at, r.id, t.number`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []Reservation
+	for rows.Next() {
+		var reservation Reservation
+		var orderId, createdBy, tableId, tableNumber, tableCapacity sql.NullInt64
+		if err := rows.Scan(&reservation.ID, &reservation.UID, &reservation.PartySize, &reservation.StartsAt, &reservation.EndsAt, &reservation.Name, &reservation.Phone,
+			&reservation.Email, &reservation.Notes, &reservation.Status, &orderId, &createdBy, &reservation.CreatedAt, &tableId, &tableNumber, &tableCapacity); err != nil {
+			return nil, fmt.Errorf("failed to scan reservation: %w", err)
+		}
+		reservation.OrderID = orderId.Int64
+		reservation.CreatedBy = createdBy.Int64
+		reservation.Tables = []ReservedTable{}
+
+		if len(reservations) == 0 || reservations[len(reservations)-1].ID != reservation.ID {
+			reservations = append(reservations, reservation)
+		}
+		if tableId.Valid {
+			last := &reservations[len(reservations)-1]
+			last.Tables = append(last.Tables, ReservedTable{ID: tableId.Int64, Number: int(tableNumber.Int64), Capacity: int(tableCapacity.Int64)})
+		}
+	}
+
+	return reservations, rows.Err()
+}
+
+// SetReservationStatus moves a reservation on from confirmed. Seating it opens an order at its
+// tables for the party; marking it a no-show or cancelling it lets its tables go.
+func SetReservationStatus(id int64, status ReservationStatus, actorId int64) (*Reservation, error) {
+	var err error
+	if status == ReservationSeated {
+		err = seatReservation(id, actorId)
+	} else {
+		err = updateStatus(ReservationEntity, id, string(status), actorId, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return GetReservationById(id)
+}
+
+// seatReservation opens the party's order at the first of its tables, by number, and marks the
+// rest occupied with it so they are cleared together when the order closes.
+func seatReservation(id int64, actorId int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = seatParty(tx, id, actorId)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("%v %v", err1, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func seatParty(tx *sql.Tx, id int64, actorId int64) error {
+	var status ReservationStatus
+	var partySize int
+	if err := tx.QueryRow("SELECT status, party_size FROM Reservations WHERE id = ? FOR UPDATE", id).Scan(&status, &partySize); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("reservation not found")
+		}
+		return err
+	}
+	if status == ReservationSeated {
+		return fmt.Errorf("reservation is already seated")
+	}
+	if err := changeStatus(tx, ReservationEntity, id, string(ReservationSeated), actorId); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query("SELECT t.id, t.number, t.status FROM ReservationTables rt JOIN DiningTables t ON t.id = rt.table_id WHERE rt.reservation_id = ? ORDER BY t.number FOR UPDATE", id)
+	if err != nil {
+		return err
+	}
+	var tables []Table
+	for rows.Next() {
+		var table Table
+		if err := rows.Scan(&table.ID, &table.Number, &table.Status); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(tables) == 0 {
+		return fmt.Errorf("reservation has no tables")
+	}
+
+	for _, table := range tables {
+		if table.Status != TableFree && table.Status != TableReserved {
+			return fmt.Errorf("table %d is not free", table.Number)
+		}
+	}
+
+	order, err := createOrder(tx, actorId, tables[0].ID, partySize)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables[1:] {
+		if err := changeStatus(tx, TableEntity, table.ID, string(TableOccupied), actorId); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE Reservations SET order_id = ? WHERE id = ?", order.ID, id)
+	return err
+}
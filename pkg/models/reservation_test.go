@@ -0,0 +1,102 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func tableNumbers(tables []Table) []int {
+	numbers := make([]int, 0, len(tables))
+	for _, table := range tables {
+		numbers = append(numbers, table.Number)
+	}
+	return numbers
+}
+
+func sameNumbers(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAssignTables(t *testing.T) {
+	tables := []Table{
+		{ID: 1, Number: 1, Section: "main", Capacity: 2, Combinable: true},
+		{ID: 2, Number: 2, Section: "main", Capacity: 2, Combinable: true},
+		{ID: 3, Number: 3, Section: "main", Capacity: 4, Combinable: true},
+		{ID: 4, Number: 4, Section: "main", Capacity: 6},
+		{ID: 5, Number: 5, Section: "patio", Capacity: 4, Combinable: true},
+		{ID: 6, Number: 6, Section: "patio", Capacity: 4, Combinable: true},
+	}
+	start := time.Date(2025, 9, 7, 19, 0, 0, 0, time.UTC)
+	end := start.Add(90 * time.Minute)
+	turn := 15 * time.Minute
+
+	tests := []struct {
+		name      string
+		partySize int
+		bookings  []tableBooking
+		expected  []int
+	}{
+		{"smallest table that fits", 2, nil, []int{1}},
+		{"bigger table when small ones are taken", 2, []tableBooking{{TableID: 1, StartsAt: start, EndsAt: end}, {TableID: 2, StartsAt: start, EndsAt: end}}, []int{3}},
+		{"single table before combining", 6, nil, []int{4}},
+		{"fewest seats when combining", 6, []tableBooking{{TableID: 4, StartsAt: start, EndsAt: end}}, []int{1, 3}},
+		{"tables of one section only", 10, []tableBooking{{TableID: 4, StartsAt: start, EndsAt: end}}, nil},
+		{"three tables", 8, []tableBooking{{TableID: 4, StartsAt: start, EndsAt: end}, {TableID: 5, StartsAt: start, EndsAt: end}}, []int{1, 2, 3}},
+		{"turn time keeps tables apart", 2, []tableBooking{
+			{TableID: 1, StartsAt: end.Add(10 * time.Minute), EndsAt: end.Add(2 * time.Hour)},
+			{TableID: 2, StartsAt: start.Add(-2 * time.Hour), EndsAt: start.Add(-15 * time.Minute)},
+		}, []int{2}},
+		{"party too big", 20, nil, nil},
+	}
+	for _, test := range tests {
+		assigned := assignTables(tables, test.bookings, test.partySize, start, end, turn, 3)
+		if numbers := tableNumbers(assigned); !sameNumbers(numbers, test.expected) {
+			t.Errorf("%s: expected tables %v, got %v", test.name, test.expected, numbers)
+		}
+	}
+
+	if assigned := assignTables(tables, []tableBooking{{TableID: 4, StartsAt: start, EndsAt: end}}, 6, start, end, turn, 1); assigned != nil {
+		t.Errorf("Expected no combined tables when combining is off, got %v", tableNumbers(assigned))
+	}
+}
+
+func TestFindReservationSlots(t *testing.T) {
+	tables := []Table{{ID: 1, Number: 1, Section: "main", Capacity: 4}}
+	day := time.Date(2025, 9, 7, 0, 0, 0, 0, time.UTC)
+
+	starts, err := seatingTimes(day, "18:00", "20:00", 30*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(starts) != 5 || !starts[0].Equal(day.Add(18*time.Hour)) || !starts[4].Equal(day.Add(20*time.Hour)) {
+		t.Fatalf("Expected seatings from 18:00 to 20:00 every 30 minutes, got %v", starts)
+	}
+
+	// the table is booked 18:30 to 19:00, and with 15 minutes turn time on either side a 30 minute
+	// booking fits only before 17:45 or from 19:15
+	bookings := []tableBooking{{TableID: 1, StartsAt: day.Add(18*time.Hour + 30*time.Minute), EndsAt: day.Add(19 * time.Hour)}}
+	slots := findReservationSlots(tables, bookings, 2, starts, 30*time.Minute, 15*time.Minute, 1)
+
+	var times []string
+	for _, slot := range slots {
+		times = append(times, slot.StartsAt.Format("15:04"))
+		if len(slot.Tables) != 1 || slot.Tables[0].Number != 1 {
+			t.Errorf("Expected the %s slot to offer table 1, got %v", slot.StartsAt.Format("15:04"), slot.Tables)
+		}
+	}
+	if len(times) != 2 || times[0] != "19:30" || times[1] != "20:00" {
+		t.Errorf("Expected slots at 19:30 and 20:00, got %v", times)
+	}
+
+	if _, err := seatingTimes(day, "6pm", "20:00", time.Hour); err == nil {
+		t.Error("Expected an error for an invalid seating time")
+	}
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"time"
+)
+
+// pickupSlotAt finds the slot among those starting at starts, each interval long, that a pickup
+// time falls in.
+func pickupSlotAt(starts []time.Time, interval time.Duration, at time.Time) (time.Time, bool) {
+	for _, start := range starts {
+		if !at.Before(start) && at.Before(start.Add(interval)) {
+			return start, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// pickupSlotReady reports whether an order placed at now can be ready before the slot starting at
+// start ends.
+func pickupSlotReady(start time.Time, interval time.Duration, prepTime time.Duration, now time.Time) bool {
+	return start.Add(interval).After(now.Add(prepTime))
+}
+
+// countPickupSlots counts the pickup times promised in each slot and how many more orders each
+// takes. capacity 0 takes any number.
+func countPickupSlots(starts []time.Time, interval time.Duration, capacity int, pickups []time.Time) []PickupSlot {
+	slots := make([]PickupSlot, len(starts))
+	for i, start := range starts {
+		slots[i] = PickupSlot{StartsAt: start, EndsAt: start.Add(interval)}
+	}
+	for _, pickupAt := range pickups {
+		for i := range slots {
+			if !pickupAt.Before(slots[i].StartsAt) && pickupAt.Before(slots[i].EndsAt) {
+				slots[i].Orders++
+				break
+			}
+		}
+	}
+	for i := range slots {
+		slots[i].Remaining = -1
+		if capacity > 0 {
+			slots[i].Remaining = max(capacity-slots[i].Orders, 0)
+		}
+	}
+	return slots
+}
+
+// GetPickupSlots lists the pickup slots on a day with the orders already promised for them, leaving
+// out those that end before the kitchen could have a new order ready.
+func GetPickupSlots(day time.Time, now time.Time) ([]PickupSlot, error) {
+	cfg := config.Config.Kitchen
+	starts, err := seatingTimes(day, cfg.FirstPickup, cfg.LastPickup, cfg.SlotInterval)
+	if err != nil {
+		return nil, err
+	}
+	for len(starts) > 0 && !pickupSlotReady(starts[0], cfg.SlotInterval, cfg.PrepTime, now) {
+		starts = starts[1:]
+	}
+	if len(starts) == 0 {
+		return nil, nil
+	}
+
+	rows, err := DB.Query("SELECT pickup_at FROM Orders WHERE pickup_at >= ? AND pickup_at < ?", starts[0], starts[len(starts)-1].Add(cfg.SlotInterval))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pickups []time.Time
+	for rows.Next() {
+		var pickupAt time.Time
+		if err := rows.Scan(&pickupAt); err != nil {
+			return nil, err
+		}
+		pickups = append(pickups, pickupAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return countPickupSlots(starts, cfg.SlotInterval, cfg.SlotCapacity, pickups), nil
+}
+
+// bookPickupSlot checks that a pickup time falls within pickup hours, in a slot that the kitchen can
+// still make for an order placed at now and that takes another order. The slot's orders are locked
+// so that concurrent orders cannot overbook it.
+func bookPickupSlot(tx *sql.Tx, pickupAt time.Time, now time.Time) error {
+	cfg := config.Config.Kitchen
+	pickupAt = pickupAt.In(time.Local)
+	starts, err := seatingTimes(pickupAt, cfg.FirstPickup, cfg.LastPickup, cfg.SlotInterval)
+	if err != nil {
+		return err
+	}
+	start, ok := pickupSlotAt(starts, cfg.SlotInterval, pickupAt)
+	if !ok {
+		return fmt.Errorf("pickup time %s is outside pickup hours", pickupAt.Format("15:04"))
+	}
+	if !pickupSlotReady(start, cfg.SlotInterval, cfg.PrepTime, now) {
+		return fmt.Errorf("pickup slot at %s is too soon to prepare the order", start.Format("15:04"))
+	}
+	if cfg.SlotCapacity == 0 {
+		return nil
+	}
+
+	var orders int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM Orders WHERE pickup_at >= ? AND pickup_at < ? FOR UPDATE", start, start.Add(cfg.SlotInterval)).Scan(&orders); err != nil {
+		return err
+	}
+	if orders >= cfg.SlotCapacity {
+		return fmt.Errorf("pickup slot at %s is full", start.Format("15:04"))
+	}
+	return nil
+}
+
+// ReleaseScheduledOrderItems moves the items of orders scheduled ahead into the kitchen queue once
+// their pickup time is less than the release lead time away, and returns them.
+func ReleaseScheduledOrderItems(now time.Time) ([]OrderItem, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := releaseScheduledOrderItems(tx, now.Add(config.Config.Kitchen.ReleaseLeadTime))
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func releaseScheduledOrderItems(tx *sql.Tx, pickupBy time.Time) ([]OrderItem, error) {
+	rows, err := tx.Query("SELECT "+orderItemColumns+" FROM OrderItems WHERE status = ? AND order_id IN (SELECT id FROM Orders WHERE pickup_at <= ?) ORDER BY id FOR UPDATE", ItemScheduled, pickupBy)
+	if err != nil {
+		return nil, err
+	}
+	var items []OrderItem
+	for rows.Next() {
+		var item OrderItem
+		if err := scanOrderItem(rows, &item); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if err := changeStatus(tx, OrderItemEntity, items[i].ID, string(ItemPending), 0); err != nil {
+			return nil, err
+		}
+		items[i].Status = ItemPending
+	}
+	return items, nil
+}
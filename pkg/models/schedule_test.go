@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPickupSlotAt(t *testing.T) {
+	day := time.Date(2025, 9, 12, 0, 0, 0, 0, time.UTC)
+	starts, err := seatingTimes(day, "11:00", "12:30", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		at       time.Time
+		expected time.Time
+		ok       bool
+	}{
+		{day.Add(11 * time.Hour), day.Add(11 * time.Hour), true},
+		{day.Add(12*time.Hour + 29*time.Minute), day.Add(12*time.Hour + 15*time.Minute), true},
+		{day.Add(12*time.Hour + 44*time.Minute), day.Add(12*time.Hour + 30*time.Minute), true},
+		{day.Add(12*time.Hour + 45*time.Minute), time.Time{}, false},
+		{day.Add(10*time.Hour + 59*time.Minute), time.Time{}, false},
+	}
+	for _, test := range tests {
+		start, ok := pickupSlotAt(starts, 15*time.Minute, test.at)
+		if ok != test.ok || !start.Equal(test.expected) {
+			t.Errorf("pickupSlotAt(%s) = %s, %v; want %s, %v", test.at.Format("15:04"), start.Format("15:04"), ok, test.expected.Format("15:04"), test.ok)
+		}
+	}
+}
+
+func TestCountPickupSlots(t *testing.T) {
+	noon := time.Date(2025, 9, 12, 12, 0, 0, 0, time.UTC)
+	starts := []time.Time{noon, noon.Add(15 * time.Minute), noon.Add(30 * time.Minute)}
+	pickups := []time.Time{
+		noon,
+		noon.Add(10 * time.Minute),
+		noon.Add(14 * time.Minute),
+		noon.Add(30 * time.Minute),
+		noon.Add(45 * time.Minute),
+	}
+
+	slots := countPickupSlots(starts, 15*time.Minute, 2, pickups)
+	expected := []struct {
+		orders    int
+		remaining int
+	}{{3, 0}, {0, 2}, {1, 1}}
+	if len(slots) != len(expected) {
+		t.Fatalf("Expected %d slots, got %d", len(expected), len(slots))
+	}
+	for i, slot := range slots {
+		if slot.Orders != expected[i].orders || slot.Remaining != expected[i].remaining {
+			t.Errorf("Slot at %s: expected %d orders and %d remaining, got %d and %d",
+				slot.StartsAt.Format("15:04"), expected[i].orders, expected[i].remaining, slot.Orders, slot.Remaining)
+		}
+		if !slot.EndsAt.Equal(slot.StartsAt.Add(15 * time.Minute)) {
+			t.Errorf("Slot at %s: expected it to end 15 minutes later, got %s", slot.StartsAt.Format("15:04"), slot.EndsAt.Format("15:04"))
+		}
+	}
+
+	for _, slot := range countPickupSlots(starts, 15*time.Minute, 0, pickups) {
+		if slot.Remaining != -1 {
+			t.Errorf("Expected unlimited slots to have -1 remaining, got %d", slot.Remaining)
+		}
+	}
+}
+
+func TestPickupSlotReady(t *testing.T) {
+	now := time.Date(2025, 9, 12, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		start    time.Time
+		expected bool
+	}{
+		{now.Add(-15 * time.Minute), false},
+		{now.Add(5 * time.Minute), false},
+		{now.Add(10 * time.Minute), false},
+		{now.Add(11 * time.Minute), true},
+		{now.Add(30 * time.Minute), true},
+	}
+	for _, test := range tests {
+		if ready := pickupSlotReady(test.start, 15*time.Minute, 25*time.Minute, now); ready != test.expected {
+			t.Errorf("pickupSlotReady(%s) = %v, want %v", test.start.Format("15:04"), ready, test.expected)
+		}
+	}
+}
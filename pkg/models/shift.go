@@ -0,0 +1,137 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ClockIn starts a shift for the user. A user can only have one shift open at a time.
+func ClockIn(userId int64, at time.Time) (*Shift, error) {
+	res, err := DB.Exec("INSERT INTO Shifts (user_id, clocked_in_at) SELECT ?, ? FROM DUAL WHERE NOT EXISTS (SELECT 1 FROM Shifts WHERE user_id = ? AND clocked_out_at IS NULL)",
+		userId, at, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, fmt.Errorf("user %d is already clocked in", userId)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Shift{ID: id, UserID: userId, ClockedInAt: at}, nil
+}
+
+// ClockOut ends the user's open shift.
+func ClockOut(userId int64, at time.Time) (*Shift, error) {
+	shift := &Shift{}
+	row := DB.QueryRow("SELECT id, user_id, clocked_in_at, clocked_out_at FROM Shifts WHERE user_id = ? AND clocked_out_at IS NULL", userId)
+	if err := scanShift(row, shift); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("user %d is not clocked in", userId)
+		}
+		return nil, err
+	}
+
+	if at.Before(shift.ClockedInAt) {
+		at = shift.ClockedInAt
+	}
+
+	res, err := DB.Exec("UPDATE Shifts SET clocked_out_at = ? WHERE id = ? AND clocked_out_at IS NULL", at, shift.ID)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		return nil, fmt.Errorf("user %d is not clocked in", userId)
+	}
+
+	shift.ClockedOutAt = &at
+	return shift, nil
+}
+
+// GetShifts returns the shifts that overlap from and to, newest first. userId 0 returns everyone's.
+func GetShifts(userId int64, from time.Time, to time.Time, limit int, offset int) ([]Shift, error) {
+	rows, err := DB.Query("SELECT id, user_id, clocked_in_at, clocked_out_at FROM Shifts WHERE (user_id = ? OR ? = 0) AND clocked_in_at < ? AND (clocked_out_at IS NULL OR clocked_out_at > ?) ORDER BY clocked_in_at DESC LIMIT ? OFFSET ?",
+		userId, userId, to, from, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []Shift
+	for rows.Next() {
+		var shift Shift
+		if err := scanShift(rows, &shift); err != nil {
+			return nil, fmt.Errorf("failed to scan shift: %w", err)
+		}
+		shifts = append(shifts, shift)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return shifts, nil
+}
+
+// GetHoursWorked adds up everyone's shifts between from and to. Shifts crossing either end are cut
+// at it and shifts that are still open count until now.
+func GetHoursWorked(from time.Time, to time.Time, now time.Time) (map[int64]time.Duration, error) {
+	rows, err := DB.Query("SELECT id, user_id, clocked_in_at, clocked_out_at FROM Shifts WHERE clocked_in_at < ? AND (clocked_out_at IS NULL OR clocked_out_at > ?)", to, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	worked := make(map[int64]time.Duration)
+	for rows.Next() {
+		var shift Shift
+		if err := scanShift(rows, &shift); err != nil {
+			return nil, fmt.Errorf("failed to scan shift: %w", err)
+		}
+
+		start, end := shift.ClockedInAt, now
+		if shift.ClockedOutAt != nil {
+			end = *shift.ClockedOutAt
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			worked[shift.UserID] += end.Sub(start)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return worked, nil
+}
+
+func scanShift(row interface{ Scan(dest ...any) error }, shift *Shift) error {
+	var clockedOutAt sql.NullTime
+	if err := row.Scan(&shift.ID, &shift.UserID, &shift.ClockedInAt, &clockedOutAt); err != nil {
+		return err
+	}
+	if clockedOutAt.Valid {
+		shift.ClockedOutAt = &clockedOutAt.Time
+	}
+	return nil
+}
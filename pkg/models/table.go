@@ -0,0 +1,226 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const tableColumns = "id, number, section, capacity, combinable, status, qr_token, created_at"
+
+// GenerateTableToken returns the random token a table's QR code carries. It identifies the table
+// without being guessable from its number.
+func GenerateTableToken() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
+func CreateTable(number int, section string, capacity int, combinable bool) (*Table, error) {
+	token, err := GenerateTableToken()
+	if err != nil {
+		return nil, err
+	}
+
+	table := &Table{
+		Number:     number,
+		Section:    section,
+		Capacity:   capacity,
+		Combinable: combinable,
+		Status:     TableFree,
+		QRToken:    token,
+		CreatedAt:  time.Now(),
+	}
+	res, err := DB.Exec("INSERT INTO DiningTables (number, section, capacity, combinable, status, qr_token, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		table.Number, table.Section, table.Capacity, table.Combinable, table.Status, table.QRToken, table.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if table.ID, err = res.LastInsertId(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+func GetTableById(id int64) (*Table, error) {
+	return getTable("id = ?", id)
+}
+
+func GetTableByNumber(number int) (*Table, error) {
+	return getTable("number = ?", number)
+}
+
+func GetTableByToken(token string) (*Table, error) {
+	return getTable("qr_token = ?", token)
+}
+
+func getTable(condition string, arg any) (*Table, error) {
+	table := &Table{}
+	if err := scanTable(DB.QueryRow("SELECT "+tableColumns+" FROM DiningTables WHERE "+condition, arg), table); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("table not found")
+		}
+		return nil, err
+	}
+	return table, nil
+}
+
+// GetTables lists tables by section and number. An empty section or status matches every table.
+func GetTables(section string, status TableStatus) ([]Table, error) {
+	query := "SELECT " + tableColumns + " FROM DiningTables WHERE 1=1"
+	var args []any
+
+	if section != "" {
+		query += " AND section = ?"
+		args = append(args, section)
+	}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	query += " ORDER BY section, number"
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []Table
+	for rows.Next() {
+		var table Table
+		if err := scanTable(rows, &table); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+// EditTable changes a table's number, section, capacity and whether it may be pushed together with
+// others for bigger parties. The open order at the table is
+// renumbered with it so kitchen tickets and receipts show the new number.
+func EditTable(id int64, number int, section string, capacity int, combinable bool) (*Table, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Exec("UPDATE DiningTables SET number = ?, section = ?, capacity = ?, combinable = ? WHERE id = ?", number, section, capacity, combinable, id)
+	if err == nil {
+		var affected int64
+		if affected, err = res.RowsAffected(); err == nil && affected == 0 {
+			var exists bool
+			if exists, err = rowExists(tx, "DiningTables", id); err == nil && !exists {
+				err = fmt.Errorf("table not found")
+			}
+		}
+	}
+	if err == nil {
+		_, err = tx.Exec("UPDATE Orders SET table_number = ? WHERE table_id = ? AND status = 'open'", number, id)
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetTableById(id)
+}
+
+// DeleteTable removes a table that no order was ever taken at. Tables with order history are kept
+// so the history still points somewhere.
+func DeleteTable(id int64) error {
+	var hasOrders bool
+	if err := DB.QueryRow("SELECT EXISTS (SELECT 1 FROM Orders WHERE table_id = ?)", id).Scan(&hasOrders); err != nil {
+		return err
+	}
+	if hasOrders {
+		return fmt.Errorf("table has orders")
+	}
+
+	res, err := DB.Exec("DELETE FROM DiningTables WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("table not found")
+	}
+	return nil
+}
+
+// SetTableStatus moves a table through free, reserved, occupied and needs cleaning. A table with an
+// open order stays occupied.
+func SetTableStatus(id int64, status TableStatus, actorId int64) error {
+	return updateStatus(TableEntity, id, string(status), actorId, func(tx *sql.Tx) error {
+		if status == TableOccupied {
+			return nil
+		}
+		var open bool
+		if err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM Orders WHERE table_id = ? AND status = 'open')", id).Scan(&open); err != nil {
+			return err
+		}
+		if open {
+			return fmt.Errorf("table has an open order")
+		}
+		return nil
+	})
+}
+
+// GetFloorPlan lists every table by section with the open order seated at it.
+func GetFloorPlan() ([]FloorPlanSection, error) {
+	rows, err := DB.Query(`SELECT t.id, t.number, t.section, t.capacity, t.status, o.id, o.guests, o.ordered_at, o.bill_requested_at
+		FROM DiningTables t
+		LEFT JOIN Orders o ON o.table_id = t.id AND o.status = 'open'
+		ORDER BY t.section, t.number`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sections []FloorPlanSection
+	for rows.Next() {
+		var table FloorPlanTable
+		var section string
+		var orderId, guests sql.NullInt64
+		var orderedAt, billRequestedAt sql.NullTime
+		if err := rows.Scan(&table.ID, &table.Number, &section, &table.Capacity, &table.Status, &orderId, &guests, &orderedAt, &billRequestedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan floor plan table: %w", err)
+		}
+		table.OrderID = orderId.Int64
+		table.Guests = int(guests.Int64)
+		if orderedAt.Valid {
+			table.OrderedAt = &orderedAt.Time
+		}
+		if billRequestedAt.Valid {
+			table.BillRequestedAt = &billRequestedAt.Time
+		}
+
+		if len(sections) == 0 || sections[len(sections)-1].Section != section {
+			sections = append(sections, FloorPlanSection{Section: section})
+		}
+		sections[len(sections)-1].Tables = append(sections[len(sections)-1].Tables, table)
+	}
+
+	return sections, rows.Err()
+}
+
+func scanTable(row interface{ Scan(dest ...any) error }, table *Table) error {
+	return row.Scan(&table.ID, &table.Number, &table.Section, &table.Capacity, &table.Combinable, &table.Status, &table.QRToken, &table.CreatedAt)
+}
@@ -3,11 +3,12 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
 )
 
-func CreateTag(name string) (*Tag, error) {
+func CreateTag(name string, taxCategoryId int64, loyaltyMultiplier money.Percent) (*Tag, error) {
 	var tag Tag
-	res, err := DB.Exec("INSERT INTO Tags (name) VALUES (?);", name)
+	res, err := DB.Exec("INSERT INTO Tags (name, tax_category_id, loyalty_multiplier) VALUES (?, ?, ?);", name, nullableId(taxCategoryId), loyaltyMultiplier)
 	if err != nil {
 		return nil, err
 	}
@@ -17,11 +18,13 @@ func CreateTag(name string) (*Tag, error) {
 	}
 	tag.ID = id
 	tag.Name = name
+	tag.TaxCategoryID = taxCategoryId
+	tag.LoyaltyMultiplier = loyaltyMultiplier
 	return &tag, nil
 }
 
 func GetTagById(id int64) (*Tag, error) {
-	rows, err := DB.Query("SELECT id, name FROM Tags WHERE id = ? LIMIT 1;", id)
+	rows, err := DB.Query("SELECT id, name, tax_category_id, loyalty_multiplier FROM Tags WHERE id = ? LIMIT 1;", id)
 	if err != nil {
 		return nil, err
 	}
@@ -38,9 +41,9 @@ func GetTagById(id int64) (*Tag, error) {
 	}
 }
 
-func EditTag(id int64, name string) (*Tag, error) {
+func EditTag(id int64, name string, taxCategoryId int64, loyaltyMultiplier money.Percent) (*Tag, error) {
 	var tag Tag
-	res, err := DB.Exec("UPDATE Tags SET name = ? WHERE id = ?;", name, id)
+	res, err := DB.Exec("UPDATE Tags SET name = ?, tax_category_id = ?, loyalty_multiplier = ? WHERE id = ?;", name, nullableId(taxCategoryId), loyaltyMultiplier, id)
 	if err != nil {
 		return nil, err
 	}
@@ -49,12 +52,15 @@ func EditTag(id int64, name string) (*Tag, error) {
 		return nil, fmt.Errorf("tag not found with id '%d'", id)
 	}
 
+	tag.ID = id
 	tag.Name = name
+	tag.TaxCategoryID = taxCategoryId
+	tag.LoyaltyMultiplier = loyaltyMultiplier
 	return &tag, nil
 }
 
 func GetTags() ([]Tag, error) {
-	rows, err := DB.Query("SELECT id, name FROM Tags;")
+	rows, err := DB.Query("SELECT id, name, tax_category_id, loyalty_multiplier FROM Tags;")
 	if err != nil {
 		return nil, err
 	}
@@ -73,8 +79,10 @@ func GetTags() ([]Tag, error) {
 }
 
 func scanTag(rows *sql.Rows, tag *Tag) error {
-	if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
+	var taxCategoryId sql.NullInt64
+	if err := rows.Scan(&tag.ID, &tag.Name, &taxCategoryId, &tag.LoyaltyMultiplier); err != nil {
 		return fmt.Errorf("failed to scan tag: %w", err)
 	}
+	tag.TaxCategoryID = taxCategoryId.Int64
 	return nil
 }
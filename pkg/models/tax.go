@@ -0,0 +1,158 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+func CreateTaxCategory(category *TaxCategory) (*TaxCategory, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	if category.IsDefault {
+		if _, err := tx.Exec("UPDATE TaxCategories SET is_default = false"); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec("INSERT INTO TaxCategories (name, rate, inclusive, is_default) VALUES (?, ?, ?, ?)", category.Name, category.Rate, category.Inclusive, category.IsDefault)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		if strings.Contains(err.Error(), "Duplicate") {
+			return nil, fmt.Errorf("tax category '%s' already exists", category.Name)
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	created := *category
+	created.ID = id
+	return &created, nil
+}
+
+func EditTaxCategory(category *TaxCategory) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if category.IsDefault {
+		if _, err := tx.Exec("UPDATE TaxCategories SET is_default = false WHERE id != ?", category.ID); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	res, err := tx.Exec("UPDATE TaxCategories SET name = ?, rate = ?, inclusive = ?, is_default = ? WHERE id = ?", category.Name, category.Rate, category.Inclusive, category.IsDefault, category.ID)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("%v %v", err1, err)
+		}
+		if strings.Contains(err.Error(), "Duplicate") {
+			return fmt.Errorf("tax category '%s' already exists", category.Name)
+		}
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if rowsAffected == 0 {
+		exists, err := rowExists(tx, "TaxCategories", category.ID)
+		if err == nil && !exists {
+			err = fmt.Errorf("tax category not found")
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func GetTaxCategories() ([]TaxCategory, error) {
+	rows, err := DB.Query("SELECT id, name, rate, inclusive, is_default FROM TaxCategories ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var categories []TaxCategory
+	for rows.Next() {
+		var category TaxCategory
+		if err := rows.Scan(&category.ID, &category.Name, &category.Rate, &category.Inclusive, &category.IsDefault); err != nil {
+			return nil, fmt.Errorf("failed to scan tax category: %w", err)
+		}
+		categories = append(categories, category)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// GetTaxCategoriesForItems resolves the tax category of each item: the item's own category,
+// then the category of its first tag that has one, then the default category.
+// Items that resolve to no category are left out of the map and are not taxed.
+func GetTaxCategoriesForItems(itemIds []int64) (map[int64]*TaxCategory, error) {
+	categories := make(map[int64]*TaxCategory)
+	if len(itemIds) == 0 {
+		return categories, nil
+	}
+
+	query := `
+		SELECT Items.id, TaxCategories.id, TaxCategories.name, TaxCategories.rate, TaxCategories.inclusive, TaxCategories.is_default
+		FROM Items
+		JOIN TaxCategories ON TaxCategories.id = COALESCE(
+			Items.tax_category_id,
+			(SELECT Tags.tax_category_id FROM ItemTags JOIN Tags ON Tags.id = ItemTags.tag_id
+				WHERE ItemTags.item_id = Items.id AND Tags.tax_category_id IS NOT NULL ORDER BY Tags.id LIMIT 1),
+			(SELECT id FROM TaxCategories WHERE is_default = true LIMIT 1))
+		WHERE Items.id IN (`
+	args := make([]any, len(itemIds))
+	for i, id := range itemIds {
+		query += "?,"
+		args[i] = id
+	}
+	query = query[:len(query)-1] + ")"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemId int64
+		var category TaxCategory
+		if err := rows.Scan(&itemId, &category.ID, &category.Name, &category.Rate, &category.Inclusive, &category.IsDefault); err != nil {
+			return nil, fmt.Errorf("failed to scan tax category: %w", err)
+		}
+		categories[itemId] = &category
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
@@ -0,0 +1,420 @@
+package models
+
+import (
+	"fmt"
+	"github.com/gqvz/mvc/pkg/money"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Validate checks that the pool has what its method distributes by: role pools need cashier and
+// chef percentages adding up to 100, points pools need members with positive points.
+func (p *TipPool) Validate() error {
+	switch p.Method {
+	case RoleTipPool:
+		if len(p.Roles) == 0 {
+			return fmt.Errorf("a role pool needs role percentages")
+		}
+		total := money.Percent(0)
+		seen := make(map[Role]bool)
+		for _, role := range p.Roles {
+			if role.Role != Cashier && role.Role != Chef {
+				return fmt.Errorf("tips can only be pooled for the cashier and chef roles")
+			}
+			if seen[role.Role] {
+				return fmt.Errorf("role %d is listed twice", role.Role)
+			}
+			if role.Percent <= 0 {
+				return fmt.Errorf("role percentages must be positive")
+			}
+			seen[role.Role] = true
+			total += role.Percent
+		}
+		if total != money.OneHundredPercent {
+			return fmt.Errorf("role percentages must add up to 100")
+		}
+	case PointsTipPool:
+		if len(p.Members) == 0 {
+			return fmt.Errorf("a points pool needs members")
+		}
+		seen := make(map[int64]bool)
+		for _, member := range p.Members {
+			if member.Points <= 0 {
+				return fmt.Errorf("member points must be positive")
+			}
+			if seen[member.UserID] {
+				return fmt.Errorf("user %d is listed twice", member.UserID)
+			}
+			seen[member.UserID] = true
+		}
+	case HoursTipPool:
+	default:
+		return fmt.Errorf("method must be one of 'role', 'hours' or 'points'")
+	}
+	return nil
+}
+
+// CalculateTipPayouts attributes every tip evenly to the cashier and the chefs who prepared the
+// paid items, then distributes all tips of the period with the pool's method among the staff they
+// were attributed to: role pools give each role its percentage split evenly between its staff (a
+// role nobody worked hands its share to the others), hours pools go by time worked and points
+// pools by the members' points. Staff without hours or points get nothing; what cannot be
+// distributed is left out of the payouts.
+func CalculateTipPayouts(pool *TipPool, payments []TippedPayment, worked map[int64]time.Duration) []TipPayout {
+	byUser := make(map[int64]*TipPayout)
+	total := money.Money(0)
+	for _, payment := range payments {
+		if payment.Tip <= 0 {
+			continue
+		}
+		total += payment.Tip
+
+		var staff []*TipPayout
+		attribute := func(userId int64, role Role) {
+			if userId == 0 {
+				return
+			}
+			payout, ok := byUser[userId]
+			if !ok {
+				payout = &TipPayout{UserID: userId}
+				byUser[userId] = payout
+			}
+			payout.Roles |= role
+			for _, other := range staff {
+				if other == payout {
+					return
+				}
+			}
+			staff = append(staff, payout)
+		}
+		attribute(payment.CashierID, Cashier)
+		for _, chefId := range payment.ChefIDs {
+			attribute(chefId, Chef)
+		}
+
+		for i, share := range payment.Tip.Split(len(staff)) {
+			staff[i].Payments++
+			staff[i].Attributed += share
+		}
+	}
+
+	payouts := make([]TipPayout, 0, len(byUser))
+	for _, payout := range byUser {
+		payouts = append(payouts, *payout)
+	}
+	sort.Slice(payouts, func(i, j int) bool { return payouts[i].UserID < payouts[j].UserID })
+
+	points := make(map[int64]int)
+	for _, member := range pool.Members {
+		points[member.UserID] = member.Points
+	}
+	for i := range payouts {
+		payouts[i].Hours = math.Round(worked[payouts[i].UserID].Hours()*100) / 100
+		payouts[i].Points = points[payouts[i].UserID]
+	}
+
+	switch pool.Method {
+	case RoleTipPool:
+		var roles []TipPoolRole
+		var weights []int64
+		for _, role := range pool.Roles {
+			for _, payout := range payouts {
+				if payout.Roles.HasFlag(role.Role) {
+					roles = append(roles, role)
+					weights = append(weights, int64(role.Percent))
+					break
+				}
+			}
+		}
+		for r, roleShare := range total.Allocate(weights) {
+			var members []int
+			for i, payout := range payouts {
+				if payout.Roles.HasFlag(roles[r].Role) {
+					members = append(members, i)
+				}
+			}
+			for m, share := range roleShare.Split(len(members)) {
+				payouts[members[m]].Payout += share
+			}
+		}
+	case HoursTipPool, PointsTipPool:
+		weights := make([]int64, len(payouts))
+		for i, payout := range payouts {
+			if pool.Method == HoursTipPool {
+				weights[i] = int64(worked[payout.UserID] / time.Second)
+			} else {
+				weights[i] = int64(payout.Points)
+			}
+		}
+		for i, share := range total.Allocate(weights) {
+			payouts[i].Payout = share
+		}
+	}
+
+	return payouts
+}
+
+func CreateTipPool(pool *TipPool) (*TipPool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt := time.Now()
+	res, err := tx.Exec("INSERT INTO TipPools (name, method, created_at) VALUES (?, ?, ?)", pool.Name, pool.Method, createdAt)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		if strings.Contains(err.Error(), "Duplicate") {
+			return nil, fmt.Errorf("tip pool '%s' already exists", pool.Name)
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	for _, role := range pool.Roles {
+		if _, err := tx.Exec("INSERT INTO TipPoolRoles (pool_id, role, percent) VALUES (?, ?, ?)", id, role.Role, role.Percent); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			return nil, err
+		}
+	}
+
+	for _, member := range pool.Members {
+		if _, err := tx.Exec("INSERT INTO TipPoolMembers (pool_id, user_id, points) VALUES (?, ?, ?)", id, member.UserID, member.Points); err != nil {
+			if err1 := tx.Rollback(); err1 != nil {
+				return nil, fmt.Errorf("%v %v", err1, err)
+			}
+			if strings.Contains(err.Error(), "foreign key") {
+				return nil, fmt.Errorf("user %d not found", member.UserID)
+			}
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	created := *pool
+	created.ID = id
+	created.CreatedAt = createdAt
+	return &created, nil
+}
+
+func GetTipPools() ([]TipPool, error) {
+	rows, err := DB.Query("SELECT id, name, method, created_at FROM TipPools ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pools []TipPool
+	for rows.Next() {
+		var pool TipPool
+		if err := rows.Scan(&pool.ID, &pool.Name, &pool.Method, &pool.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tip pool: %w", err)
+		}
+		pools = append(pools, pool)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pools, nil
+}
+
+// GetTipPoolById returns the pool with its role percentages and members.
+func GetTipPoolById(id int64) (*TipPool, error) {
+	pool := &TipPool{}
+	err := DB.QueryRow("SELECT id, name, method, created_at FROM TipPools WHERE id = ?", id).Scan(&pool.ID, &pool.Name, &pool.Method, &pool.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("tip pool not found")
+		}
+		return nil, err
+	}
+
+	rows, err := DB.Query("SELECT role, percent FROM TipPoolRoles WHERE pool_id = ? ORDER BY role", id)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var role TipPoolRole
+		if err := rows.Scan(&role.Role, &role.Percent); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan tip pool role: %w", err)
+		}
+		pool.Roles = append(pool.Roles, role)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query("SELECT user_id, points FROM TipPoolMembers WHERE pool_id = ? ORDER BY user_id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var member TipPoolMember
+		if err := rows.Scan(&member.UserID, &member.Points); err != nil {
+			return nil, fmt.Errorf("failed to scan tip pool member: %w", err)
+		}
+		pool.Members = append(pool.Members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+func DeleteTipPool(id int64) error {
+	res, err := DB.Exec("DELETE FROM TipPools WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("tip pool not found")
+	}
+	return nil
+}
+
+// GetTipPayoutReport distributes the tips of payments collected between from and to (inclusive
+// dates) with the pool. Fully refunded payments are left out; partial refunds keep their tip.
+func GetTipPayoutReport(pool *TipPool, from time.Time, to time.Time) (*TipPayoutReport, error) {
+	end := to.AddDate(0, 0, 1)
+	payments, err := getTippedPayments(from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	worked, err := GetHoursWorked(from, end, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	report := &TipPayoutReport{
+		Pool:    *pool,
+		From:    from.Format(time.DateOnly),
+		To:      to.Format(time.DateOnly),
+		Payouts: CalculateTipPayouts(pool, payments, worked),
+	}
+	for _, payment := range payments {
+		report.Tips += payment.Tip
+	}
+	for _, payout := range report.Payouts {
+		report.Distributed += payout.Payout
+	}
+	report.Undistributed = report.Tips - report.Distributed
+
+	if err := fillTipPayoutNames(report.Payouts); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// getTippedPayments loads the tipped payments created in [from, end) with the chefs who started or
+// completed the order items they pay for.
+func getTippedPayments(from time.Time, end time.Time) ([]TippedPayment, error) {
+	rows, err := DB.Query("SELECT id, tip, cashier_id FROM Payments WHERE status IN ('accepted', 'partially_refunded') AND tip > 0 AND created_at >= ? AND created_at < ? ORDER BY id", from, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var payments []TippedPayment
+	byId := make(map[int64]int)
+	for rows.Next() {
+		var payment TippedPayment
+		if err := rows.Scan(&payment.PaymentID, &payment.Tip, &payment.CashierID); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+		byId[payment.PaymentID] = len(payments)
+		payments = append(payments, payment)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = DB.Query(`SELECT DISTINCT PaymentItems.payment_id, StatusTransitions.actor_id FROM Payments
+		JOIN PaymentItems ON PaymentItems.payment_id = Payments.id
+		JOIN StatusTransitions ON StatusTransitions.entity = 'order_item' AND StatusTransitions.entity_id = PaymentItems.order_item_id
+		WHERE Payments.status IN ('accepted', 'partially_refunded') AND Payments.tip > 0 AND Payments.created_at >= ? AND Payments.created_at < ?
+		AND StatusTransitions.to_status IN ('preparing', 'completed') AND StatusTransitions.actor_id IS NOT NULL
+		ORDER BY PaymentItems.payment_id, StatusTransitions.actor_id`, from, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var paymentId, chefId int64
+		if err := rows.Scan(&paymentId, &chefId); err != nil {
+			return nil, fmt.Errorf("failed to scan chef: %w", err)
+		}
+		if i, ok := byId[paymentId]; ok {
+			payments[i].ChefIDs = append(payments[i].ChefIDs, chefId)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return payments, nil
+}
+
+func fillTipPayoutNames(payouts []TipPayout) error {
+	if len(payouts) == 0 {
+		return nil
+	}
+
+	query := "SELECT id, name FROM Users WHERE id IN ("
+	args := make([]any, len(payouts))
+	for i, payout := range payouts {
+		query += "?,"
+		args[i] = payout.UserID
+	}
+	query = query[:len(query)-1] + ")"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		names[id] = name
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range payouts {
+		payouts[i].Name = names[payouts[i].UserID]
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"github.com/gqvz/mvc/pkg/money"
+	"testing"
+	"time"
+)
+
+var tippedPayments = []TippedPayment{
+	{PaymentID: 1, Tip: 1000, CashierID: 1, ChefIDs: []int64{2, 3}},
+	{PaymentID: 2, Tip: 500, CashierID: 1, ChefIDs: []int64{2}},
+	{PaymentID: 3, Tip: 1, CashierID: 4},
+	{PaymentID: 4, Tip: 0, CashierID: 5, ChefIDs: []int64{6}},
+}
+
+func TestCalculateTipPayoutsAttribution(t *testing.T) {
+	payouts := CalculateTipPayouts(&TipPool{Method: HoursTipPool}, tippedPayments, nil)
+
+	expected := []TipPayout{
+		{UserID: 1, Roles: Cashier, Payments: 2, Attributed: 584},
+		{UserID: 2, Roles: Chef, Payments: 2, Attributed: 583},
+		{UserID: 3, Roles: Chef, Payments: 1, Attributed: 333},
+		{UserID: 4, Roles: Cashier, Payments: 1, Attributed: 1},
+	}
+	if len(payouts) != len(expected) {
+		t.Fatalf("Expected %d payouts, got %+v", len(expected), payouts)
+	}
+	for i, payout := range payouts {
+		if payout != expected[i] {
+			t.Errorf("Payout %d = %+v, want %+v", i, payout, expected[i])
+		}
+	}
+}
+
+func TestCalculateTipPayouts(t *testing.T) {
+	worked := map[int64]time.Duration{1: 4 * time.Hour, 2: 2 * time.Hour, 3: 2 * time.Hour}
+	tests := []struct {
+		name string
+		pool TipPool
+		want map[int64]money.Money
+	}{
+		{
+			name: "role",
+			pool: TipPool{Method: RoleTipPool, Roles: []TipPoolRole{{Role: Cashier, Percent: 40_000}, {Role: Chef, Percent: 60_000}}},
+			want: map[int64]money.Money{1: 300, 2: 451, 3: 450, 4: 300},
+		},
+		{
+			name: "hours",
+			pool: TipPool{Method: HoursTipPool},
+			want: map[int64]money.Money{1: 751, 2: 375, 3: 375, 4: 0},
+		},
+		{
+			name: "points",
+			pool: TipPool{Method: PointsTipPool, Members: []TipPoolMember{{UserID: 2, Points: 3}, {UserID: 3, Points: 1}, {UserID: 9, Points: 10}}},
+			want: map[int64]money.Money{1: 0, 2: 1126, 3: 375, 4: 0},
+		},
+	}
+
+	for _, test := range tests {
+		payouts := CalculateTipPayouts(&test.pool, tippedPayments, worked)
+		distributed := money.Money(0)
+		for _, payout := range payouts {
+			if payout.Payout != test.want[payout.UserID] {
+				t.Errorf("%s: user %d got %s, want %s", test.name, payout.UserID, payout.Payout, test.want[payout.UserID])
+			}
+			distributed += payout.Payout
+		}
+		if test.pool.Method != PointsTipPool && distributed != 1501 {
+			t.Errorf("%s: distributed %s, want 15.01", test.name, distributed)
+		}
+	}
+}
+
+func TestCalculateTipPayoutsRoleWithoutStaff(t *testing.T) {
+	pool := &TipPool{Method: RoleTipPool, Roles: []TipPoolRole{{Role: Cashier, Percent: 30_000}, {Role: Chef, Percent: 70_000}}}
+	payouts := CalculateTipPayouts(pool, []TippedPayment{{PaymentID: 1, Tip: 999, CashierID: 1}}, nil)
+
+	if len(payouts) != 1 || payouts[0].Payout != 999 {
+		t.Errorf("Expected the cashier to receive the chefs' share too, got %+v", payouts)
+	}
+}
+
+func TestTipPoolValidate(t *testing.T) {
+	valid := []TipPool{
+		{Method: HoursTipPool},
+		{Method: RoleTipPool, Roles: []TipPoolRole{{Role: Cashier, Percent: 25_000}, {Role: Chef, Percent: 75_000}}},
+		{Method: PointsTipPool, Members: []TipPoolMember{{UserID: 1, Points: 2}}},
+	}
+	for _, pool := range valid {
+		if err := pool.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", pool, err)
+		}
+	}
+
+	invalid := []TipPool{
+		{Method: "equal"},
+		{Method: RoleTipPool},
+		{Method: RoleTipPool, Roles: []TipPoolRole{{Role: Cashier, Percent: 50_000}}},
+		{Method: RoleTipPool, Roles: []TipPoolRole{{Role: Admin, Percent: 100_000}}},
+		{Method: RoleTipPool, Roles: []TipPoolRole{{Role: Chef, Percent: 50_000}, {Role: Chef, Percent: 50_000}}},
+		{Method: PointsTipPool},
+		{Method: PointsTipPool, Members: []TipPoolMember{{UserID: 1, Points: 0}}},
+		{Method: PointsTipPool, Members: []TipPoolMember{{UserID: 1, Points: 1}, {UserID: 1, Points: 2}}},
+	}
+	for _, pool := range invalid {
+		if err := pool.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", pool)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TransferOrder moves an open order to a free or reserved table, for parties that move. The table
+// they leave needs cleaning and guests who scanned its QR code follow the order to the new table.
+func TransferOrder(orderId int64, tableId int64, actorId int64) (*Order, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = transferOrder(tx, orderId, tableId, actorId)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetOrderById(orderId, 0)
+}
+
+func transferOrder(tx *sql.Tx, orderId int64, tableId int64, actorId int64) error {
+	order, err := lockOpenOrder(tx, orderId)
+	if err != nil {
+		return err
+	}
+	if order.Type != DineIn {
+		return fmt.Errorf("order %d is not a dine-in order", orderId)
+	}
+	if order.TableID == tableId {
+		return fmt.Errorf("order is already at table %d", order.TableNumber)
+	}
+
+	var tableNumber int
+	var tableStatus TableStatus
+	if err := tx.QueryRow("SELECT number, status FROM DiningTables WHERE id = ? FOR UPDATE", tableId).Scan(&tableNumber, &tableStatus); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("table not found")
+		}
+		return err
+	}
+	if tableStatus != TableFree && tableStatus != TableReserved {
+		return fmt.Errorf("table %d is not free", tableNumber)
+	}
+
+	if _, err := tx.Exec("UPDATE Orders SET table_id = ?, table_number = ? WHERE id = ?", tableId, tableNumber, orderId); err != nil {
+		return err
+	}
+	if err := moveTableGuests(tx, order.TableID, tableId); err != nil {
+		return err
+	}
+	if err := leaveTable(tx, order.TableID, actorId); err != nil {
+		return err
+	}
+	if err := changeStatus(tx, TableEntity, tableId, string(TableOccupied), actorId); err != nil {
+		return err
+	}
+	// tables pushed together for the order's reservation are left behind too
+	if err := clearTable(tx, orderId, actorId); err != nil {
+		return err
+	}
+
+	return recordAudit(tx, OrderEntity, orderId, TransferAction, actorId, map[string]any{
+		"from_table_id": order.TableID,
+		"from_table":    order.TableNumber,
+		"to_table_id":   tableId,
+		"to_table":      tableNumber,
+	})
+}
+
+// MergeOrders moves the items, payments and participants of one open order into another, for
+// tables that join. The emptied order is closed and its table needs cleaning.
+func MergeOrders(orderId int64, sourceId int64, actorId int64) (*Order, error) {
+	if orderId == sourceId {
+		return nil, fmt.Errorf("cannot merge an order into itself")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = mergeOrders(tx, orderId, sourceId, actorId)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetOrderById(orderId, 0)
+}
+
+func mergeOrders(tx *sql.Tx, orderId int64, sourceId int64, actorId int64) error {
+	// lock in id order so two merges of the same orders cannot deadlock
+	first, second := min(orderId, sourceId), max(orderId, sourceId)
+	locked := map[int64]*Order{}
+	for _, id := range []int64{first, second} {
+		order, err := lockOpenOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Type != DineIn {
+			return fmt.Errorf("order %d is not a dine-in order", id)
+		}
+		locked[id] = order
+	}
+	order, source := locked[orderId], locked[sourceId]
+
+	statements := []struct {
+		query string
+		args  []any
+	}{
+		{"UPDATE OrderItems SET order_id = ? WHERE order_id = ?", []any{orderId, sourceId}},
+		{"UPDATE Payments SET order_id = ? WHERE order_id = ?", []any{orderId, sourceId}},
+		// participants stay on the source order too so its history still shows who was there
+		{`INSERT INTO OrderParticipants (order_id, user_id, joined_at)
+			SELECT ?, user_id, joined_at FROM OrderParticipants WHERE order_id = ?
+			ON DUPLICATE KEY UPDATE joined_at = LEAST(OrderParticipants.joined_at, VALUES(joined_at))`, []any{orderId, sourceId}},
+		{"UPDATE Orders SET guests = guests + ? WHERE id = ?", []any{source.Guests, orderId}},
+		{"UPDATE Orders SET join_code = NULL WHERE id = ?", []any{sourceId}},
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+			return err
+		}
+	}
+
+	if err := changeStatus(tx, OrderEntity, sourceId, string(Closed), actorId); err != nil {
+		return err
+	}
+	if source.TableID != order.TableID {
+		if err := moveTableGuests(tx, source.TableID, order.TableID); err != nil {
+			return err
+		}
+	}
+	if err := clearTable(tx, sourceId, actorId); err != nil {
+		return err
+	}
+
+	if err := recordAudit(tx, OrderEntity, orderId, MergeAction, actorId, map[string]any{
+		"merged_order_id": sourceId,
+		"merged_table_id": source.TableID,
+		"merged_table":    source.TableNumber,
+	}); err != nil {
+		return err
+	}
+	return recordAudit(tx, OrderEntity, sourceId, MergedIntoAction, actorId, map[string]any{
+		"order_id": orderId,
+		"table_id": order.TableID,
+		"table":    order.TableNumber,
+	})
+}
+
+func lockOpenOrder(tx *sql.Tx, orderId int64) (*Order, error) {
+	var order Order
+	if err := scanOrder(tx.QueryRow("SELECT "+orderColumns+" FROM Orders WHERE id = ? FOR UPDATE", orderId), &order); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("order not found")
+		}
+		return nil, err
+	}
+	if order.Status != Open {
+		return nil, fmt.Errorf("order %d is closed", orderId)
+	}
+	return &order, nil
+}
+
+// leaveTable marks a table the party left as needing cleaning.
+func leaveTable(tx *sql.Tx, tableId int64, actorId int64) error {
+	var status TableStatus
+	if err := tx.QueryRow("SELECT status FROM DiningTables WHERE id = ? FOR UPDATE", tableId).Scan(&status); err != nil {
+		return err
+	}
+	if status != TableOccupied {
+		return nil
+	}
+	return changeStatus(tx, TableEntity, tableId, string(TableNeedsCleaning), actorId)
+}
+
+// moveTableGuests points the live guest sessions of one table at another.
+func moveTableGuests(tx *sql.Tx, fromTableId int64, toTableId int64) error {
+	_, err := tx.Exec("UPDATE GuestSessions SET table_id = ? WHERE table_id = ? AND converted_at IS NULL AND expires_at > ?", toTableId, fromTableId, time.Now())
+	return err
+}
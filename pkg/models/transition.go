@@ -0,0 +1,143 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// statusTransitions lists the statuses each status may move to. Anything not listed is rejected,
+// and statuses without an entry are final.
+var statusTransitions = map[StatusEntity]map[string][]string{
+	PaymentEntity: {
+		string(Processing):        {string(Accepted), string(Declined), string(Voided)},
+		string(Accepted):          {string(PartiallyRefunded), string(Refunded)},
+		string(PartiallyRefunded): {string(Refunded)},
+	},
+	OrderItemEntity: {
+		// scheduled items are released by the scheduler, or early by the kitchen
+		string(ItemScheduled): {string(ItemPending)},
+		string(ItemPending):   {string(Preparing)},
+		string(Preparing):     {string(Completed)},
+	},
+	OrderEntity: {
+		string(Open): {string(Closed)},
+	},
+	TableEntity: {
+		string(TableFree):          {string(TableOccupied), string(TableReserved)},
+		string(TableReserved):      {string(TableOccupied), string(TableFree)},
+		string(TableOccupied):      {string(TableNeedsCleaning), string(TableFree)},
+		string(TableNeedsCleaning): {string(TableFree)},
+	},
+	ReservationEntity: {
+		string(ReservationConfirmed): {string(ReservationSeated), string(ReservationNoShow), string(ReservationCancelled)},
+		// a party marked as a no-show who turns up late can still be seated
+		string(ReservationNoShow): {string(ReservationSeated)},
+	},
+	WaitlistEntity: {
+		string(WaitlistWaiting): {string(WaitlistNotified), string(WaitlistSeated), string(WaitlistCancelled)},
+		// a party that does not come back when told goes back to waiting for the next table
+		string(WaitlistNotified): {string(WaitlistWaiting), string(WaitlistSeated), string(WaitlistCancelled)},
+	},
+}
+
+var statusTables = map[StatusEntity]string{
+	PaymentEntity:     "Payments",
+	OrderItemEntity:   "OrderItems",
+	OrderEntity:       "Orders",
+	TableEntity:       "DiningTables",
+	ReservationEntity: "Reservations",
+	WaitlistEntity:    "Waitlist",
+}
+
+// CanTransition reports whether an entity may move from one status to another. Staying in the
+// same status is always allowed and is not recorded as a transition.
+func CanTransition(entity StatusEntity, from string, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, allowed := range statusTransitions[entity][from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// changeStatus locks the row, checks the transition, updates the status and records it in the
+// history. actorId is 0 for changes the system makes on its own, such as provider results.
+func changeStatus(tx *sql.Tx, entity StatusEntity, id int64, to string, actorId int64) error {
+	table := statusTables[entity]
+	name := strings.ReplaceAll(string(entity), "_", " ")
+
+	var from string
+	if err := tx.QueryRow("SELECT status FROM "+table+" WHERE id = ? FOR UPDATE", id).Scan(&from); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("%s not found", name)
+		}
+		return err
+	}
+
+	if !CanTransition(entity, from, to) {
+		return fmt.Errorf("invalid status transition for %s from %s to %s", name, from, to)
+	}
+	if from == to {
+		return nil
+	}
+
+	if _, err := tx.Exec("UPDATE "+table+" SET status = ? WHERE id = ?", to, id); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec("INSERT INTO StatusTransitions (entity, entity_id, from_status, to_status, actor_id, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		entity, id, from, to, nullableId(actorId), time.Now())
+	return err
+}
+
+// updateStatus runs changeStatus in its own transaction, followed by any extra statements that
+// have to change together with the status.
+func updateStatus(entity StatusEntity, id int64, to string, actorId int64, extra func(tx *sql.Tx) error) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = changeStatus(tx, entity, id, to, actorId)
+	if err == nil && extra != nil {
+		err = extra(tx)
+	}
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return fmt.Errorf("%v %v", err1, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func GetStatusTransitions(entity StatusEntity, entityId int64) ([]StatusTransition, error) {
+	rows, err := DB.Query("SELECT id, entity, entity_id, from_status, to_status, actor_id, created_at FROM StatusTransitions WHERE entity = ? AND entity_id = ? ORDER BY id", entity, entityId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transitions []StatusTransition
+	for rows.Next() {
+		var transition StatusTransition
+		var actorId sql.NullInt64
+		if err := rows.Scan(&transition.ID, &transition.Entity, &transition.EntityID, &transition.From, &transition.To, &actorId, &transition.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status transition: %w", err)
+		}
+		transition.ActorID = actorId.Int64
+		transitions = append(transitions, transition)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
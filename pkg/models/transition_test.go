@@ -0,0 +1,44 @@
+package models
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		entity StatusEntity
+		from   string
+		to     string
+		want   bool
+	}{
+		{PaymentEntity, string(Processing), string(Accepted), true},
+		{PaymentEntity, string(Processing), string(Voided), true},
+		{PaymentEntity, string(Accepted), string(Processing), false},
+		{PaymentEntity, string(Accepted), string(Refunded), true},
+		{PaymentEntity, string(PartiallyRefunded), string(PartiallyRefunded), true},
+		{PaymentEntity, string(Refunded), string(Accepted), false},
+		{PaymentEntity, string(Declined), string(Accepted), false},
+		{OrderItemEntity, string(ItemPending), string(Preparing), true},
+		{OrderItemEntity, string(ItemPending), string(Completed), false},
+		{OrderItemEntity, string(Completed), string(Preparing), false},
+		{OrderItemEntity, string(ItemScheduled), string(ItemPending), true},
+		{OrderItemEntity, string(ItemScheduled), string(Preparing), false},
+		{OrderEntity, string(Open), string(Closed), true},
+		{OrderEntity, string(Closed), string(Open), false},
+		{TableEntity, string(TableFree), string(TableOccupied), true},
+		{TableEntity, string(TableOccupied), string(TableNeedsCleaning), true},
+		{TableEntity, string(TableNeedsCleaning), string(TableOccupied), false},
+		{TableEntity, string(TableReserved), string(TableNeedsCleaning), false},
+		{ReservationEntity, string(ReservationConfirmed), string(ReservationSeated), true},
+		{ReservationEntity, string(ReservationNoShow), string(ReservationSeated), true},
+		{ReservationEntity, string(ReservationCancelled), string(ReservationConfirmed), false},
+		{ReservationEntity, string(ReservationSeated), string(ReservationNoShow), false},
+		{WaitlistEntity, string(WaitlistWaiting), string(WaitlistNotified), true},
+		{WaitlistEntity, string(WaitlistNotified), string(WaitlistWaiting), true},
+		{WaitlistEntity, string(WaitlistSeated), string(WaitlistWaiting), false},
+	}
+
+	for _, test := range tests {
+		if got := CanTransition(test.entity, test.from, test.to); got != test.want {
+			t.Errorf("CanTransition(%s, %s, %s) = %v, want %v", test.entity, test.from, test.to, got, test.want)
+		}
+	}
+}
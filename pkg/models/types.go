@@ -1,14 +1,20 @@
 package models
 
-import "time"
+import (
+	"github.com/gqvz/mvc/pkg/money"
+	"time"
+)
 
 type Role byte // @name Role
 
 const (
-	Any      Role              = iota // @name Any
-	Customer                          // @name Customer
-	Chef                              // @name Chef
-	Admin    = Customer | Chef        // @name Admin
+	Any      Role = iota                      // @name Any
+	Customer Role = 1                         // @name Customer
+	Chef     Role = 2                         // @name Chef
+	Cashier  Role = 4                         // @name Cashier
+	Admin         = Customer | Chef | Cashier // @name Admin
+	// Guest is a walk-in at a table who has not registered. It is never combined with other roles.
+	Guest Role = 8 // @name Guest
 )
 
 func (r Role) HasFlag(flag Role) bool {
@@ -16,8 +22,10 @@ func (r Role) HasFlag(flag Role) bool {
 }
 
 type Tag struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name"`
+	ID                int64         `json:"id"`
+	Name              string        `json:"name"`
+	TaxCategoryID     int64         `json:"tax_category_id,omitempty"`
+	LoyaltyMultiplier money.Percent `json:"loyalty_multiplier"`
 } // @name Tag
 
 type UserSeenStatus string // @name UserSeenStatus
@@ -48,18 +56,143 @@ type PaymentStatus string // @name PaymentStatus
 const (
 	Processing PaymentStatus = "processing"
 	Accepted   PaymentStatus = "accepted"
+	Declined   PaymentStatus = "declined"
+
+	Refunded          PaymentStatus = "refunded"
+	PartiallyRefunded PaymentStatus = "partially_refunded"
+	Voided            PaymentStatus = "voided"
+)
+
+type PaymentMethod string // @name PaymentMethod
+
+const (
+	CashPayment  PaymentMethod = "cash"
+	CardPayment  PaymentMethod = "card"
+	OtherPayment PaymentMethod = "other"
 )
 
 type Payment struct {
-	ID        int64         `json:"id"`
-	OrderID   int64         `json:"order_id"`
-	Subtotal  float64       `json:"subtotal"`
-	Tip       float64       `json:"tip"`
-	Total     float64       `json:"total"`
-	Status    PaymentStatus `json:"status"`
-	CashierID int64         `json:"cashier_id"`
+	ID               int64         `json:"id"`
+	OrderID          int64         `json:"order_id"`
+	Subtotal         money.Money   `json:"subtotal"`
+	Discount         money.Money   `json:"discount"`
+	ServiceCharge    money.Money   `json:"service_charge"`
+	DeliveryFee      money.Money   `json:"delivery_fee"`
+	Tax              money.Money   `json:"tax"`
+	TaxIncluded      money.Money   `json:"tax_included"`
+	Tip              money.Money   `json:"tip"`
+	Total            money.Money   `json:"total"`
+	Currency         string        `json:"currency"`
+	Status           PaymentStatus `json:"status"`
+	CashierID        int64         `json:"cashier_id"`
+	PromoCodeID      int64         `json:"promo_code_id"`
+	DiscountReason   string        `json:"discount_reason"`
+	DiscountedBy     int64         `json:"discounted_by"`
+	Taxes            []PaymentTax  `json:"taxes,omitempty"`
+	SplitMode        SplitMode     `json:"split_mode"`
+	SplitParts       int           `json:"split_parts,omitempty"`
+	Items            []PaymentItem `json:"items,omitempty"`
+	Provider         string        `json:"provider,omitempty"`
+	ProviderRef      string        `json:"provider_ref,omitempty"`
+	DeclineReason    string        `json:"decline_reason,omitempty"`
+	Refunded         money.Money   `json:"refunded"`
+	CreatedAt        time.Time     `json:"created_at"`
+	Method           PaymentMethod `json:"method"`
+	DrawerSessionID  int64         `json:"drawer_session_id,omitempty"`
+	GiftCardID       int64         `json:"gift_card_id,omitempty"`
+	GiftCardAmount   money.Money   `json:"gift_card_amount"`
+	GiftCardRefunded money.Money   `json:"-"`
+	LoyaltyPoints    int64         `json:"loyalty_points,omitempty"`
+	LoyaltyDiscount  money.Money   `json:"loyalty_discount"`
+	LoyaltyEarned    int64         `json:"loyalty_earned,omitempty"`
 } // @name Payment
 
+// RefundStatus tracks refunds through the payment provider, which are pending while it is asked
+// for them. Only completed refunds count as refunded.
+type RefundStatus string // @name RefundStatus
+
+const (
+	RefundPending   RefundStatus = "pending"
+	RefundCompleted RefundStatus = "completed"
+	RefundVoided    RefundStatus = "voided"
+)
+
+type Refund struct {
+	ID              int64        `json:"id"`
+	PaymentID       int64        `json:"payment_id"`
+	Amount          money.Money  `json:"amount"`
+	Status          RefundStatus `json:"status"`
+	Reason          string       `json:"reason"`
+	ProviderRef     string       `json:"provider_ref,omitempty"`
+	CreatedBy       int64        `json:"created_by"`
+	CreatedAt       time.Time    `json:"created_at"`
+	DrawerSessionID int64        `json:"drawer_session_id,omitempty"`
+	StoreCredit     bool         `json:"store_credit"`
+	GiftCardID      int64        `json:"gift_card_id,omitempty"`
+	GiftCardAmount  money.Money  `json:"gift_card_amount"`
+} // @name Refund
+
+type RevenueDay struct {
+	Date     string      `json:"date"`
+	Payments int         `json:"payments"`
+	Gross    money.Money `json:"gross"`
+	Tips     money.Money `json:"tips"`
+	Tax      money.Money `json:"tax"`
+	Refunds  money.Money `json:"refunds"`
+	Net      money.Money `json:"net"`
+} // @name RevenueDay
+
+type RevenueReport struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Currency string       `json:"currency"`
+	Total    RevenueDay   `json:"total"`
+	Days     []RevenueDay `json:"days"`
+} // @name RevenueReport
+
+type SplitMode string // @name SplitMode
+
+const (
+	FullSplit   SplitMode = "full"
+	EvenSplit   SplitMode = "even"
+	ItemsSplit  SplitMode = "items"
+	AmountSplit SplitMode = "amount"
+)
+
+// PaymentItem is the part of an order item's amount (before discounts and taxes) covered by a payment.
+type PaymentItem struct {
+	OrderItemID int64       `json:"order_item_id"`
+	Amount      money.Money `json:"amount"`
+} // @name PaymentItem
+
+type OrderBalance struct {
+	OrderID          int64       `json:"order_id"`
+	Subtotal         money.Money `json:"subtotal"`
+	Pending          money.Money `json:"pending"`
+	Paid             money.Money `json:"paid"`
+	PaidTotal        money.Money `json:"paid_total"`
+	Outstanding      money.Money `json:"outstanding"`
+	OutstandingTotal money.Money `json:"outstanding_total"`
+	Settled          bool        `json:"settled"`
+} // @name OrderBalance
+
+type PaymentTax struct {
+	TaxCategoryID int64         `json:"tax_category_id"`
+	Name          string        `json:"name"`
+	Rate          money.Percent `json:"rate"`
+	Inclusive     bool          `json:"inclusive"`
+	Taxable       money.Money   `json:"taxable"`
+	Amount        money.Money   `json:"amount"`
+} // @name PaymentTax
+
+type TaxCategory struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	Rate      money.Percent `json:"rate"`
+	Inclusive bool          `json:"inclusive"`
+	IsDefault bool          `json:"is_default"`
+} // @name TaxCategory
+
 type ItemStatus string // @name ItemStatus
 
 const (
@@ -67,15 +200,20 @@ const (
 	Completed ItemStatus = "completed"
 
 	ItemPending ItemStatus = "pending"
+	// ItemScheduled items belong to orders scheduled ahead and are held out of the kitchen queue
+	ItemScheduled ItemStatus = "scheduled"
 ) // @name ItemStatus
 
 type OrderItem struct {
-	ID                 int64      `json:"id"`
-	OrderID            int64      `json:"order_id"`
-	ItemID             int64      `json:"item_id"`
-	Quantity           int        `json:"quantity"`
-	CustomInstructions string     `json:"custom_instructions"`
-	Status             ItemStatus `json:"status"`
+	ID                 int64       `json:"id"`
+	OrderID            int64       `json:"order_id"`
+	ItemID             int64       `json:"item_id"`
+	Quantity           int         `json:"quantity"`
+	UnitPrice          money.Money `json:"unit_price"`
+	CustomInstructions string      `json:"custom_instructions"`
+	Status             ItemStatus  `json:"status"`
+	AddedBy            int64       `json:"added_by,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
 } // @name OrderItem
 
 type OrderStatus string // @name OrderStatus
@@ -85,20 +223,482 @@ const (
 	Closed OrderStatus = "closed"
 )
 
+type OrderType string // @name OrderType
+
+const (
+	DineIn   OrderType = "dine_in"
+	Takeaway OrderType = "takeaway"
+	Delivery OrderType = "delivery"
+)
+
 type Order struct {
-	ID          int64       `json:"id"`
-	CustomerID  int64       `json:"customer_id"`
-	Status      OrderStatus `json:"status"`
-	TableNumber int         `json:"table_number"`
-	OrderedAt   time.Time   `json:"ordered_at"`
+	ID              int64       `json:"id"`
+	CustomerID      int64       `json:"customer_id"`
+	Status          OrderStatus `json:"status"`
+	Type            OrderType   `json:"type"`
+	TableID         int64       `json:"table_id,omitempty"`
+	TableNumber     int         `json:"table_number,omitempty"`
+	Guests          int         `json:"guests"`
+	PickupAt        *time.Time  `json:"pickup_at,omitempty"`
+	DeliveryAddress string      `json:"delivery_address,omitempty"`
+	DeliveryFee     money.Money `json:"delivery_fee"`
+	OrderedAt       time.Time   `json:"ordered_at"`
+	BillRequestedAt *time.Time  `json:"bill_requested_at,omitempty"`
+	JoinCode        string      `json:"join_code,omitempty"`
 } // @name Order
 
 type Item struct {
-	ID          int64   `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Tags        []Tag   `json:"tags"`
-	ImageURL    string  `json:"image_url"`
-	Available   bool    `json:"available"`
+	ID            int64       `json:"id"`
+	Name          string      `json:"name"`
+	Description   string      `json:"description"`
+	Price         money.Money `json:"price"`
+	Tags          []Tag       `json:"tags"`
+	ImageURL      string      `json:"image_url"`
+	Available     bool        `json:"available"`
+	TaxCategoryID int64       `json:"tax_category_id"`
 } // @name Item
+
+type PriceSchedule struct {
+	ID          int64       `json:"id"`
+	ItemID      int64       `json:"item_id"`
+	Price       money.Money `json:"price"`
+	EffectiveAt time.Time   `json:"effective_at"`
+	Applied     bool        `json:"applied"`
+	CreatedBy   int64       `json:"created_by"`
+} // @name PriceSchedule
+
+type Discount struct {
+	ID        int64          `json:"id"`
+	Name      string         `json:"name"`
+	Percent   money.Percent  `json:"percent"`
+	ItemID    int64          `json:"item_id"`
+	TagID     int64          `json:"tag_id"`
+	Days      []time.Weekday `json:"days"`
+	StartTime string         `json:"start_time"`
+	EndTime   string         `json:"end_time"`
+	StartsAt  *time.Time     `json:"starts_at"`
+	EndsAt    *time.Time     `json:"ends_at"`
+	Active    bool           `json:"active"`
+} // @name Discount
+
+type ItemPrice struct {
+	ListPrice      money.Money `json:"list_price"`
+	EffectivePrice money.Money `json:"effective_price"`
+	DiscountID     int64       `json:"discount_id"`
+} // @name ItemPrice
+
+type PromoKind string // @name PromoKind
+
+const (
+	PercentagePromo PromoKind = "percentage"
+	FixedPromo      PromoKind = "fixed"
+)
+
+type PromoCode struct {
+	ID             int64         `json:"id"`
+	Code           string        `json:"code"`
+	Kind           PromoKind     `json:"kind"`
+	Percent        money.Percent `json:"percent,omitempty"`
+	Amount         money.Money   `json:"amount,omitempty"`
+	MinSpend       money.Money   `json:"min_spend"`
+	StartsAt       *time.Time    `json:"starts_at"`
+	EndsAt         *time.Time    `json:"ends_at"`
+	MaxUses        int           `json:"max_uses"`
+	MaxUsesPerUser int           `json:"max_uses_per_user"`
+	ItemID         int64         `json:"item_id"`
+	TagID          int64         `json:"tag_id"`
+	Active         bool          `json:"active"`
+	Uses           int           `json:"uses"`
+} // @name PromoCode
+
+type WebhookEvent struct {
+	ID          int64      `json:"id"`
+	Provider    string     `json:"provider"`
+	EventID     string     `json:"event_id"`
+	Type        string     `json:"type"`
+	Payload     string     `json:"payload"`
+	ReceivedAt  time.Time  `json:"received_at"`
+	ProcessedAt *time.Time `json:"processed_at"`
+	Error       string     `json:"error,omitempty"`
+} // @name WebhookEvent
+
+type StatusEntity string // @name StatusEntity
+
+const (
+	PaymentEntity     StatusEntity = "payment"
+	OrderItemEntity   StatusEntity = "order_item"
+	OrderEntity       StatusEntity = "order"
+	TableEntity       StatusEntity = "table"
+	ReservationEntity StatusEntity = "reservation"
+	WaitlistEntity    StatusEntity = "waitlist"
+)
+
+type StatusTransition struct {
+	ID        int64        `json:"id"`
+	Entity    StatusEntity `json:"entity"`
+	EntityID  int64        `json:"entity_id"`
+	From      string       `json:"from"`
+	To        string       `json:"to"`
+	ActorID   int64        `json:"actor_id,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+} // @name StatusTransition
+
+type IdempotencyKey struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Key         string    `json:"key"`
+	RequestHash string    `json:"request_hash"`
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+} // @name IdempotencyKey
+
+type Receipt struct {
+	Number    int64     `json:"number"`
+	PaymentID int64     `json:"payment_id"`
+	IssuedAt  time.Time `json:"issued_at"`
+} // @name Receipt
+
+type DrawerSession struct {
+	ID           int64        `json:"id"`
+	CashierID    int64        `json:"cashier_id"`
+	OpenedAt     time.Time    `json:"opened_at"`
+	ClosedAt     *time.Time   `json:"closed_at"`
+	OpeningFloat money.Money  `json:"opening_float"`
+	ExpectedCash *money.Money `json:"expected_cash"`
+	CountedCash  *money.Money `json:"counted_cash"`
+	Variance     *money.Money `json:"variance"`
+	Notes        string       `json:"notes,omitempty"`
+} // @name DrawerSession
+
+type MethodTotal struct {
+	Method   PaymentMethod `json:"method"`
+	Payments int           `json:"payments"`
+	Amount   money.Money   `json:"amount"`
+	Tips     money.Money   `json:"tips"`
+	Refunds  money.Money   `json:"refunds"`
+} // @name MethodTotal
+
+type ZReport struct {
+	Session       DrawerSession `json:"session"`
+	Currency      string        `json:"currency"`
+	Payments      int           `json:"payments"`
+	Gross         money.Money   `json:"gross"`
+	Discounts     money.Money   `json:"discounts"`
+	ServiceCharge money.Money   `json:"service_charge"`
+	Tax           money.Money   `json:"tax"`
+	Tips          money.Money   `json:"tips"`
+	Refunds       money.Money   `json:"refunds"`
+	Net           money.Money   `json:"net"`
+	Methods       []MethodTotal `json:"methods"`
+	GiftCards     money.Money   `json:"gift_cards"`
+	GiftCardsSold money.Money   `json:"gift_cards_sold"`
+	ExpectedCash  money.Money   `json:"expected_cash"`
+} // @name ZReport
+
+type Shift struct {
+	ID           int64      `json:"id"`
+	UserID       int64      `json:"user_id"`
+	ClockedInAt  time.Time  `json:"clocked_in_at"`
+	ClockedOutAt *time.Time `json:"clocked_out_at"`
+} // @name Shift
+
+type TipPoolMethod string // @name TipPoolMethod
+
+const (
+	RoleTipPool   TipPoolMethod = "role"
+	HoursTipPool  TipPoolMethod = "hours"
+	PointsTipPool TipPoolMethod = "points"
+)
+
+type TipPoolRole struct {
+	Role    Role          `json:"role"`
+	Percent money.Percent `json:"percent"`
+} // @name TipPoolRole
+
+type TipPoolMember struct {
+	UserID int64 `json:"user_id"`
+	Points int   `json:"points"`
+} // @name TipPoolMember
+
+type TipPool struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Method    TipPoolMethod   `json:"method"`
+	Roles     []TipPoolRole   `json:"roles,omitempty"`
+	Members   []TipPoolMember `json:"members,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+} // @name TipPool
+
+type TippedPayment struct {
+	PaymentID int64
+	Tip       money.Money
+	CashierID int64
+	ChefIDs   []int64
+}
+
+type TipPayout struct {
+	UserID     int64       `json:"user_id"`
+	Name       string      `json:"name"`
+	Roles      Role        `json:"roles"`
+	Payments   int         `json:"payments"`
+	Hours      float64     `json:"hours"`
+	Points     int         `json:"points"`
+	Attributed money.Money `json:"attributed"`
+	Payout     money.Money `json:"payout"`
+} // @name TipPayout
+
+type TipPayoutReport struct {
+	Pool          TipPool     `json:"pool"`
+	From          string      `json:"from"`
+	To            string      `json:"to"`
+	Currency      string      `json:"currency"`
+	Tips          money.Money `json:"tips"`
+	Distributed   money.Money `json:"distributed"`
+	Undistributed money.Money `json:"undistributed"`
+	Payouts       []TipPayout `json:"payouts"`
+} // @name TipPayoutReport
+
+type GiftCardKind string // @name GiftCardKind
+
+const (
+	StandardGiftCard GiftCardKind = "gift_card"
+	StoreCredit      GiftCardKind = "store_credit"
+)
+
+type GiftCard struct {
+	ID         int64        `json:"id"`
+	Code       string       `json:"code"`
+	Kind       GiftCardKind `json:"kind"`
+	Balance    money.Money  `json:"balance"`
+	Currency   string       `json:"currency"`
+	CustomerID int64        `json:"customer_id,omitempty"`
+	IssuedBy   int64        `json:"issued_by,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+} // @name GiftCard
+
+type GiftCardTransactionKind string // @name GiftCardTransactionKind
+
+const (
+	GiftCardIssue    GiftCardTransactionKind = "issue"
+	GiftCardRedeem   GiftCardTransactionKind = "redeem"
+	GiftCardReversal GiftCardTransactionKind = "reversal"
+	GiftCardRefund   GiftCardTransactionKind = "refund"
+)
+
+type GiftCardTransaction struct {
+	ID              int64                   `json:"id"`
+	GiftCardID      int64                   `json:"gift_card_id"`
+	Kind            GiftCardTransactionKind `json:"kind"`
+	Amount          money.Money             `json:"amount"`
+	BalanceAfter    money.Money             `json:"balance_after"`
+	PaymentID       int64                   `json:"payment_id,omitempty"`
+	RefundID        int64                   `json:"refund_id,omitempty"`
+	DrawerSessionID int64                   `json:"drawer_session_id,omitempty"`
+	Method          PaymentMethod           `json:"method,omitempty"`
+	CreatedBy       int64                   `json:"created_by,omitempty"`
+	CreatedAt       time.Time               `json:"created_at"`
+} // @name GiftCardTransaction
+
+type LoyaltyTransactionKind string // @name LoyaltyTransactionKind
+
+const (
+	LoyaltyEarn     LoyaltyTransactionKind = "earn"
+	LoyaltyRedeem   LoyaltyTransactionKind = "redeem"
+	LoyaltyReversal LoyaltyTransactionKind = "reversal"
+	LoyaltyRefund   LoyaltyTransactionKind = "refund"
+)
+
+type LoyaltyTransaction struct {
+	ID           int64                  `json:"id"`
+	UserID       int64                  `json:"user_id"`
+	Kind         LoyaltyTransactionKind `json:"kind"`
+	Points       int64                  `json:"points"`
+	BalanceAfter int64                  `json:"balance_after"`
+	PaymentID    int64                  `json:"payment_id,omitempty"`
+	RefundID     int64                  `json:"refund_id,omitempty"`
+	CreatedBy    int64                  `json:"created_by,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+} // @name LoyaltyTransaction
+
+// LoyaltyLine is the part of a payment one order item accounts for, with the highest loyalty
+// multiplier of the item's tags.
+type LoyaltyLine struct {
+	Amount     money.Money
+	Multiplier money.Percent
+}
+
+type TableStatus string // @name TableStatus
+
+const (
+	TableFree          TableStatus = "free"
+	TableOccupied      TableStatus = "occupied"
+	TableReserved      TableStatus = "reserved"
+	TableNeedsCleaning TableStatus = "needs_cleaning"
+)
+
+type Table struct {
+	ID         int64       `json:"id"`
+	Number     int         `json:"number"`
+	Section    string      `json:"section"`
+	Capacity   int         `json:"capacity"`
+	Combinable bool        `json:"combinable"`
+	Status     TableStatus `json:"status"`
+	QRToken    string      `json:"qr_token"`
+	CreatedAt  time.Time   `json:"created_at"`
+} // @name Table
+
+// FloorPlanTable is a table as the floor staff see it, with the open order seated at it.
+type FloorPlanTable struct {
+	ID              int64       `json:"id"`
+	Number          int         `json:"number"`
+	Capacity        int         `json:"capacity"`
+	Status          TableStatus `json:"status"`
+	OrderID         int64       `json:"order_id,omitempty"`
+	Guests          int         `json:"guests,omitempty"`
+	OrderedAt       *time.Time  `json:"ordered_at,omitempty"`
+	BillRequestedAt *time.Time  `json:"bill_requested_at,omitempty"`
+} // @name FloorPlanTable
+
+type FloorPlanSection struct {
+	Section string           `json:"section"`
+	Tables  []FloorPlanTable `json:"tables"`
+} // @name FloorPlanSection
+
+// GuestSession ties a guest user to the table whose QR code they scanned until it expires or the
+// guest registers.
+type GuestSession struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	TableID     int64      `json:"table_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ConvertedAt *time.Time `json:"converted_at,omitempty"`
+} // @name GuestSession
+
+// OrderParticipant is someone at the table who joined an order, with what they added to it.
+type OrderParticipant struct {
+	UserID   int64       `json:"user_id"`
+	Name     string      `json:"name"`
+	Owner    bool        `json:"owner"`
+	JoinedAt time.Time   `json:"joined_at"`
+	Items    int         `json:"items"`
+	Subtotal money.Money `json:"subtotal"`
+} // @name OrderParticipant
+
+type AuditAction string // @name AuditAction
+
+const (
+	TransferAction   AuditAction = "transfer"
+	MergeAction      AuditAction = "merge"
+	MergedIntoAction AuditAction = "merged_into"
+)
+
+// AuditEntry records a change staff made that is not a status change, such as moving an order to
+// another table.
+type AuditEntry struct {
+	ID        int64          `json:"id"`
+	Entity    StatusEntity   `json:"entity"`
+	EntityID  int64          `json:"entity_id"`
+	Action    AuditAction    `json:"action"`
+	ActorID   int64          `json:"actor_id,omitempty"`
+	Details   map[string]any `json:"details"`
+	CreatedAt time.Time      `json:"created_at"`
+} // @name AuditEntry
+
+type ReservationStatus string // @name ReservationStatus
+
+const (
+	ReservationConfirmed ReservationStatus = "confirmed"
+	ReservationSeated    ReservationStatus = "seated"
+	ReservationNoShow    ReservationStatus = "no_show"
+	ReservationCancelled ReservationStatus = "cancelled"
+)
+
+// ReservedTable is a table a reservation holds. Parties too big for one table hold several.
+type ReservedTable struct {
+	ID       int64 `json:"id"`
+	Number   int   `json:"number"`
+	Capacity int   `json:"capacity"`
+} // @name ReservedTable
+
+type Reservation struct {
+	ID int64 `json:"id"`
+	// UID identifies the reservation in calendar feeds, and for imported ones in the tool they came from.
+	UID       string            `json:"uid"`
+	PartySize int               `json:"party_size"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	Name      string            `json:"name"`
+	Phone     string            `json:"phone"`
+	Email     string            `json:"email"`
+	Notes     string            `json:"notes"`
+	Status    ReservationStatus `json:"status"`
+	Tables    []ReservedTable   `json:"tables"`
+	OrderID   int64             `json:"order_id,omitempty"`
+	CreatedBy int64             `json:"created_by,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+} // @name Reservation
+
+// ReservationSlot is a time a party can be booked for, with the tables they would get.
+type ReservationSlot struct {
+	StartsAt time.Time       `json:"starts_at"`
+	EndsAt   time.Time       `json:"ends_at"`
+	Tables   []ReservedTable `json:"tables"`
+} // @name ReservationSlot
+
+// PickupSlot is a window takeaway and delivery orders can be promised for, with how many more it takes.
+// Remaining is -1 while slots take any number of orders.
+type PickupSlot struct {
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	Orders    int       `json:"orders"`
+	Remaining int       `json:"remaining"`
+} // @name PickupSlot
+
+type ReservationImportResult string // @name ReservationImportResult
+
+const (
+	ImportCreated   ReservationImportResult = "created"
+	ImportDuplicate ReservationImportResult = "duplicate"
+	ImportCancelled ReservationImportResult = "cancelled"
+	ImportSkipped   ReservationImportResult = "skipped"
+	ImportFailed    ReservationImportResult = "failed"
+)
+
+// ReservationImport is what became of one event of an imported calendar.
+type ReservationImport struct {
+	UID           string                  `json:"uid"`
+	Result        ReservationImportResult `json:"result"`
+	ReservationID int64                   `json:"reservation_id,omitempty"`
+	Error         string                  `json:"error,omitempty"`
+} // @name ReservationImport
+
+type WaitlistStatus string // @name WaitlistStatus
+
+const (
+	WaitlistWaiting   WaitlistStatus = "waiting"
+	WaitlistNotified  WaitlistStatus = "notified"
+	WaitlistSeated    WaitlistStatus = "seated"
+	WaitlistCancelled WaitlistStatus = "cancelled"
+)
+
+// WaitlistEntry is a walk-in party waiting for a table. QuotedWait is the wait in minutes the party
+// was told when they joined and EstimatedWait the current estimate while they are still waiting.
+type WaitlistEntry struct {
+	ID            int64          `json:"id"`
+	Name          string         `json:"name"`
+	PartySize     int            `json:"party_size"`
+	Phone         string         `json:"phone"`
+	QuotedWait    int            `json:"quoted_wait"`
+	EstimatedWait *int           `json:"estimated_wait,omitempty"`
+	Status        WaitlistStatus `json:"status"`
+	TableID       int64          `json:"table_id,omitempty"`
+	TableNumber   int            `json:"table_number,omitempty"`
+	OrderID       int64          `json:"order_id,omitempty"`
+	NotifiedAt    *time.Time     `json:"notified_at,omitempty"`
+	SeatedAt      *time.Time     `json:"seated_at,omitempty"`
+	CreatedBy     int64          `json:"created_by,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+} // @name WaitlistEntry
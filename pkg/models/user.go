@@ -33,9 +33,10 @@ func CreateUser(name string, email string, passwordHash string, role Role) (*Use
 	}, nil
 }
 
+// GetUserByEmailOrUsername finds a registered user. Guests have no credentials and are skipped.
 func GetUserByEmailOrUsername(email string, name string) (*User, error) {
 	var user User
-	err := scanUserRow(DB.QueryRow("SELECT id, name, email, password_hash, role FROM Users WHERE email = ? OR name = ? LIMIT 1;", email, name), &user)
+	err := scanUserRow(DB.QueryRow("SELECT id, name, email, password_hash, role FROM Users WHERE (email = ? OR name = ?) AND role & ? = 0 LIMIT 1;", email, name, Guest), &user)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -0,0 +1,413 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"strings"
+	"time"
+)
+
+const waitlistQuery = `SELECT w.id, w.name, w.party_size, w.phone, w.quoted_wait, w.status, w.table_id, t.number, w.order_id, w.notified_at, w.seated_at, w.created_by, w.created_at
+	FROM Waitlist w LEFT JOIN DiningTables t ON t.id = w.table_id`
+
+// quoteStep is what quoted waits are rounded up to, since guests are told "about 20 minutes"
+// rather than "17 minutes".
+const quoteStep = 5 * time.Minute
+
+// waitTable is a table walk-ins could be seated at and how long until it is free.
+type waitTable struct {
+	ID       int64
+	Capacity int
+	FreeIn   time.Duration
+}
+
+// estimateWaits estimates how long each party of the queue, in order, waits for a table. Each
+// party takes the table big enough for it that frees up first, which is then taken for turn before
+// it frees up again for the parties behind. Parties no single table seats get -1.
+func estimateWaits(tables []waitTable, queue []int, turn time.Duration) []time.Duration {
+	tables = append([]waitTable(nil), tables...)
+	waits := make([]time.Duration, len(queue))
+	for i, partySize := range queue {
+		best := -1
+		for j, table := range tables {
+			if table.Capacity < partySize {
+				continue
+			}
+			if best < 0 || table.FreeIn < tables[best].FreeIn || (table.FreeIn == tables[best].FreeIn && table.Capacity < tables[best].Capacity) {
+				best = j
+			}
+		}
+		if best < 0 {
+			waits[i] = -1
+			continue
+		}
+		waits[i] = tables[best].FreeIn
+		tables[best].FreeIn += turn
+	}
+	return waits
+}
+
+// waitMinutes rounds a wait up to whole minutes, or to the quote step for a quote.
+func waitMinutes(wait time.Duration, step time.Duration) int {
+	if wait <= 0 {
+		return 0
+	}
+	steps := (wait + step - 1) / step
+	return int(steps * step / time.Minute)
+}
+
+// tableTurn is how long a table is taken per party: the average time from ordering to closing of
+// the dine-in orders closed since since, or the reservation duration without any, plus the time to
+// reset the table.
+func tableTurn(since time.Time) (time.Duration, error) {
+	var average sql.NullFloat64
+	err := DB.QueryRow(`SELECT AVG(TIMESTAMPDIFF(SECOND, o.ordered_at, st.created_at)) FROM Orders o
+		JOIN StatusTransitions st ON st.entity = 'order' AND st.entity_id = o.id AND st.to_status = 'closed'
+		WHERE o.type = 'dine_in' AND st.created_at >= ?`, since).Scan(&average)
+	if err != nil {
+		return 0, err
+	}
+
+	turn := config.Config.Reservations.Duration
+	if average.Valid && average.Float64 > 0 {
+		turn = time.Duration(average.Float64) * time.Second
+	}
+	return turn + config.Config.Reservations.TurnTime, nil
+}
+
+// loadWaitTables loads the tables walk-ins could get and when each is expected to be free. A
+// seated table frees up when its order reaches the average turn, tables offered to a waiting party
+// are taken for a whole turn, and reserved tables are left out for their bookings.
+func loadWaitTables(now time.Time, turn time.Duration) ([]waitTable, error) {
+	rows, err := DB.Query(`SELECT t.id, t.capacity, t.status, o.ordered_at,
+			EXISTS (SELECT 1 FROM Waitlist w WHERE w.table_id = t.id AND w.status = 'notified')
+		FROM DiningTables t
+		LEFT JOIN Orders o ON o.table_id = t.id AND o.status = 'open'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reset := config.Config.Reservations.TurnTime
+	var tables []waitTable
+	for rows.Next() {
+		var table waitTable
+		var status TableStatus
+		var orderedAt sql.NullTime
+		var offered bool
+		if err := rows.Scan(&table.ID, &table.Capacity, &status, &orderedAt, &offered); err != nil {
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+
+		switch {
+		case offered:
+			table.FreeIn = turn
+		case orderedAt.Valid:
+			table.FreeIn = max(turn-now.Sub(orderedAt.Time), reset)
+		case status == TableFree:
+			table.FreeIn = 0
+		case status == TableNeedsCleaning:
+			table.FreeIn = reset
+		case status == TableOccupied:
+			// pushed together with another table for a reservation, whose order is elsewhere
+			table.FreeIn = turn
+		default:
+			continue
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, rows.Err()
+}
+
+// waitEstimates estimates the wait of every party still waiting, and of a party of partySize
+// joining behind them when it is not 0.
+func waitEstimates(waiting []WaitlistEntry, partySize int, now time.Time) ([]time.Duration, error) {
+	turn, err := tableTurn(now.Add(-config.Config.Waitlist.TurnWindow))
+	if err != nil {
+		return nil, err
+	}
+	tables, err := loadWaitTables(now, turn)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := make([]int, 0, len(waiting)+1)
+	for _, entry := range waiting {
+		queue = append(queue, entry.PartySize)
+	}
+	if partySize > 0 {
+		queue = append(queue, partySize)
+	}
+	return estimateWaits(tables, queue, turn), nil
+}
+
+// EstimateWait estimates in minutes how long a party joining the waitlist now would wait. It
+// reports false when no single table seats the party.
+func EstimateWait(partySize int, now time.Time) (int, bool, error) {
+	waiting, err := getWaitlist("w.status = 'waiting'")
+	if err != nil {
+		return 0, false, err
+	}
+	waits, err := waitEstimates(waiting, partySize, now)
+	if err != nil {
+		return 0, false, err
+	}
+	wait := waits[len(waits)-1]
+	return waitMinutes(wait, time.Minute), wait >= 0, nil
+}
+
+// GetWaitlist lists the parties waiting or told their table is ready in the order they joined,
+// with the current estimate for those still waiting.
+func GetWaitlist(now time.Time) ([]WaitlistEntry, error) {
+	entries, err := getWaitlist("w.status IN ('waiting', 'notified')")
+	if err != nil {
+		return nil, err
+	}
+
+	var waiting []WaitlistEntry
+	for _, entry := range entries {
+		if entry.Status == WaitlistWaiting {
+			waiting = append(waiting, entry)
+		}
+	}
+	waits, err := waitEstimates(waiting, 0, now)
+	if err != nil {
+		return nil, err
+	}
+
+	next := 0
+	for i := range entries {
+		if entries[i].Status != WaitlistWaiting {
+			continue
+		}
+		if waits[next] >= 0 {
+			minutes := waitMinutes(waits[next], time.Minute)
+			entries[i].EstimatedWait = &minutes
+		}
+		next++
+	}
+	return entries, nil
+}
+
+func GetWaitlistEntryById(id int64) (*WaitlistEntry, error) {
+	entries, err := getWaitlist("w.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("waitlist entry not found")
+	}
+	return &entries[0], nil
+}
+
+func getWaitlist(condition string, args ...any) ([]WaitlistEntry, error) {
+	rows, err := DB.Query(waitlistQuery+" WHERE "+condition+" ORDER BY w.created_at, w.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []WaitlistEntry
+	for rows.Next() {
+		var entry WaitlistEntry
+		var tableId, tableNumber, orderId, createdBy sql.NullInt64
+		var notifiedAt, seatedAt sql.NullTime
+		if err := rows.Scan(&entry.ID, &entry.Name, &entry.PartySize, &entry.Phone, &entry.QuotedWait, &entry.Status, &tableId, &tableNumber,
+			&orderId, &notifiedAt, &seatedAt, &createdBy, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan waitlist entry: %w", err)
+		}
+		entry.TableID = tableId.Int64
+		entry.TableNumber = int(tableNumber.Int64)
+		entry.OrderID = orderId.Int64
+		entry.CreatedBy = createdBy.Int64
+		if notifiedAt.Valid {
+			entry.NotifiedAt = &notifiedAt.Time
+		}
+		if seatedAt.Valid {
+			entry.SeatedAt = &seatedAt.Time
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// CreateWaitlistEntry adds a party to the end of the waitlist. Without a quoted wait the party is
+// quoted the estimate, rounded up to 5 minutes.
+func CreateWaitlistEntry(entry *WaitlistEntry, actorId int64, now time.Time) (*WaitlistEntry, error) {
+	if entry.QuotedWait < 0 {
+		minutes, fits, err := EstimateWait(entry.PartySize, now)
+		if err != nil {
+			return nil, err
+		}
+		if !fits {
+			return nil, fmt.Errorf("no table seats %d guests, quote the wait yourself", entry.PartySize)
+		}
+		entry.QuotedWait = waitMinutes(time.Duration(minutes)*time.Minute, quoteStep)
+	}
+
+	res, err := DB.Exec("INSERT INTO Waitlist (name, party_size, phone, quoted_wait, status, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		entry.Name, entry.PartySize, entry.Phone, entry.QuotedWait, WaitlistWaiting, nullableId(actorId), now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetWaitlistEntryById(id)
+}
+
+// NotifyWaitlistEntry records that a party was told a table is ready for them. A table offered
+// before is kept when tableId is 0.
+func NotifyWaitlistEntry(id int64, tableId int64, actorId int64, now time.Time) (*WaitlistEntry, error) {
+	err := updateStatus(WaitlistEntity, id, string(WaitlistNotified), actorId, func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE Waitlist SET table_id = COALESCE(?, table_id), notified_at = ? WHERE id = ?", nullableId(tableId), now, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetWaitlistEntryById(id)
+}
+
+// OfferTableToWaitlist offers a table that just became free to the first waiting party it seats,
+// returning that party, or nil when the table is not free, already offered or seats nobody waiting.
+func OfferTableToWaitlist(tableId int64, actorId int64, now time.Time) (*WaitlistEntry, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	entryId, err := offerTable(tx, tableId, actorId, now)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if entryId == 0 {
+		return nil, nil
+	}
+	return GetWaitlistEntryById(entryId)
+}
+
+func offerTable(tx *sql.Tx, tableId int64, actorId int64, now time.Time) (int64, error) {
+	var capacity int
+	var status TableStatus
+	var offered bool
+	err := tx.QueryRow("SELECT capacity, status, EXISTS (SELECT 1 FROM Waitlist WHERE table_id = t.id AND status = 'notified') FROM DiningTables t WHERE t.id = ? FOR UPDATE", tableId).
+		Scan(&capacity, &status, &offered)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return 0, fmt.Errorf("table not found")
+		}
+		return 0, err
+	}
+	if status != TableFree || offered {
+		return 0, nil
+	}
+
+	var entryId int64
+	err = tx.QueryRow("SELECT id FROM Waitlist WHERE status = 'waiting' AND party_size <= ? ORDER BY created_at, id LIMIT 1 FOR UPDATE", capacity).Scan(&entryId)
+	if err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	if err := changeStatus(tx, WaitlistEntity, entryId, string(WaitlistNotified), actorId); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("UPDATE Waitlist SET table_id = ?, notified_at = ? WHERE id = ?", tableId, now, entryId)
+	return entryId, err
+}
+
+// SeatWaitlistEntry seats a waiting party, opening their order at the table. The table offered to
+// them is used when tableId is 0.
+func SeatWaitlistEntry(id int64, tableId int64, actorId int64, now time.Time) (*WaitlistEntry, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	err = seatWaitlistEntry(tx, id, tableId, actorId, now)
+	if err != nil {
+		if err1 := tx.Rollback(); err1 != nil {
+			return nil, fmt.Errorf("%v %v", err1, err)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return GetWaitlistEntryById(id)
+}
+
+func seatWaitlistEntry(tx *sql.Tx, id int64, tableId int64, actorId int64, now time.Time) error {
+	var status WaitlistStatus
+	var partySize int
+	var offeredTableId sql.NullInt64
+	if err := tx.QueryRow("SELECT status, party_size, table_id FROM Waitlist WHERE id = ? FOR UPDATE", id).Scan(&status, &partySize, &offeredTableId); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("waitlist entry not found")
+		}
+		return err
+	}
+	if status == WaitlistSeated {
+		return fmt.Errorf("waitlist entry is already seated")
+	}
+	if tableId == 0 {
+		tableId = offeredTableId.Int64
+	}
+	if tableId == 0 {
+		return fmt.Errorf("no table was offered, choose one")
+	}
+
+	var tableNumber int
+	var tableStatus TableStatus
+	if err := tx.QueryRow("SELECT number, status FROM DiningTables WHERE id = ? FOR UPDATE", tableId).Scan(&tableNumber, &tableStatus); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return fmt.Errorf("table not found")
+		}
+		return err
+	}
+	if tableStatus != TableFree {
+		return fmt.Errorf("table %d is not free", tableNumber)
+	}
+
+	if err := changeStatus(tx, WaitlistEntity, id, string(WaitlistSeated), actorId); err != nil {
+		return err
+	}
+	order, err := createOrder(tx, actorId, tableId, partySize)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE Waitlist SET table_id = ?, order_id = ?, seated_at = ? WHERE id = ?", tableId, order.ID, now, id)
+	return err
+}
+
+// SetWaitlistStatus puts a party told their table is ready back to waiting, letting the table go,
+// or takes a party off the waitlist.
+func SetWaitlistStatus(id int64, status WaitlistStatus, actorId int64) (*WaitlistEntry, error) {
+	err := updateStatus(WaitlistEntity, id, string(status), actorId, func(tx *sql.Tx) error {
+		if status != WaitlistWaiting {
+			return nil
+		}
+		_, err := tx.Exec("UPDATE Waitlist SET table_id = NULL, notified_at = NULL WHERE id = ?", id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetWaitlistEntryById(id)
+}
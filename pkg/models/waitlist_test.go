@@ -0,0 +1,65 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateWaits(t *testing.T) {
+	tables := []waitTable{
+		{ID: 1, Capacity: 2, FreeIn: 20 * time.Minute},
+		{ID: 2, Capacity: 4, FreeIn: 10 * time.Minute},
+		{ID: 3, Capacity: 4, FreeIn: 10 * time.Minute},
+		{ID: 4, Capacity: 6, FreeIn: time.Hour},
+	}
+	turn := time.Hour
+
+	tests := []struct {
+		name     string
+		queue    []int
+		expected []time.Duration
+	}{
+		{"first table to free up", []int{2}, []time.Duration{10 * time.Minute}},
+		{"parties ahead take the tables", []int{4, 4, 4}, []time.Duration{10 * time.Minute, 10 * time.Minute, time.Hour}},
+		{"small parties take the first table that fits", []int{2, 2, 2, 2}, []time.Duration{10 * time.Minute, 10 * time.Minute, 20 * time.Minute, time.Hour}},
+		{"only big tables fit", []int{6, 5}, []time.Duration{time.Hour, 2 * time.Hour}},
+		{"no table fits", []int{8, 2}, []time.Duration{-1, 10 * time.Minute}},
+	}
+	for _, test := range tests {
+		waits := estimateWaits(tables, test.queue, turn)
+		if len(waits) != len(test.expected) {
+			t.Errorf("%s: expected %d waits, got %d", test.name, len(test.expected), len(waits))
+			continue
+		}
+		for i := range waits {
+			if waits[i] != test.expected[i] {
+				t.Errorf("%s: expected %v, got %v", test.name, test.expected, waits)
+				break
+			}
+		}
+	}
+
+	if tables[1].FreeIn != 10*time.Minute {
+		t.Errorf("Expected the tables to be left as they were, got %s", tables[1].FreeIn)
+	}
+}
+
+func TestWaitMinutes(t *testing.T) {
+	tests := []struct {
+		wait     time.Duration
+		step     time.Duration
+		expected int
+	}{
+		{-time.Minute, time.Minute, 0},
+		{0, quoteStep, 0},
+		{90 * time.Second, time.Minute, 2},
+		{17 * time.Minute, quoteStep, 20},
+		{20 * time.Minute, quoteStep, 20},
+		{21 * time.Minute, quoteStep, 25},
+	}
+	for _, test := range tests {
+		if minutes := waitMinutes(test.wait, test.step); minutes != test.expected {
+			t.Errorf("waitMinutes(%s, %s) = %d; want %d", test.wait, test.step, minutes, test.expected)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const webhookEventColumns = "id, provider, event_id, type, payload, received_at, processed_at, error"
+
+func CreateWebhookEvent(event *WebhookEvent) (*WebhookEvent, error) {
+	res, err := DB.Exec("INSERT INTO WebhookEvents (provider, event_id, type, payload, received_at) VALUES (?, ?, ?, ?, ?)",
+		event.Provider, event.EventID, event.Type, event.Payload, event.ReceivedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "Duplicate") {
+			return nil, fmt.Errorf("webhook event '%s' already exists", event.EventID)
+		}
+		return nil, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	created := *event
+	created.ID = id
+	return &created, nil
+}
+
+// MarkWebhookEventProcessed records when an event was last applied and why it failed, if it did.
+func MarkWebhookEventProcessed(id int64, processedAt time.Time, errMessage string) error {
+	_, err := DB.Exec("UPDATE WebhookEvents SET processed_at = ?, error = ? WHERE id = ?",
+		processedAt, sql.NullString{String: errMessage, Valid: errMessage != ""}, id)
+	return err
+}
+
+func GetWebhookEventById(id int64) (*WebhookEvent, error) {
+	row := DB.QueryRow("SELECT "+webhookEventColumns+" FROM WebhookEvents WHERE id = ?", id)
+	event := &WebhookEvent{}
+	if err := scanWebhookEvent(row, event); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("webhook event not found")
+		}
+		return nil, err
+	}
+	return event, nil
+}
+
+func GetWebhookEventByEventId(provider string, eventId string) (*WebhookEvent, error) {
+	row := DB.QueryRow("SELECT "+webhookEventColumns+" FROM WebhookEvents WHERE provider = ? AND event_id = ?", provider, eventId)
+	event := &WebhookEvent{}
+	if err := scanWebhookEvent(row, event); err != nil {
+		if strings.Contains(err.Error(), "no rows") {
+			return nil, fmt.Errorf("webhook event not found")
+		}
+		return nil, err
+	}
+	return event, nil
+}
+
+func GetWebhookEvents(provider string, failedOnly bool, limit int, offset int) ([]WebhookEvent, error) {
+	query := "SELECT " + webhookEventColumns + " FROM WebhookEvents WHERE 1=1"
+	var args []any
+	if provider != "" {
+		query += " AND provider = ?"
+		args = append(args, provider)
+	}
+	if failedOnly {
+		query += " AND error IS NOT NULL"
+	}
+	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []WebhookEvent
+	for rows.Next() {
+		var event WebhookEvent
+		if err := scanWebhookEvent(rows, &event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func scanWebhookEvent(row interface{ Scan(dest ...any) error }, event *WebhookEvent) error {
+	var processedAt sql.NullTime
+	var errMessage sql.NullString
+	if err := row.Scan(&event.ID, &event.Provider, &event.EventID, &event.Type, &event.Payload, &event.ReceivedAt, &processedAt, &errMessage); err != nil {
+		return err
+	}
+	if processedAt.Valid {
+		event.ProcessedAt = &processedAt.Time
+	}
+	event.Error = errMessage.String
+	return nil
+}
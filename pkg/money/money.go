@@ -0,0 +1,237 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const moneyScale = 2
+
+// Money is an amount in minor units (cents) of the configured currency. It is encoded as a
+// decimal string in JSON ("12.34") and as DECIMAL in the database, so it never goes through float64.
+type Money int64 // @name Money
+
+func ParseMoney(value string) (Money, error) {
+	amount, err := parseFixed(value, moneyScale, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount '%s'", value)
+	}
+	return Money(amount), nil
+}
+
+func (m Money) String() string {
+	return formatFixed(int64(m), moneyScale)
+}
+
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Percent returns the given percentage of the amount, rounded half away from zero.
+func (m Money) Percent(rate Percent) Money {
+	return Money(divRound(int64(m)*int64(rate), int64(OneHundredPercent)))
+}
+
+// IncludedTax returns the part of a tax-inclusive amount that is tax at the given rate,
+// rounded half away from zero.
+func (m Money) IncludedTax(rate Percent) Money {
+	net := divRound(int64(m)*int64(OneHundredPercent), int64(OneHundredPercent+rate))
+	return m - Money(net)
+}
+
+// Allocate distributes the amount over the weights proportionally. Shares are rounded down and
+// the leftover cents go to the shares with the largest remainders (earlier shares win ties),
+// so the shares always add up to exactly the amount.
+func (m Money) Allocate(weights []int64) []Money {
+	shares := make([]Money, len(weights))
+	var total int64
+	for _, weight := range weights {
+		if weight > 0 {
+			total += weight
+		}
+	}
+	if total == 0 || len(weights) == 0 {
+		return shares
+	}
+
+	sign := Money(1)
+	amount := m
+	if amount < 0 {
+		sign, amount = -1, -amount
+	}
+
+	remainders := make([]int64, len(weights))
+	allocated := Money(0)
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		product := int64(amount) * weight
+		shares[i] = Money(product / total)
+		remainders[i] = product % total
+		allocated += shares[i]
+	}
+
+	for left := amount - allocated; left > 0; left-- {
+		best := -1
+		for i := range weights {
+			if weights[i] > 0 && (best == -1 || remainders[i] > remainders[best]) {
+				best = i
+			}
+		}
+		shares[best]++
+		remainders[best] = -1
+	}
+
+	for i := range shares {
+		shares[i] *= sign
+	}
+	return shares
+}
+
+// Split divides the amount into n shares that differ by at most one cent.
+func (m Money) Split(n int) []Money {
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return m.Allocate(weights)
+}
+
+func Min(a Money, b Money) Money {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// UnmarshalJSON accepts both decimal strings and JSON numbers; numbers are parsed from their
+// text so that 0.1 stays exactly ten cents.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	amount, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+// UnmarshalText lets amounts be read from environment variables.
+func (m *Money) UnmarshalText(text []byte) error {
+	amount, err := ParseMoney(string(text))
+	if err != nil {
+		return err
+	}
+	*m = amount
+	return nil
+}
+
+func (m *Money) Scan(src any) error {
+	amount, err := scanFixed(src, moneyScale)
+	if err != nil {
+		return err
+	}
+	*m = Money(amount)
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+func parseFixed(value string, scale int, strict bool) (int64, error) {
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("empty number")
+	}
+	if strict && len(fraction) > scale {
+		return 0, fmt.Errorf("too many decimal places")
+	}
+
+	roundUp := false
+	if len(fraction) > scale {
+		roundUp = fraction[scale] >= '5'
+		fraction = fraction[:scale]
+	}
+	fraction += strings.Repeat("0", scale-len(fraction))
+
+	digits := whole + fraction
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid digit '%c'", c)
+		}
+	}
+
+	result, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if roundUp {
+		result++
+	}
+	if negative {
+		result = -result
+	}
+	return result, nil
+}
+
+func formatFixed(value int64, scale int) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	digits := strconv.FormatInt(value, 10)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+func scanFixed(src any, scale int) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case []byte:
+		return parseFixed(string(v), scale, false)
+	case string:
+		return parseFixed(v, scale, false)
+	case int64:
+		return parseFixed(strconv.FormatInt(v, 10), scale, false)
+	case float64:
+		return parseFixed(strconv.FormatFloat(v, 'f', -1, 64), scale, false)
+	default:
+		return 0, fmt.Errorf("cannot scan %T into a fixed-point value", src)
+	}
+}
+
+// divRound divides rounding half away from zero.
+func divRound(numerator int64, denominator int64) int64 {
+	if denominator < 0 {
+		numerator, denominator = -numerator, -denominator
+	}
+	if numerator < 0 {
+		return -((-numerator + denominator/2) / denominator)
+	}
+	return (numerator + denominator/2) / denominator
+}
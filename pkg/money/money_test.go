@@ -0,0 +1,179 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]Money{
+		"0":      0,
+		"12":     1200,
+		"12.3":   1230,
+		"12.34":  1234,
+		"-0.05":  -5,
+		".5":     50,
+		"+1.01":  101,
+		"100000": 10000000,
+	}
+	for input, expected := range cases {
+		amount, err := ParseMoney(input)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) returned error %v", input, err)
+			continue
+		}
+		if amount != expected {
+			t.Errorf("ParseMoney(%q) = %d, expected %d", input, amount, expected)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.234", "1e3", "1,5"} {
+		if _, err := ParseMoney(input); err == nil {
+			t.Errorf("Expected ParseMoney(%q) to fail", input)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	cases := map[Money]string{
+		0:      "0.00",
+		5:      "0.05",
+		-5:     "-0.05",
+		1234:   "12.34",
+		-12345: "-123.45",
+	}
+	for amount, expected := range cases {
+		if amount.String() != expected {
+			t.Errorf("Money(%d).String() = %q, expected %q", int64(amount), amount.String(), expected)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	var body struct {
+		Number Money `json:"number"`
+		String Money `json:"string"`
+	}
+	if err := json.Unmarshal([]byte(`{"number": 0.1, "string": "19.99"}`), &body); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if body.Number != 10 || body.String != 1999 {
+		t.Errorf("Expected 10 and 1999, got %d and %d", body.Number, body.String)
+	}
+
+	if err := json.Unmarshal([]byte(`{"number": 0.001}`), &body); err == nil {
+		t.Errorf("Expected an error for sub-cent amounts")
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if string(encoded) != `{"number":"0.10","string":"19.99"}` {
+		t.Errorf("Unexpected encoding %s", encoded)
+	}
+}
+
+func TestMoneyScan(t *testing.T) {
+	cases := []struct {
+		src      any
+		expected Money
+	}{
+		{[]byte("12.34"), 1234},
+		{"7.50", 750},
+		{int64(3), 300},
+		{[]byte("2.3450"), 235},
+		{nil, 0},
+	}
+	for _, c := range cases {
+		var amount Money
+		if err := amount.Scan(c.src); err != nil {
+			t.Errorf("Scan(%v) returned error %v", c.src, err)
+			continue
+		}
+		if amount != c.expected {
+			t.Errorf("Scan(%v) = %d, expected %d", c.src, amount, c.expected)
+		}
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	cases := []struct {
+		amount   Money
+		rate     string
+		expected Money
+	}{
+		{1000, "10", 100},
+		{1999, "8.875", 177},  // 177.41125 cents
+		{1, "50", 1},          // half a cent rounds up
+		{-1, "50", -1},        // and away from zero for negatives
+		{333, "15", 50},       // 49.95 cents
+		{12345, "100", 12345}, // whole amount
+	}
+	for _, c := range cases {
+		rate, err := ParsePercent(c.rate)
+		if err != nil {
+			t.Fatalf("ParsePercent(%q) returned error %v", c.rate, err)
+		}
+		if got := c.amount.Percent(rate); got != c.expected {
+			t.Errorf("%s%% of %s = %s, expected %s", c.rate, c.amount, got, c.expected)
+		}
+	}
+}
+
+func TestMoneyIncludedTax(t *testing.T) {
+	rate, _ := ParsePercent("20")
+	if tax := Money(1200).IncludedTax(rate); tax != 200 {
+		t.Errorf("Expected 2.00 included tax, got %s", tax)
+	}
+	if tax := Money(1000).IncludedTax(rate); tax != 167 {
+		t.Errorf("Expected 1.67 included tax, got %s", tax)
+	}
+}
+
+func TestMoneyAllocate(t *testing.T) {
+	shares := Money(1000).Split(3)
+	expected := []Money{334, 333, 333}
+	for i := range expected {
+		if shares[i] != expected[i] {
+			t.Fatalf("Split(3) = %v, expected %v", shares, expected)
+		}
+	}
+
+	shares = Money(100).Allocate([]int64{1, 1, 1, 0})
+	if shares[0]+shares[1]+shares[2] != 100 || shares[3] != 0 {
+		t.Errorf("Allocate lost cents or paid a zero weight: %v", shares)
+	}
+
+	shares = Money(-500).Allocate([]int64{2, 1})
+	if shares[0] != -333 || shares[1] != -167 {
+		t.Errorf("Expected [-333 -167], got %v", shares)
+	}
+
+	shares = Money(500).Allocate([]int64{0, 0})
+	if shares[0] != 0 || shares[1] != 0 {
+		t.Errorf("Expected nothing to be allocated without weights, got %v", shares)
+	}
+}
+
+func TestPercentString(t *testing.T) {
+	cases := map[string]string{
+		"5":      "5",
+		"8.875":  "8.875",
+		"12.50":  "12.5",
+		"100":    "100",
+		"0":      "0",
+		"0.001":  "0.001",
+		"-2.500": "-2.5",
+	}
+	for input, expected := range cases {
+		rate, err := ParsePercent(input)
+		if err != nil {
+			t.Errorf("ParsePercent(%q) returned error %v", input, err)
+			continue
+		}
+		if rate.String() != expected {
+			t.Errorf("ParsePercent(%q).String() = %q, expected %q", input, rate.String(), expected)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const percentScale = 3
+
+// Percent is a rate in thousandths of a percent, so 8.875% is stored exactly as 8875.
+type Percent int64 // @name Percent
+
+const OneHundredPercent Percent = 100_000
+
+func ParsePercent(value string) (Percent, error) {
+	rate, err := parseFixed(value, percentScale, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage '%s'", value)
+	}
+	return Percent(rate), nil
+}
+
+func (p Percent) String() string {
+	return strings.TrimSuffix(strings.TrimRight(formatFixed(int64(p), percentScale), "0"), ".")
+}
+
+func (p Percent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+func (p *Percent) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, `"`) {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+	rate, err := ParsePercent(text)
+	if err != nil {
+		return err
+	}
+	*p = rate
+	return nil
+}
+
+// UnmarshalText lets percentages be read from environment variables.
+func (p *Percent) UnmarshalText(text []byte) error {
+	rate, err := ParsePercent(string(text))
+	if err != nil {
+		return err
+	}
+	*p = rate
+	return nil
+}
+
+func (p *Percent) Scan(src any) error {
+	rate, err := scanFixed(src, percentScale)
+	if err != nil {
+		return err
+	}
+	*p = Percent(rate)
+	return nil
+}
+
+func (p Percent) Value() (driver.Value, error) {
+	return formatFixed(int64(p), percentScale), nil
+}
@@ -0,0 +1,57 @@
+// Package notify sends short messages to guests, such as telling a waiting party their table is ready.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"github.com/gqvz/mvc/pkg/config"
+	"log"
+)
+
+type Message struct {
+	// To is where the message goes, such as a phone number. It may be empty for guests who left no
+	// contact, in which case staff tell them in person.
+	To   string
+	Text string
+}
+
+// Notifier delivers messages to guests. Errors mean the message may not have been delivered.
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, message Message) error
+}
+
+func NewNotifier(waitlistConfig config.WaitlistConfig) (Notifier, error) {
+	switch waitlistConfig.Notifier {
+	case "log":
+		return NewLogNotifier(log.Default()), nil
+	default:
+		return nil, fmt.Errorf("unknown notifier '%s'", waitlistConfig.Notifier)
+	}
+}
+
+// LogNotifier writes messages to a log instead of sending them, for development and for
+// restaurants that call guests up themselves.
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Name() string {
+	return "log"
+}
+
+func (n *LogNotifier) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	to := message.To
+	if to == "" {
+		to = "(no contact)"
+	}
+	n.logger.Printf("Notification to %s: %s", to, message.Text)
+	return nil
+}
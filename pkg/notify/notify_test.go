@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"github.com/gqvz/mvc/pkg/config"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestLogNotifier(t *testing.T) {
+	var buf bytes.Buffer
+	notifier := NewLogNotifier(log.New(&buf, "", 0))
+
+	if err := notifier.Send(context.Background(), Message{To: "+1 555 0100", Text: "Your table is ready"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := notifier.Send(context.Background(), Message{Text: "Your table is ready"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	expected := "Notification to +1 555 0100: Your table is ready\nNotification to (no contact): Your table is ready\n"
+	if buf.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, buf.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := notifier.Send(ctx, Message{Text: "late"}); err == nil || strings.Contains(buf.String(), "late") {
+		t.Error("Expected a cancelled send to fail without logging")
+	}
+}
+
+func TestNewNotifier(t *testing.T) {
+	notifier, err := NewNotifier(config.WaitlistConfig{Notifier: "log"})
+	if err != nil || notifier.Name() != "log" {
+		t.Errorf("Expected the log notifier, got %v, %v", notifier, err)
+	}
+	if _, err := NewNotifier(config.WaitlistConfig{Notifier: "carrier-pigeon"}); err == nil {
+		t.Error("Expected an error for an unknown notifier")
+	}
+}
@@ -0,0 +1,93 @@
+// Package printer renders kitchen tickets and receipts as ESC/POS byte streams and delivers them
+// to receipt printers, retrying while a printer is offline.
+package printer
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	esc = 0x1b
+	gs  = 0x1d
+)
+
+type Alignment byte
+
+const (
+	AlignLeft   Alignment = 0
+	AlignCenter Alignment = 1
+	AlignRight  Alignment = 2
+)
+
+// Encoder builds an ESC/POS command stream. Text is sent in code page PC437, the power-on default
+// of most printers, so characters outside ASCII are replaced.
+type Encoder struct {
+	buf bytes.Buffer
+}
+
+func NewEncoder() *Encoder {
+	e := &Encoder{}
+	e.buf.Write([]byte{esc, '@'})
+	return e
+}
+
+func (e *Encoder) Align(alignment Alignment) *Encoder {
+	e.buf.Write([]byte{esc, 'a', byte(alignment)})
+	return e
+}
+
+func (e *Encoder) Bold(on bool) *Encoder {
+	e.buf.Write([]byte{esc, 'E', flag(on)})
+	return e
+}
+
+// DoubleSize doubles character width and height, for headings that have to be read across a kitchen.
+func (e *Encoder) DoubleSize(on bool) *Encoder {
+	size := byte(0x00)
+	if on {
+		size = 0x11
+	}
+	e.buf.Write([]byte{gs, '!', size})
+	return e
+}
+
+func (e *Encoder) Text(text string) *Encoder {
+	for _, r := range text {
+		switch {
+		case r == '\n' || (r >= 0x20 && r < 0x7f):
+			e.buf.WriteByte(byte(r))
+		case r == '\t':
+			e.buf.WriteByte(' ')
+		default:
+			e.buf.WriteByte('?')
+		}
+	}
+	return e
+}
+
+func (e *Encoder) Line(text string) *Encoder {
+	return e.Text(strings.TrimRight(text, "\n") + "\n")
+}
+
+func (e *Encoder) Feed(lines int) *Encoder {
+	e.buf.Write([]byte{esc, 'd', byte(lines)})
+	return e
+}
+
+// Cut feeds past the cutter and makes a partial cut.
+func (e *Encoder) Cut() *Encoder {
+	e.buf.Write([]byte{gs, 'V', 66, 3})
+	return e
+}
+
+func (e *Encoder) Bytes() []byte {
+	return e.buf.Bytes()
+}
+
+func flag(on bool) byte {
+	if on {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package printer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPort = "9100"
+
+type Printer interface {
+	Name() string
+	Print(ctx context.Context, data []byte) error
+}
+
+// NewPrinter creates a printer from a target such as "tcp://10.0.0.20:9100", "10.0.0.20" or
+// "file:///tmp/kitchen.prn". It returns nil for an empty target.
+func NewPrinter(target string, timeout time.Duration) (Printer, error) {
+	if target == "" {
+		return nil, nil
+	}
+	if !strings.Contains(target, "://") {
+		target = "tcp://" + target
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid printer '%s': %v", target, err)
+	}
+
+	switch parsed.Scheme {
+	case "tcp":
+		address := parsed.Host
+		if parsed.Port() == "" {
+			address = net.JoinHostPort(parsed.Hostname(), defaultPort)
+		}
+		return &NetworkPrinter{Address: address, Timeout: timeout}, nil
+	case "file":
+		return &FilePrinter{Path: parsed.Path}, nil
+	default:
+		return nil, fmt.Errorf("unsupported printer scheme '%s'", parsed.Scheme)
+	}
+}
+
+// NetworkPrinter sends jobs over raw TCP, usually port 9100 (JetDirect), which nearly every
+// network receipt printer accepts.
+type NetworkPrinter struct {
+	Address string
+	Timeout time.Duration
+}
+
+func (p *NetworkPrinter) Name() string {
+	return "tcp://" + p.Address
+}
+
+func (p *NetworkPrinter) Print(ctx context.Context, data []byte) error {
+	dialer := net.Dialer{Timeout: p.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.SetWriteDeadline(time.Now().Add(p.Timeout)); err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
+
+// FilePrinter appends every job to a file. It stands in for a real printer in development and
+// tests; the file can be sent to a printer later with `cat file > /dev/usb/lp0`.
+type FilePrinter struct {
+	Path  string
+	mutex sync.Mutex
+}
+
+func (p *FilePrinter) Name() string {
+	return "file://" + p.Path
+}
+
+func (p *FilePrinter) Print(ctx context.Context, data []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	file, err := os.OpenFile(p.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
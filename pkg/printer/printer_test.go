@@ -0,0 +1,205 @@
+package printer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	got := NewEncoder().Align(AlignCenter).Bold(true).Line("Café").Cut().Bytes()
+	want := []byte{0x1b, '@', 0x1b, 'a', 1, 0x1b, 'E', 1, 'C', 'a', 'f', '?', '\n', 0x1d, 'V', 66, 3}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestRenderKitchenTicket(t *testing.T) {
+	ticket := &KitchenTicket{
+		OrderID:     7,
+		TableNumber: 12,
+		CreatedAt:   time.Date(2025, 8, 30, 19, 5, 0, 0, time.UTC),
+		Items:       []TicketItem{{Name: "Margherita", Quantity: 2, Instructions: "no basil"}},
+	}
+	data := RenderKitchenTicket(ticket, 32)
+
+	for _, want := range []string{"TABLE 12\n", "Order #7  19:05\n", "2x Margherita\n", "  ** no basil\n"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Expected ticket to contain %q", want)
+		}
+	}
+	if !bytes.HasPrefix(data, []byte{0x1b, '@'}) || !bytes.HasSuffix(data, []byte{0x1d, 'V', 66, 3}) {
+		t.Errorf("Expected ticket to initialise the printer and end with a cut")
+	}
+}
+
+func TestRenderTakeawayKitchenTicket(t *testing.T) {
+	ticket := &KitchenTicket{
+		OrderID:   8,
+		OrderType: "takeaway",
+		PickupAt:  time.Date(2025, 8, 30, 19, 45, 0, 0, time.UTC),
+		CreatedAt: time.Date(2025, 8, 30, 19, 5, 0, 0, time.UTC),
+		Items:     []TicketItem{{Name: "Margherita", Quantity: 1}},
+	}
+	data := RenderKitchenTicket(ticket, 32)
+
+	for _, want := range []string{"TAKEAWAY\n", "Order #8  19:05\n", "PICKUP 19:45\n"} {
+		if !bytes.Contains(data, []byte(want)) {
+			t.Errorf("Expected ticket to contain %q", want)
+		}
+	}
+	if bytes.Contains(data, []byte("TABLE")) {
+		t.Errorf("Expected no table on a takeaway ticket")
+	}
+}
+
+func TestNewPrinter(t *testing.T) {
+	tests := map[string]string{
+		"10.0.0.20":                 "tcp://10.0.0.20:9100",
+		"tcp://printer.local:9101":  "tcp://printer.local:9101",
+		"file:///tmp/kitchen.prn":   "file:///tmp/kitchen.prn",
+		"tcp://[fe80::1]":           "tcp://[fe80::1]:9100",
+		"kitchen-printer.lan:19100": "tcp://kitchen-printer.lan:19100",
+	}
+	for target, want := range tests {
+		printer, err := NewPrinter(target, time.Second)
+		if err != nil {
+			t.Fatalf("Expected no error for %s, got %v", target, err)
+		}
+		if printer.Name() != want {
+			t.Errorf("Expected %s to become %s, got %s", target, want, printer.Name())
+		}
+	}
+
+	if printer, err := NewPrinter("", time.Second); printer != nil || err != nil {
+		t.Errorf("Expected no printer for an empty target")
+	}
+	if _, err := NewPrinter("usb://lp0", time.Second); err == nil {
+		t.Errorf("Expected an unsupported scheme to fail")
+	}
+}
+
+func TestNetworkPrinter(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("Cannot listen on loopback: %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- data
+	}()
+
+	printer := &NetworkPrinter{Address: listener.Addr().String(), Timeout: time.Second}
+	if err := printer.Print(context.Background(), []byte("ticket")); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	select {
+	case data := <-received:
+		if string(data) != "ticket" {
+			t.Errorf("Expected the printer to receive the job, got %q", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the job to arrive")
+	}
+}
+
+func TestFilePrinterAppendsJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "printer.prn")
+	printer := &FilePrinter{Path: path}
+	for _, job := range []string{"one", "two"} {
+		if err := printer.Print(context.Background(), []byte(job)); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "onetwo" {
+		t.Errorf("Expected both jobs in the file, got %q, %v", data, err)
+	}
+}
+
+// flakyPrinter fails a set number of times before printing.
+type flakyPrinter struct {
+	mutex    sync.Mutex
+	failures int
+	attempts int
+	printed  [][]byte
+}
+
+func (p *flakyPrinter) Name() string { return "flaky" }
+
+func (p *flakyPrinter) Print(ctx context.Context, data []byte) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.attempts++
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("paper out")
+	}
+	p.printed = append(p.printed, data)
+	return nil
+}
+
+func (p *flakyPrinter) snapshot() (int, [][]byte) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.attempts, p.printed
+}
+
+func TestQueueRetriesInOrder(t *testing.T) {
+	printer := &flakyPrinter{failures: 2}
+	queue := NewQueue(printer, 10, 3, time.Millisecond)
+	_ = queue.Enqueue([]byte("first"))
+	_ = queue.Enqueue([]byte("second"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		attempts, printed := printer.snapshot()
+		if len(printed) == 2 {
+			if string(printed[0]) != "first" || string(printed[1]) != "second" || attempts != 4 {
+				t.Errorf("Expected both jobs in order after 4 attempts, got %q after %d", printed, attempts)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected jobs to be printed, got %q after %d attempts", printed, attempts)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueGivesUpAndIsBounded(t *testing.T) {
+	printer := &flakyPrinter{failures: 5}
+	queue := NewQueue(printer, 1, 2, time.Millisecond)
+	if err := queue.print(context.Background(), []byte("job")); err == nil {
+		t.Errorf("Expected the job to fail after all attempts")
+	}
+	if attempts, _ := printer.snapshot(); attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+
+	_ = queue.Enqueue([]byte("one"))
+	if err := queue.Enqueue([]byte("two")); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected a full queue to refuse jobs, got %v", err)
+	}
+}
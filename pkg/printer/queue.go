@@ -0,0 +1,78 @@
+package printer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var ErrQueueFull = errors.New("print queue is full")
+
+// Queue prints jobs one at a time so tickets come out in order, retrying a failed job with
+// exponential backoff before moving on to the next one.
+type Queue struct {
+	printer     Printer
+	jobs        chan []byte
+	maxAttempts int
+	backoff     time.Duration
+}
+
+func NewQueue(printer Printer, size int, maxAttempts int, backoff time.Duration) *Queue {
+	return &Queue{
+		printer:     printer,
+		jobs:        make(chan []byte, size),
+		maxAttempts: max(maxAttempts, 1),
+		backoff:     backoff,
+	}
+}
+
+// Enqueue adds a job without blocking the caller.
+func (q *Queue) Enqueue(data []byte) error {
+	select {
+	case q.jobs <- data:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *Queue) Pending() int {
+	return len(q.jobs)
+}
+
+// Run prints queued jobs until ctx is cancelled.
+func (q *Queue) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-q.jobs:
+			if err := q.print(ctx, data); err != nil {
+				log.Printf("Giving up on print job for %s: %v", q.printer.Name(), err)
+			}
+		}
+	}
+}
+
+func (q *Queue) print(ctx context.Context, data []byte) error {
+	wait := q.backoff
+	var err error
+	for attempt := 1; attempt <= q.maxAttempts; attempt++ {
+		if err = q.printer.Print(ctx, data); err == nil {
+			return nil
+		}
+		if attempt == q.maxAttempts {
+			break
+		}
+
+		log.Printf("Print to %s failed (attempt %d of %d): %v", q.printer.Name(), attempt, q.maxAttempts, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+	return err
+}
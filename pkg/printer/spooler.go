@@ -0,0 +1,77 @@
+package printer
+
+import (
+	"context"
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/receipt"
+)
+
+const queueSize = 100
+
+// Spooler routes kitchen tickets and receipts to their printers. A printer that is not
+// configured is skipped, so every method is safe to call either way.
+type Spooler struct {
+	kitchen  *Queue
+	receipts *Queue
+	width    int
+}
+
+func NewSpooler(printersConfig config.PrintersConfig) (*Spooler, error) {
+	spooler := &Spooler{width: printersConfig.Width}
+
+	kitchen, err := NewPrinter(printersConfig.Kitchen, printersConfig.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if kitchen != nil {
+		spooler.kitchen = NewQueue(kitchen, queueSize, printersConfig.RetryAttempts, printersConfig.RetryBackoff)
+	}
+
+	receipts, err := NewPrinter(printersConfig.Receipt, printersConfig.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if receipts != nil {
+		spooler.receipts = NewQueue(receipts, queueSize, printersConfig.RetryAttempts, printersConfig.RetryBackoff)
+	}
+
+	return spooler, nil
+}
+
+// Run drains both queues until ctx is cancelled.
+func (s *Spooler) Run(ctx context.Context) {
+	for _, queue := range []*Queue{s.kitchen, s.receipts} {
+		if queue != nil {
+			go queue.Run(ctx)
+		}
+	}
+	<-ctx.Done()
+}
+
+// PrintsKitchenTickets reports whether a kitchen printer is configured.
+func (s *Spooler) PrintsKitchenTickets() bool {
+	return s != nil && s.kitchen != nil
+}
+
+func (s *Spooler) PrintKitchenTicket(ticket *KitchenTicket) error {
+	if !s.PrintsKitchenTickets() {
+		return nil
+	}
+	return s.kitchen.Enqueue(RenderKitchenTicket(ticket, s.width))
+}
+
+// PrintsReceipts reports whether a receipt printer is configured.
+func (s *Spooler) PrintsReceipts() bool {
+	return s != nil && s.receipts != nil
+}
+
+func (s *Spooler) PrintReceipt(rendered *receipt.Receipt) error {
+	if !s.PrintsReceipts() {
+		return nil
+	}
+	data, err := RenderReceipt(rendered, s.width)
+	if err != nil {
+		return err
+	}
+	return s.receipts.Enqueue(data)
+}
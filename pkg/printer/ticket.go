@@ -0,0 +1,62 @@
+package printer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gqvz/mvc/pkg/receipt"
+)
+
+type TicketItem struct {
+	Name         string
+	Quantity     int
+	Instructions string
+}
+
+// KitchenTicket is what the kitchen needs to start cooking: no prices, large quantities. Orders not
+// seated at a table are headed by their type instead, with the time they were promised for.
+type KitchenTicket struct {
+	OrderID     int64
+	TableNumber int
+	OrderType   string
+	PickupAt    time.Time
+	CreatedAt   time.Time
+	Items       []TicketItem
+}
+
+func RenderKitchenTicket(ticket *KitchenTicket, width int) []byte {
+	heading := fmt.Sprintf("TABLE %d", ticket.TableNumber)
+	if ticket.OrderType != "" {
+		heading = strings.ToUpper(ticket.OrderType)
+	}
+
+	e := NewEncoder()
+	e.Align(AlignCenter).DoubleSize(true).Bold(true).
+		Line(heading).
+		DoubleSize(false).Bold(false).
+		Line(fmt.Sprintf("Order #%d  %s", ticket.OrderID, ticket.CreatedAt.Format("15:04")))
+	if !ticket.PickupAt.IsZero() {
+		e.Bold(true).Line("PICKUP " + ticket.PickupAt.Format("15:04")).Bold(false)
+	}
+	e.Align(AlignLeft).
+		Line(strings.Repeat("=", width))
+
+	for _, item := range ticket.Items {
+		e.DoubleSize(true).Line(fmt.Sprintf("%dx %s", item.Quantity, item.Name)).DoubleSize(false)
+		if item.Instructions != "" {
+			e.Bold(true).Line("  ** " + item.Instructions).Bold(false)
+		}
+	}
+
+	return e.Line(strings.Repeat("=", width)).Feed(3).Cut().Bytes()
+}
+
+// RenderReceipt prints the fixed-width text receipt and cuts the paper after it.
+func RenderReceipt(rendered *receipt.Receipt, width int) ([]byte, error) {
+	var text strings.Builder
+	if err := receipt.RenderText(&text, rendered, width); err != nil {
+		return nil, err
+	}
+	return NewEncoder().Text(text.String()).Line("").Feed(3).Cut().Bytes(), nil
+}
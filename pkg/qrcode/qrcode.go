@@ -0,0 +1,483 @@
+// Package qrcode encodes short texts such as links as QR codes (ISO/IEC 18004) and renders them
+// as PNG images. Only byte mode and versions 1 to 10 are supported, which fits up to 271 bytes.
+package qrcode
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Level is the error correction level; higher levels survive more damage but need a larger code.
+type Level int
+
+const (
+	Low Level = iota
+	Medium
+	Quartile
+	High
+)
+
+const (
+	MaxVersion = 10
+	// QuietZone is the light border, in modules, readers need around the code.
+	QuietZone = 4
+)
+
+var ErrTooLong = errors.New("text is too long for a QR code")
+
+// blockLayout describes how the codewords of a version and level are split into Reed-Solomon blocks.
+type blockLayout struct {
+	eccPerBlock int
+	groups      [2][2]int // number of blocks and data codewords per block of each group
+}
+
+// layouts is indexed by version and level.
+var layouts = [MaxVersion + 1][4]blockLayout{
+	{},
+	{{7, [2][2]int{{1, 19}}}, {10, [2][2]int{{1, 16}}}, {13, [2][2]int{{1, 13}}}, {17, [2][2]int{{1, 9}}}},
+	{{10, [2][2]int{{1, 34}}}, {16, [2][2]int{{1, 28}}}, {22, [2][2]int{{1, 22}}}, {28, [2][2]int{{1, 16}}}},
+	{{15, [2][2]int{{1, 55}}}, {26, [2][2]int{{1, 44}}}, {18, [2][2]int{{2, 17}}}, {22, [2][2]int{{2, 13}}}},
+	{{20, [2][2]int{{1, 80}}}, {18, [2][2]int{{2, 32}}}, {26, [2][2]int{{2, 24}}}, {16, [2][2]int{{4, 9}}}},
+	{{26, [2][2]int{{1, 108}}}, {24, [2][2]int{{2, 43}}}, {18, [2][2]int{{2, 15}, {2, 16}}}, {22, [2][2]int{{2, 11}, {2, 12}}}},
+	{{18, [2][2]int{{2, 68}}}, {16, [2][2]int{{4, 27}}}, {24, [2][2]int{{4, 19}}}, {28, [2][2]int{{4, 15}}}},
+	{{20, [2][2]int{{2, 78}}}, {18, [2][2]int{{4, 31}}}, {18, [2][2]int{{2, 14}, {4, 15}}}, {26, [2][2]int{{4, 13}, {1, 14}}}},
+	{{24, [2][2]int{{2, 97}}}, {22, [2][2]int{{2, 38}, {2, 39}}}, {22, [2][2]int{{4, 18}, {2, 19}}}, {26, [2][2]int{{4, 14}, {2, 15}}}},
+	{{30, [2][2]int{{2, 116}}}, {22, [2][2]int{{3, 36}, {2, 37}}}, {20, [2][2]int{{4, 16}, {4, 17}}}, {24, [2][2]int{{4, 12}, {4, 13}}}},
+	{{18, [2][2]int{{2, 68}, {2, 69}}}, {26, [2][2]int{{4, 43}, {1, 44}}}, {24, [2][2]int{{6, 19}, {2, 20}}}, {28, [2][2]int{{6, 15}, {2, 16}}}},
+}
+
+// alignmentCenters lists the row and column coordinates of the alignment patterns per version.
+var alignmentCenters = [MaxVersion + 1][]int{
+	{}, {}, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34}, {6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+}
+
+// formatLevelBits are the two bits that identify each level in the format information.
+var formatLevelBits = [4]int{1, 0, 3, 2}
+
+func (l blockLayout) dataCodewords() int {
+	return l.groups[0][0]*l.groups[0][1] + l.groups[1][0]*l.groups[1][1]
+}
+
+// Code is an encoded QR code. Modules are indexed by row then column; true is dark.
+type Code struct {
+	Version  int
+	Level    Level
+	Size     int
+	Mask     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode builds the smallest QR code that holds text at the given error correction level.
+func Encode(text string, level Level) (*Code, error) {
+	data := []byte(text)
+
+	version := 0
+	for v := 1; v <= MaxVersion; v++ {
+		if 4+countBits(v)+8*len(data) <= 8*layouts[v][level].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+
+	code := newCode(version, level)
+	code.drawCodewords(code.addErrorCorrection(encodeData(data, version, level)))
+	code.applyBestMask()
+	return code, nil
+}
+
+// Dark reports whether the module at the given column and row is dark.
+func (c *Code) Dark(x int, y int) bool {
+	return x >= 0 && y >= 0 && x < c.Size && y < c.Size && c.modules[y][x]
+}
+
+// Image renders the code with scale pixels per module and the quiet zone around it.
+func (c *Code) Image(scale int) image.Image {
+	if scale < 1 {
+		scale = 1
+	}
+	side := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+QuietZone)*scale+dx, (y+QuietZone)*scale+dy, 1)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// WritePNG writes the code as a black and white PNG with scale pixels per module.
+func (c *Code) WritePNG(w io.Writer, scale int) error {
+	return png.Encode(w, c.Image(scale))
+}
+
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// encodeData writes the byte mode segment, the terminator and the padding codewords.
+func encodeData(data []byte, version int, level Level) []byte {
+	capacity := layouts[version][level].dataCodewords()
+	bits := &bitBuffer{}
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits(version))
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	bits.append(0, min(4, 8*capacity-bits.len))
+	bits.append(0, (8-bits.len%8)%8)
+
+	codewords := bits.bytes()
+	for pad := 0; len(codewords) < capacity; pad++ {
+		codewords = append(codewords, []byte{0xec, 0x11}[pad%2])
+	}
+	return codewords
+}
+
+// addErrorCorrection splits the data into blocks, appends each block's Reed-Solomon codewords and
+// interleaves the blocks.
+func (c *Code) addErrorCorrection(data []byte) []byte {
+	layout := layouts[c.Version][c.Level]
+	divisor := reedSolomonDivisor(layout.eccPerBlock)
+
+	var blocks, eccs [][]byte
+	offset := 0
+	for _, group := range layout.groups {
+		for i := 0; i < group[0]; i++ {
+			block := data[offset : offset+group[1]]
+			blocks = append(blocks, block)
+			eccs = append(eccs, reedSolomonRemainder(block, divisor))
+			offset += group[1]
+		}
+	}
+
+	var result []byte
+	for i := 0; i < layout.groups[1][1] || i < layout.groups[0][1]; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < layout.eccPerBlock; i++ {
+		for _, ecc := range eccs {
+			result = append(result, ecc[i])
+		}
+	}
+	return result
+}
+
+func newCode(version int, level Level) *Code {
+	size := 17 + 4*version
+	c := &Code{Version: version, Level: level, Size: size}
+	c.modules = make([][]bool, size)
+	c.function = make([][]bool, size)
+	for y := range c.modules {
+		c.modules[y] = make([]bool, size)
+		c.function[y] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinder(3, 3)
+	c.drawFinder(size-4, 3)
+	c.drawFinder(3, size-4)
+
+	centers := alignmentCenters[version]
+	last := len(centers) - 1
+	for i, y := range centers {
+		for j, x := range centers {
+			// the corners with finder patterns have no alignment pattern
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			c.drawAlignment(x, y)
+		}
+	}
+
+	// reserve the format areas; the real bits are drawn once the mask is chosen
+	c.drawFormat(0)
+	c.drawVersion()
+	return c
+}
+
+func (c *Code) setFunction(x int, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// drawFinder draws a finder pattern with its separator around the center module.
+func (c *Code) drawFinder(cx int, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+				continue
+			}
+			distance := max(abs(dx), abs(dy))
+			c.setFunction(x, y, distance != 2 && distance != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(cx int, cy int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(cx+dx, cy+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// formatBits returns the 15 bit format information for a level and mask.
+func formatBits(level Level, mask int) int {
+	data := formatLevelBits[level]<<3 | mask
+	remainder := data
+	for i := 0; i < 10; i++ {
+		remainder = remainder<<1 ^ (remainder>>9)*0x537
+	}
+	return (data<<10 | remainder) ^ 0x5412
+}
+
+// versionBits returns the 18 bit version information that versions 7 and up carry.
+func versionBits(version int) int {
+	remainder := version
+	for i := 0; i < 12; i++ {
+		remainder = remainder<<1 ^ (remainder>>11)*0x1f25
+	}
+	return version<<12 | remainder
+}
+
+func (c *Code) drawFormat(mask int) {
+	bits := formatBits(c.Level, mask)
+
+	// around the top left finder
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	// split between the other two finders
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(bits, i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(bits, i))
+	}
+	c.setFunction(8, c.Size-8, true)
+}
+
+func (c *Code) drawVersion() {
+	if c.Version < 7 {
+		return
+	}
+	bits := versionBits(c.Version)
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords places the codewords in the two module wide columns that zigzag up and down from
+// the bottom right corner, skipping function patterns.
+func (c *Code) drawCodewords(codewords []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vertical := 0; vertical < c.Size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vertical
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vertical
+				}
+				if c.function[y][x] {
+					continue
+				}
+				if i < len(codewords)*8 {
+					c.modules[y][x] = bit(int(codewords[i/8]), 7-i%8)
+					i++
+				}
+			}
+		}
+	}
+}
+
+func masked(mask int, x int, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask flips the data modules the mask selects; applying it twice undoes it.
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.function[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask keeps the mask with the lowest penalty score, as readers find those easiest.
+func (c *Code) applyBestMask() {
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if penalty := c.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask)
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+	c.Mask = best
+}
+
+// penalty scores long runs, 2x2 blocks, finder-like patterns and an uneven balance of dark and
+// light modules.
+func (c *Code) penalty() int {
+	penalty := 0
+	for i := 0; i < c.Size; i++ {
+		row := make([]bool, c.Size)
+		column := make([]bool, c.Size)
+		for j := 0; j < c.Size; j++ {
+			row[j] = c.modules[i][j]
+			column[j] = c.modules[j][i]
+		}
+		penalty += linePenalty(row) + linePenalty(column)
+	}
+
+	dark := 0
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x < c.Size-1 && y < c.Size-1 {
+				v := c.modules[y][x]
+				if v == c.modules[y][x+1] && v == c.modules[y+1][x] && v == c.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+
+	total := c.Size * c.Size
+	deviation := abs(dark*20 - total*10)
+	penalty += ((deviation+total-1)/total - 1) * 10
+	return penalty
+}
+
+var finderLike = []bool{true, false, true, true, true, false, true}
+
+func linePenalty(line []bool) int {
+	penalty := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += run - 2
+		}
+		run = 1
+	}
+
+	for i := 0; i+7 <= len(line); i++ {
+		matches := true
+		for j, dark := range finderLike {
+			matches = matches && line[i+j] == dark
+		}
+		if matches && (lightRun(line, i-4, i) || lightRun(line, i+7, i+11)) {
+			penalty += 40
+		}
+	}
+	return penalty
+}
+
+// lightRun reports whether the modules from start up to end are light; the quiet zone counts as light.
+func lightRun(line []bool, start int, end int) bool {
+	for i := start; i < end; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func bit(value int, i int) bool {
+	return value>>i&1 != 0
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+type bitBuffer struct {
+	data []byte
+	len  int
+}
+
+func (b *bitBuffer) append(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if b.len%8 == 0 {
+			b.data = append(b.data, 0)
+		}
+		if bit(value, i) {
+			b.data[b.len/8] |= 0x80 >> (b.len % 8)
+		}
+		b.len++
+	}
+}
+
+func (b *bitBuffer) bytes() []byte {
+	return b.data
+}
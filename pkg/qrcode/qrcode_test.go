@@ -0,0 +1,254 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestFormatBits(t *testing.T) {
+	// values from the format information table of ISO/IEC 18004
+	tests := []struct {
+		level    Level
+		mask     int
+		expected int
+	}{
+		{Low, 0, 0b111011111000100},
+		{Low, 7, 0b110100101110110},
+		{Medium, 0, 0b101010000010010},
+		{Quartile, 0, 0b011010101011111},
+		{High, 0, 0b001011010001001},
+	}
+	for _, test := range tests {
+		if bits := formatBits(test.level, test.mask); bits != test.expected {
+			t.Errorf("formatBits(%d, %d) = %015b, want %015b", test.level, test.mask, bits, test.expected)
+		}
+	}
+
+	if bits := versionBits(7); bits != 0b000111110010010100 {
+		t.Errorf("versionBits(7) = %018b, want 000111110010010100", bits)
+	}
+}
+
+func TestReedSolomon(t *testing.T) {
+	data := []byte("https://example.com/order?table=42")
+	divisor := reedSolomonDivisor(18)
+	codeword := append(append([]byte{}, data...), reedSolomonRemainder(data, divisor)...)
+
+	// a valid codeword evaluates to zero at every root of the generator polynomial
+	root := byte(1)
+	for i := 0; i < len(divisor); i++ {
+		syndrome := byte(0)
+		for _, b := range codeword {
+			syndrome = gfMultiply(syndrome, root) ^ b
+		}
+		if syndrome != 0 {
+			t.Fatalf("Syndrome %d is %d, want 0", i, syndrome)
+		}
+		root = gfMultiply(root, 0x02)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	texts := []string{
+		"",
+		"12",
+		"https://example.com/order?table=0123456789abcdef0123456789abcdef",
+		strings.Repeat("QR", 60),
+		strings.Repeat("x", 213),
+	}
+	for _, text := range texts {
+		for level := Low; level <= High; level++ {
+			code, err := Encode(text, level)
+			if err != nil {
+				if err == ErrTooLong && 4+countBits(MaxVersion)+8*len(text) > 8*layouts[MaxVersion][level].dataCodewords() {
+					continue
+				}
+				t.Fatalf("Encode(%d bytes, %d) returned error %v", len(text), level, err)
+			}
+			if code.Size != 17+4*code.Version {
+				t.Fatalf("Version %d code has size %d", code.Version, code.Size)
+			}
+			if decoded := decode(t, code); decoded != text {
+				t.Errorf("Version %d level %d decoded %q, want %q", code.Version, level, decoded, text)
+			}
+		}
+	}
+
+	if _, err := Encode(strings.Repeat("x", 272), Low); err != ErrTooLong {
+		t.Errorf("Expected a 272 byte text to be too long, got %v", err)
+	}
+}
+
+func TestEncodeStructure(t *testing.T) {
+	code, err := Encode("https://example.com/order?table=abc", Medium)
+	if err != nil {
+		t.Fatalf("Encode returned error %v", err)
+	}
+
+	// finder patterns: dark ring, light ring, dark 3x3 core
+	for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+		for dy := 0; dy < 7; dy++ {
+			for dx := 0; dx < 7; dx++ {
+				ring := max(abs(dx-3), abs(dy-3))
+				if code.Dark(corner[0]+dx, corner[1]+dy) != (ring != 2) {
+					t.Fatalf("Finder pattern at %v is wrong at %d,%d", corner, dx, dy)
+				}
+			}
+		}
+	}
+
+	for i := 8; i < code.Size-8; i++ {
+		if code.Dark(i, 6) != (i%2 == 0) || code.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("Timing pattern is wrong at %d", i)
+		}
+	}
+	if !code.Dark(8, code.Size-8) {
+		t.Error("Expected the dark module next to the bottom left finder")
+	}
+
+	var buf bytes.Buffer
+	if err := code.WritePNG(&buf, 4); err != nil {
+		t.Fatalf("WritePNG returned error %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Expected a valid PNG, got %v", err)
+	}
+	if side := (code.Size + 2*QuietZone) * 4; img.Bounds().Dx() != side || img.Bounds().Dy() != side {
+		t.Errorf("Expected a %dx%d image, got %v", side, side, img.Bounds())
+	}
+}
+
+// decode reads a code back the way a reader does once it has found the modules: it reads the
+// format information, removes the mask, collects the codewords, de-interleaves the data blocks
+// and parses the byte mode segment.
+func decode(t *testing.T, code *Code) string {
+	t.Helper()
+
+	format := 0
+	for i := 0; i < 15; i++ {
+		var dark bool
+		switch {
+		case i <= 5:
+			dark = code.Dark(8, i)
+		case i == 6:
+			dark = code.Dark(8, 7)
+		case i == 7:
+			dark = code.Dark(8, 8)
+		case i == 8:
+			dark = code.Dark(7, 8)
+		default:
+			dark = code.Dark(14-i, 8)
+		}
+		if dark {
+			format |= 1 << i
+		}
+	}
+	level, mask := Level(-1), -1
+	for l := Low; l <= High; l++ {
+		for m := 0; m < 8; m++ {
+			if formatBits(l, m) == format {
+				level, mask = l, m
+			}
+		}
+	}
+	if level != code.Level || mask != code.Mask {
+		t.Fatalf("Format information %015b does not match level %d mask %d", format, code.Level, code.Mask)
+	}
+
+	// the function patterns only depend on the version, so a fresh code marks which modules hold data
+	layoutCode := newCode(code.Version, code.Level)
+	var bits []bool
+	for right := code.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vertical := 0; vertical < code.Size; vertical++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vertical
+				if (right+1)&2 == 0 {
+					y = code.Size - 1 - vertical
+				}
+				if !layoutCode.function[y][x] {
+					bits = append(bits, code.Dark(x, y) != masked(mask, x, y))
+				}
+			}
+		}
+	}
+
+	layout := layouts[code.Version][code.Level]
+	var blockLengths []int
+	for _, group := range layout.groups {
+		for i := 0; i < group[0]; i++ {
+			blockLengths = append(blockLengths, group[1])
+		}
+	}
+	blocks := make([][]byte, len(blockLengths))
+	position := 0
+	readByte := func() byte {
+		b := byte(0)
+		for i := 0; i < 8; i++ {
+			b <<= 1
+			if bits[position] {
+				b |= 1
+			}
+			position++
+		}
+		return b
+	}
+	for i := 0; i < blockLengths[len(blockLengths)-1]; i++ {
+		for j, length := range blockLengths {
+			if i < length {
+				blocks[j] = append(blocks[j], readByte())
+			}
+		}
+	}
+	divisor := reedSolomonDivisor(layout.eccPerBlock)
+	for j := range blocks {
+		expected := reedSolomonRemainder(blocks[j], divisor)
+		for i := range expected {
+			// ecc codewords are interleaved after all data codewords
+			if got := bitsByte(bits, 8*(layout.dataCodewords()+i*len(blocks)+j)); got != expected[i] {
+				t.Fatalf("Block %d error correction codeword %d is %d, want %d", j, i, got, expected[i])
+			}
+		}
+	}
+
+	data := &bitReader{data: bytes.Join(blocks, nil)}
+	if data.read(4) != 0b0100 {
+		t.Fatal("Expected a byte mode segment")
+	}
+	length := data.read(countBits(code.Version))
+	var text []byte
+	for i := 0; i < length; i++ {
+		text = append(text, byte(data.read(8)))
+	}
+	return string(text)
+}
+
+func bitsByte(bits []bool, start int) byte {
+	b := byte(0)
+	for i := 0; i < 8; i++ {
+		b <<= 1
+		if bits[start+i] {
+			b |= 1
+		}
+	}
+	return b
+}
+
+type bitReader struct {
+	data     []byte
+	position int
+}
+
+func (r *bitReader) read(n int) int {
+	value := 0
+	for i := 0; i < n; i++ {
+		value = value<<1 | int(r.data[r.position/8]>>(7-r.position%8)&1)
+		r.position++
+	}
+	return value
+}
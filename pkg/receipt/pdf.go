@@ -0,0 +1,73 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const (
+	pdfFontSize   = 8.0
+	pdfLineHeight = 10.0
+	pdfMargin     = 12.0
+	// Courier glyphs are 600/1000 of the font size wide.
+	pdfCharWidth = pdfFontSize * 0.6
+)
+
+// writePDF lays out monospaced lines on one page sized to fit them, like a receipt roll. Only the
+// standard Courier font is used, so characters outside Latin-1 are replaced.
+func writePDF(w io.Writer, lines []string, width int) error {
+	pageWidth := pdfMargin*2 + float64(width)*pdfCharWidth
+	pageHeight := pdfMargin*2 + float64(len(lines))*pdfLineHeight
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n/F1 %.0f Tf\n%.0f TL\n%.2f %.2f Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pageHeight-pdfMargin-pdfFontSize)
+	for _, line := range lines {
+		content.WriteString("(")
+		content.Write(pdfString(line))
+		content.WriteString(") Tj T*\n")
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// pdfString escapes a line for a PDF literal string in Latin-1.
+func pdfString(line string) []byte {
+	var out []byte
+	for _, r := range line {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out = append(out, '\\', byte(r))
+		case r < 0x20 || r > 0xff:
+			out = append(out, '?')
+		default:
+			out = append(out, byte(r))
+		}
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+// Package receipt renders payment receipts as HTML, fixed-width text for thermal printers and PDF.
+package receipt
+
+import (
+	"embed"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gqvz/mvc/pkg/money"
+)
+
+const (
+	DefaultWidth = 42
+	MinWidth     = 24
+	MaxWidth     = 64
+)
+
+//go:embed templates
+var templates embed.FS
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/receipt.html"))
+
+type Restaurant struct {
+	Name    string
+	Address string
+	Phone   string
+	TaxID   string
+	Footer  string
+}
+
+type Line struct {
+	Name      string
+	Quantity  int
+	UnitPrice money.Money
+	Amount    money.Money
+	// Share is set when the payment covers only part of the line, as in a split bill.
+	Share     bool
+	Modifiers []string
+}
+
+type Tax struct {
+	Name      string
+	Rate      money.Percent
+	Inclusive bool
+	Amount    money.Money
+}
+
+type Receipt struct {
+	Number      int64
+	IssuedAt    time.Time
+	Restaurant  Restaurant
+	OrderID     int64
+	TableNumber int
+	// OrderType is shown instead of the table for orders not seated at one, such as "Takeaway".
+	OrderType      string
+	PaymentID      int64
+	Lines          []Line
+	Subtotal       money.Money
+	Discount       money.Money
+	DiscountReason string
+	ServiceCharge  money.Money
+	DeliveryFee    money.Money
+	Taxes          []Tax
+	Tip            money.Money
+	Total          money.Money
+	Refunded       money.Money
+	Currency       string
+	Reference      string
+}
+
+func RenderHTML(w io.Writer, receipt *Receipt) error {
+	return htmlTemplate.Execute(w, receipt)
+}
+
+// RenderText writes the receipt in a fixed number of columns, wrapping long item names so
+// amounts stay right aligned.
+func RenderText(w io.Writer, receipt *Receipt, width int) error {
+	width = min(max(width, MinWidth), MaxWidth)
+	tmpl, err := texttemplate.New("receipt.txt").Funcs(textFuncs(width)).ParseFS(templates, "templates/receipt.txt")
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, receipt)
+}
+
+// RenderPDF writes the text rendering onto a single receipt-roll sized PDF page.
+func RenderPDF(w io.Writer, receipt *Receipt) error {
+	var text strings.Builder
+	if err := RenderText(&text, receipt, DefaultWidth); err != nil {
+		return err
+	}
+	return writePDF(w, strings.Split(strings.TrimRight(text.String(), "\n"), "\n"), DefaultWidth)
+}
+
+func textFuncs(width int) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"center": func(text string) string {
+			length := utf8.RuneCountInString(text)
+			if length >= width {
+				return text
+			}
+			return strings.Repeat(" ", (width-length)/2) + text
+		},
+		"rule": func() string {
+			return strings.Repeat("-", width)
+		},
+		"row": func(left string, right string) string {
+			return row(left, right, width)
+		},
+	}
+}
+
+// row puts right at the end of the line and wraps left onto as many lines as it needs.
+func row(left string, right string, width int) string {
+	indent := left[:len(left)-len(strings.TrimLeft(left, " "))]
+	room := width - utf8.RuneCountInString(right) - 1 - len(indent)
+	if room < width/3 && strings.TrimSpace(left) != "" {
+		// a long value such as a provider reference gets a line of its own
+		return row(left, "", width) + "\n" + row("", right, width)
+	}
+	var lines []string
+	words := strings.Fields(left)
+	current := ""
+	for _, word := range words {
+		for utf8.RuneCountInString(word) > room {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:room]))
+			word = string(runes[room:])
+		}
+		switch {
+		case current == "":
+			current = word
+		case utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) <= room:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+
+	lines = append(lines, current)
+	for i := range lines {
+		lines[i] = indent + lines[i]
+	}
+
+	last := lines[len(lines)-1]
+	padding := max(width-utf8.RuneCountInString(last)-utf8.RuneCountInString(right), 0)
+	lines[len(lines)-1] = last + strings.Repeat(" ", padding) + right
+	if right == "" {
+		lines[len(lines)-1] = last
+	}
+	return strings.Join(lines, "\n")
+}
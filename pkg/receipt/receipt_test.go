@@ -0,0 +1,151 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleReceipt() *Receipt {
+	return &Receipt{
+		Number:      17,
+		IssuedAt:    time.Date(2025, 8, 29, 19, 30, 0, 0, time.UTC),
+		Restaurant:  Restaurant{Name: "Cafe (Main)", Address: "1 High Street", Footer: "Thank you!"},
+		OrderID:     4,
+		TableNumber: 12,
+		PaymentID:   9,
+		Lines: []Line{
+			{Name: "Margherita", Quantity: 2, UnitPrice: 1250, Amount: 2500, Modifiers: []string{"no basil"}},
+			{Name: "A very long dish name that will not fit on one line", Quantity: 1, UnitPrice: 900, Amount: 450, Share: true},
+		},
+		Subtotal: 2950,
+		Taxes:    []Tax{{Name: "VAT", Rate: 20_000, Inclusive: true, Amount: 492}},
+		Tip:      300,
+		Total:    3250,
+		Currency: "EUR",
+	}
+}
+
+func TestRenderTextAlignsAmounts(t *testing.T) {
+	var out strings.Builder
+	if err := RenderText(&out, sampleReceipt(), 32); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	text := out.String()
+
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		if len([]rune(line)) > 32 {
+			t.Errorf("Expected lines of at most 32 columns, got %q", line)
+		}
+	}
+
+	for _, want := range []string{
+		"2 x Margherita             25.00",
+		"  @ 12.50",
+		"  + no basil",
+		"  share of                  9.00",
+		"VAT 20% incl.               4.92",
+		"TOTAL EUR                  32.50",
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Errorf("Expected receipt to contain line %q, got\n%s", want, text)
+		}
+	}
+
+	if strings.Contains(text, "Discount") || strings.Contains(text, "Service charge") {
+		t.Errorf("Expected zero amounts to be left out, got\n%s", text)
+	}
+}
+
+func TestRenderTextDelivery(t *testing.T) {
+	receipt := sampleReceipt()
+	receipt.OrderType = "Delivery"
+	receipt.DeliveryFee = 350
+	receipt.Total += 350
+
+	var out strings.Builder
+	if err := RenderText(&out, receipt, 32); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	text := out.String()
+
+	for _, want := range []string{
+		"Order #4                Delivery",
+		"Delivery fee                3.50",
+		"TOTAL EUR                  36.00",
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Errorf("Expected receipt to contain line %q, got\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Table") {
+		t.Errorf("Expected no table for a delivery order, got\n%s", text)
+	}
+}
+
+func TestRow(t *testing.T) {
+	if got := row("Tip", "3.00", 12); got != "Tip     3.00" {
+		t.Errorf("Expected right aligned amount, got %q", got)
+	}
+	if got := row("  one two three", "1.00", 14); got != "  one two\n  three   1.00" {
+		t.Errorf("Expected wrapped and indented name, got %q", got)
+	}
+	if got := row("Ref", "mock_1234567890", 16); got != "Ref\n mock_1234567890" {
+		t.Errorf("Expected a long value on its own line, got %q", got)
+	}
+}
+
+func TestRenderHTMLEscapes(t *testing.T) {
+	receipt := sampleReceipt()
+	receipt.Lines[0].Modifiers = []string{"<script>"}
+
+	var out strings.Builder
+	if err := RenderHTML(&out, receipt); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if strings.Contains(out.String(), "<script>") || !strings.Contains(out.String(), "&lt;script&gt;") {
+		t.Errorf("Expected modifiers to be escaped")
+	}
+	if !strings.Contains(out.String(), "Receipt #17") {
+		t.Errorf("Expected receipt number in HTML")
+	}
+}
+
+func TestRenderPDFStructure(t *testing.T) {
+	var out bytes.Buffer
+	if err := RenderPDF(&out, sampleReceipt()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	pdf := out.Bytes()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("Expected PDF header and trailer")
+	}
+	if !bytes.Contains(pdf, []byte(`(               Cafe \(Main\)) Tj`)) {
+		t.Errorf("Expected parentheses to be escaped in the content stream")
+	}
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if startxref == nil {
+		t.Fatalf("Expected startxref")
+	}
+	xref, _ := strconv.Atoi(string(startxref[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("Expected startxref to point at the xref table")
+	}
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf, -1)
+	if len(entries) != 5 {
+		t.Fatalf("Expected 5 objects, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if !bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))) {
+			t.Errorf("Expected xref entry %d to point at its object", i+1)
+		}
+	}
+}
@@ -1,15 +1,36 @@
 package services
 
-var itemsCache string
+import (
+	"sync"
+	"time"
+)
+
+var (
+	itemsCache          string
+	itemsCacheExpiresAt time.Time
+	itemsCacheMutex     sync.RWMutex
+)
 
 func GetItemsCache() string {
+	itemsCacheMutex.RLock()
+	defer itemsCacheMutex.RUnlock()
+	if time.Now().After(itemsCacheExpiresAt) {
+		return ""
+	}
 	return itemsCache
 }
 
+// SetItemsCache caches the items response until the next minute, since effective
+// prices change when discount windows open or close.
 func SetItemsCache(jsonString string) {
+	itemsCacheMutex.Lock()
+	defer itemsCacheMutex.Unlock()
 	itemsCache = jsonString
+	itemsCacheExpiresAt = time.Now().Truncate(time.Minute).Add(time.Minute)
 }
 
 func ClearItemsCache() {
+	itemsCacheMutex.Lock()
+	defer itemsCacheMutex.Unlock()
 	itemsCache = ""
 }
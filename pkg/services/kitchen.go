@@ -0,0 +1,82 @@
+package services
+
+import (
+	"log"
+	"time"
+
+	"github.com/gqvz/mvc/pkg/models"
+	"github.com/gqvz/mvc/pkg/printer"
+)
+
+// PrintKitchenTicket sends order items to the kitchen printer on one ticket. Printing problems are
+// logged rather than failing the order.
+func PrintKitchenTicket(spooler *printer.Spooler, orderId int64, items []printer.TicketItem) {
+	if !spooler.PrintsKitchenTickets() {
+		return
+	}
+
+	order, err := models.GetOrderById(orderId, 0)
+	if err != nil {
+		log.Printf("Error loading order %d for kitchen ticket: %v", orderId, err)
+		return
+	}
+
+	ticket := &printer.KitchenTicket{
+		OrderID:     order.ID,
+		TableNumber: order.TableNumber,
+		OrderType:   order.TypeLabel(),
+		CreatedAt:   time.Now(),
+		Items:       items,
+	}
+	if order.PickupAt != nil {
+		ticket.PickupAt = *order.PickupAt
+	}
+	if err := spooler.PrintKitchenTicket(ticket); err != nil {
+		log.Printf("Error printing kitchen ticket for order %d: %v", orderId, err)
+	}
+}
+
+// ReleaseScheduledItems returns a job that moves the held items of orders scheduled ahead into the
+// kitchen queue when they are due, printing one kitchen ticket per order.
+func ReleaseScheduledItems(spooler *printer.Spooler) func(now time.Time) {
+	return func(now time.Time) {
+		released, err := models.ReleaseScheduledOrderItems(now)
+		if err != nil {
+			log.Printf("Error releasing scheduled order items: %v", err)
+			return
+		}
+		if len(released) == 0 || !spooler.PrintsKitchenTickets() {
+			return
+		}
+
+		ids := make([]int64, len(released))
+		for i, orderItem := range released {
+			ids[i] = orderItem.ItemID
+		}
+		items, err := models.GetItemByIdBulk(ids)
+		if err != nil {
+			log.Printf("Error loading items for kitchen tickets: %v", err)
+			return
+		}
+		names := make(map[int64]string, len(*items))
+		for _, item := range *items {
+			names[item.ID] = item.Name
+		}
+
+		var orderIds []int64
+		tickets := make(map[int64][]printer.TicketItem)
+		for _, orderItem := range released {
+			if _, ok := tickets[orderItem.OrderID]; !ok {
+				orderIds = append(orderIds, orderItem.OrderID)
+			}
+			tickets[orderItem.OrderID] = append(tickets[orderItem.OrderID], printer.TicketItem{
+				Name:         names[orderItem.ItemID],
+				Quantity:     orderItem.Quantity,
+				Instructions: orderItem.CustomInstructions,
+			})
+		}
+		for _, orderId := range orderIds {
+			PrintKitchenTicket(spooler, orderId, tickets[orderId])
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/gqvz/mvc/pkg/config"
+	"github.com/gqvz/mvc/pkg/models"
+)
+
+func RunEvery(ctx context.Context, interval time.Duration, job func(now time.Time)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	job(time.Now())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			job(now)
+		}
+	}
+}
+
+func ApplyPriceSchedules(now time.Time) {
+	updated, err := models.ApplyDuePriceSchedules(now)
+	if err != nil {
+		log.Printf("Error applying price schedules: %v", err)
+		return
+	}
+	if updated > 0 {
+		ClearItemsCache()
+	}
+}
+
+// PurgeIdempotencyKeys drops stored responses older than the idempotency window.
+func PurgeIdempotencyKeys(now time.Time) {
+	if _, err := models.DeleteIdempotencyKeysBefore(now.Add(-config.Config.Idempotency.Window)); err != nil {
+		log.Printf("Error purging idempotency keys: %v", err)
+	}
+}